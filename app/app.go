@@ -1,22 +1,41 @@
 package app
 
 import (
-	"fmt"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/audit_utils"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/blob_utils"
+	"testing-project/utils/locale_utils"
+	"testing-project/utils/logger_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/moderation_utils"
 	"testing-project/utils/rabbitmq_utils"
+	"testing-project/utils/ratelimit_utils"
+	"testing-project/utils/tracing_utils"
+	"time"
 )
 
 var (
-	router = gin.Default()
+	router = gin.New()
 )
 
 func init() {
 	if err := godotenv.Load(); err != nil {
-		log.Print("sad .env file found")
+		slog.Warn("no .env file found")
 	}
 }
 
@@ -29,12 +48,346 @@ func StartApp() {
 	port := os.Getenv("PORT")
 	brokerAddr := os.Getenv("RABBITMQ_URL")
 
-	domain.MessageRepo.Initialize(dbdriver, username, password, port, host, database)
-	fmt.Println("DATABASE STARTED")
+	if name := os.Getenv("LOG_LEVEL"); name != "" {
+		lvl, err := logger_utils.ParseLevel(name)
+		if err != nil {
+			logger_utils.Fatal("invalid LOG_LEVEL", "error", err)
+		}
+		logger_utils.SetLevel(lvl)
+	}
+
+	shutdownTracing, err := tracing_utils.Init(tracing_utils.Config{
+		Exporter: os.Getenv("OTEL_TRACES_EXPORTER"),
+		FilePath: os.Getenv("OTEL_TRACES_FILE"),
+		Endpoint: os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"),
+	})
+	if err != nil {
+		logger_utils.Fatal("failed to initialize tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	db := domain.MessageRepo.Initialize(dbdriver, username, password, port, host, database)
+	slog.Info("database started")
+	if err := metrics_utils.RegisterDBStats(db, database); err != nil {
+		logger_utils.Fatal("failed to register database metrics", "error", err)
+	}
+	domain.WebhookRepo = domain.NewWebhookRepository(db)
+	domain.ApiKeyRepo = domain.NewApiKeyRepository(db)
+	domain.AttachmentRepo = domain.NewAttachmentRepository(db)
+	domain.TranslationRepo = domain.NewTranslationRepository(db)
+	domain.ReactionRepo = domain.NewReactionRepository(db)
+	domain.PinRepo = domain.NewPinRepository(db)
+	domain.ModerationRepo = domain.NewModerationRepository(db)
+	domain.AuditRepo = domain.NewAuditRepository(db)
 
 	rabbitmq_utils.InitRabbitMQ(brokerAddr)
 
-	routes()
+	services.WebhookDispatcher = services.NewWebhookDispatcher(services.WebhookDispatcherConfig{
+		MaxAttempts:  envInt("WEBHOOK_MAX_ATTEMPTS"),
+		DisableAfter: envInt("WEBHOOK_DISABLE_AFTER"),
+		Workers:      envInt("WEBHOOK_WORKERS"),
+		QueueSize:    envInt("WEBHOOK_QUEUE_SIZE"),
+	})
+
+	services.ErasureReportKey = []byte(os.Getenv("ERASURE_REPORT_KEY"))
+	if len(services.ErasureReportKey) == 0 {
+		services.ErasureReportKey = make([]byte, 32)
+		if _, err := rand.Read(services.ErasureReportKey); err != nil {
+			logger_utils.Fatal("failed to generate an erasure report key", "error", err)
+		}
+		slog.Warn("ERASURE_REPORT_KEY is not set; erasure reports can only be verified until restart")
+	}
+	services.DailyMessageQuota = envInt("MESSAGES_DAILY_QUOTA")
+	duplicatePolicy, err := services.ParseDuplicatePolicy(os.Getenv("DUPLICATE_POLICY"))
+	if err != nil {
+		logger_utils.Fatal("invalid DUPLICATE_POLICY", "error", err)
+	}
+	services.DuplicatePolicy = duplicatePolicy
+	if path := os.Getenv("MODERATION_WORDLIST_FILE"); path != "" {
+		filter, err := moderation_utils.LoadWordFilter(path)
+		if err != nil {
+			logger_utils.Fatal("failed to load moderation word list", "error", err)
+		}
+		services.ModerationChecks = append(services.ModerationChecks, filter)
+	}
+	if domains := os.Getenv("MODERATION_BLOCKED_DOMAINS"); domains != "" {
+		services.ModerationChecks = append(services.ModerationChecks, moderation_utils.NewLinkBlocklist(strings.Split(domains, ",")))
+	}
+	if raw := os.Getenv("MODERATION_SPAM_THRESHOLD"); raw != "" {
+		threshold, err := strconv.ParseFloat(raw, 64)
+		if err != nil || threshold <= 0 {
+			logger_utils.Fatal("invalid MODERATION_SPAM_THRESHOLD", "value", raw)
+		}
+		services.ModerationChecks = append(services.ModerationChecks, moderation_utils.NewSpamScorer(threshold))
+	}
+	if maxBytes := envInt("METADATA_MAX_BYTES"); maxBytes > 0 {
+		services.MaxMetadataSize = maxBytes
+	}
+	if dir := os.Getenv("METADATA_SCHEMA_DIR"); dir != "" {
+		schemas, err := services.LoadMetadataSchemas(dir)
+		if err != nil {
+			logger_utils.Fatal("failed to load metadata schemas", "error", err)
+		}
+		services.MetadataSchemas = schemas
+	}
+	if keys := os.Getenv("METADATA_FILTER_KEYS"); keys != "" {
+		services.FilterableMetadataKeys = make(map[string]bool)
+		for _, key := range strings.Split(keys, ",") {
+			if key = strings.TrimSpace(key); !domain.ValidMetadataKey(key) {
+				logger_utils.Fatal("invalid METADATA_FILTER_KEYS", "key", key)
+			}
+			services.FilterableMetadataKeys[key] = true
+		}
+	}
+	if name := os.Getenv("DEFAULT_LOCALE"); name != "" {
+		locale, ok := locale_utils.Canonical(name)
+		if !ok {
+			logger_utils.Fatal("invalid DEFAULT_LOCALE", "locale", name)
+		}
+		services.DefaultLocale = locale
+	}
+
+	if replaySize := envInt("SSE_REPLAY_BUFFER"); replaySize > 0 {
+		services.MessageEvents = services.NewMessageEventsHub(replaySize)
+	}
+
+	schedulerInterval := 15 * time.Second
+	if seconds := envInt("PUBLISH_SCHEDULER_INTERVAL_SECONDS"); seconds > 0 {
+		schedulerInterval = time.Duration(seconds) * time.Second
+	}
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go services.RunPublishScheduler(jobsCtx, schedulerInterval)
+
+	retention, err := services.ParseRetentionPolicy(os.Getenv("MESSAGE_RETENTION"))
+	if err != nil {
+		logger_utils.Fatal("invalid MESSAGE_RETENTION", "error", err)
+	}
+	services.MessageRetention = retention
+	reaperConfig := services.MessageReaperConfig{Interval: time.Minute}
+	switch mode := os.Getenv("MESSAGE_REAPER_MODE"); mode {
+	case "", "delete":
+	case "archive":
+		reaperConfig.Archive = true
+	default:
+		logger_utils.Fatal("invalid MESSAGE_REAPER_MODE", "mode", mode)
+	}
+	if seconds := envInt("MESSAGE_REAPER_INTERVAL_SECONDS"); seconds > 0 {
+		reaperConfig.Interval = time.Duration(seconds) * time.Second
+	}
+	go services.RunMessageReaper(jobsCtx, reaperConfig)
+
+	services.Blobs = blobStore()
+	if maxBytes := envInt("ATTACHMENT_MAX_BYTES"); maxBytes > 0 {
+		services.MaxAttachmentSize = int64(maxBytes)
+	}
+	if types := os.Getenv("ATTACHMENT_TYPES"); types != "" {
+		services.AllowedAttachmentTypes = make(map[string]bool)
+		for _, contentType := range strings.Split(types, ",") {
+			services.AllowedAttachmentTypes[strings.TrimSpace(contentType)] = true
+		}
+	}
+	blobCleanupInterval := time.Minute
+	if seconds := envInt("BLOB_CLEANUP_INTERVAL_SECONDS"); seconds > 0 {
+		blobCleanupInterval = time.Duration(seconds) * time.Second
+	}
+	go services.RunBlobCleanup(jobsCtx, blobCleanupInterval)
+	limitStore, limits := rateLimits(db)
+	// Stores that keep buckets outside the process, such as the database,
+	// have idle buckets swept by a job; the memory store sweeps itself.
+	if sweeper, ok := limitStore.(ratelimit_utils.Sweeper); ok {
+		sweepInterval := time.Minute
+		if seconds := envInt("RATE_LIMIT_SWEEP_INTERVAL_SECONDS"); seconds > 0 {
+			sweepInterval = time.Duration(seconds) * time.Second
+		}
+		go services.RunRateLimitSweep(jobsCtx, sweeper, sweepInterval, ratelimit_utils.RefillTime(limits))
+	}
+
+	// Forwarded headers only name the client when they come from one of
+	// TRUSTED_PROXIES, comma separated addresses or CIDRs. None are trusted
+	// by default, so the client is the peer and cannot pose as another.
+	if err := router.SetTrustedProxies(trustedProxies()); err != nil {
+		logger_utils.Fatal("invalid TRUSTED_PROXIES", "error", err)
+	}
+	router.Use(
+		gin.Recovery(),
+		logger_utils.RequestIDMiddleware(),
+		audit_utils.Middleware(),
+		tracing_utils.GinMiddleware(),
+		logger_utils.GinMiddleware(),
+		metrics_utils.GinMiddleware(),
+	)
+	limit := rateLimiter(limitStore, limits)
+	// Callers are limited by IP before they are authenticated, so that bad
+	// credentials cannot be tried at full speed.
+	router.Use(limit("requests"))
+	authenticate, requireScope := authMiddlewares()
+	routes(authenticate, requireScope, limit)
+
+	server := &http.Server{Addr: ":8080", Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger_utils.Fatal("server failed", "error", err)
+		}
+	}()
+	signals, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	<-signals.Done()
+
+	// Requests in flight finish, and the webhook deliveries they queued are
+	// drained, within the grace period.
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to shut down the server", "error", err)
+	}
+	stopJobs()
+	services.WebhookDispatcher.Shutdown(shutdownCtx)
+}
+
+// authMiddlewares builds the authentication middleware and the per-route
+// scope check. JWTs are accepted when a JWKS is configured through the JWT_*
+// settings; API keys are always accepted. Setting AUTH_DISABLED=true turns
+// both off for local development only.
+func authMiddlewares() (gin.HandlerFunc, func(string) gin.HandlerFunc) {
+	if scope := os.Getenv("JWT_ADMIN_SCOPE"); scope != "" {
+		auth_utils.AdminScope = scope
+	}
+	if os.Getenv("AUTH_DISABLED") == "true" {
+		slog.Warn("authentication is disabled, every caller may modify every message")
+		pass := func(c *gin.Context) { c.Next() }
+		return pass, func(string) gin.HandlerFunc { return pass }
+	}
+
+	authenticators := auth_utils.Authenticators{ApiKey: services.ApiKeysService}
+	jwksFile, jwksURL := os.Getenv("JWT_JWKS_FILE"), os.Getenv("JWT_JWKS_URL")
+	if jwksFile == "" && jwksURL == "" {
+		slog.Warn("no JWKS configured, only API keys are accepted")
+	} else {
+		bearer, err := auth_utils.NewJWTAuthenticator(auth_utils.JWTConfig{
+			Issuer:   os.Getenv("JWT_ISSUER"),
+			Audience: os.Getenv("JWT_AUDIENCE"),
+			JWKSFile: jwksFile,
+			JWKSURL:  jwksURL,
+		})
+		if err != nil {
+			logger_utils.Fatal("failed to initialize authentication", "error", err)
+		}
+		authenticators.Bearer = bearer
+	}
+	return auth_utils.Middleware(authenticators), auth_utils.RequireScope
+}
+
+// defaultRateLimits apply to each client per route unless RATE_LIMITS
+// overrides them. Rates are in requests per second. "requests" limits each
+// client IP across all routes, before authentication.
+var defaultRateLimits = map[string]ratelimit_utils.Limit{
+	"requests":        {Rate: 20, Burst: 200},
+	"messages.list":   {Rate: 5, Burst: 50},
+	"messages.create": {Rate: 1, Burst: 10},
+	"messages.attach": {Rate: 0.2, Burst: 5},
+	"messages.update": {Rate: 2, Burst: 20},
+	"messages.react":  {Rate: 2, Burst: 20},
+	"messages.delete": {Rate: 2, Burst: 20},
+	"messages.stream": {Rate: 0.2, Burst: 5},
+	"messages.export": {Rate: 0.1, Burst: 2},
+	"messages.import": {Rate: 0.1, Burst: 2},
+}
+
+// rateLimits returns where buckets live and the limit of each route.
+// Buckets live in memory unless RATE_LIMIT_STORE=sql, which shares them
+// between replicas through the database.
+func rateLimits(db *sql.DB) (ratelimit_utils.Store, map[string]ratelimit_utils.Limit) {
+	var store ratelimit_utils.Store
+	switch kind := os.Getenv("RATE_LIMIT_STORE"); kind {
+	case "", "memory":
+		store = ratelimit_utils.NewMemoryStore()
+	case "sql":
+		store = domain.NewRateLimitStore(db)
+	default:
+		logger_utils.Fatal("invalid RATE_LIMIT_STORE", "store", kind)
+	}
+
+	limits := make(map[string]ratelimit_utils.Limit, len(defaultRateLimits))
+	for route, limit := range defaultRateLimits {
+		limits[route] = limit
+	}
+	overrides, err := ratelimit_utils.ParseLimits(os.Getenv("RATE_LIMITS"))
+	if err != nil {
+		logger_utils.Fatal("invalid RATE_LIMITS", "error", err)
+	}
+	for route, limit := range overrides {
+		limits[route] = limit
+	}
+	return store, limits
+}
 
-	router.Run(":8080")
+// rateLimiter returns the per-route limiting middleware.
+func rateLimiter(store ratelimit_utils.Store, limits map[string]ratelimit_utils.Limit) func(string) gin.HandlerFunc {
+	return func(route string) gin.HandlerFunc {
+		limit, ok := limits[route]
+		if !ok {
+			logger_utils.Fatal("no rate limit configured", "route", route)
+		}
+		return ratelimit_utils.Middleware(store, route, limit)
+	}
+}
+
+// blobStore picks where attachment bytes live: a local directory by default,
+// or an S3-compatible bucket with BLOB_STORE=s3.
+func blobStore() blob_utils.BlobStore {
+	switch backend := os.Getenv("BLOB_STORE"); backend {
+	case "", "local":
+		dir := os.Getenv("BLOB_LOCAL_DIR")
+		if dir == "" {
+			dir = "data/blobs"
+		}
+		store, err := blob_utils.NewLocalStore(dir)
+		if err != nil {
+			logger_utils.Fatal("failed to initialize blob store", "error", err)
+		}
+		return store
+	case "s3":
+		store, err := blob_utils.NewS3Store(blob_utils.S3Config{
+			Endpoint:              os.Getenv("S3_ENDPOINT"),
+			Region:                os.Getenv("S3_REGION"),
+			Bucket:                os.Getenv("S3_BUCKET"),
+			AccessKeyId:           os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey:       os.Getenv("S3_SECRET_ACCESS_KEY"),
+			DialTimeout:           time.Duration(envInt("S3_DIAL_TIMEOUT_SECONDS")) * time.Second,
+			ResponseHeaderTimeout: time.Duration(envInt("S3_RESPONSE_HEADER_TIMEOUT_SECONDS")) * time.Second,
+		})
+		if err != nil {
+			logger_utils.Fatal("failed to initialize blob store", "error", err)
+		}
+		return store
+	default:
+		logger_utils.Fatal("invalid BLOB_STORE", "backend", backend)
+		return nil
+	}
+}
+
+// trustedProxies reads TRUSTED_PROXIES, returning nil when it is unset.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// envInt reads an optional integer setting, returning 0 when it is unset.
+func envInt(name string) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return 0
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		logger_utils.Fatal("invalid integer setting", "name", name, "error", err)
+	}
+	return value
 }
@@ -1,9 +1,79 @@
 package app
 
-import "testing-project/controllers"
+import (
+	"github.com/gin-gonic/gin"
+	"testing-project/controllers"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+)
 
-func routes() {
-	router.POST("/messages", controllers.CreateMessage)
-	router.PUT("/messages/:message_id", controllers.UpdateMessage)
-	router.DELETE("/messages/:message_id", controllers.DeleteMessage)
+// routes registers every endpoint. authenticate resolves the caller,
+// requireScope rejects callers missing the scope a route needs and limit
+// throttles each caller on the named route. Message and webhook routes are
+// scoped to the caller's tenant.
+func routes(authenticate gin.HandlerFunc, requireScope func(string) gin.HandlerFunc, limit func(string) gin.HandlerFunc) {
+	tenant := tenant_utils.Middleware()
+	read := requireScope(auth_utils.ScopeMessagesRead)
+	write := requireScope(auth_utils.ScopeMessagesWrite)
+	remove := requireScope(auth_utils.ScopeMessagesDelete)
+	moderate := requireScope(auth_utils.ScopeMessagesModerate)
+	admin := requireScope(auth_utils.AdminScope)
+
+	router.GET("/messages", authenticate, tenant, read, limit("messages.list"), controllers.ListMessages)
+	router.GET("/tags", authenticate, tenant, read, limit("messages.list"), controllers.ListTags)
+	router.GET("/messages/pinned", authenticate, tenant, read, limit("messages.list"), controllers.ListPinned)
+	router.PUT("/messages/pinned/order", authenticate, tenant, admin, limit("messages.update"), controllers.ReorderPins)
+	router.GET("/messages/by-slug/:slug", authenticate, tenant, read, limit("messages.list"), controllers.GetMessageBySlug)
+	router.GET("/messages/export", authenticate, tenant, admin, limit("messages.export"), controllers.ExportMessages)
+	router.POST("/messages/import", authenticate, tenant, admin, limit("messages.import"), controllers.ImportMessages)
+	router.GET("/messages/stream", authenticate, tenant, read, limit("messages.stream"), controllers.StreamMessages)
+	router.GET("/messages/:message_id", authenticate, tenant, read, limit("messages.list"), controllers.GetMessage)
+	router.GET("/messages/:message_id/thread", authenticate, tenant, read, limit("messages.list"), controllers.GetThread)
+	router.GET("/messages/:message_id/similar", authenticate, tenant, read, limit("messages.list"), controllers.GetSimilarMessages)
+	router.POST("/messages", authenticate, tenant, write, limit("messages.create"), controllers.CreateMessage)
+	router.PUT("/messages/:message_id", authenticate, tenant, write, limit("messages.update"), controllers.UpdateMessage)
+	router.DELETE("/messages/:message_id", authenticate, tenant, remove, limit("messages.delete"), controllers.DeleteMessage)
+	router.POST("/messages/:message_id/publish", authenticate, tenant, write, limit("messages.update"), controllers.PublishMessage)
+	router.POST("/messages/:message_id/schedule", authenticate, tenant, write, limit("messages.update"), controllers.ScheduleMessage)
+	router.POST("/messages/:message_id/draft", authenticate, tenant, write, limit("messages.update"), controllers.DraftMessage)
+	router.POST("/messages/:message_id/archive", authenticate, tenant, write, limit("messages.update"), controllers.ArchiveMessage)
+	router.GET("/messages/:message_id/attachments", authenticate, tenant, read, limit("messages.list"), controllers.ListAttachments)
+	router.POST("/messages/:message_id/attachments", authenticate, tenant, write, limit("messages.attach"), controllers.UploadAttachment)
+	router.GET("/messages/:message_id/attachments/:attachment_id", authenticate, tenant, read, limit("messages.list"), controllers.DownloadAttachment)
+	router.DELETE("/messages/:message_id/attachments/:attachment_id", authenticate, tenant, write, limit("messages.update"), controllers.DeleteAttachment)
+	router.POST("/messages/:message_id/pin", authenticate, tenant, admin, limit("messages.update"), controllers.PinMessage)
+	router.DELETE("/messages/:message_id/pin", authenticate, tenant, admin, limit("messages.update"), controllers.UnpinMessage)
+	router.PUT("/messages/:message_id/reactions/:emoji", authenticate, tenant, read, limit("messages.react"), controllers.AddReaction)
+	router.DELETE("/messages/:message_id/reactions/:emoji", authenticate, tenant, read, limit("messages.react"), controllers.RemoveReaction)
+	router.POST("/messages/:message_id/read", authenticate, tenant, read, limit("messages.react"), controllers.MarkRead)
+	router.GET("/messages/:message_id/moderation", authenticate, tenant, moderate, limit("messages.list"), controllers.GetModeration)
+	router.POST("/messages/:message_id/approve", authenticate, tenant, moderate, limit("messages.update"), controllers.ApproveMessage)
+	router.POST("/messages/:message_id/reject", authenticate, tenant, moderate, limit("messages.update"), controllers.RejectMessage)
+	router.GET("/moderation/queue", authenticate, tenant, moderate, limit("messages.list"), controllers.ListModerationQueue)
+	router.GET("/audit", authenticate, tenant, admin, limit("messages.list"), controllers.ListAudit)
+	router.GET("/audit/verify", authenticate, tenant, admin, limit("messages.list"), controllers.VerifyAudit)
+	router.GET("/authors/:author_id/export", authenticate, tenant, read, limit("messages.export"), controllers.ExportAuthor)
+	router.POST("/authors/:author_id/erase", authenticate, tenant, remove, limit("messages.delete"), controllers.EraseAuthor)
+	router.POST("/erasure-reports/verify", authenticate, tenant, read, limit("messages.list"), controllers.VerifyErasureReport)
+	router.GET("/messages/:message_id/translations", authenticate, tenant, read, limit("messages.list"), controllers.ListTranslations)
+	router.PUT("/messages/:message_id/translations/:locale", authenticate, tenant, write, limit("messages.update"), controllers.PutTranslation)
+	router.DELETE("/messages/:message_id/translations/:locale", authenticate, tenant, write, limit("messages.update"), controllers.DeleteTranslation)
+
+	webhooks := router.Group("/webhooks", authenticate, tenant, admin)
+	webhooks.GET("", controllers.ListWebhooks)
+	webhooks.POST("", controllers.CreateWebhook)
+	webhooks.GET("/:webhook_id", controllers.GetWebhook)
+	webhooks.PUT("/:webhook_id", controllers.UpdateWebhook)
+	webhooks.DELETE("/:webhook_id", controllers.DeleteWebhook)
+	webhooks.GET("/:webhook_id/deliveries", controllers.ListWebhookDeliveries)
+
+	router.GET("/metrics", metrics_utils.Handler())
+
+	adminRoutes := router.Group("/admin", authenticate, admin)
+	adminRoutes.GET("/log-level", controllers.GetLogLevel)
+	adminRoutes.PUT("/log-level", controllers.SetLogLevel)
+	adminRoutes.GET("/api-keys", controllers.ListApiKeys)
+	adminRoutes.POST("/api-keys", controllers.MintApiKey)
+	adminRoutes.DELETE("/api-keys/:key_id", controllers.RevokeApiKey)
 }
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/logger_utils"
+)
+
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+func GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, logLevelRequest{Level: logger_utils.Level().String()})
+}
+
+func SetLogLevel(c *gin.Context) {
+	var request logLevelRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	lvl, err := logger_utils.ParseLevel(request.Level)
+	if err != nil {
+		theErr := error_utils.NewBadRequestError(err.Error())
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	previous := logger_utils.Level()
+	logger_utils.SetLevel(lvl)
+	slog.InfoContext(c.Request.Context(), "log level changed", "from", previous.String(), "to", lvl.String())
+	c.JSON(http.StatusOK, logLevelRequest{Level: lvl.String()})
+}
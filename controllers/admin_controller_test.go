@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/logger_utils"
+)
+
+func TestSetLogLevel_Success(t *testing.T) {
+	defer logger_utils.SetLevel(logger_utils.Level())
+
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPut, "/admin/log-level", bytes.NewBufferString(`{"level": "debug"}`))
+	rr := httptest.NewRecorder()
+	r.PUT("/admin/log-level", SetLogLevel)
+	r.ServeHTTP(rr, req)
+
+	var body map[string]string
+	err := json.Unmarshal(rr.Body.Bytes(), &body)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "DEBUG", body["level"])
+	assert.EqualValues(t, slog.LevelDebug, logger_utils.Level())
+}
+
+func TestSetLogLevel_Unknown_Level(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPut, "/admin/log-level", bytes.NewBufferString(`{"level": "verbose"}`))
+	rr := httptest.NewRecorder()
+	r.PUT("/admin/log-level", SetLogLevel)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.NotNil(t, apiErr)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "bad_request", apiErr.Error())
+}
+
+func TestGetLogLevel_Success(t *testing.T) {
+	defer logger_utils.SetLevel(logger_utils.Level())
+	logger_utils.SetLevel(slog.LevelWarn)
+
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/admin/log-level", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/admin/log-level", GetLogLevel)
+	r.ServeHTTP(rr, req)
+
+	var body map[string]string
+	err := json.Unmarshal(rr.Body.Bytes(), &body)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "WARN", body["level"])
+}
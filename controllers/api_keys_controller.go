@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type mintApiKeyResponse struct {
+	Key    string         `json:"key"`
+	ApiKey *domain.ApiKey `json:"api_key"`
+}
+
+func getApiKeyId(keyIdParam string) (int64, error_utils.MessageErr) {
+	keyId, err := strconv.ParseInt(keyIdParam, 10, 64)
+	if err != nil {
+		return 0, error_utils.NewBadRequestError("api key id should be a number")
+	}
+	return keyId, nil
+}
+
+func ListApiKeys(c *gin.Context) {
+	keys, err := services.ApiKeysService.ListApiKeys(c.Request.Context())
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+func MintApiKey(c *gin.Context) {
+	var key domain.ApiKey
+	if err := c.ShouldBindJSON(&key); err != nil {
+		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	created, plaintext, err := services.ApiKeysService.MintApiKey(c.Request.Context(), &key)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, mintApiKeyResponse{Key: plaintext, ApiKey: created})
+}
+
+func RevokeApiKey(c *gin.Context) {
+	keyId, err := getApiKeyId(c.Param("key_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if err := services.ApiKeysService.RevokeApiKey(c.Request.Context(), keyId); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{"status": "revoked"})
+}
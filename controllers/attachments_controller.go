@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"mime"
+	"net/http"
+	"strconv"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+// multipartOverhead leaves room for the multipart framing around the file.
+const multipartOverhead = 64 << 10
+
+func getAttachmentId(param string) (int64, error_utils.MessageErr) {
+	attachmentId, err := strconv.ParseInt(param, 10, 64)
+	if err != nil {
+		return 0, error_utils.NewBadRequestError("attachment id should be a number")
+	}
+	return attachmentId, nil
+}
+
+// UploadAttachment accepts a multipart form with the file in the "file" field.
+func UploadAttachment(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxAttachmentSize+multipartOverhead)
+	fileHeader, formErr := c.FormFile("file")
+	if formErr != nil {
+		theErr := error_utils.NewBadRequestError("a file is required in the file field")
+		var tooLarge *http.MaxBytesError
+		if errors.As(formErr, &tooLarge) {
+			theErr = error_utils.NewPayloadTooLargeError("attachment is too large")
+		}
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	file, openErr := fileHeader.Open()
+	if openErr != nil {
+		theErr := error_utils.NewBadRequestError("could not read attachment")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	defer file.Close()
+
+	attachment, uploadErr := services.AttachmentsService.UploadAttachment(c.Request.Context(), msgId, fileHeader.Filename, file, fileHeader.Size)
+	if uploadErr != nil {
+		c.JSON(uploadErr.Status(), uploadErr)
+		return
+	}
+	c.JSON(http.StatusCreated, attachment)
+}
+
+func ListAttachments(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	attachments, listErr := services.AttachmentsService.ListAttachments(c.Request.Context(), msgId)
+	if listErr != nil {
+		c.JSON(listErr.Status(), listErr)
+		return
+	}
+	c.JSON(http.StatusOK, attachments)
+}
+
+// DownloadAttachment streams the attachment's bytes from the blob store.
+func DownloadAttachment(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	attachmentId, err := getAttachmentId(c.Param("attachment_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	attachment, content, openErr := services.AttachmentsService.OpenAttachment(c.Request.Context(), msgId, attachmentId)
+	if openErr != nil {
+		c.JSON(openErr.Status(), openErr)
+		return
+	}
+	defer content.Close()
+
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":    mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}),
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func DeleteAttachment(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	attachmentId, err := getAttachmentId(c.Param("attachment_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if deleteErr := services.AttachmentsService.DeleteAttachment(c.Request.Context(), msgId, attachmentId); deleteErr != nil {
+		c.JSON(deleteErr.Status(), deleteErr)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
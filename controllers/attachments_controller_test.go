@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type attachmentsServiceMock struct {
+	fileName string
+	content  []byte
+}
+
+func (m *attachmentsServiceMock) UploadAttachment(ctx context.Context, msgId int64, fileName string, content io.Reader, size int64) (*domain.Attachment, error_utils.MessageErr) {
+	m.fileName = fileName
+	m.content, _ = io.ReadAll(content)
+	return &domain.Attachment{Id: 1, MessageId: msgId, FileName: fileName, ContentType: "application/pdf", Size: size}, nil
+}
+func (m *attachmentsServiceMock) ListAttachments(ctx context.Context, msgId int64) ([]domain.Attachment, error_utils.MessageErr) {
+	return []domain.Attachment{}, nil
+}
+func (m *attachmentsServiceMock) OpenAttachment(ctx context.Context, msgId int64, attachmentId int64) (*domain.Attachment, io.ReadCloser, error_utils.MessageErr) {
+	attachment := &domain.Attachment{Id: attachmentId, MessageId: msgId, FileName: "report 2024.pdf", ContentType: "application/pdf", Size: int64(len(m.content))}
+	return attachment, io.NopCloser(bytes.NewReader(m.content)), nil
+}
+func (m *attachmentsServiceMock) DeleteAttachment(ctx context.Context, msgId int64, attachmentId int64) error_utils.MessageErr {
+	return nil
+}
+
+func TestUploadAttachment_Success(t *testing.T) {
+	mock := &attachmentsServiceMock{}
+	services.AttachmentsService = mock
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", "report.pdf")
+	part.Write([]byte("%PDF-1.4 content"))
+	form.Close()
+
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/messages/4/attachments", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	rr := httptest.NewRecorder()
+	r.POST("/messages/:message_id/attachments", UploadAttachment)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusCreated, rr.Code)
+	assert.Equal(t, "report.pdf", mock.fileName)
+	assert.Equal(t, "%PDF-1.4 content", string(mock.content))
+	assert.Contains(t, rr.Body.String(), `"message_id":4`)
+}
+
+func TestUploadAttachment_Missing_File(t *testing.T) {
+	services.AttachmentsService = &attachmentsServiceMock{}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/messages/4/attachments", strings.NewReader(""))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	rr := httptest.NewRecorder()
+	r.POST("/messages/:message_id/attachments", UploadAttachment)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+}
+
+func TestDownloadAttachment_Streams(t *testing.T) {
+	services.AttachmentsService = &attachmentsServiceMock{content: []byte("%PDF-1.4 content")}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/4/attachments/1", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/attachments/:attachment_id", DownloadAttachment)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/pdf", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "16", rr.Header().Get("Content-Length"))
+	assert.Equal(t, `attachment; filename="report 2024.pdf"`, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "%PDF-1.4 content", rr.Body.String())
+}
+
+func TestDownloadAttachment_Invalid_Id(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/4/attachments/abc", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/attachments/:attachment_id", DownloadAttachment)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "attachment id should be a number", apiErr.Message())
+}
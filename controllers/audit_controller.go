@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// ListAudit lists the tenant's audit log, newest first, filtered by
+// ?message_id=, ?actor=, ?action=, ?request_id= and the RFC 3339 times
+// ?since= and ?until=, and paged with ?limit= and ?offset=.
+func ListAudit(c *gin.Context) {
+	filter := domain.AuditFilter{
+		Actor:     c.Query("actor"),
+		Action:    c.Query("action"),
+		RequestId: c.Query("request_id"),
+	}
+	if value := c.Query("message_id"); value != "" {
+		msgId, err := getMessageId(value)
+		if err != nil {
+			c.JSON(err.Status(), err)
+			return
+		}
+		filter.MessageId = msgId
+	}
+	var err error
+	if filter.Since, err = queryTime(c, "since"); err != nil {
+		theErr := error_utils.NewBadRequestError("since should be an RFC 3339 time")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	if filter.Until, err = queryTime(c, "until"); err != nil {
+		theErr := error_utils.NewBadRequestError("until should be an RFC 3339 time")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	if filter.Limit, err = queryInt(c, "limit"); err != nil {
+		theErr := error_utils.NewBadRequestError("limit should be a number")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	if filter.Offset, err = queryInt(c, "offset"); err != nil {
+		theErr := error_utils.NewBadRequestError("offset should be a number")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	entries, listErr := services.AuditService.ListEntries(c.Request.Context(), filter)
+	if listErr != nil {
+		c.JSON(listErr.Status(), listErr)
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// VerifyAudit checks the hash chain of the tenant's audit log.
+func VerifyAudit(c *gin.Context) {
+	report, err := services.AuditService.VerifyChain(c.Request.Context())
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+type auditServiceMock struct {
+	filter domain.AuditFilter
+}
+
+func (m *auditServiceMock) ListEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error_utils.MessageErr) {
+	m.filter = filter
+	return []domain.AuditEntry{{Id: 1, MessageId: filter.MessageId, Action: domain.AuditActionCreate}}, nil
+}
+func (m *auditServiceMock) VerifyChain(ctx context.Context) (*services.ChainReport, error_utils.MessageErr) {
+	return &services.ChainReport{Valid: false, Entries: 2, BrokenAt: 3, Reason: "hash does not match"}, nil
+}
+
+func TestListAudit_PassesFilter(t *testing.T) {
+	audit := &auditServiceMock{}
+	services.AuditService = audit
+	r := gin.Default()
+	r.GET("/audit", ListAudit)
+	req, _ := http.NewRequest(http.MethodGet, "/audit?message_id=7&actor=user-1&action=delete&since=2024-01-02T00:00:00Z&limit=5", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, 7, audit.filter.MessageId)
+	assert.Equal(t, "user-1", audit.filter.Actor)
+	assert.Equal(t, domain.AuditActionDelete, audit.filter.Action)
+	assert.True(t, audit.filter.Since.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)))
+	assert.Nil(t, audit.filter.Until)
+	assert.Equal(t, 5, audit.filter.Limit)
+}
+
+func TestListAudit_InvalidSince(t *testing.T) {
+	services.AuditService = &auditServiceMock{}
+	r := gin.Default()
+	r.GET("/audit", ListAudit)
+	req, _ := http.NewRequest(http.MethodGet, "/audit?since=yesterday", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "since should be an RFC 3339 time")
+}
+
+func TestVerifyAudit(t *testing.T) {
+	services.AuditService = &auditServiceMock{}
+	r := gin.Default()
+	r.GET("/audit/verify", VerifyAudit)
+	req, _ := http.NewRequest(http.MethodGet, "/audit/verify", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"valid":false,"entries":2,"broken_at":3,"reason":"hash does not match"}`, rr.Body.String())
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"mime"
+	"net/http"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type eraseRequest struct {
+	Mode string `json:"mode"`
+}
+
+// ExportAuthor streams everything stored about an author as NDJSON, or as
+// a ZIP archive with ?format=zip.
+func ExportAuthor(c *gin.Context) {
+	authorId := c.Param("author_id")
+	format := c.DefaultQuery("format", services.ExportFormatNDJSON)
+	if !services.ValidExportFormat(format) {
+		theErr := error_utils.NewBadRequestError("format must be ndjson or zip")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	contentType := "application/x-ndjson"
+	if format == services.ExportFormatZIP {
+		contentType = "application/zip"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "author-export." + format}))
+	if err := services.AuthorsService.ExportAuthor(c.Request.Context(), authorId, format, c.Writer); err != nil {
+		respondToExportError(c, format, err)
+	}
+}
+
+// EraseAuthor erases an author in the mode given by an optional JSON body,
+// anonymize unless it says delete, and responds with the report.
+func EraseAuthor(c *gin.Context) {
+	request := eraseRequest{Mode: services.ErasureModeAnonymize}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+			c.JSON(theErr.Status(), theErr)
+			return
+		}
+	}
+	report, err := services.AuthorsService.EraseAuthor(c.Request.Context(), c.Param("author_id"), request.Mode)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// VerifyErasureReport checks an erasure report, as returned by EraseAuthor,
+// for changes since it was signed.
+func VerifyErasureReport(c *gin.Context) {
+	var report services.ErasureReport
+	if err := c.ShouldBindJSON(&report); err != nil {
+		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]bool{"valid": services.AuthorsService.VerifyErasureReport(c.Request.Context(), report)})
+}
+
+// respondToExportError reports err as JSON while nothing has been written
+// yet. Once the export has started its status is sent, so an NDJSON export
+// ends with an error line instead; a ZIP export is left without its
+// directory, which makes it unreadable.
+func respondToExportError(c *gin.Context, format string, err error_utils.MessageErr) {
+	if !c.Writer.Written() {
+		c.Writer.Header().Del("Content-Disposition")
+		c.Writer.Header().Del("Content-Type")
+		c.JSON(err.Status(), err)
+		return
+	}
+	slog.ErrorContext(c.Request.Context(), "export failed after it started", "error", err.Message())
+	if format == services.ExportFormatNDJSON {
+		if line, marshalErr := json.Marshal(map[string]interface{}{"type": "error", "data": err}); marshalErr == nil {
+			c.Writer.Write(append(line, '\n'))
+		}
+	}
+	c.Abort()
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type authorsServiceMock struct {
+	exportErr      error_utils.MessageErr
+	exportWritten  bool
+	erasedMode     string
+	exportedFormat string
+}
+
+func (m *authorsServiceMock) ExportAuthor(ctx context.Context, authorId string, format string, w io.Writer) error_utils.MessageErr {
+	m.exportedFormat = format
+	if m.exportWritten {
+		io.WriteString(w, `{"type":"message","data":{"id":4}}`+"\n")
+	}
+	return m.exportErr
+}
+func (m *authorsServiceMock) EraseAuthor(ctx context.Context, authorId string, mode string) (*services.ErasureReport, error_utils.MessageErr) {
+	m.erasedMode = mode
+	return &services.ErasureReport{AuthorId: authorId, Mode: mode, MessageIds: []int64{4}, Verified: true}, nil
+}
+
+func (m *authorsServiceMock) VerifyErasureReport(ctx context.Context, report services.ErasureReport) bool {
+	return report.Signature == "valid"
+}
+
+func serveAuthors(authors *authorsServiceMock, method string, target string, body string) *httptest.ResponseRecorder {
+	services.AuthorsService = authors
+	r := gin.Default()
+	r.GET("/authors/:author_id/export", ExportAuthor)
+	r.POST("/authors/:author_id/erase", EraseAuthor)
+	r.POST("/erasure-reports/verify", VerifyErasureReport)
+	req, _ := http.NewRequest(method, target, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestExportAuthor_ZIP(t *testing.T) {
+	authors := &authorsServiceMock{exportWritten: true}
+	rr := serveAuthors(authors, http.MethodGet, "/authors/user-1/export?format=zip", "")
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, services.ExportFormatZIP, authors.exportedFormat)
+	assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), "author-export.zip")
+}
+
+func TestExportAuthor_UnknownFormat(t *testing.T) {
+	rr := serveAuthors(&authorsServiceMock{}, http.MethodGet, "/authors/user-1/export?format=xml", "")
+
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestExportAuthor_ErrorBeforeWriting(t *testing.T) {
+	rr := serveAuthors(&authorsServiceMock{exportErr: error_utils.NewForbiddenError("you are not allowed to act for this author")},
+		http.MethodGet, "/authors/user-1/export", "")
+
+	assert.EqualValues(t, http.StatusForbidden, rr.Code)
+	assert.Contains(t, rr.Header().Get("Content-Type"), "application/json")
+	assert.Empty(t, rr.Header().Get("Content-Disposition"))
+}
+
+func TestExportAuthor_ErrorAfterWritingEndsWithErrorLine(t *testing.T) {
+	rr := serveAuthors(&authorsServiceMock{exportWritten: true, exportErr: error_utils.NewInternalServerError("database is gone")},
+		http.MethodGet, "/authors/user-1/export", "")
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	assert.Equal(t, 2, len(lines))
+	assert.Contains(t, lines[1], `"type":"error"`)
+}
+
+func TestEraseAuthor_DefaultsToAnonymize(t *testing.T) {
+	authors := &authorsServiceMock{}
+	rr := serveAuthors(authors, http.MethodPost, "/authors/user-1/erase", "")
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, services.ErasureModeAnonymize, authors.erasedMode)
+	assert.Contains(t, rr.Body.String(), `"verified":true`)
+}
+
+func TestEraseAuthor_Delete(t *testing.T) {
+	authors := &authorsServiceMock{}
+	rr := serveAuthors(authors, http.MethodPost, "/authors/user-1/erase", `{"mode":"delete"}`)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, services.ErasureModeDelete, authors.erasedMode)
+}
+
+func TestVerifyErasureReport(t *testing.T) {
+	rr := serveAuthors(&authorsServiceMock{}, http.MethodPost, "/erasure-reports/verify", `{"author_id":"user-1","signature":"valid"}`)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"valid":true}`, rr.Body.String())
+}
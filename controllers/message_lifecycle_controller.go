@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+type scheduleMessageRequest struct {
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+func PublishMessage(c *gin.Context) {
+	transitionMessage(c, domain.MessageStatusPublished, nil)
+}
+
+func ScheduleMessage(c *gin.Context) {
+	var request scheduleMessageRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	transitionMessage(c, domain.MessageStatusScheduled, request.PublishAt)
+}
+
+func DraftMessage(c *gin.Context) {
+	transitionMessage(c, domain.MessageStatusDraft, nil)
+}
+
+func ArchiveMessage(c *gin.Context) {
+	transitionMessage(c, domain.MessageStatusArchived, nil)
+}
+
+func transitionMessage(c *gin.Context, status string, publishAt *time.Time) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	msg, err := services.MessagesService.TransitionMessage(c.Request.Context(), msgId, status, publishAt)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, msg)
+}
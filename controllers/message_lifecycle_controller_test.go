@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"bytes"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+func TestScheduleMessage_Success(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	var gotStatus string
+	var gotPublishAt *time.Time
+	transitionService = func(msgId int64, status string, publishAt *time.Time) (*domain.Message, error_utils.MessageErr) {
+		gotStatus, gotPublishAt = status, publishAt
+		return &domain.Message{Id: msgId, Status: status, PublishAt: publishAt}, nil
+	}
+	r := gin.Default()
+	r.POST("/messages/:message_id/schedule", ScheduleMessage)
+	req, _ := http.NewRequest(http.MethodPost, "/messages/1/schedule", bytes.NewBufferString(`{"publish_at":"2030-01-02T09:00:00Z"}`))
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, domain.MessageStatusScheduled, gotStatus)
+	assert.EqualValues(t, time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC), gotPublishAt.UTC())
+	assert.Contains(t, rr.Body.String(), `"status":"scheduled"`)
+}
+
+func TestArchiveMessage_Conflict(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	transitionService = func(msgId int64, status string, publishAt *time.Time) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewConflictError("cannot move a message from draft to archived")
+	}
+	r := gin.Default()
+	r.POST("/messages/:message_id/archive", ArchiveMessage)
+	req, _ := http.NewRequest(http.MethodPost, "/messages/1/archive", nil)
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusConflict, rr.Code)
+}
+
+func TestPublishMessage_InvalidId(t *testing.T) {
+	r := gin.Default()
+	r.POST("/messages/:message_id/publish", PublishMessage)
+	req, _ := http.NewRequest(http.MethodPost, "/messages/abc/publish", nil)
+	rr := httptest.NewRecorder()
+
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+}
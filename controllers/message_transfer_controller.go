@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"mime"
+	"net/http"
+	"strconv"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+// ExportMessages streams every message of the tenant as NDJSON, or as CSV
+// with ?format=csv.
+func ExportMessages(c *gin.Context) {
+	format := c.DefaultQuery("format", services.ExportFormatNDJSON)
+	if !services.ValidTransferFormat(format) {
+		theErr := error_utils.NewBadRequestError("format must be ndjson or csv")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	contentType := "application/x-ndjson"
+	if format == services.ExportFormatCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "messages." + format}))
+	if err := services.TransferService.ExportMessages(c.Request.Context(), format, c.Writer); err != nil {
+		respondToExportError(c, format, err)
+	}
+}
+
+// ImportMessages imports the messages in the body, NDJSON unless ?format=csv
+// says otherwise, and responds with a report of every row. ?on_conflict is
+// fail unless it says skip or upsert, and ?events=false imports without
+// sending events.
+func ImportMessages(c *gin.Context) {
+	events, parseErr := strconv.ParseBool(c.DefaultQuery("events", "true"))
+	if parseErr != nil {
+		theErr := error_utils.NewBadRequestError("events must be true or false")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	options := services.ImportOptions{
+		Format:         c.DefaultQuery("format", services.ExportFormatNDJSON),
+		OnConflict:     c.DefaultQuery("on_conflict", services.ImportConflictFail),
+		SuppressEvents: !events,
+	}
+	report, err := services.TransferService.ImportMessages(c.Request.Context(), c.Request.Body, options)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
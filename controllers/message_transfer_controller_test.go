@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type transferServiceMock struct {
+	exportedFormat string
+	options        services.ImportOptions
+	imported       string
+}
+
+func (m *transferServiceMock) ExportMessages(ctx context.Context, format string, w io.Writer) error_utils.MessageErr {
+	m.exportedFormat = format
+	io.WriteString(w, "id,title\n4,title\n")
+	return nil
+}
+func (m *transferServiceMock) ImportMessages(ctx context.Context, r io.Reader, options services.ImportOptions) (*services.ImportReport, error_utils.MessageErr) {
+	m.options = options
+	body, _ := io.ReadAll(r)
+	m.imported = string(body)
+	return &services.ImportReport{Created: 1, Rows: []services.ImportRow{{Row: 1, Status: services.ImportRowCreated, MessageId: 4}}}, nil
+}
+
+func serveTransfer(transfer *transferServiceMock, method string, target string, body string) *httptest.ResponseRecorder {
+	services.TransferService = transfer
+	r := gin.Default()
+	r.GET("/messages/export", ExportMessages)
+	r.POST("/messages/import", ImportMessages)
+	req, _ := http.NewRequest(method, target, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestExportMessages_CSV(t *testing.T) {
+	transfer := &transferServiceMock{}
+	rr := serveTransfer(transfer, http.MethodGet, "/messages/export?format=csv", "")
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, services.ExportFormatCSV, transfer.exportedFormat)
+	assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), "messages.csv")
+}
+
+func TestExportMessages_UnknownFormat(t *testing.T) {
+	rr := serveTransfer(&transferServiceMock{}, http.MethodGet, "/messages/export?format=zip", "")
+
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestImportMessages_Defaults(t *testing.T) {
+	transfer := &transferServiceMock{}
+	rr := serveTransfer(transfer, http.MethodPost, "/messages/import", `{"title":"title","body":"body"}`)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, services.ImportOptions{Format: services.ExportFormatNDJSON, OnConflict: services.ImportConflictFail}, transfer.options)
+	assert.Equal(t, `{"title":"title","body":"body"}`, transfer.imported)
+	assert.Contains(t, rr.Body.String(), `"status":"created"`)
+}
+
+func TestImportMessages_WithoutEvents(t *testing.T) {
+	transfer := &transferServiceMock{}
+	rr := serveTransfer(transfer, http.MethodPost, "/messages/import?format=csv&on_conflict=upsert&events=false", "title,body\n")
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, services.ImportOptions{Format: services.ExportFormatCSV, OnConflict: services.ImportConflictUpsert, SuppressEvents: true}, transfer.options)
+}
+
+func TestImportMessages_InvalidEvents(t *testing.T) {
+	transfer := &transferServiceMock{}
+	rr := serveTransfer(transfer, http.MethodPost, "/messages/import?events=maybe", "")
+
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "", transfer.options.Format)
+}
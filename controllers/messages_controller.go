@@ -3,7 +3,9 @@ package controllers
 import (
 	"github.com/gin-gonic/gin"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"testing-project/domain"
 	"testing-project/services"
 	"testing-project/utils/error_utils"
@@ -17,20 +19,210 @@ func getMessageId(msgIdParam string) (int64, error_utils.MessageErr) {
 	return msgId, nil
 }
 
+// renderHTML reports whether the client asked for body_html with
+// ?render=html.
+func renderHTML(c *gin.Context) (bool, error_utils.MessageErr) {
+	switch c.Query("render") {
+	case "":
+		return false, nil
+	case "html":
+		return true, nil
+	default:
+		return false, error_utils.NewBadRequestError("render must be html")
+	}
+}
+
+// GetMessage reads a message in the locale that best matches the request's
+// Accept-Language, which is echoed in Content-Language.
 func GetMessage(c *gin.Context) {
 	msgId, err := getMessageId(c.Param("message_id"))
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
 	}
-	message, getErr := services.MessagesService.GetMessage(msgId)
+	render, err := renderHTML(c)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	message, getErr := services.MessagesService.GetMessage(c.Request.Context(), msgId)
+	if getErr != nil {
+		c.JSON(getErr.Status(), getErr)
+		return
+	}
+	respondWithMessage(c, message, render)
+}
+
+// GetMessageBySlug looks a message up by its slug. A slug the message had
+// before its title changed is answered with a permanent redirect to the
+// current one.
+func GetMessageBySlug(c *gin.Context) {
+	render, err := renderHTML(c)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	slug := c.Param("slug")
+	message, getErr := services.MessagesService.GetMessageBySlug(c.Request.Context(), slug)
 	if getErr != nil {
 		c.JSON(getErr.Status(), getErr)
 		return
 	}
+	if message.Slug != slug {
+		target := url.URL{Path: "/messages/by-slug/" + message.Slug, RawQuery: c.Request.URL.RawQuery}
+		c.Redirect(http.StatusMovedPermanently, target.String())
+		return
+	}
+	respondWithMessage(c, message, render)
+}
+
+// respondWithMessage answers with message in the language negotiated from
+// Accept-Language, its body rendered to HTML if render is set.
+func respondWithMessage(c *gin.Context, message *domain.Message, render bool) {
+	if err := services.TranslationsService.LocalizeMessage(c.Request.Context(), message, c.GetHeader("Accept-Language")); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if render {
+		services.RenderBody(message)
+	}
+	c.Header("Content-Language", message.Locale)
+	c.Header("Vary", "Accept-Language")
 	c.JSON(http.StatusOK, message)
 }
 
+// ListMessages lists the caller's tenant's messages, optionally narrowed with
+// ?tag= (repeatable, all must match), ?category=, ?status= and ?meta.<key>=,
+// and paged with ?limit= and ?offset=. ?order=pinned_first lists the pinned
+// messages of the category, or of the tenant, first.
+func ListMessages(c *gin.Context) {
+	filter := domain.MessageFilter{
+		Tags:     c.QueryArray("tag"),
+		Category: c.Query("category"),
+		Status:   c.Query("status"),
+		Metadata: metadataFilter(c),
+	}
+	var err error
+	if filter.Limit, err = queryInt(c, "limit"); err != nil {
+		theErr := error_utils.NewBadRequestError("limit should be a number")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	if filter.Offset, err = queryInt(c, "offset"); err != nil {
+		theErr := error_utils.NewBadRequestError("offset should be a number")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	switch c.Query("order") {
+	case "", "newest":
+	case "pinned_first":
+		filter.PinnedFirst = true
+	default:
+		theErr := error_utils.NewBadRequestError("order must be newest or pinned_first")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	render, renderErr := renderHTML(c)
+	if renderErr != nil {
+		c.JSON(renderErr.Status(), renderErr)
+		return
+	}
+	messages, listErr := services.MessagesService.ListMessages(c.Request.Context(), filter)
+	if listErr != nil {
+		c.JSON(listErr.Status(), listErr)
+		return
+	}
+	if render {
+		services.RenderBodies(messages)
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// metadataFilter collects the ?meta.<key>=<value> parameters of a listing.
+func metadataFilter(c *gin.Context) map[string]string {
+	var filter map[string]string
+	for param, values := range c.Request.URL.Query() {
+		if key := strings.TrimPrefix(param, "meta."); key != param && len(values) > 0 {
+			if filter == nil {
+				filter = make(map[string]string)
+			}
+			filter[key] = values[len(values)-1]
+		}
+	}
+	return filter
+}
+
+func ListTags(c *gin.Context) {
+	tags, err := services.MessagesService.ListTags(c.Request.Context())
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// GetThread returns a message with its replies nested under it, ?depth=
+// levels down.
+func GetThread(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	depth, depthErr := queryInt(c, "depth")
+	if depthErr != nil {
+		theErr := error_utils.NewBadRequestError("depth should be a number")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	render, err := renderHTML(c)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	thread, getErr := services.MessagesService.GetThread(c.Request.Context(), msgId, depth)
+	if getErr != nil {
+		c.JSON(getErr.Status(), getErr)
+		return
+	}
+	if render {
+		services.RenderBody(thread)
+		services.RenderBodies(thread.Replies)
+	}
+	c.JSON(http.StatusOK, thread)
+}
+
+// GetSimilarMessages lists the messages whose body duplicates, or nearly
+// duplicates, that of the message, closest first. ?limit= caps the list.
+func GetSimilarMessages(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	limit, limitErr := queryInt(c, "limit")
+	if limitErr != nil {
+		theErr := error_utils.NewBadRequestError("limit should be a number")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	similar, getErr := services.MessagesService.SimilarMessages(c.Request.Context(), msgId, limit)
+	if getErr != nil {
+		c.JSON(getErr.Status(), getErr)
+		return
+	}
+	c.JSON(http.StatusOK, similar)
+}
+
+// queryInt reads an optional integer query parameter, 0 when absent.
+func queryInt(c *gin.Context, name string) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(raw)
+}
+
 func CreateMessage(c *gin.Context) {
 	var message domain.Message
 	if err := c.ShouldBindJSON(&message); err != nil {
@@ -38,7 +230,7 @@ func CreateMessage(c *gin.Context) {
 		c.JSON(theErr.Status(), theErr)
 		return
 	}
-	msg, err := services.MessagesService.CreateMessage(&message)
+	msg, err := services.MessagesService.CreateMessage(c.Request.Context(), &message)
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
@@ -59,7 +251,7 @@ func UpdateMessage(c *gin.Context) {
 		return
 	}
 	message.Id = msgId
-	msg, err := services.MessagesService.UpdateMessage(&message)
+	msg, err := services.MessagesService.UpdateMessage(c.Request.Context(), &message)
 	if err != nil {
 		c.JSON(err.Status(), err)
 		return
@@ -73,7 +265,7 @@ func DeleteMessage(c *gin.Context) {
 		c.JSON(err.Status(), err)
 		return
 	}
-	if err := services.MessagesService.DeleteMessage(msgId); err != nil {
+	if err := services.MessagesService.DeleteMessage(c.Request.Context(), msgId); err != nil {
 		c.JSON(err.Status(), err)
 		return
 	}
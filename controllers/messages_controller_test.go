@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -11,6 +12,7 @@ import (
 	"testing-project/domain"
 	"testing-project/services"
 	"testing-project/utils/error_utils"
+	"time"
 )
 
 var (
@@ -19,22 +21,46 @@ var (
 	updateMessageService func(message *domain.Message) (*domain.Message, error_utils.MessageErr)
 	deleteMessageService func(msgId int64) error_utils.MessageErr
 	getAllMessageService func() ([]domain.Message, error_utils.MessageErr)
+	transitionService    func(msgId int64, status string, publishAt *time.Time) (*domain.Message, error_utils.MessageErr)
+	listMessagesService  func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr)
+	listTagsService      func() ([]domain.TagCount, error_utils.MessageErr)
+	getThreadService     func(msgId int64, depth int) (*domain.Message, error_utils.MessageErr)
+	getBySlugService     func(slug string) (*domain.Message, error_utils.MessageErr)
+	similarService       func(msgId int64, limit int) ([]domain.SimilarMessage, error_utils.MessageErr)
 )
 
 type serviceMock struct{}
 
-func (sm *serviceMock) GetMessage(msgId int64) (*domain.Message, error_utils.MessageErr) {
+func (sm *serviceMock) GetMessage(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
 	return getMessageService(msgId)
 }
-func (sm *serviceMock) CreateMessage(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+func (sm *serviceMock) GetMessageBySlug(ctx context.Context, slug string) (*domain.Message, error_utils.MessageErr) {
+	return getBySlugService(slug)
+}
+func (sm *serviceMock) SimilarMessages(ctx context.Context, msgId int64, limit int) ([]domain.SimilarMessage, error_utils.MessageErr) {
+	return similarService(msgId, limit)
+}
+func (sm *serviceMock) CreateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 	return createMessageService(message)
 }
-func (sm *serviceMock) UpdateMessage(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+func (sm *serviceMock) UpdateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 	return updateMessageService(message)
 }
-func (sm *serviceMock) DeleteMessage(msgId int64) error_utils.MessageErr {
+func (sm *serviceMock) DeleteMessage(ctx context.Context, msgId int64) error_utils.MessageErr {
 	return deleteMessageService(msgId)
 }
+func (sm *serviceMock) ListMessages(ctx context.Context, filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+	return listMessagesService(filter)
+}
+func (sm *serviceMock) ListTags(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+	return listTagsService()
+}
+func (sm *serviceMock) GetThread(ctx context.Context, msgId int64, depth int) (*domain.Message, error_utils.MessageErr) {
+	return getThreadService(msgId, depth)
+}
+func (sm *serviceMock) TransitionMessage(ctx context.Context, msgId int64, status string, publishAt *time.Time) (*domain.Message, error_utils.MessageErr) {
+	return transitionService(msgId, status, publishAt)
+}
 
 // "GetMessage" test cases
 
@@ -64,6 +90,87 @@ func TestGetMessage_Success(t *testing.T) {
 	assert.EqualValues(t, "the body", message.Body)
 }
 
+func TestGetMessageBySlug_CurrentSlug(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	getBySlugService = func(slug string) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 4, Title: "Launch day", Slug: "launch-day", Body: "the body"}, nil
+	}
+	r := gin.Default()
+	r.GET("/messages/by-slug/:slug", GetMessageBySlug)
+	req, _ := http.NewRequest(http.MethodGet, "/messages/by-slug/launch-day", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var message domain.Message
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &message))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, 4, message.Id)
+	assert.Equal(t, "launch-day", message.Slug)
+}
+
+func TestGetMessageBySlug_OldSlugRedirects(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	getBySlugService = func(slug string) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 4, Title: "Launch day", Slug: "launch-day", Body: "the body"}, nil
+	}
+	r := gin.Default()
+	r.GET("/messages/by-slug/:slug", GetMessageBySlug)
+	req, _ := http.NewRequest(http.MethodGet, "/messages/by-slug/launch?render=html", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusMovedPermanently, rr.Code)
+	assert.Equal(t, "/messages/by-slug/launch-day?render=html", rr.Header().Get("Location"))
+}
+
+func TestGetMessageBySlug_NotFound(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	getBySlugService = func(slug string) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	r := gin.Default()
+	r.GET("/messages/by-slug/:slug", GetMessageBySlug)
+	req, _ := http.NewRequest(http.MethodGet, "/messages/by-slug/nope", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+}
+
+func TestGetSimilarMessages_Success(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	var limit int
+	similarService = func(msgId int64, l int) ([]domain.SimilarMessage, error_utils.MessageErr) {
+		limit = l
+		return []domain.SimilarMessage{{Message: domain.Message{Id: 4, Title: "copy", Body: "the body"}, Exact: true}}, nil
+	}
+	r := gin.Default()
+	r.GET("/messages/:message_id/similar", GetSimilarMessages)
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1/similar?limit=5", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var similar []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &similar))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 5, limit)
+	assert.Equal(t, 1, len(similar))
+	assert.EqualValues(t, 4, similar[0]["id"])
+	assert.Equal(t, true, similar[0]["exact"])
+	assert.EqualValues(t, 0, similar[0]["distance"])
+}
+
+func TestGetSimilarMessages_InvalidLimit(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	r := gin.Default()
+	r.GET("/messages/:message_id/similar", GetSimilarMessages)
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1/similar?limit=many", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+}
+
 func TestGetMessage_Invalid_Id(t *testing.T) {
 	msgId := "abc"
 	r := gin.Default()
@@ -440,3 +547,173 @@ func TestDeleteMessage_Failure(t *testing.T) {
 	assert.EqualValues(t, "error deleting message", apiErr.Message())
 	assert.EqualValues(t, "server_error", apiErr.Error())
 }
+
+// "ListMessages" test cases
+
+func TestListMessages_ParsesFilter(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	var got domain.MessageFilter
+	listMessagesService = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		got = filter
+		return []domain.Message{{Id: 1, Title: "the title", Tags: []string{"go"}}}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages?tag=go&tag=sql&category=news&status=draft&limit=20&offset=40", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages", ListMessages)
+	r.ServeHTTP(rr, req)
+
+	var messages []domain.Message
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &messages))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, domain.MessageFilter{Tags: []string{"go", "sql"}, Category: "news", Status: "draft", Limit: 20, Offset: 40}, got)
+}
+
+func TestListMessages_ParsesMetadataFilter(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	var got domain.MessageFilter
+	listMessagesService = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		got = filter
+		return []domain.Message{}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages?meta.priority=high&meta.team=ops&metadata=x", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages", ListMessages)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, map[string]string{"priority": "high", "team": "ops"}, got.Metadata)
+}
+
+func TestListMessages_Order(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	var got domain.MessageFilter
+	listMessagesService = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		got = filter
+		return []domain.Message{}, nil
+	}
+	r := gin.Default()
+	r.GET("/messages", ListMessages)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/messages?order=pinned_first&category=news", nil)
+	r.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.True(t, got.PinnedFirst)
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodGet, "/messages?order=oldest", nil)
+	r.ServeHTTP(rr, req)
+	assert.EqualValues(t, http.StatusBadRequest, rr.Code)
+}
+
+func TestListMessages_Invalid_Limit(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages?limit=ten", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages", ListMessages)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "limit should be a number", apiErr.Message())
+}
+
+func TestListTags_Success(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	listTagsService = func() ([]domain.TagCount, error_utils.MessageErr) {
+		return []domain.TagCount{{Name: "go", Count: 3}}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/tags", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/tags", ListTags)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[{"name":"go","count":3}]`, rr.Body.String())
+}
+
+// "GetThread" test cases
+
+func TestGetThread_Success(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	var gotDepth int
+	getThreadService = func(msgId int64, depth int) (*domain.Message, error_utils.MessageErr) {
+		gotDepth = depth
+		parentId := msgId
+		return &domain.Message{Id: msgId, ReplyCount: 1, Replies: []domain.Message{{Id: 2, ParentId: &parentId}}}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1/thread?depth=4", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/thread", GetThread)
+	r.ServeHTTP(rr, req)
+
+	var thread domain.Message
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &thread))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 4, gotDepth)
+	assert.Equal(t, 1, thread.ReplyCount)
+	assert.EqualValues(t, 1, *thread.Replies[0].ParentId)
+}
+
+func TestGetThread_Invalid_Depth(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1/thread?depth=all", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages/:message_id/thread", GetThread)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "depth should be a number", apiErr.Message())
+}
+
+func TestListMessages_RenderHtml(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	listMessagesService = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1, Version: 1, Format: domain.MessageFormatMarkdown, Body: "**hi** <script>"}}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages?render=html", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages", ListMessages)
+	r.ServeHTTP(rr, req)
+
+	var messages []domain.Message
+	assert.Nil(t, json.Unmarshal(rr.Body.Bytes(), &messages))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "<p><strong>hi</strong> &lt;script&gt;</p>\n", messages[0].BodyHtml)
+}
+
+func TestListMessages_NoRender_OmitsHtml(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	listMessagesService = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 1, Version: 1, Format: domain.MessageFormatMarkdown, Body: "**hi**"}}, nil
+	}
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages", ListMessages)
+	r.ServeHTTP(rr, req)
+
+	assert.NotContains(t, rr.Body.String(), "body_html")
+}
+
+func TestListMessages_Invalid_Render(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages?render=pdf", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/messages", ListMessages)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "render must be html", apiErr.Message())
+}
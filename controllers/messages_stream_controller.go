@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+var streamHeartbeatInterval = 15 * time.Second
+
+// StreamMessages pushes changes to the caller's tenant's messages as
+// Server-Sent Events.
+//
+// Clients may restrict the stream with ?events=created,updated and resume
+// after a disconnect with the Last-Event-ID header (or the last_event_id
+// query parameter, for EventSource polyfills that cannot set headers).
+func StreamMessages(c *gin.Context) {
+	lastEventId, err := getLastEventId(c)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	var types []string
+	if events := c.Query("events"); events != "" {
+		for _, eventType := range strings.Split(events, ",") {
+			if eventType = strings.TrimSpace(eventType); eventType != "" {
+				types = append(types, eventType)
+			}
+		}
+	}
+
+	sub := services.MessageEvents.Subscribe(tenant_utils.TenantFrom(c.Request.Context()), lastEventId, types)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	for _, event := range sub.Replay {
+		writeMessageEvent(c.Writer, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events:
+			if !ok {
+				return
+			}
+			writeMessageEvent(c.Writer, event)
+		case <-heartbeat.C:
+			io.WriteString(c.Writer, ": ping\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+func getLastEventId(c *gin.Context) (int64, error_utils.MessageErr) {
+	raw := c.GetHeader("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, error_utils.NewBadRequestError("last event id should be a number")
+	}
+	return id, nil
+}
+
+func writeMessageEvent(w io.Writer, event services.MessageEvent) {
+	sse.Encode(w, sse.Event{
+		Id:    strconv.FormatInt(event.Id, 10),
+		Event: event.Type,
+		Data:  event.Message,
+	})
+}
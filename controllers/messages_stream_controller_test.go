@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+func serveStream(t *testing.T, target string, lastEventId string, publish func()) *httptest.ResponseRecorder {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := gin.Default()
+	r.GET("/messages/stream", tenant_utils.Middleware(), StreamMessages)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	req.Header.Set(tenant_utils.TenantHeader, "acme")
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	rr := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		r.ServeHTTP(rr, req)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	publish()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+	return rr
+}
+
+func TestStreamMessages_PushesEvents(t *testing.T) {
+	services.MessageEvents = services.NewMessageEventsHub(8)
+
+	rr := serveStream(t, "/messages/stream", "", func() {
+		services.MessageEvents.Publish(services.MessageEventCreated, &domain.Message{Id: 1, TenantId: "acme", Title: "the title"})
+	})
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	body := rr.Body.String()
+	assert.Contains(t, body, "id:1\n")
+	assert.Contains(t, body, "event:created\n")
+	assert.Contains(t, body, `"title":"the title"`)
+}
+
+func TestStreamMessages_FiltersAndResumes(t *testing.T) {
+	services.MessageEvents = services.NewMessageEventsHub(8)
+	services.MessageEvents.Publish(services.MessageEventCreated, &domain.Message{Id: 1, TenantId: "acme"})
+	services.MessageEvents.Publish(services.MessageEventUpdated, &domain.Message{Id: 1, TenantId: "acme", Title: "replayed"})
+
+	rr := serveStream(t, "/messages/stream?events=updated,deleted", "1", func() {
+		services.MessageEvents.Publish(services.MessageEventCreated, &domain.Message{Id: 2, TenantId: "acme"})
+		services.MessageEvents.Publish(services.MessageEventDeleted, &domain.Message{Id: 1, TenantId: "acme"})
+	})
+
+	body := rr.Body.String()
+	assert.Contains(t, body, "id:2\nevent:updated\n")
+	assert.Contains(t, body, `"title":"replayed"`)
+	assert.Contains(t, body, "id:4\nevent:deleted\n")
+	assert.NotContains(t, body, "event:created")
+	assert.True(t, strings.Index(body, "id:2") < strings.Index(body, "id:4"))
+}
+
+func TestStreamMessages_OnlyOwnTenant(t *testing.T) {
+	services.MessageEvents = services.NewMessageEventsHub(8)
+
+	rr := serveStream(t, "/messages/stream", "", func() {
+		services.MessageEvents.Publish(services.MessageEventCreated, &domain.Message{Id: 1, TenantId: "other", Title: "not yours"})
+		services.MessageEvents.Publish(services.MessageEventCreated, &domain.Message{Id: 2, TenantId: "acme", Title: "yours"})
+	})
+
+	body := rr.Body.String()
+	assert.NotContains(t, body, "not yours")
+	assert.Contains(t, body, "id:2\n")
+	assert.Contains(t, body, `"title":"yours"`)
+}
+
+func TestStreamMessages_Invalid_LastEventId(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/messages/stream", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	rr := httptest.NewRecorder()
+	r.GET("/messages/stream", StreamMessages)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.NotNil(t, apiErr)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "last event id should be a number", apiErr.Message())
+}
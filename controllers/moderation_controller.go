@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type decisionRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ListModerationQueue lists the messages held for review, paged with
+// ?limit= and ?offset=.
+func ListModerationQueue(c *gin.Context) {
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		theErr := error_utils.NewBadRequestError("limit should be a number")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	offset, err := queryInt(c, "offset")
+	if err != nil {
+		theErr := error_utils.NewBadRequestError("offset should be a number")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	messages, listErr := services.ModerationService.ListQueue(c.Request.Context(), limit, offset)
+	if listErr != nil {
+		c.JSON(listErr.Status(), listErr)
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// GetModeration shows why a message was held and what was decided.
+func GetModeration(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	moderation, getErr := services.ModerationService.GetModeration(c.Request.Context(), msgId)
+	if getErr != nil {
+		c.JSON(getErr.Status(), getErr)
+		return
+	}
+	c.JSON(http.StatusOK, moderation)
+}
+
+// ApproveMessage lets a held message go live, with an optional JSON body
+// giving the reason.
+func ApproveMessage(c *gin.Context) {
+	decideMessage(c, services.ModerationService.ApproveMessage)
+}
+
+// RejectMessage keeps a held message from going live. The JSON body must
+// give the reason.
+func RejectMessage(c *gin.Context) {
+	decideMessage(c, services.ModerationService.RejectMessage)
+}
+
+func decideMessage(c *gin.Context, decide func(context.Context, int64, string) (*domain.Message, error_utils.MessageErr)) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	var request decisionRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+			c.JSON(theErr.Status(), theErr)
+			return
+		}
+	}
+	message, decideErr := decide(c.Request.Context(), msgId, request.Reason)
+	if decideErr != nil {
+		c.JSON(decideErr.Status(), decideErr)
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type moderationServiceMock struct {
+	decision string
+	reason   string
+	limit    int
+}
+
+func (m *moderationServiceMock) ListQueue(ctx context.Context, limit int, offset int) ([]domain.Message, error_utils.MessageErr) {
+	m.limit = limit
+	return []domain.Message{{Id: 3, Status: domain.MessageStatusPendingReview}}, nil
+}
+func (m *moderationServiceMock) GetModeration(ctx context.Context, msgId int64) (*domain.Moderation, error_utils.MessageErr) {
+	return nil, error_utils.NewNotFoundError("no record matching given id")
+}
+func (m *moderationServiceMock) ApproveMessage(ctx context.Context, msgId int64, reason string) (*domain.Message, error_utils.MessageErr) {
+	m.decision, m.reason = domain.ModerationApproved, reason
+	return &domain.Message{Id: msgId, Status: domain.MessageStatusPublished}, nil
+}
+func (m *moderationServiceMock) RejectMessage(ctx context.Context, msgId int64, reason string) (*domain.Message, error_utils.MessageErr) {
+	m.decision, m.reason = domain.ModerationRejected, reason
+	return &domain.Message{Id: msgId, Status: domain.MessageStatusRejected}, nil
+}
+
+func withModerationService() *moderationServiceMock {
+	moderation := &moderationServiceMock{}
+	services.ModerationService = moderation
+	return moderation
+}
+
+func TestApproveMessage_WithoutBody(t *testing.T) {
+	moderation := withModerationService()
+	r := gin.Default()
+	r.POST("/messages/:message_id/approve", ApproveMessage)
+	req, _ := http.NewRequest(http.MethodPost, "/messages/3/approve", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, domain.ModerationApproved, moderation.decision)
+	assert.Equal(t, "", moderation.reason)
+}
+
+func TestRejectMessage_PassesReason(t *testing.T) {
+	moderation := withModerationService()
+	r := gin.Default()
+	r.POST("/messages/:message_id/reject", RejectMessage)
+	req, _ := http.NewRequest(http.MethodPost, "/messages/3/reject", strings.NewReader(`{"reason":"advertising"}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, domain.ModerationRejected, moderation.decision)
+	assert.Equal(t, "advertising", moderation.reason)
+}
+
+func TestRejectMessage_InvalidBody(t *testing.T) {
+	moderation := withModerationService()
+	r := gin.Default()
+	r.POST("/messages/:message_id/reject", RejectMessage)
+	req, _ := http.NewRequest(http.MethodPost, "/messages/3/reject", strings.NewReader(`{"reason":`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusUnprocessableEntity, rr.Code)
+	assert.Equal(t, "", moderation.decision)
+}
+
+func TestListModerationQueue(t *testing.T) {
+	moderation := withModerationService()
+	r := gin.Default()
+	r.GET("/moderation/queue", ListModerationQueue)
+	req, _ := http.NewRequest(http.MethodGet, "/moderation/queue?limit=5", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 5, moderation.limit)
+	assert.Contains(t, rr.Body.String(), `"status":"pending_review"`)
+}
+
+func TestGetModeration_NotFound(t *testing.T) {
+	withModerationService()
+	r := gin.Default()
+	r.GET("/messages/:message_id/moderation", GetModeration)
+	req, _ := http.NewRequest(http.MethodGet, "/messages/3/moderation", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type pinRequest struct {
+	Category string `json:"category"`
+	Position int    `json:"position"`
+}
+
+type reorderPinsRequest struct {
+	Category   string  `json:"category"`
+	MessageIds []int64 `json:"message_ids"`
+}
+
+// ListPinned lists the pinned messages of ?category=, or of the tenant when
+// it is omitted, in order.
+func ListPinned(c *gin.Context) {
+	messages, err := services.PinsService.ListPinned(c.Request.Context(), c.Query("category"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// PinMessage pins a message from an optional JSON body naming the category
+// and the position; without one the message is pinned tenant wide, last.
+func PinMessage(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	var request pinRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+			c.JSON(theErr.Status(), theErr)
+			return
+		}
+	}
+	messages, pinErr := services.PinsService.PinMessage(c.Request.Context(), msgId, request.Category, request.Position)
+	if pinErr != nil {
+		c.JSON(pinErr.Status(), pinErr)
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+// UnpinMessage removes the pin of a message in ?category=, or its tenant
+// wide pin when it is omitted.
+func UnpinMessage(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if unpinErr := services.PinsService.UnpinMessage(c.Request.Context(), msgId, c.Query("category")); unpinErr != nil {
+		c.JSON(unpinErr.Status(), unpinErr)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{"status": "unpinned"})
+}
+
+// ReorderPins sets the order of all the pins of a category at once.
+func ReorderPins(c *gin.Context) {
+	var request reorderPinsRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	messages, err := services.PinsService.ReorderPins(c.Request.Context(), request.Category, request.MessageIds)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
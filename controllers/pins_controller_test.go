@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type pinsServiceMock struct {
+	category string
+	position int
+	order    []int64
+}
+
+func (m *pinsServiceMock) ListPinned(ctx context.Context, category string) ([]domain.Message, error_utils.MessageErr) {
+	m.category = category
+	return []domain.Message{}, nil
+}
+func (m *pinsServiceMock) PinMessage(ctx context.Context, msgId int64, category string, position int) ([]domain.Message, error_utils.MessageErr) {
+	m.category = category
+	m.position = position
+	return []domain.Message{{Id: msgId}}, nil
+}
+func (m *pinsServiceMock) UnpinMessage(ctx context.Context, msgId int64, category string) error_utils.MessageErr {
+	m.category = category
+	return nil
+}
+func (m *pinsServiceMock) ReorderPins(ctx context.Context, category string, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	m.category = category
+	m.order = msgIds
+	return []domain.Message{}, nil
+}
+
+func TestPinMessage_WithoutBody(t *testing.T) {
+	pins := &pinsServiceMock{}
+	services.PinsService = pins
+	r := gin.Default()
+	r.POST("/messages/:message_id/pin", PinMessage)
+	req, _ := http.NewRequest(http.MethodPost, "/messages/4/pin", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "", pins.category)
+	assert.Equal(t, 0, pins.position)
+}
+
+func TestPinMessage_WithCategoryAndPosition(t *testing.T) {
+	pins := &pinsServiceMock{}
+	services.PinsService = pins
+	r := gin.Default()
+	r.POST("/messages/:message_id/pin", PinMessage)
+	req, _ := http.NewRequest(http.MethodPost, "/messages/4/pin", strings.NewReader(`{"category":"news","position":1}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "news", pins.category)
+	assert.Equal(t, 1, pins.position)
+}
+
+func TestReorderPins(t *testing.T) {
+	pins := &pinsServiceMock{}
+	services.PinsService = pins
+	r := gin.Default()
+	r.PUT("/messages/pinned/order", ReorderPins)
+	req, _ := http.NewRequest(http.MethodPut, "/messages/pinned/order", strings.NewReader(`{"message_ids":[8,7]}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, []int64{8, 7}, pins.order)
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/services"
+)
+
+// AddReaction reacts to a message as the caller with the emoji in the path.
+func AddReaction(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	message, reactErr := services.ReactionsService.AddReaction(c.Request.Context(), msgId, c.Param("emoji"))
+	if reactErr != nil {
+		c.JSON(reactErr.Status(), reactErr)
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+func RemoveReaction(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	message, reactErr := services.ReactionsService.RemoveReaction(c.Request.Context(), msgId, c.Param("emoji"))
+	if reactErr != nil {
+		c.JSON(reactErr.Status(), reactErr)
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
+
+// MarkRead records that the caller has read a message.
+func MarkRead(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	message, readErr := services.ReactionsService.MarkRead(c.Request.Context(), msgId)
+	if readErr != nil {
+		c.JSON(readErr.Status(), readErr)
+		return
+	}
+	c.JSON(http.StatusOK, message)
+}
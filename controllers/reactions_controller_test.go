@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type reactionsServiceMock struct {
+	emoji string
+}
+
+func (m *reactionsServiceMock) AddReaction(ctx context.Context, msgId int64, emoji string) (*domain.Message, error_utils.MessageErr) {
+	m.emoji = emoji
+	return &domain.Message{Id: msgId, Reactions: map[string]int{emoji: 1}}, nil
+}
+func (m *reactionsServiceMock) RemoveReaction(ctx context.Context, msgId int64, emoji string) (*domain.Message, error_utils.MessageErr) {
+	m.emoji = emoji
+	return &domain.Message{Id: msgId}, nil
+}
+func (m *reactionsServiceMock) MarkRead(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+	return nil, error_utils.NewNotFoundError("no record matching given id")
+}
+
+func TestAddReaction_DecodesEmoji(t *testing.T) {
+	reactions := &reactionsServiceMock{}
+	services.ReactionsService = reactions
+	r := gin.Default()
+	r.PUT("/messages/:message_id/reactions/:emoji", AddReaction)
+	req, _ := http.NewRequest(http.MethodPut, "/messages/4/reactions/"+url.PathEscape("👍"), nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "👍", reactions.emoji)
+	var message domain.Message
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &message))
+	assert.Equal(t, map[string]int{"👍": 1}, message.Reactions)
+}
+
+func TestMarkRead_NotFound(t *testing.T) {
+	services.ReactionsService = &reactionsServiceMock{}
+	r := gin.Default()
+	r.POST("/messages/:message_id/read", MarkRead)
+	req, _ := http.NewRequest(http.MethodPost, "/messages/4/read", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+}
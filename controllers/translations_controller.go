@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+func ListTranslations(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	translations, listErr := services.TranslationsService.ListTranslations(c.Request.Context(), msgId)
+	if listErr != nil {
+		c.JSON(listErr.Status(), listErr)
+		return
+	}
+	c.JSON(http.StatusOK, translations)
+}
+
+// PutTranslation creates or replaces the translation for the locale in the
+// path from a JSON body with a title and a body.
+func PutTranslation(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	var translation domain.Translation
+	if err := c.ShouldBindJSON(&translation); err != nil {
+		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	translation.Locale = c.Param("locale")
+	saved, putErr := services.TranslationsService.PutTranslation(c.Request.Context(), msgId, &translation)
+	if putErr != nil {
+		c.JSON(putErr.Status(), putErr)
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+func DeleteTranslation(c *gin.Context) {
+	msgId, err := getMessageId(c.Param("message_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if deleteErr := services.TranslationsService.DeleteTranslation(c.Request.Context(), msgId, c.Param("locale")); deleteErr != nil {
+		c.JSON(deleteErr.Status(), deleteErr)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
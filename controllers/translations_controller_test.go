@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+type translationsServiceMock struct {
+	acceptLanguage string
+	put            *domain.Translation
+}
+
+func (m *translationsServiceMock) LocalizeMessage(ctx context.Context, msg *domain.Message, acceptLanguage string) error_utils.MessageErr {
+	m.acceptLanguage = acceptLanguage
+	msg.Title = "Bonjour"
+	msg.Locale = "fr"
+	return nil
+}
+func (m *translationsServiceMock) ListTranslations(ctx context.Context, msgId int64) ([]domain.Translation, error_utils.MessageErr) {
+	return []domain.Translation{}, nil
+}
+func (m *translationsServiceMock) PutTranslation(ctx context.Context, msgId int64, translation *domain.Translation) (*domain.Translation, error_utils.MessageErr) {
+	translation.MessageId = msgId
+	m.put = translation
+	return translation, nil
+}
+func (m *translationsServiceMock) DeleteTranslation(ctx context.Context, msgId int64, locale string) error_utils.MessageErr {
+	return error_utils.NewNotFoundError("no translation for given locale")
+}
+
+func TestGetMessage_NegotiatesLocale(t *testing.T) {
+	services.MessagesService = &serviceMock{}
+	translations := &translationsServiceMock{}
+	previous := services.TranslationsService
+	defer func() { services.TranslationsService = previous }()
+	services.TranslationsService = translations
+	getMessageService = func(msgId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 1, Title: "Hello", Body: "world"}, nil
+	}
+	r := gin.Default()
+	r.GET("/messages/:message_id", GetMessage)
+	req, _ := http.NewRequest(http.MethodGet, "/messages/1", nil)
+	req.Header.Set("Accept-Language", "fr-CA, fr;q=0.9")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var message domain.Message
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &message))
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "fr-CA, fr;q=0.9", translations.acceptLanguage)
+	assert.Equal(t, "Bonjour", message.Title)
+	assert.Equal(t, "fr", message.Locale)
+	assert.Equal(t, "fr", rr.Header().Get("Content-Language"))
+	assert.Equal(t, "Accept-Language", rr.Header().Get("Vary"))
+}
+
+func TestPutTranslation_TakesLocaleFromPath(t *testing.T) {
+	translations := &translationsServiceMock{}
+	previous := services.TranslationsService
+	defer func() { services.TranslationsService = previous }()
+	services.TranslationsService = translations
+	r := gin.Default()
+	r.PUT("/messages/:message_id/translations/:locale", PutTranslation)
+	req, _ := http.NewRequest(http.MethodPut, "/messages/4/translations/de", strings.NewReader(`{"title":"Hallo","body":"Welt","locale":"it"}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "de", translations.put.Locale)
+	assert.EqualValues(t, 4, translations.put.MessageId)
+}
+
+func TestDeleteTranslation_NotFound(t *testing.T) {
+	previous := services.TranslationsService
+	defer func() { services.TranslationsService = previous }()
+	services.TranslationsService = &translationsServiceMock{}
+	r := gin.Default()
+	r.DELETE("/messages/:message_id/translations/:locale", DeleteTranslation)
+	req, _ := http.NewRequest(http.MethodDelete, "/messages/4/translations/de", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusNotFound, rr.Code)
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+func getWebhookId(webhookIdParam string) (int64, error_utils.MessageErr) {
+	webhookId, err := strconv.ParseInt(webhookIdParam, 10, 64)
+	if err != nil {
+		return 0, error_utils.NewBadRequestError("webhook id should be a number")
+	}
+	return webhookId, nil
+}
+
+func ListWebhooks(c *gin.Context) {
+	hooks, err := services.WebhooksService.ListWebhooks(c.Request.Context())
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func GetWebhook(c *gin.Context) {
+	webhookId, err := getWebhookId(c.Param("webhook_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	hook, getErr := services.WebhooksService.GetWebhook(c.Request.Context(), webhookId)
+	if getErr != nil {
+		c.JSON(getErr.Status(), getErr)
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+func CreateWebhook(c *gin.Context) {
+	var hook domain.Webhook
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	created, err := services.WebhooksService.CreateWebhook(c.Request.Context(), &hook)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+func UpdateWebhook(c *gin.Context) {
+	webhookId, err := getWebhookId(c.Param("webhook_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	// A webhook stays active unless the request explicitly sets "active": false.
+	hook := domain.Webhook{Active: true}
+	if err := c.ShouldBindJSON(&hook); err != nil {
+		theErr := error_utils.NewUnprocessibleEntityError("invalid json body")
+		c.JSON(theErr.Status(), theErr)
+		return
+	}
+	hook.Id = webhookId
+	updated, err := services.WebhooksService.UpdateWebhook(c.Request.Context(), &hook)
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+func DeleteWebhook(c *gin.Context) {
+	webhookId, err := getWebhookId(c.Param("webhook_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	if err := services.WebhooksService.DeleteWebhook(c.Request.Context(), webhookId); err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+func ListWebhookDeliveries(c *gin.Context) {
+	webhookId, err := getWebhookId(c.Param("webhook_id"))
+	if err != nil {
+		c.JSON(err.Status(), err)
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	deliveries, listErr := services.WebhooksService.ListDeliveries(c.Request.Context(), webhookId, limit)
+	if listErr != nil {
+		c.JSON(listErr.Status(), listErr)
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	createWebhookService func(hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr)
+	updateWebhookService func(hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr)
+)
+
+type webhooksServiceMock struct{}
+
+func (sm *webhooksServiceMock) GetWebhook(ctx context.Context, id int64) (*domain.Webhook, error_utils.MessageErr) {
+	return nil, error_utils.NewNotFoundError("no record matching given id")
+}
+func (sm *webhooksServiceMock) ListWebhooks(ctx context.Context) ([]domain.Webhook, error_utils.MessageErr) {
+	return nil, nil
+}
+func (sm *webhooksServiceMock) CreateWebhook(ctx context.Context, hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+	return createWebhookService(hook)
+}
+func (sm *webhooksServiceMock) UpdateWebhook(ctx context.Context, hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+	return updateWebhookService(hook)
+}
+func (sm *webhooksServiceMock) DeleteWebhook(ctx context.Context, id int64) error_utils.MessageErr {
+	return nil
+}
+func (sm *webhooksServiceMock) ListDeliveries(ctx context.Context, id int64, limit int) ([]domain.WebhookDelivery, error_utils.MessageErr) {
+	return nil, nil
+}
+
+func TestCreateWebhook_Success(t *testing.T) {
+	services.WebhooksService = &webhooksServiceMock{}
+	createWebhookService = func(hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+		hook.Id = 1
+		hook.Secret = "whsec_abc"
+		return hook, nil
+	}
+	jsonBody := `{"url": "https://example.com/hook", "events": ["created"]}`
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPost, "/webhooks", bytes.NewBufferString(jsonBody))
+	rr := httptest.NewRecorder()
+	r.POST("/webhooks", CreateWebhook)
+	r.ServeHTTP(rr, req)
+
+	var hook domain.Webhook
+	err := json.Unmarshal(rr.Body.Bytes(), &hook)
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusCreated, rr.Code)
+	assert.EqualValues(t, 1, hook.Id)
+	assert.EqualValues(t, "whsec_abc", hook.Secret)
+	assert.EqualValues(t, []string{"created"}, hook.Events)
+}
+
+func TestUpdateWebhook_DefaultsToActive(t *testing.T) {
+	services.WebhooksService = &webhooksServiceMock{}
+	var received *domain.Webhook
+	updateWebhookService = func(hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+		received = hook
+		return hook, nil
+	}
+	jsonBody := `{"url": "https://example.com/hook", "events": ["created"]}`
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodPut, "/webhooks/4", bytes.NewBufferString(jsonBody))
+	rr := httptest.NewRecorder()
+	r.PUT("/webhooks/:webhook_id", UpdateWebhook)
+	r.ServeHTTP(rr, req)
+
+	assert.EqualValues(t, http.StatusOK, rr.Code)
+	assert.EqualValues(t, 4, received.Id)
+	assert.True(t, received.Active)
+}
+
+func TestGetWebhook_Invalid_Id(t *testing.T) {
+	r := gin.Default()
+	req, _ := http.NewRequest(http.MethodGet, "/webhooks/abc", nil)
+	rr := httptest.NewRecorder()
+	r.GET("/webhooks/:webhook_id", GetWebhook)
+	r.ServeHTTP(rr, req)
+
+	apiErr, err := error_utils.NewApiErrFromBytes(rr.Body.Bytes())
+	assert.Nil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, apiErr.Status())
+	assert.EqualValues(t, "webhook id should be a number", apiErr.Message())
+}
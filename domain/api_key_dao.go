@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/metrics_utils"
+	"time"
+)
+
+var (
+	ApiKeyRepo apiKeyRepoInterface = &apiKeyRepo{}
+)
+
+const (
+	apiKeyColumns          = "id, name, prefix, hash, scopes, tenant_id, created_by, expires_at, last_used_at, revoked_at, created_at"
+	queryGetApiKey         = "SELECT " + apiKeyColumns + " FROM api_keys WHERE id=?;"
+	queryGetApiKeyByPrefix = "SELECT " + apiKeyColumns + " FROM api_keys WHERE prefix=?;"
+	queryListApiKeys       = "SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id;"
+	queryInsertApiKey      = "INSERT INTO api_keys(name, prefix, hash, scopes, tenant_id, created_by, expires_at, created_at) VALUES(?, ?, ?, ?, ?, ?, ?, ?);"
+	queryRevokeApiKey      = "UPDATE api_keys SET revoked_at=? WHERE id=? AND revoked_at IS NULL;"
+	queryTouchApiKey       = "UPDATE api_keys SET last_used_at=? WHERE id=?;"
+)
+
+type apiKeyRepoInterface interface {
+	Get(context.Context, int64) (*ApiKey, error_utils.MessageErr)
+	GetByPrefix(context.Context, string) (*ApiKey, error_utils.MessageErr)
+	List(context.Context) ([]ApiKey, error_utils.MessageErr)
+	Create(context.Context, *ApiKey) (*ApiKey, error_utils.MessageErr)
+	Revoke(context.Context, int64, time.Time) error_utils.MessageErr
+	TouchLastUsed(context.Context, int64, time.Time) error_utils.MessageErr
+}
+
+type apiKeyRepo struct {
+	db *sql.DB
+}
+
+func NewApiKeyRepository(db *sql.DB) apiKeyRepoInterface {
+	return &apiKeyRepo{db: db}
+}
+
+func scanApiKey(row rowScanner) (*ApiKey, error) {
+	var key ApiKey
+	var scopes string
+	var tenantId, createdBy sql.NullString
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	if err := row.Scan(&key.Id, &key.Name, &key.Prefix, &key.Hash, &scopes, &tenantId, &createdBy,
+		&expiresAt, &lastUsedAt, &revokedAt, &key.CreatedAt); err != nil {
+		return nil, err
+	}
+	key.Scopes = strings.Split(scopes, ",")
+	key.TenantId = tenantId.String
+	key.CreatedBy = createdBy.String
+	key.ExpiresAt = timePtr(expiresAt)
+	key.LastUsedAt = timePtr(lastUsedAt)
+	key.RevokedAt = timePtr(revokedAt)
+	return &key, nil
+}
+
+func (kr *apiKeyRepo) Get(ctx context.Context, keyId int64) (*ApiKey, error_utils.MessageErr) {
+	return kr.getOne(ctx, "get", queryGetApiKey, keyId)
+}
+
+func (kr *apiKeyRepo) GetByPrefix(ctx context.Context, prefix string) (*ApiKey, error_utils.MessageErr) {
+	return kr.getOne(ctx, "get_by_prefix", queryGetApiKeyByPrefix, prefix)
+}
+
+func (kr *apiKeyRepo) getOne(ctx context.Context, operation string, query string, arg interface{}) (*ApiKey, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("apiKeyRepo", operation, time.Now())
+	ctx, span := startRepoSpan(ctx, "apiKeyRepo", operation, query)
+	defer span.End()
+
+	stmt, err := kr.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare api key: %s", err.Error())))
+	}
+	defer stmt.Close()
+
+	key, getErr := scanApiKey(stmt.QueryRowContext(ctx, arg))
+	if getErr != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(getErr))
+	}
+	return key, nil
+}
+
+func (kr *apiKeyRepo) List(ctx context.Context) ([]ApiKey, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("apiKeyRepo", "list", time.Now())
+	ctx, span := startRepoSpan(ctx, "apiKeyRepo", "list", queryListApiKeys)
+	defer span.End()
+
+	rows, err := kr.db.QueryContext(ctx, queryListApiKeys)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list api keys: %s", err.Error())))
+	}
+	defer rows.Close()
+
+	keys := make([]ApiKey, 0)
+	for rows.Next() {
+		key, err := scanApiKey(rows)
+		if err != nil {
+			return nil, recordRepoErr(span, error_formats.ParseError(err))
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	return keys, nil
+}
+
+func (kr *apiKeyRepo) Create(ctx context.Context, key *ApiKey) (*ApiKey, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("apiKeyRepo", "create", time.Now())
+	ctx, span := startRepoSpan(ctx, "apiKeyRepo", "create", queryInsertApiKey)
+	defer span.End()
+
+	stmt, err := kr.db.PrepareContext(ctx, queryInsertApiKey)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare api key to save: %s", err.Error())))
+	}
+	defer stmt.Close()
+
+	insertResult, createErr := stmt.ExecContext(ctx, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, ","),
+		nullString(key.TenantId), nullString(key.CreatedBy), nullTime(key.ExpiresAt), key.CreatedAt)
+	if createErr != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(createErr))
+	}
+	keyId, err := insertResult.LastInsertId()
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save api key: %s", err.Error())))
+	}
+	key.Id = keyId
+	return key, nil
+}
+
+func (kr *apiKeyRepo) Revoke(ctx context.Context, keyId int64, at time.Time) error_utils.MessageErr {
+	return kr.exec(ctx, "revoke", queryRevokeApiKey, at, keyId)
+}
+
+func (kr *apiKeyRepo) TouchLastUsed(ctx context.Context, keyId int64, at time.Time) error_utils.MessageErr {
+	return kr.exec(ctx, "touch_last_used", queryTouchApiKey, at, keyId)
+}
+
+func (kr *apiKeyRepo) exec(ctx context.Context, operation string, query string, args ...interface{}) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("apiKeyRepo", operation, time.Now())
+	ctx, span := startRepoSpan(ctx, "apiKeyRepo", operation, query)
+	defer span.End()
+
+	stmt, err := kr.db.PrepareContext(ctx, query)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare api key statement: %s", err.Error())))
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to update api key: %s", err.Error())))
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"strings"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+type ApiKey struct {
+	Id         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Hash       string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	TenantId   string     `json:"tenant_id,omitempty"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (k *ApiKey) Validate() error_utils.MessageErr {
+	k.Name = strings.TrimSpace(k.Name)
+	if k.Name == "" {
+		return error_utils.NewUnprocessibleEntityError("Please enter a valid name")
+	}
+	if len(k.Scopes) == 0 {
+		return error_utils.NewUnprocessibleEntityError("Please grant at least one scope")
+	}
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+		return error_utils.NewUnprocessibleEntityError("expires_at must be in the future")
+	}
+	return nil
+}
+
+// Usable reports whether the key may authenticate a request at now.
+func (k *ApiKey) Usable(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
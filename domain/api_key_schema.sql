@@ -0,0 +1,14 @@
+  CREATE TABLE `api_keys` (
+  `id` INT NOT NULL AUTO_INCREMENT,
+  `name` VARCHAR(100) NOT NULL,
+  `prefix` VARCHAR(16) NOT NULL,
+  `hash` CHAR(64) NOT NULL,
+  `scopes` VARCHAR(255) NOT NULL,
+  `tenant_id` VARCHAR(64) NULL,
+  `created_by` VARCHAR(255) NULL,
+  `expires_at` TIMESTAMP NULL,
+  `last_used_at` TIMESTAMP NULL,
+  `revoked_at` TIMESTAMP NULL,
+  `created_at` TIMESTAMP NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `prefix_UNIQUE` (`prefix` ASC));
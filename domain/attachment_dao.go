@@ -0,0 +1,205 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+var (
+	AttachmentRepo attachmentRepoInterface = &attachmentRepo{}
+)
+
+const (
+	selectAttachments = "SELECT id, message_id, tenant_id, file_name, content_type, size, blob_key, created_at FROM attachments"
+
+	queryGetAttachment   = selectAttachments + " WHERE id=? AND message_id=? AND tenant_id=? AND deleted_at IS NULL;"
+	queryLockAttachment  = selectAttachments + " WHERE id=? AND message_id=? AND tenant_id=? AND deleted_at IS NULL FOR UPDATE;"
+	queryListAttachments = selectAttachments + " WHERE message_id=? AND tenant_id=? AND deleted_at IS NULL ORDER BY id;"
+	// queryLockAttachedMessage holds off the deletion of the message until
+	// the attachment is saved, so releaseAttachments always sees it.
+	queryLockAttachedMessage = "SELECT id FROM messages WHERE id=? AND tenant_id=? AND deleted_at IS NULL FOR UPDATE;"
+	queryInsertAttachment    = "INSERT INTO attachments(tenant_id, message_id, file_name, content_type, size, blob_key, created_at) VALUES(?, ?, ?, ?, ?, ?, ?);"
+	queryDeleteAttachment    = "UPDATE attachments SET deleted_at=? WHERE id=? AND message_id=? AND tenant_id=? AND deleted_at IS NULL;"
+	queryListDeletedBlobs    = selectAttachments + " WHERE deleted_at IS NOT NULL ORDER BY deleted_at, id LIMIT ?;"
+	queryPurgeAttachment     = "DELETE FROM attachments WHERE id=? AND deleted_at IS NOT NULL;"
+	queryReleaseAttachment   = "UPDATE attachments SET deleted_at=? WHERE message_id=? AND tenant_id=? AND deleted_at IS NULL;"
+)
+
+// attachmentRepoInterface stores attachment metadata; the bytes live in a
+// blob store. Deleting an attachment only marks the row, and the blob
+// cleanup job removes the blob and then the row through ListDeleted and
+// Purge, which work across tenants. Attaching and deleting are audited as
+// changes to the message.
+type attachmentRepoInterface interface {
+	Get(ctx context.Context, messageId int64, attachmentId int64) (*Attachment, error_utils.MessageErr)
+	ListByMessage(ctx context.Context, messageId int64) ([]Attachment, error_utils.MessageErr)
+	Create(context.Context, *Attachment) (*Attachment, error_utils.MessageErr)
+	Delete(ctx context.Context, messageId int64, attachmentId int64) error_utils.MessageErr
+	ListDeleted(ctx context.Context, limit int) ([]Attachment, error_utils.MessageErr)
+	Purge(ctx context.Context, attachmentId int64) error_utils.MessageErr
+}
+
+type attachmentRepo struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) attachmentRepoInterface {
+	return &attachmentRepo{db: db}
+}
+
+func scanAttachment(row rowScanner) (*Attachment, error) {
+	var attachment Attachment
+	if err := row.Scan(&attachment.Id, &attachment.MessageId, &attachment.TenantId, &attachment.FileName, &attachment.ContentType,
+		&attachment.Size, &attachment.BlobKey, &attachment.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (ar *attachmentRepo) Get(ctx context.Context, messageId int64, attachmentId int64) (*Attachment, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("attachmentRepo", "get", time.Now())
+	ctx, span := startRepoSpan(ctx, "attachmentRepo", "get", queryGetAttachment)
+	defer span.End()
+
+	attachment, err := scanAttachment(ar.db.QueryRowContext(ctx, queryGetAttachment, attachmentId, messageId, tenant_utils.TenantFrom(ctx)))
+	if err != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	return attachment, nil
+}
+
+func (ar *attachmentRepo) ListByMessage(ctx context.Context, messageId int64) ([]Attachment, error_utils.MessageErr) {
+	return ar.list(ctx, "list", queryListAttachments, messageId, tenant_utils.TenantFrom(ctx))
+}
+
+// ListDeleted returns up to limit attachments of any tenant that are waiting
+// for their blob to be removed, oldest first.
+func (ar *attachmentRepo) ListDeleted(ctx context.Context, limit int) ([]Attachment, error_utils.MessageErr) {
+	return ar.list(ctx, "list_deleted", queryListDeletedBlobs, limit)
+}
+
+func (ar *attachmentRepo) list(ctx context.Context, operation string, query string, args ...interface{}) ([]Attachment, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("attachmentRepo", operation, time.Now())
+	ctx, span := startRepoSpan(ctx, "attachmentRepo", operation, query)
+	defer span.End()
+
+	rows, err := ar.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list attachments: %s", err.Error())))
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list attachments: %s", err.Error())))
+		}
+		attachments = append(attachments, *attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list attachments: %s", err.Error())))
+	}
+	return attachments, nil
+}
+
+// Create saves the attachment provided its message still exists; a message
+// deleted while the bytes were uploaded is not found.
+func (ar *attachmentRepo) Create(ctx context.Context, attachment *Attachment) (*Attachment, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("attachmentRepo", "create", time.Now())
+	ctx, span := startRepoSpan(ctx, "attachmentRepo", "create", queryInsertAttachment)
+	defer span.End()
+
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save attachment: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	attachment.TenantId = tenant_utils.TenantFrom(ctx)
+	var messageId int64
+	if err := tx.QueryRowContext(ctx, queryLockAttachedMessage, attachment.MessageId, attachment.TenantId).Scan(&messageId); err != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	result, err := tx.ExecContext(ctx, queryInsertAttachment, attachment.TenantId, attachment.MessageId, attachment.FileName,
+		attachment.ContentType, attachment.Size, attachment.BlobKey, attachment.CreatedAt)
+	if err != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	attachmentId, err := result.LastInsertId()
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save attachment: %s", err.Error())))
+	}
+	attachment.Id = attachmentId
+	after, auditErr := auditValue(attachment)
+	if auditErr != nil {
+		return nil, recordRepoErr(span, auditErr)
+	}
+	if err := writeAudit(ctx, tx, AuditActionAttach, attachment.MessageId, attachment.TenantId, nil, after); err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save attachment: %s", err.Error())))
+	}
+	return attachment, nil
+}
+
+// Delete marks the attachment for cleanup; it disappears from reads at once.
+func (ar *attachmentRepo) Delete(ctx context.Context, messageId int64, attachmentId int64) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("attachmentRepo", "delete", time.Now())
+	ctx, span := startRepoSpan(ctx, "attachmentRepo", "delete", queryDeleteAttachment)
+	defer span.End()
+
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete attachment: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	attachment, err := scanAttachment(tx.QueryRowContext(ctx, queryLockAttachment, attachmentId, messageId, tenantId))
+	if err != nil {
+		return recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if _, err := tx.ExecContext(ctx, queryDeleteAttachment, time.Now(), attachmentId, messageId, tenantId); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete attachment: %s", err.Error())))
+	}
+	before, auditErr := auditValue(attachment)
+	if auditErr != nil {
+		return recordRepoErr(span, auditErr)
+	}
+	if err := writeAudit(ctx, tx, AuditActionDetach, messageId, tenantId, before, nil); err != nil {
+		return recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete attachment: %s", err.Error())))
+	}
+	return nil
+}
+
+// Purge removes the row of an attachment whose blob is gone.
+func (ar *attachmentRepo) Purge(ctx context.Context, attachmentId int64) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("attachmentRepo", "purge", time.Now())
+	ctx, span := startRepoSpan(ctx, "attachmentRepo", "purge", queryPurgeAttachment)
+	defer span.End()
+
+	if _, err := ar.db.ExecContext(ctx, queryPurgeAttachment, attachmentId); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to purge attachment: %s", err.Error())))
+	}
+	return nil
+}
+
+// releaseAttachments marks every attachment of a message for cleanup, inside
+// the transaction that deletes the message.
+func releaseAttachments(ctx context.Context, tx *sql.Tx, messageId int64, tenantId string) error_utils.MessageErr {
+	if _, err := tx.ExecContext(ctx, queryReleaseAttachment, time.Now(), messageId, tenantId); err != nil {
+		return error_formats.ParseError(err)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package domain
+
+import (
+	"time"
+)
+
+type Attachment struct {
+	Id          int64     `json:"id"`
+	MessageId   int64     `json:"message_id"`
+	TenantId    string    `json:"-"`
+	FileName    string    `json:"file_name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	BlobKey     string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+}
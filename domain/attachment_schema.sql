@@ -0,0 +1,13 @@
+  CREATE TABLE `attachments` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL,
+  `message_id` INT NOT NULL,
+  `file_name` VARCHAR(255) NOT NULL,
+  `content_type` VARCHAR(100) NOT NULL,
+  `size` BIGINT NOT NULL,
+  `blob_key` VARCHAR(255) NOT NULL,
+  `created_at` TIMESTAMP NULL,
+  `deleted_at` TIMESTAMP NULL,
+  PRIMARY KEY (`id`),
+  INDEX `tenant_message_idx` (`tenant_id` ASC, `message_id` ASC),
+  INDEX `deleted_idx` (`deleted_at` ASC));
@@ -0,0 +1,118 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+func TestAttachmentRepo_Create_SetsTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAttachmentRepository(db)
+
+	tm := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM messages WHERE id=\\? AND tenant_id=\\? AND deleted_at IS NULL FOR UPDATE").WithArgs(4, "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec("INSERT INTO attachments").
+		WithArgs("acme", 4, "photo.png", "image/png", 2048, "acme/4/abc", tm).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	expectAudit(mock, "acme", 4, AuditActionAttach)
+	mock.ExpectCommit()
+
+	attachment, createErr := repo.Create(tenant_utils.WithTenant(context.Background(), "acme"), &Attachment{
+		MessageId: 4, FileName: "photo.png", ContentType: "image/png", Size: 2048, BlobKey: "acme/4/abc", CreatedAt: tm,
+	})
+
+	assert.Nil(t, createErr)
+	assert.EqualValues(t, 9, attachment.Id)
+	assert.Equal(t, "acme", attachment.TenantId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttachmentRepo_Create_MessageDeleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAttachmentRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM messages").WithArgs(4, "default").WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, createErr := repo.Create(context.Background(), &Attachment{MessageId: 4, FileName: "photo.png", BlobKey: "default/4/abc"})
+
+	assert.NotNil(t, createErr)
+	assert.EqualValues(t, http.StatusNotFound, createErr.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttachmentRepo_Delete_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAttachmentRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM attachments WHERE id=\\? AND message_id=\\? AND tenant_id=\\? AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(9, 4, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "tenant_id", "file_name", "content_type", "size", "blob_key", "created_at"}))
+	mock.ExpectRollback()
+
+	deleteErr := repo.Delete(context.Background(), 4, 9)
+
+	assert.NotNil(t, deleteErr)
+	assert.EqualValues(t, http.StatusNotFound, deleteErr.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttachmentRepo_Delete_Audited(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAttachmentRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT (.+) FROM attachments WHERE id=\\? AND message_id=\\? AND tenant_id=\\? AND deleted_at IS NULL FOR UPDATE").
+		WithArgs(9, 4, "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "tenant_id", "file_name", "content_type", "size", "blob_key", "created_at"}).
+			AddRow(9, 4, "acme", "photo.png", "image/png", 2048, "acme/4/abc", time.Now()))
+	mock.ExpectExec("UPDATE attachments SET deleted_at=\\? WHERE id=\\? AND message_id=\\? AND tenant_id=\\?").
+		WithArgs(sqlmock.AnyArg(), 9, 4, "acme").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "acme", 4, AuditActionDetach)
+	mock.ExpectCommit()
+
+	deleteErr := repo.Delete(tenant_utils.WithTenant(context.Background(), "acme"), 4, 9)
+
+	assert.Nil(t, deleteErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAttachmentRepo_ListDeleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAttachmentRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "message_id", "tenant_id", "file_name", "content_type", "size", "blob_key", "created_at"}).
+		AddRow(1, 4, "acme", "a.png", "image/png", 10, "acme/4/a", time.Now()).
+		AddRow(2, 5, "globex", "b.pdf", "application/pdf", 20, "globex/5/b", time.Now())
+	mock.ExpectQuery("SELECT (.+) FROM attachments WHERE deleted_at IS NOT NULL").
+		WithArgs(100).
+		WillReturnRows(rows)
+
+	attachments, listErr := repo.ListDeleted(context.Background(), 100)
+
+	assert.Nil(t, listErr)
+	assert.Equal(t, 2, len(attachments))
+	assert.Equal(t, "globex/5/b", attachments[1].BlobKey)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
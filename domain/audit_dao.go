@@ -0,0 +1,252 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing-project/utils/audit_utils"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/logger_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+var (
+	AuditRepo auditRepoInterface = &auditRepo{}
+)
+
+const (
+	// querySnapshotMessage reads a message as it stands inside a write,
+	// tombstones and expired messages included.
+	querySnapshotMessage = selectMessages + " WHERE m.id=? AND m.tenant_id=? GROUP BY m.id;"
+	// queryAuditHead locks the newest entry of the tenant, and the gap after
+	// it, so that entries are chained one at a time.
+	queryAuditHead   = "SELECT hash FROM audit_log WHERE tenant_id=? ORDER BY id DESC LIMIT 1 FOR UPDATE;"
+	queryInsertAudit = "INSERT INTO audit_log(tenant_id, message_id, action, actor, client_ip, user_agent, request_id, before_value, after_value, " +
+		"before_hash, after_hash, created_at, prev_hash, hash) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	selectAudit = "SELECT id, tenant_id, message_id, action, actor, client_ip, user_agent, request_id, before_value, after_value, " +
+		"before_hash, after_hash, redacted_at, created_at, prev_hash, hash FROM audit_log"
+	// queryAuditChain reads the entries of a tenant in chain order.
+	queryAuditChain = selectAudit + " WHERE tenant_id=? AND id>? ORDER BY id LIMIT ?;"
+	// queryRedactAudit clears the values of a message's entries, leaving
+	// their hashes, and those of earlier redactions, as they are.
+	queryRedactAudit = "UPDATE audit_log SET before_value=NULL, after_value=NULL, redacted_at=? " +
+		"WHERE tenant_id=? AND message_id=? AND action<>? AND redacted_at IS NULL AND (before_value IS NOT NULL OR after_value IS NOT NULL);"
+)
+
+// auditRepoInterface reads the audit log of the tenant. Entries are written
+// by the message writes themselves, inside their transaction, see
+// writeAudit.
+type auditRepoInterface interface {
+	List(ctx context.Context, filter AuditFilter) ([]AuditEntry, error_utils.MessageErr)
+	// Chain returns up to limit entries after afterId, oldest first, for
+	// verifying the hash chain.
+	Chain(ctx context.Context, afterId int64, limit int) ([]AuditEntry, error_utils.MessageErr)
+	// Redact removes the before and after values of the tenant's entries
+	// for the message and records a redact entry saying how many it
+	// redacted, unless there were none. Their hashes stay, so the chain
+	// still verifies.
+	Redact(ctx context.Context, msgId int64) (int, error_utils.MessageErr)
+}
+
+type auditRepo struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) auditRepoInterface {
+	return &auditRepo{db: db}
+}
+
+// snapshotMessage returns the message as JSON as it stands in tx, or nil
+// when there is no such message.
+func snapshotMessage(ctx context.Context, tx *sql.Tx, msgId int64, tenantId string) (json.RawMessage, error_utils.MessageErr) {
+	msg, err := scanMessage(tx.QueryRowContext(ctx, querySnapshotMessage, msgId, tenantId))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to audit message: %s", err.Error()))
+	}
+	snapshot, err := json.Marshal(msg)
+	if err != nil {
+		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to audit message: %s", err.Error()))
+	}
+	return snapshot, nil
+}
+
+// auditValue encodes the translation or attachment a change was made to for
+// its audit entry.
+func auditValue(value interface{}) (json.RawMessage, error_utils.MessageErr) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to audit message: %s", err.Error()))
+	}
+	return encoded, nil
+}
+
+// writeAudit appends an entry for a change to a message to the tenant's
+// audit log inside tx, chained to the entry before it. The actor, client
+// and request come from ctx; background jobs leave them empty.
+func writeAudit(ctx context.Context, tx *sql.Tx, action string, msgId int64, tenantId string, before json.RawMessage, after json.RawMessage) error_utils.MessageErr {
+	entry := AuditEntry{
+		TenantId:   tenantId,
+		MessageId:  msgId,
+		Action:     action,
+		RequestId:  logger_utils.RequestIDFrom(ctx),
+		Before:     before,
+		After:      after,
+		BeforeHash: AuditValueHash(before),
+		AfterHash:  AuditValueHash(after),
+		CreatedAt:  time.Now().UTC().Truncate(time.Microsecond),
+		PrevHash:   AuditGenesisHash,
+	}
+	if principal := auth_utils.PrincipalFrom(ctx); principal != nil {
+		entry.Actor = principal.Subject
+	}
+	source := audit_utils.SourceFrom(ctx)
+	entry.ClientIp = source.ClientIP
+	entry.UserAgent = source.UserAgent
+
+	err := tx.QueryRowContext(ctx, queryAuditHead, tenantId).Scan(&entry.PrevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to audit message: %s", err.Error()))
+	}
+	entry.Hash = entry.ComputeHash()
+	if _, err := tx.ExecContext(ctx, queryInsertAudit, entry.TenantId, entry.MessageId, entry.Action, nullString(entry.Actor),
+		nullString(entry.ClientIp), nullString(entry.UserAgent), nullString(entry.RequestId), nullJSON(entry.Before), nullJSON(entry.After),
+		nullString(entry.BeforeHash), nullString(entry.AfterHash), entry.CreatedAt, entry.PrevHash, entry.Hash); err != nil {
+		return error_formats.ParseError(err)
+	}
+	return nil
+}
+
+// auditChange snapshots the message as it stands after a write in tx and
+// audits the change from before.
+func auditChange(ctx context.Context, tx *sql.Tx, action string, msgId int64, tenantId string, before json.RawMessage) error_utils.MessageErr {
+	after, err := snapshotMessage(ctx, tx, msgId, tenantId)
+	if err != nil {
+		return err
+	}
+	return writeAudit(ctx, tx, action, msgId, tenantId, before, after)
+}
+
+// List returns the tenant's entries matching filter, newest first.
+func (ar *auditRepo) List(ctx context.Context, filter AuditFilter) ([]AuditEntry, error_utils.MessageErr) {
+	where := []string{"tenant_id=?"}
+	args := []interface{}{tenant_utils.TenantFrom(ctx)}
+	if filter.MessageId != 0 {
+		where = append(where, "message_id=?")
+		args = append(args, filter.MessageId)
+	}
+	if filter.Actor != "" {
+		where = append(where, "actor=?")
+		args = append(args, filter.Actor)
+	}
+	if filter.Action != "" {
+		where = append(where, "action=?")
+		args = append(args, filter.Action)
+	}
+	if filter.RequestId != "" {
+		where = append(where, "request_id=?")
+		args = append(args, filter.RequestId)
+	}
+	if filter.Since != nil {
+		where = append(where, "created_at >= ?")
+		args = append(args, *filter.Since)
+	}
+	if filter.Until != nil {
+		where = append(where, "created_at < ?")
+		args = append(args, *filter.Until)
+	}
+	query := selectAudit + " WHERE " + strings.Join(where, " AND ") + " ORDER BY id DESC LIMIT ? OFFSET ?;"
+	args = append(args, filter.Limit, filter.Offset)
+	return ar.list(ctx, "list", query, args...)
+}
+
+func (ar *auditRepo) Chain(ctx context.Context, afterId int64, limit int) ([]AuditEntry, error_utils.MessageErr) {
+	return ar.list(ctx, "chain", queryAuditChain, tenant_utils.TenantFrom(ctx), afterId, limit)
+}
+
+func (ar *auditRepo) Redact(ctx context.Context, msgId int64) (int, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("auditRepo", "redact", time.Now())
+	ctx, span := startRepoSpan(ctx, "auditRepo", "redact", queryRedactAudit)
+	defer span.End()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	tx, err := ar.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to redact audit entries: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, queryRedactAudit, time.Now().UTC().Truncate(time.Microsecond), tenantId, msgId, AuditActionRedact)
+	if err != nil {
+		return 0, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	redacted, err := result.RowsAffected()
+	if err != nil {
+		return 0, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if redacted == 0 {
+		return 0, nil
+	}
+	after, auditErr := auditValue(map[string]int64{"redacted": redacted})
+	if auditErr != nil {
+		return 0, recordRepoErr(span, auditErr)
+	}
+	if auditErr := writeAudit(ctx, tx, AuditActionRedact, msgId, tenantId, nil, after); auditErr != nil {
+		return 0, recordRepoErr(span, auditErr)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	return int(redacted), nil
+}
+
+func (ar *auditRepo) list(ctx context.Context, operation string, query string, args ...interface{}) ([]AuditEntry, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("auditRepo", operation, time.Now())
+	ctx, span := startRepoSpan(ctx, "auditRepo", operation, query)
+	defer span.End()
+
+	rows, err := ar.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list audit entries: %s", err.Error())))
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var entry AuditEntry
+		var actor, clientIp, userAgent, requestId, before, after, beforeHash, afterHash sql.NullString
+		var redactedAt sql.NullTime
+		if err := rows.Scan(&entry.Id, &entry.TenantId, &entry.MessageId, &entry.Action, &actor, &clientIp, &userAgent, &requestId,
+			&before, &after, &beforeHash, &afterHash, &redactedAt, &entry.CreatedAt, &entry.PrevHash, &entry.Hash); err != nil {
+			return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list audit entries: %s", err.Error())))
+		}
+		entry.Actor = actor.String
+		entry.ClientIp = clientIp.String
+		entry.UserAgent = userAgent.String
+		entry.RequestId = requestId.String
+		entry.BeforeHash = beforeHash.String
+		entry.AfterHash = afterHash.String
+		if redactedAt.Valid {
+			entry.RedactedAt = &redactedAt.Time
+		}
+		if before.Valid {
+			entry.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			entry.After = json.RawMessage(after.String)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list audit entries: %s", err.Error())))
+	}
+	return entries, nil
+}
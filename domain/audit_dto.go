@@ -0,0 +1,120 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+	// AuditActionRestore is recorded when an archived message is brought
+	// back to draft.
+	AuditActionRestore = "restore"
+	// AuditActionTranslate is recorded when a translation of a message is
+	// put or removed. Before and After are the translation.
+	AuditActionTranslate = "translate"
+	// AuditActionAttach and AuditActionDetach are recorded when a file is
+	// attached to a message or removed from it. Before and After are the
+	// attachment.
+	AuditActionAttach = "attach"
+	AuditActionDetach = "detach"
+	// AuditActionRedact is recorded when the before and after values of a
+	// message's entries are removed, on erasure of its author. After holds
+	// how many entries were redacted.
+	AuditActionRedact = "redact"
+
+	// AuditGenesisHash is the previous hash of the first entry of a tenant.
+	AuditGenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+)
+
+// AuditEntry records one change to a message. Before and After are the
+// message as JSON, null before a create and after a delete, or for changes
+// to a translation or attachment that translation or attachment. Hash
+// covers every other field but the id and RedactedAt, and PrevHash is the
+// hash of the tenant's entry before, so the entries of a tenant form a
+// chain that breaks when one is edited, removed or reordered. Before and
+// After are covered through BeforeHash and AfterHash, so that they can be
+// redacted, which sets RedactedAt, without breaking the chain.
+type AuditEntry struct {
+	Id         int64           `json:"id"`
+	TenantId   string          `json:"tenant_id"`
+	MessageId  int64           `json:"message_id"`
+	Action     string          `json:"action"`
+	Actor      string          `json:"actor,omitempty"`
+	ClientIp   string          `json:"client_ip,omitempty"`
+	UserAgent  string          `json:"user_agent,omitempty"`
+	RequestId  string          `json:"request_id,omitempty"`
+	Before     json.RawMessage `json:"before"`
+	After      json.RawMessage `json:"after"`
+	BeforeHash string          `json:"before_hash,omitempty"`
+	AfterHash  string          `json:"after_hash,omitempty"`
+	RedactedAt *time.Time      `json:"redacted_at,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+// ComputeHash returns the hash the entry should carry. Every field is
+// length prefixed, so no two different entries hash the same input.
+func (e *AuditEntry) ComputeHash() string {
+	h := sha256.New()
+	for _, field := range []string{
+		e.PrevHash, e.TenantId, strconv.FormatInt(e.MessageId, 10), e.Action, e.Actor, e.ClientIp, e.UserAgent, e.RequestId,
+		e.BeforeHash, e.AfterHash, e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	} {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(field)))
+		h.Write(size[:])
+		h.Write([]byte(field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ValuesMatch reports whether Before and After are the values BeforeHash
+// and AfterHash were computed from. A redacted entry has no values left,
+// so one that still carries a value must match its hash like any other.
+func (e *AuditEntry) ValuesMatch() bool {
+	if e.RedactedAt != nil && e.Before == nil && e.After == nil {
+		return true
+	}
+	return AuditValueHash(e.Before) == e.BeforeHash && AuditValueHash(e.After) == e.AfterHash
+}
+
+// AuditValueHash returns the hash an entry carries for one of its values,
+// empty for none.
+func AuditValueHash(value json.RawMessage) string {
+	if value == nil {
+		return ""
+	}
+	sum := sha256.Sum256(value)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditFilter narrows an audit log listing, which is newest first. Zero
+// values match every entry.
+type AuditFilter struct {
+	MessageId int64
+	Actor     string
+	Action    string
+	RequestId string
+	Since     *time.Time
+	Until     *time.Time
+	Limit     int
+	Offset    int
+}
+
+// ValidAuditAction reports whether action is one the audit log records.
+func ValidAuditAction(action string) bool {
+	switch action {
+	case AuditActionCreate, AuditActionUpdate, AuditActionDelete, AuditActionRestore, AuditActionTranslate, AuditActionAttach, AuditActionDetach,
+		AuditActionRedact:
+		return true
+	}
+	return false
+}
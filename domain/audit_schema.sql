@@ -0,0 +1,28 @@
+  -- audit_log is append-only: every entry carries the hash of the tenant's
+  -- entry before it, so editing or removing an entry breaks the chain.
+  -- before_value and after_value are kept as text, not JSON, so that their
+  -- bytes stay exactly as they were hashed. The chain covers them through
+  -- before_hash and after_hash, so erasing an author can redact them,
+  -- setting redacted_at, and leave the chain intact.
+  CREATE TABLE `audit_log` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL,
+  `message_id` INT NOT NULL,
+  `action` VARCHAR(16) NOT NULL,
+  `actor` VARCHAR(255) NULL,
+  `client_ip` VARCHAR(45) NULL,
+  `user_agent` VARCHAR(255) NULL,
+  `request_id` VARCHAR(128) NULL,
+  `before_value` MEDIUMTEXT NULL,
+  `after_value` MEDIUMTEXT NULL,
+  `before_hash` CHAR(64) NULL,
+  `after_hash` CHAR(64) NULL,
+  `redacted_at` TIMESTAMP(6) NULL,
+  `created_at` TIMESTAMP(6) NOT NULL,
+  `prev_hash` CHAR(64) NOT NULL,
+  `hash` CHAR(64) NOT NULL,
+  PRIMARY KEY (`id`),
+  INDEX `tenant_id_idx` (`tenant_id` ASC, `id` ASC),
+  INDEX `tenant_message_idx` (`tenant_id` ASC, `message_id` ASC),
+  INDEX `tenant_actor_idx` (`tenant_id` ASC, `actor` ASC),
+  INDEX `tenant_request_idx` (`tenant_id` ASC, `request_id` ASC));
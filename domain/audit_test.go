@@ -0,0 +1,182 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/utils/audit_utils"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/logger_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+func TestMessageRepo_UpdateStatus_AuditsRestore(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	ctx := auth_utils.WithPrincipal(context.Background(), &auth_utils.Principal{Subject: "user-1"})
+	ctx = audit_utils.WithSource(ctx, audit_utils.Source{ClientIP: "10.0.0.1", UserAgent: "curl/8.0"})
+	ctx = logger_utils.WithRequestID(ctx, "req-1")
+	prevHash := "ab" + AuditGenesisHash[2:]
+
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", MessageStatusArchived)
+	mock.ExpectExec("UPDATE messages SET status").
+		WithArgs(MessageStatusDraft, nil, 1, "default", MessageStatusArchived).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 1, "default", MessageStatusDraft)
+	mock.ExpectQuery("SELECT hash FROM audit_log").WithArgs("default").
+		WillReturnRows(sqlmock.NewRows([]string{"hash"}).AddRow(prevHash))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs("default", 1, AuditActionRestore, "user-1", "10.0.0.1", "curl/8.0", "req-1", sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), prevHash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectCommit()
+
+	updateErr := repo.UpdateStatus(ctx, &Message{Id: 1, Status: MessageStatusDraft}, MessageStatusArchived)
+
+	assert.Nil(t, updateErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditRepo_List_Filters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAuditRepository(db)
+
+	since := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"id", "tenant_id", "message_id", "action", "actor", "client_ip", "user_agent", "request_id",
+		"before_value", "after_value", "before_hash", "after_hash", "redacted_at", "created_at", "prev_hash", "hash"}).
+		AddRow(4, "acme", 7, AuditActionDelete, "user-1", nil, nil, "req-1", `{"id":7}`, nil, "bh", nil, nil, createdAt, AuditGenesisHash, "h")
+	mock.ExpectQuery("SELECT (.+) FROM audit_log WHERE tenant_id=\\? AND message_id=\\? AND actor=\\? AND created_at >= \\? ORDER BY id DESC LIMIT \\? OFFSET \\?").
+		WithArgs("acme", 7, "user-1", since, 20, 0).
+		WillReturnRows(rows)
+
+	entries, listErr := repo.List(tenant_utils.WithTenant(context.Background(), "acme"), AuditFilter{MessageId: 7, Actor: "user-1", Since: &since, Limit: 20})
+
+	assert.Nil(t, listErr)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, json.RawMessage(`{"id":7}`), entries[0].Before)
+	assert.Nil(t, entries[0].After)
+	assert.Equal(t, "req-1", entries[0].RequestId)
+	assert.Equal(t, "bh", entries[0].BeforeHash)
+	assert.Nil(t, entries[0].RedactedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditEntry_ComputeHash(t *testing.T) {
+	entry := AuditEntry{TenantId: "acme", MessageId: 7, Action: AuditActionUpdate, Actor: "user-1", BeforeHash: AuditValueHash(json.RawMessage(`{"a":1}`)),
+		AfterHash: AuditValueHash(json.RawMessage(`{"a":2}`)), CreatedAt: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), PrevHash: AuditGenesisHash}
+	hash := entry.ComputeHash()
+
+	assert.Len(t, hash, 64)
+	local := entry
+	local.CreatedAt = entry.CreatedAt.In(time.FixedZone("CET", 3600))
+	assert.Equal(t, hash, local.ComputeHash())
+	tampered := entry
+	tampered.AfterHash = AuditValueHash(json.RawMessage(`{"a":3}`))
+	assert.NotEqual(t, hash, tampered.ComputeHash())
+	redacted := entry
+	redacted.RedactedAt = &entry.CreatedAt
+	assert.Equal(t, hash, redacted.ComputeHash())
+	// Moving bytes from one field to the next must change the hash too.
+	shifted := entry
+	shifted.Actor, shifted.ClientIp = "user-", "1"
+	assert.NotEqual(t, hash, shifted.ComputeHash())
+}
+
+func TestAuditEntry_ValuesMatch(t *testing.T) {
+	entry := AuditEntry{Before: json.RawMessage(`{"a":1}`), BeforeHash: AuditValueHash(json.RawMessage(`{"a":1}`))}
+	assert.True(t, entry.ValuesMatch())
+
+	entry.Before = json.RawMessage(`{"a":2}`)
+	assert.False(t, entry.ValuesMatch())
+
+	redactedAt := time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)
+	entry.Before, entry.RedactedAt = nil, &redactedAt
+	assert.True(t, entry.ValuesMatch())
+
+	entry.After = json.RawMessage(`{"a":3}`)
+	assert.False(t, entry.ValuesMatch())
+}
+
+func TestAuditRepo_Redact(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAuditRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE audit_log SET before_value=NULL, after_value=NULL, redacted_at=\\? WHERE tenant_id=\\? AND message_id=\\? AND action<>\\?").
+		WithArgs(sqlmock.AnyArg(), "acme", 7, AuditActionRedact).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	expectAudit(mock, "acme", 7, AuditActionRedact)
+	mock.ExpectCommit()
+
+	redacted, redactErr := repo.Redact(tenant_utils.WithTenant(context.Background(), "acme"), 7)
+
+	assert.Nil(t, redactErr)
+	assert.Equal(t, 3, redacted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditRepo_Redact_NothingLeft(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewAuditRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE audit_log SET before_value=NULL").
+		WithArgs(sqlmock.AnyArg(), "default", 7, AuditActionRedact).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	redacted, redactErr := repo.Redact(context.Background(), 7)
+
+	assert.Nil(t, redactErr)
+	assert.Equal(t, 0, redacted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_Anonymize(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	mock.ExpectBegin()
+	expectSnapshot(mock, 4, "default", MessageStatusPublished)
+	mock.ExpectExec("UPDATE messages SET author_id=NULL WHERE id=\\? AND tenant_id=\\?").WithArgs(4, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 4, "default", MessageStatusPublished)
+	expectAudit(mock, "default", 4, AuditActionUpdate)
+	mock.ExpectCommit()
+
+	assert.Nil(t, repo.Anonymize(context.Background(), 4))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_Anonymize_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	mock.ExpectBegin()
+	expectSnapshot(mock, 4, "default", "")
+	mock.ExpectRollback()
+
+	anonymizeErr := repo.Anonymize(context.Background(), 4)
+
+	assert.NotNil(t, anonymizeErr)
+	assert.Equal(t, "not_found", anonymizeErr.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
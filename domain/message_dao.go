@@ -1,12 +1,21 @@
 package domain
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	_ "github.com/go-sql-driver/mysql"
-	"log"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing-project/utils/auth_utils"
 	"testing-project/utils/error_formats"
 	"testing-project/utils/error_utils"
+	"testing-project/utils/logger_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
 )
 
 var (
@@ -14,32 +23,112 @@ var (
 )
 
 const (
-	queryGetMessage    = "SELECT id, title, body, created_at FROM messages WHERE id=?;"
-	queryInsertMessage = "INSERT INTO messages(title, body, created_at) VALUES(?, ?, ?);"
-	queryUpdateMessage = "UPDATE messages SET title=?, body=? WHERE id=?;"
-	queryDeleteMessage = "DELETE FROM messages WHERE id=?;"
+	// selectMessages reads messages along with their tags. Queries built on it
+	// must end with GROUP BY m.id.
+	selectMessages = "SELECT m.id, m.tenant_id, m.title, m.body, m.author_id, m.category, m.status, m.publish_at, m.expires_at, m.created_at, " +
+		"m.parent_id, m.deleted_at, m.format, m.version, m.metadata, m.read_count, m.reaction_counts, m.slug, (SELECT COUNT(*) FROM messages r WHERE r.parent_id = m.id AND r.deleted_at IS NULL), " +
+		"GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR ',') " +
+		"FROM messages m LEFT JOIN message_tags mt ON mt.message_id = m.id LEFT JOIN tags t ON t.id = mt.tag_id"
+	notExpired = "(m.expires_at IS NULL OR m.expires_at > ?)"
+	// visible leaves out expired messages and tombstones.
+	visible = "m.deleted_at IS NULL AND " + notExpired
+	// readable leaves out the messages the caller may not see, given the
+	// arguments of readableArgs.
+	readable = "(? OR m.status='published' OR m.author_id=? OR (? AND m.status='pending_review'))"
+
+	queryGetMessage = selectMessages + " WHERE m.id=? AND m.tenant_id=? AND " + visible + " AND " + readable + " GROUP BY m.id;"
+	// queryGetMessageBySlug finds a message by any slug it ever had.
+	queryGetMessageBySlug = selectMessages + " JOIN message_slugs s ON s.message_id = m.id WHERE s.tenant_id=? AND s.slug=? AND m.tenant_id=? AND " +
+		visible + " AND " + readable + " GROUP BY m.id;"
+	// queryGetMessageByTitle includes expired messages, which still hold
+	// their title under tenant_title_UNIQUE.
+	queryGetMessageByTitle = selectMessages + " WHERE m.tenant_id=? AND m.title=? AND m.deleted_at IS NULL GROUP BY m.id;"
+
+	queryInsertMessage = "INSERT INTO messages(tenant_id, title, body, format, author_id, parent_id, category, status, publish_at, expires_at, created_at, metadata, content_hash, simhash, version) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1);"
+	queryUpdateMessage = "UPDATE messages SET title=?, body=?, category=?, format=?, metadata=?, content_hash=?, simhash=?, version=version+1 WHERE id=? AND tenant_id=? AND version=?;"
+	queryDeleteMessage = "DELETE FROM messages WHERE id=? AND tenant_id=?;"
+
+	// queryCountReplies locks the message and its replies so that no reply can
+	// be added between the count and the delete.
+	queryCountReplies     = "SELECT COUNT(r.id) FROM messages m LEFT JOIN messages r ON r.parent_id = m.id WHERE m.id=? AND m.tenant_id=? FOR UPDATE;"
+	queryTombstoneMessage = "UPDATE messages SET title=NULL, body=NULL, category=NULL, metadata=NULL, content_hash=NULL, simhash=NULL, deleted_at=? WHERE id=? AND tenant_id=?;"
+	// queryThread walks the replies of a message breadth first, depth levels
+	// down, and reads every message found. Tombstones are kept so the tree
+	// stays connected.
+	queryThread = "WITH RECURSIVE thread (id, depth) AS (" +
+		"SELECT id, 0 FROM messages WHERE id=? AND tenant_id=? " +
+		"UNION ALL SELECT c.id, thread.depth + 1 FROM messages c JOIN thread ON c.parent_id = thread.id WHERE thread.depth < ?) " +
+		selectMessages + " JOIN thread th ON th.id = m.id WHERE m.tenant_id=? AND " + notExpired + " AND " + readable + " GROUP BY m.id ORDER BY m.id;"
+
+	// queryListPinned reads the pins of a scope in order. Pins in a category
+	// are skipped once their message has moved to another category.
+	queryListPinned = selectMessages + " JOIN message_pins p ON p.message_id = m.id WHERE p.tenant_id=? AND p.scope=? AND m.tenant_id=? AND " +
+		"(p.scope = '' OR m.category = p.scope) AND " + visible + " AND " + readable + " GROUP BY m.id, p.position ORDER BY p.position;"
+	// pinnedFirst orders the pins of a scope first, by position, and the
+	// other messages after them, newest first.
+	pinnedFirst = "COALESCE((SELECT p.position FROM message_pins p WHERE p.message_id = m.id AND p.tenant_id = m.tenant_id AND p.scope=?), 2147483647), m.id DESC"
+
+	// queryListByAuthor pages through every message of an author, tombstones
+	// and expired messages included, by id.
+	queryListByAuthor          = selectMessages + " WHERE m.tenant_id=? AND m.author_id=? AND m.id>? GROUP BY m.id ORDER BY m.id LIMIT ?;"
+	queryListAfter             = selectMessages + " WHERE m.tenant_id=? AND m.id>? AND m.deleted_at IS NULL GROUP BY m.id ORDER BY m.id LIMIT ?;"
+	queryAnonymizeMessage      = "UPDATE messages SET author_id=NULL WHERE id=? AND tenant_id=?;"
+	queryCountMessagesByAuthor = "SELECT COUNT(*) FROM messages WHERE tenant_id=? AND author_id=? AND created_at >= ?;"
+	queryUpdateMessageStatus   = "UPDATE messages SET status=?, publish_at=? WHERE id=? AND tenant_id=? AND status=?;"
+	queryListDueScheduled      = selectMessages + " WHERE m.status='scheduled' AND m.publish_at <= ? AND " + visible + " GROUP BY m.id ORDER BY m.publish_at, m.id LIMIT ?;"
+	queryListExpired           = selectMessages + " WHERE m.expires_at <= ? AND m.status <> 'archived' AND m.deleted_at IS NULL GROUP BY m.id ORDER BY m.expires_at, m.id LIMIT ?;"
 )
 
+// messageRepoInterface scopes every query to the tenant of the context, so a
+// message owned by another tenant, or one that has expired or been deleted,
+// is reported as not found. The exceptions are ListDueScheduled and
+// ListExpired, which serve the background jobs across all tenants. Reads of
+// single messages, lists, threads and similar messages are also limited to
+// what the caller of the context may see, see readableArgs.
 type messageRepoInterface interface {
-	Get(int64) (*Message, error_utils.MessageErr)
-	Create(*Message) (*Message, error_utils.MessageErr)
-	Update(*Message) (*Message, error_utils.MessageErr)
-	Delete(int64) error_utils.MessageErr
+	Get(context.Context, int64) (*Message, error_utils.MessageErr)
+	GetBySlug(ctx context.Context, slug string) (*Message, error_utils.MessageErr)
+	// GetByTitle finds the message holding title, expired messages included.
+	GetByTitle(ctx context.Context, title string) (*Message, error_utils.MessageErr)
+	Similar(ctx context.Context, msg *Message, limit int) ([]Message, error_utils.MessageErr)
+	Create(context.Context, *Message) (*Message, error_utils.MessageErr)
+	Update(context.Context, *Message) (*Message, error_utils.MessageErr)
+	Delete(context.Context, int64) error_utils.MessageErr
+	Thread(ctx context.Context, rootId int64, depth int) ([]Message, error_utils.MessageErr)
+	Pinned(ctx context.Context, scope string) ([]Message, error_utils.MessageErr)
+	List(context.Context, MessageFilter) ([]Message, error_utils.MessageErr)
+	ListTags(context.Context) ([]TagCount, error_utils.MessageErr)
+	CountByAuthorSince(context.Context, string, time.Time) (int, error_utils.MessageErr)
+	// ListByAuthor returns up to limit messages of the author with an id
+	// above afterId, tombstones and expired messages included.
+	ListByAuthor(ctx context.Context, authorId string, afterId int64, limit int) ([]Message, error_utils.MessageErr)
+	// ListAfter returns up to limit messages with an id above afterId,
+	// expired messages included and tombstones left out.
+	ListAfter(ctx context.Context, afterId int64, limit int) ([]Message, error_utils.MessageErr)
+	// Anonymize detaches a message, tombstones included, from its author.
+	Anonymize(ctx context.Context, msgId int64) error_utils.MessageErr
+	UpdateStatus(ctx context.Context, msg *Message, fromStatus string) error_utils.MessageErr
+	ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]Message, error_utils.MessageErr)
+	ListExpired(ctx context.Context, now time.Time, limit int) ([]Message, error_utils.MessageErr)
 	Initialize(string, string, string, string, string, string) *sql.DB
 }
 type messageRepo struct {
 	db *sql.DB
 }
 
+// dataSourceName is the MySQL DSN of the database. The connection is
+// utf8mb4, since utf8 cannot carry the 4-byte characters most emoji are.
+func dataSourceName(DbUser, DbPassword, DbPort, DbHost, DbName string) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", DbUser, DbPassword, DbHost, DbPort, DbName)
+}
+
 func (mr *messageRepo) Initialize(Dbdriver, DbUser, DbPassword, DbPort, DbHost, DbName string) *sql.DB {
 	var err error
-	DBURL := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local", DbUser, DbPassword, DbHost, DbPort, DbName)
-
-	mr.db, err = sql.Open(Dbdriver, DBURL)
+	mr.db, err = sql.Open(Dbdriver, dataSourceName(DbUser, DbPassword, DbPort, DbHost, DbName))
 	if err != nil {
-		log.Fatal("This is the error connecting to the database:", err)
+		logger_utils.Fatal("error connecting to the database", "error", err)
 	}
-	fmt.Printf("We are connected to the %s database", Dbdriver)
+	slog.Info("connected to the database", "driver", Dbdriver, "host", DbHost, "database", DbName)
 
 	return mr.db
 }
@@ -48,66 +137,462 @@ func NewMessageRepository(db *sql.DB) messageRepoInterface {
 	return &messageRepo{db: db}
 }
 
-func (mr *messageRepo) Get(messageId int64) (*Message, error_utils.MessageErr) {
-	stmt, err := mr.db.Prepare(queryGetMessage)
+func (mr *messageRepo) Get(ctx context.Context, messageId int64) (*Message, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "get", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "get", queryGetMessage)
+	defer span.End()
+
+	stmt, err := mr.db.PrepareContext(ctx, queryGetMessage)
 	if err != nil {
-		return nil, error_utils.NewInternalServerError(fmt.Sprintf("Error when trying to prepare message: %s", err.Error()))
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("Error when trying to prepare message: %s", err.Error())))
 	}
 	defer stmt.Close()
 
+	args := append([]interface{}{messageId, tenant_utils.TenantFrom(ctx), time.Now()}, readableArgs(ctx)...)
+	msg, getError := scanMessage(stmt.QueryRowContext(ctx, args...))
+	if getError != nil {
+		slog.DebugContext(ctx, "failed to get message", "message_id", messageId, "error", getError)
+		return nil, recordRepoErr(span, error_formats.ParseError(getError))
+	}
+	return msg, nil
+}
+
+// GetBySlug finds a message by its slug or by one it had before. Callers
+// tell the two apart by comparing slug with the Slug of the result.
+func (mr *messageRepo) GetBySlug(ctx context.Context, slug string) (*Message, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "get_by_slug", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "get_by_slug", queryGetMessageBySlug)
+	defer span.End()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	args := append([]interface{}{tenantId, slug, tenantId, time.Now()}, readableArgs(ctx)...)
+	msg, err := scanMessage(mr.db.QueryRowContext(ctx, queryGetMessageBySlug, args...))
+	if err != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	return msg, nil
+}
+
+func (mr *messageRepo) GetByTitle(ctx context.Context, title string) (*Message, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "get_by_title", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "get_by_title", queryGetMessageByTitle)
+	defer span.End()
+
+	msg, err := scanMessage(mr.db.QueryRowContext(ctx, queryGetMessageByTitle, tenant_utils.TenantFrom(ctx), title))
+	if err != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	return msg, nil
+}
+
+// reader describes what the caller of ctx may see. Admins, and internal
+// callers without a principal, see every message. Others see published
+// messages and their own, and moderators also the messages pending review.
+func reader(ctx context.Context) (all bool, subject string, moderator bool) {
+	principal := auth_utils.PrincipalFrom(ctx)
+	if principal == nil || principal.IsAdmin() {
+		return true, "", false
+	}
+	return false, principal.Subject, principal.HasScope(auth_utils.ScopeMessagesModerate)
+}
+
+// readableArgs are the arguments of readable for the caller of ctx.
+func readableArgs(ctx context.Context) []interface{} {
+	all, subject, moderator := reader(ctx)
+	return []interface{}{all, subject, moderator}
+}
+
+// Readable reports whether the caller of ctx may see msg, by the rule the
+// queries apply through readable.
+func Readable(ctx context.Context, msg *Message) bool {
+	all, subject, moderator := reader(ctx)
+	return all || msg.Status == MessageStatusPublished || (msg.AuthorId != "" && msg.AuthorId == subject) ||
+		(moderator && msg.Status == MessageStatusPendingReview)
+}
+
+func scanMessage(row rowScanner) (*Message, error) {
 	var msg Message
-	result := stmt.QueryRow(messageId)
-	if getError := result.Scan(&msg.Id, &msg.Title, &msg.Body, &msg.CreatedAt); getError != nil {
-		fmt.Println("this is the error: ", getError)
-		return nil, error_formats.ParseError(getError)
+	var title, body, authorId, category, metadata, reactions, slug, tags sql.NullString
+	var publishAt, expiresAt, deletedAt sql.NullTime
+	var parentId sql.NullInt64
+	if err := row.Scan(&msg.Id, &msg.TenantId, &title, &body, &authorId, &category, &msg.Status, &publishAt, &expiresAt, &msg.CreatedAt,
+		&parentId, &deletedAt, &msg.Format, &msg.Version, &metadata, &msg.ReadCount, &reactions, &slug, &msg.ReplyCount, &tags); err != nil {
+		return nil, err
+	}
+	msg.Title = title.String
+	msg.Slug = slug.String
+	msg.Body = body.String
+	msg.AuthorId = authorId.String
+	if parentId.Valid {
+		msg.ParentId = &parentId.Int64
+	}
+	msg.Deleted = deletedAt.Valid
+	msg.Category = category.String
+	if metadata.Valid {
+		msg.Metadata = json.RawMessage(metadata.String)
+	}
+	if reactions.Valid {
+		if err := json.Unmarshal([]byte(reactions.String), &msg.Reactions); err != nil {
+			return nil, err
+		}
+		for emoji, count := range msg.Reactions {
+			if count <= 0 {
+				delete(msg.Reactions, emoji)
+			}
+		}
 	}
+	msg.Tags = []string{}
+	if tags.String != "" {
+		msg.Tags = strings.Split(tags.String, ",")
+	}
+	msg.PublishAt = timePtr(publishAt)
+	msg.ExpiresAt = timePtr(expiresAt)
 	return &msg, nil
 }
 
-func (mr *messageRepo) Create(msg *Message) (*Message, error_utils.MessageErr) {
-	stmt, err := mr.db.Prepare(queryInsertMessage)
+func (mr *messageRepo) Create(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "create", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "create", queryInsertMessage)
+	defer span.End()
+
+	tx, err := mr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save message: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, queryInsertMessage)
 	if err != nil {
-		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare user to save: %s", err.Error()))
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare user to save: %s", err.Error())))
 	}
 
 	defer stmt.Close()
 
-	insertResult, createErr := stmt.Exec(msg.Title, msg.Body, msg.CreatedAt)
+	msg.TenantId = tenant_utils.TenantFrom(ctx)
+	contentHash, simhash := fingerprintArgs(msg.Body)
+	insertResult, createErr := stmt.ExecContext(ctx, msg.TenantId, msg.Title, msg.Body, msg.Format, nullString(msg.AuthorId), nullInt64(msg.ParentId), nullString(msg.Category), msg.Status, nullTime(msg.PublishAt), nullTime(msg.ExpiresAt), msg.CreatedAt, nullJSON(msg.Metadata), contentHash, simhash)
 	if createErr != nil {
-		return nil, error_formats.ParseError(createErr)
+		return nil, recordRepoErr(span, error_formats.ParseError(createErr))
 	}
 	msgId, err := insertResult.LastInsertId()
 	if err != nil {
-		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save message: %s", err.Error()))
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save message: %s", err.Error())))
 	}
 	msg.Id = msgId
+	msg.Version = 1
 
+	if err := writeTags(ctx, tx, msg, false); err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	if err := writeSlug(ctx, tx, msg); err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	if err := writeModeration(ctx, tx, msg, false); err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	if err := auditChange(ctx, tx, AuditActionCreate, msg.Id, msg.TenantId, nil); err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save message: %s", err.Error())))
+	}
 	return msg, nil
 }
 
-func (mr *messageRepo) Update(msg *Message) (*Message, error_utils.MessageErr) {
-	stmt, err := mr.db.Prepare(queryUpdateMessage)
+// Update saves msg provided it is still at msg.Version, and moves it to the
+// next version. A conflict is returned when another request updated it first.
+func (mr *messageRepo) Update(ctx context.Context, msg *Message) (*Message, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "update", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "update", queryUpdateMessage)
+	defer span.End()
+
+	tx, err := mr.db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare user to update: %s", err.Error()))
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to update message: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	before, snapshotErr := snapshotMessage(ctx, tx, msg.Id, tenantId)
+	if snapshotErr != nil {
+		return nil, recordRepoErr(span, snapshotErr)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, queryUpdateMessage)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare user to update: %s", err.Error())))
 	}
 	defer stmt.Close()
 
-	_, updateErr := stmt.Exec(msg.Title, msg.Body, msg.Id)
+	contentHash, simhash := fingerprintArgs(msg.Body)
+	result, updateErr := stmt.ExecContext(ctx, msg.Title, msg.Body, nullString(msg.Category), msg.Format, nullJSON(msg.Metadata), contentHash, simhash, msg.Id, tenantId, msg.Version)
 	if updateErr != nil {
-		return nil, error_formats.ParseError(updateErr)
+		return nil, recordRepoErr(span, error_formats.ParseError(updateErr))
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return nil, recordRepoErr(span, error_utils.NewConflictError("message was changed by another request"))
+	}
+	msg.Version++
+	if err := writeTags(ctx, tx, msg, true); err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	if err := writeSlug(ctx, tx, msg); err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	if err := writeModeration(ctx, tx, msg, true); err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	if err := auditChange(ctx, tx, AuditActionUpdate, msg.Id, tenantId, before); err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to update message: %s", err.Error())))
 	}
 	return msg, nil
 }
 
-func (mr *messageRepo) Delete(msgId int64) error_utils.MessageErr {
-	stmt, err := mr.db.Prepare(queryDeleteMessage)
+// Delete removes a message. A message that has replies is turned into a
+// tombstone instead: its content, tags and translations are dropped but the
+// row stays, so the replies keep their place in the thread. Either way its
+// attachments are marked for cleanup.
+func (mr *messageRepo) Delete(ctx context.Context, msgId int64) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "delete", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "delete", queryDeleteMessage)
+	defer span.End()
+
+	tx, err := mr.db.BeginTx(ctx, nil)
 	if err != nil {
-		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message: %s", err.Error()))
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message: %s", err.Error())))
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	var replies int
+	if err := tx.QueryRowContext(ctx, queryCountReplies, msgId, tenantId).Scan(&replies); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message: %s", err.Error())))
+	}
+	before, snapshotErr := snapshotMessage(ctx, tx, msgId, tenantId)
+	if snapshotErr != nil {
+		return recordRepoErr(span, snapshotErr)
+	}
+	if replies > 0 {
+		if _, err := tx.ExecContext(ctx, queryTombstoneMessage, time.Now(), msgId, tenantId); err != nil {
+			return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message %s", err.Error())))
+		}
+		if err := writeTags(ctx, tx, &Message{Id: msgId, TenantId: tenantId}, true); err != nil {
+			return recordRepoErr(span, err)
+		}
+		if err := dropTranslations(ctx, tx, msgId); err != nil {
+			return recordRepoErr(span, err)
+		}
+	} else {
+		stmt, err := tx.PrepareContext(ctx, queryDeleteMessage)
+		if err != nil {
+			return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message: %s", err.Error())))
+		}
+		defer stmt.Close()
 
-	if _, err := stmt.Exec(msgId); err != nil {
-		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message %s", err.Error()))
+		if _, err := stmt.ExecContext(ctx, msgId, tenantId); err != nil {
+			return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message %s", err.Error())))
+		}
+	}
+	if err := releaseAttachments(ctx, tx, msgId, tenantId); err != nil {
+		return recordRepoErr(span, err)
+	}
+	if err := auditChange(ctx, tx, AuditActionDelete, msgId, tenantId, before); err != nil {
+		return recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete message %s", err.Error())))
+	}
+	return nil
+}
+
+// Thread returns the message rootId and its replies up to depth levels down,
+// ordered by id. Tombstones are included; expired messages, and with them
+// their replies, are not.
+func (mr *messageRepo) Thread(ctx context.Context, rootId int64, depth int) ([]Message, error_utils.MessageErr) {
+	tenantId := tenant_utils.TenantFrom(ctx)
+	args := append([]interface{}{rootId, tenantId, depth, tenantId, time.Now()}, readableArgs(ctx)...)
+	return mr.list(ctx, "thread", queryThread, args...)
+}
+
+// Pinned returns the messages pinned in scope, a category or "" for the
+// whole tenant, in pin order.
+func (mr *messageRepo) Pinned(ctx context.Context, scope string) ([]Message, error_utils.MessageErr) {
+	tenantId := tenant_utils.TenantFrom(ctx)
+	args := append([]interface{}{tenantId, scope, tenantId, time.Now()}, readableArgs(ctx)...)
+	return mr.list(ctx, "pinned", queryListPinned, args...)
+}
+
+func (mr *messageRepo) CountByAuthorSince(ctx context.Context, authorId string, since time.Time) (int, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "count_by_author", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "count_by_author", queryCountMessagesByAuthor)
+	defer span.End()
+
+	var count int
+	if err := mr.db.QueryRowContext(ctx, queryCountMessagesByAuthor, tenant_utils.TenantFrom(ctx), authorId, since).Scan(&count); err != nil {
+		return 0, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to count messages: %s", err.Error())))
+	}
+	return count, nil
+}
+
+func (mr *messageRepo) ListByAuthor(ctx context.Context, authorId string, afterId int64, limit int) ([]Message, error_utils.MessageErr) {
+	return mr.list(ctx, "list_by_author", queryListByAuthor, tenant_utils.TenantFrom(ctx), authorId, afterId, limit)
+}
+
+func (mr *messageRepo) ListAfter(ctx context.Context, afterId int64, limit int) ([]Message, error_utils.MessageErr) {
+	return mr.list(ctx, "list_after", queryListAfter, tenant_utils.TenantFrom(ctx), afterId, limit)
+}
+
+func (mr *messageRepo) Anonymize(ctx context.Context, msgId int64) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "anonymize", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "anonymize", queryAnonymizeMessage)
+	defer span.End()
+
+	tx, err := mr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to anonymize message: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	before, snapshotErr := snapshotMessage(ctx, tx, msgId, tenantId)
+	if snapshotErr != nil {
+		return recordRepoErr(span, snapshotErr)
+	}
+	if before == nil {
+		return recordRepoErr(span, error_utils.NewNotFoundError("no record matching given id"))
+	}
+	if _, err := tx.ExecContext(ctx, queryAnonymizeMessage, msgId, tenantId); err != nil {
+		return recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if err := auditChange(ctx, tx, AuditActionUpdate, msgId, tenantId, before); err != nil {
+		return recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to anonymize message: %s", err.Error())))
+	}
+	return nil
+}
+
+// UpdateStatus moves msg to msg.Status and msg.PublishAt, provided it is still
+// in fromStatus. A conflict is returned when another request or replica
+// changed the status first. Moving an archived message back to draft is
+// audited as a restore. The review of a message moderation held is started
+// along with it.
+func (mr *messageRepo) UpdateStatus(ctx context.Context, msg *Message, fromStatus string) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "update_status", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "update_status", queryUpdateMessageStatus)
+	defer span.End()
+
+	tx, err := mr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to update message status: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	before, snapshotErr := snapshotMessage(ctx, tx, msg.Id, tenantId)
+	if snapshotErr != nil {
+		return recordRepoErr(span, snapshotErr)
+	}
+	result, err := tx.ExecContext(ctx, queryUpdateMessageStatus, msg.Status, nullTime(msg.PublishAt), msg.Id, tenantId, fromStatus)
+	if err != nil {
+		return recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return recordRepoErr(span, error_utils.NewConflictError("message status was changed by another request"))
+	}
+	action := AuditActionUpdate
+	if fromStatus == MessageStatusArchived && msg.Status == MessageStatusDraft {
+		action = AuditActionRestore
+	}
+	if err := writeModeration(ctx, tx, msg, false); err != nil {
+		return recordRepoErr(span, err)
+	}
+	if err := auditChange(ctx, tx, action, msg.Id, tenantId, before); err != nil {
+		return recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to update message status: %s", err.Error())))
 	}
 	return nil
 }
+
+// List returns the tenant's unexpired messages matching filter, newest first.
+func (mr *messageRepo) List(ctx context.Context, filter MessageFilter) ([]Message, error_utils.MessageErr) {
+	where := []string{"m.tenant_id=?", visible, readable}
+	args := append([]interface{}{tenant_utils.TenantFrom(ctx), time.Now()}, readableArgs(ctx)...)
+	if filter.Category != "" {
+		where = append(where, "m.category=?")
+		args = append(args, filter.Category)
+	}
+	if filter.Status != "" {
+		where = append(where, "m.status=?")
+		args = append(args, filter.Status)
+	}
+	for _, tag := range filter.Tags {
+		where = append(where, "EXISTS (SELECT 1 FROM message_tags fm JOIN tags ft ON ft.id = fm.tag_id WHERE fm.message_id = m.id AND ft.name=?)")
+		args = append(args, tag)
+	}
+	keys := make([]string, 0, len(filter.Metadata))
+	for key := range filter.Metadata {
+		if !ValidMetadataKey(key) {
+			return nil, error_utils.NewBadRequestError(fmt.Sprintf("invalid metadata key %q", key))
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// The path is written into the query, rather than passed as an
+		// argument, so that MySQL can use a generated column indexing it.
+		where = append(where, "JSON_UNQUOTE(JSON_EXTRACT(m.metadata, '$."+key+"'))=?")
+		args = append(args, filter.Metadata[key])
+	}
+	order := "m.id DESC"
+	if filter.PinnedFirst {
+		order = pinnedFirst
+		args = append(args, filter.Category)
+	}
+	query := selectMessages + " WHERE " + strings.Join(where, " AND ") + " GROUP BY m.id ORDER BY " + order + " LIMIT ? OFFSET ?;"
+	args = append(args, filter.Limit, filter.Offset)
+	return mr.list(ctx, "list", query, args...)
+}
+
+// ListDueScheduled returns up to limit unexpired scheduled messages of any
+// tenant whose publish_at is not after now, oldest first.
+func (mr *messageRepo) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]Message, error_utils.MessageErr) {
+	return mr.list(ctx, "list_due_scheduled", queryListDueScheduled, now, now, limit)
+}
+
+// ListExpired returns up to limit messages of any tenant that expired by now
+// and have not been archived yet, oldest first.
+func (mr *messageRepo) ListExpired(ctx context.Context, now time.Time, limit int) ([]Message, error_utils.MessageErr) {
+	return mr.list(ctx, "list_expired", queryListExpired, now, limit)
+}
+
+func (mr *messageRepo) list(ctx context.Context, operation string, query string, args ...interface{}) ([]Message, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", operation, time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", operation, query)
+	defer span.End()
+
+	rows, err := mr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list messages: %s", err.Error())))
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list messages: %s", err.Error())))
+		}
+		messages = append(messages, *msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list messages: %s", err.Error())))
+	}
+	return messages, nil
+}
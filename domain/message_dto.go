@@ -1,16 +1,79 @@
 package domain
 
 import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 	"testing-project/utils/error_utils"
 	"time"
 )
 
+const (
+	MessageStatusDraft     = "draft"
+	MessageStatusScheduled = "scheduled"
+	MessageStatusPublished = "published"
+	MessageStatusArchived  = "archived"
+	// MessageStatusPendingReview holds back a message the moderation checks
+	// flagged until a moderator approves or rejects it.
+	MessageStatusPendingReview = "pending_review"
+	MessageStatusRejected      = "rejected"
+
+	MessageFormatPlain    = "plain"
+	MessageFormatMarkdown = "markdown"
+
+	MaxMessageTags    = 10
+	maxTagLength      = 50
+	maxCategoryLength = 50
+)
+
 type Message struct {
-	Id        int64     `json:"id"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
-	CreatedAt time.Time `json:"created_at"`
+	Id    int64  `json:"id"`
+	Title string `json:"title"`
+	// Slug is derived from the title and addresses the message in URLs.
+	Slug      string     `json:"slug,omitempty"`
+	Body      string     `json:"body"`
+	Format    string     `json:"format"`
+	TenantId  string     `json:"tenant_id,omitempty"`
+	AuthorId  string     `json:"author_id,omitempty"`
+	ParentId  *int64     `json:"parent_id,omitempty"`
+	Category  string     `json:"category,omitempty"`
+	Tags      []string   `json:"tags"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	// Metadata is a JSON object for structured data that does not belong in
+	// the body.
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Version starts at 1 and grows with every update of the message or of
+	// one of its translations.
+	Version int `json:"version"`
+
+	// ReadCount is the number of users who marked the message read.
+	ReadCount int `json:"read_count"`
+	// Reactions counts the users who reacted with each emoji.
+	Reactions map[string]int `json:"reactions,omitempty"`
+	// ReplyCount is the number of direct replies that have not been deleted.
+	ReplyCount int `json:"reply_count"`
+	// Deleted marks a tombstone: a deleted message kept, without its content,
+	// because other messages reply to it. Tombstones only appear in threads.
+	Deleted bool `json:"deleted,omitempty"`
+	// BodyHtml is the body rendered to sanitized HTML, only filled in when
+	// a client asks for it.
+	BodyHtml string `json:"body_html,omitempty"`
+	// Locale is the locale the title and body are in, only filled in when
+	// the message is read or changed in a negotiated or given locale.
+	Locale string `json:"locale,omitempty"`
+	// Moderation is only filled in when the message is held for review by
+	// the write that created or changed it.
+	Moderation *Moderation `json:"moderation,omitempty"`
+	// SimilarTo lists messages whose body the message duplicates, only
+	// filled in when it is created under the warn duplicate policy.
+	SimilarTo []int64 `json:"similar_to,omitempty"`
+	// Replies is only filled in when the message is read as part of a thread.
+	Replies []Message `json:"replies,omitempty"`
 }
 
 func (m *Message) Validate() error_utils.MessageErr {
@@ -22,5 +85,61 @@ func (m *Message) Validate() error_utils.MessageErr {
 	if m.Body == "" {
 		return error_utils.NewUnprocessibleEntityError("Please enter a valid body")
 	}
+	switch m.Format = strings.ToLower(strings.TrimSpace(m.Format)); m.Format {
+	case "":
+		m.Format = MessageFormatPlain
+	case MessageFormatPlain, MessageFormatMarkdown:
+	default:
+		return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("format must be %s or %s", MessageFormatPlain, MessageFormatMarkdown))
+	}
+	m.Category = strings.ToLower(strings.TrimSpace(m.Category))
+	if len(m.Category) > maxCategoryLength {
+		return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("category must be at most %d characters", maxCategoryLength))
+	}
+	return m.normalizeTags()
+}
+
+// normalizeTags lowercases, de-duplicates and sorts the tags so that equal
+// tag sets compare equal.
+func (m *Message) normalizeTags() error_utils.MessageErr {
+	seen := make(map[string]bool, len(m.Tags))
+	tags := make([]string, 0, len(m.Tags))
+	for _, tag := range m.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > maxTagLength || strings.Contains(tag, ",") {
+			return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("tags must be 1 to %d characters without commas", maxTagLength))
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > MaxMessageTags {
+		return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("a message may have at most %d tags", MaxMessageTags))
+	}
+	sort.Strings(tags)
+	m.Tags = tags
 	return nil
 }
+
+// MessageFilter narrows a message listing. A message must carry every tag
+// in Tags, and have every key in Metadata set to the given value, to match.
+// Listings are newest first; with PinnedFirst the messages pinned in
+// Category, or tenant wide when Category is empty, come first in pin order.
+type MessageFilter struct {
+	Tags        []string
+	Category    string
+	Status      string
+	Metadata    map[string]string
+	PinnedFirst bool
+	Limit       int
+	Offset      int
+}
+
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,63}$`)
+
+// ValidMetadataKey reports whether key may be used to filter on metadata.
+// Only plain identifiers are accepted, since the key ends up in a JSON path.
+func ValidMetadataKey(key string) bool {
+	return metadataKeyPattern.MatchString(key)
+}
@@ -1,7 +1,53 @@
   CREATE TABLE `messages` (
   `id` INT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
   `title` VARCHAR(100) NULL,
+  `slug` VARCHAR(120) NULL,
   `body` VARCHAR(200) NULL,
+  `format` VARCHAR(16) NOT NULL DEFAULT 'plain',
+  `author_id` VARCHAR(255) NULL,
+  `parent_id` INT NULL,
+  `category` VARCHAR(50) NULL,
+  `status` VARCHAR(16) NOT NULL DEFAULT 'published',
+  `publish_at` TIMESTAMP NULL,
+  `expires_at` TIMESTAMP NULL,
   `created_at` TIMESTAMP NULL,
+  `deleted_at` TIMESTAMP NULL,
+  `version` INT NOT NULL DEFAULT 1,
+  `metadata` JSON NULL,
+  `read_count` INT NOT NULL DEFAULT 0,
+  `reaction_counts` JSON NULL,
+  -- content_hash is the SHA-256 of the normalized body and simhash its
+  -- SimHash fingerprint. Near duplicates share at least one 8 bit band.
+  `content_hash` CHAR(64) NULL,
+  `simhash` BIGINT NULL,
+  `simhash_band0` TINYINT UNSIGNED GENERATED ALWAYS AS (`simhash` & 255) STORED,
+  `simhash_band1` TINYINT UNSIGNED GENERATED ALWAYS AS ((`simhash` >> 8) & 255) STORED,
+  `simhash_band2` TINYINT UNSIGNED GENERATED ALWAYS AS ((`simhash` >> 16) & 255) STORED,
+  `simhash_band3` TINYINT UNSIGNED GENERATED ALWAYS AS ((`simhash` >> 24) & 255) STORED,
+  `simhash_band4` TINYINT UNSIGNED GENERATED ALWAYS AS ((`simhash` >> 32) & 255) STORED,
+  `simhash_band5` TINYINT UNSIGNED GENERATED ALWAYS AS ((`simhash` >> 40) & 255) STORED,
+  `simhash_band6` TINYINT UNSIGNED GENERATED ALWAYS AS ((`simhash` >> 48) & 255) STORED,
+  `simhash_band7` TINYINT UNSIGNED GENERATED ALWAYS AS ((`simhash` >> 56) & 255) STORED,
+  -- Every metadata key that can be filtered on should get a generated column
+  -- and an index like these; the list queries use the same expression.
+  `meta_priority` VARCHAR(64) COLLATE utf8mb4_bin GENERATED ALWAYS AS (JSON_UNQUOTE(JSON_EXTRACT(`metadata`, '$.priority'))) VIRTUAL,
   PRIMARY KEY (`id`),
-  UNIQUE INDEX `title_UNIQUE` (`title` ASC));
\ No newline at end of file
+  UNIQUE INDEX `tenant_title_UNIQUE` (`tenant_id` ASC, `title` ASC),
+  UNIQUE INDEX `tenant_slug_UNIQUE` (`tenant_id` ASC, `slug` ASC),
+  INDEX `tenant_author_idx` (`tenant_id` ASC, `author_id` ASC, `created_at` ASC),
+  INDEX `status_publish_idx` (`status` ASC, `publish_at` ASC),
+  INDEX `expires_idx` (`expires_at` ASC),
+  INDEX `tenant_category_idx` (`tenant_id` ASC, `category` ASC),
+  INDEX `parent_idx` (`parent_id` ASC),
+  INDEX `tenant_meta_priority_idx` (`tenant_id` ASC, `meta_priority` ASC),
+  INDEX `tenant_content_hash_idx` (`tenant_id` ASC, `content_hash` ASC),
+  INDEX `tenant_simhash_band0_idx` (`tenant_id` ASC, `simhash_band0` ASC),
+  INDEX `tenant_simhash_band1_idx` (`tenant_id` ASC, `simhash_band1` ASC),
+  INDEX `tenant_simhash_band2_idx` (`tenant_id` ASC, `simhash_band2` ASC),
+  INDEX `tenant_simhash_band3_idx` (`tenant_id` ASC, `simhash_band3` ASC),
+  INDEX `tenant_simhash_band4_idx` (`tenant_id` ASC, `simhash_band4` ASC),
+  INDEX `tenant_simhash_band5_idx` (`tenant_id` ASC, `simhash_band5` ASC),
+  INDEX `tenant_simhash_band6_idx` (`tenant_id` ASC, `simhash_band6` ASC),
+  INDEX `tenant_simhash_band7_idx` (`tenant_id` ASC, `simhash_band7` ASC),
+  CONSTRAINT `messages_parent_fk` FOREIGN KEY (`parent_id`) REFERENCES `messages` (`id`) ON DELETE SET NULL) DEFAULT CHARSET=utf8mb4;
\ No newline at end of file
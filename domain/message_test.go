@@ -1,12 +1,18 @@
 package domain
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"net/http"
 	"reflect"
 	"testing"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/fingerprint_utils"
+	"testing-project/utils/tenant_utils"
 	"time"
 )
 
@@ -19,21 +25,29 @@ func TestMessageRepo_Get_OK(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	createdAt := time.Now()
-	rows := sqlmock.NewRows([]string{"Id", "Title", "Body", "CreatedAt"}).
-		AddRow(1, "title", "body", createdAt)
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(1, "default", "title", "body", "user-1", "news", "published", createdAt, nil, createdAt, nil, nil, "plain", 1, nil, 3, `{"👍": 2, "tada": 0}`, nil, 0, "alpha,beta")
 
 	mock.ExpectPrepare("SELECT (.+) FROM messages").
 		ExpectQuery().
-		WithArgs(1).
+		WithArgs(1, "default", sqlmock.AnyArg(), true, "", false).
 		WillReturnRows(rows)
 
-	got, err := repo.Get(1)
+	got, err := repo.Get(context.Background(), 1)
 	assert.NoError(t, err)
 	assert.NotNil(t, got)
 	assert.EqualValues(t, 1, got.Id)
 	assert.Equal(t, "title", got.Title)
 	assert.Equal(t, "body", got.Body)
+	assert.Equal(t, "default", got.TenantId)
+	assert.Equal(t, "user-1", got.AuthorId)
+	assert.Equal(t, "news", got.Category)
+	assert.Equal(t, []string{"alpha", "beta"}, got.Tags)
+	assert.Equal(t, "published", got.Status)
+	assert.NotNil(t, got.PublishAt)
 	assert.WithinDuration(t, createdAt, got.CreatedAt, time.Second)
+	assert.Equal(t, 3, got.ReadCount)
+	assert.Equal(t, map[string]int{"👍": 2}, got.Reactions)
 }
 
 func TestMessageRepo_Get_NotFound(t *testing.T) {
@@ -42,18 +56,149 @@ func TestMessageRepo_Get_NotFound(t *testing.T) {
 	defer db.Close()
 	repo := NewMessageRepository(db)
 
-	rows := sqlmock.NewRows([]string{"Id", "Title", "Body", "CreatedAt"})
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"})
 	mock.ExpectPrepare("SELECT (.+) FROM messages").
 		ExpectQuery().
-		WithArgs(1).
+		WithArgs(1, "default", sqlmock.AnyArg(), true, "", false).
 		WillReturnRows(rows)
 
-	got, err := repo.Get(1)
+	got, err := repo.Get(context.Background(), 1)
 	assert.Nil(t, got)
 	assert.Error(t, err)
 	assert.Equal(t, "not_found", err.Error())
 }
 
+func TestMessageRepo_Get_OtherTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"})
+	mock.ExpectPrepare("SELECT (.+) FROM messages m (.+) WHERE m.id=\\? AND m.tenant_id=\\?").
+		ExpectQuery().
+		WithArgs(1, "acme", sqlmock.AnyArg(), true, "", false).
+		WillReturnRows(rows)
+
+	got, err := repo.Get(tenant_utils.WithTenant(context.Background(), "acme"), 1)
+	assert.Nil(t, got)
+	assert.Error(t, err)
+	assert.Equal(t, "not_found", err.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_Get_DraftOfOtherAuthor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"})
+	mock.ExpectPrepare("SELECT (.+) WHERE m.id=\\? (.+) AND \\(\\? OR m.status='published' OR m.author_id=\\? OR \\(\\? AND m.status='pending_review'\\)\\)").
+		ExpectQuery().
+		WithArgs(1, "default", sqlmock.AnyArg(), false, "user-2", false).
+		WillReturnRows(rows)
+
+	ctx := auth_utils.WithPrincipal(context.Background(), &auth_utils.Principal{Subject: "user-2", Scopes: []string{auth_utils.ScopeMessagesRead}})
+	got, getErr := repo.Get(ctx, 1)
+	assert.Nil(t, got)
+	assert.NotNil(t, getErr)
+	assert.EqualValues(t, http.StatusNotFound, getErr.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_List_ModeratorSeesPendingReview(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"})
+	mock.ExpectQuery("SELECT (.+) AND m.status=\\? GROUP BY m.id").
+		WithArgs("default", sqlmock.AnyArg(), false, "mod-1", true, MessageStatusPendingReview, 50, 0).
+		WillReturnRows(rows)
+
+	ctx := auth_utils.WithPrincipal(context.Background(), &auth_utils.Principal{Subject: "mod-1", Scopes: []string{auth_utils.ScopeMessagesModerate}})
+	_, listErr := repo.List(ctx, MessageFilter{Status: MessageStatusPendingReview, Limit: 50})
+	assert.Nil(t, listErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReadable(t *testing.T) {
+	reader := auth_utils.WithPrincipal(context.Background(), &auth_utils.Principal{Subject: "user-2", Scopes: []string{auth_utils.ScopeMessagesRead}})
+	admin := auth_utils.WithPrincipal(context.Background(), &auth_utils.Principal{Subject: "ops", Scopes: []string{auth_utils.AdminScope}})
+	draft := &Message{AuthorId: "user-1", Status: MessageStatusDraft}
+
+	assert.False(t, Readable(reader, draft))
+	assert.True(t, Readable(reader, &Message{AuthorId: "user-2", Status: MessageStatusDraft}))
+	assert.True(t, Readable(reader, &Message{AuthorId: "user-1", Status: MessageStatusPublished}))
+	assert.True(t, Readable(admin, draft))
+	assert.True(t, Readable(context.Background(), draft))
+}
+
+func TestMessageRepo_GetBySlug_OldSlug(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	createdAt := time.Now()
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(4, "default", "Launch day", "body", nil, nil, "published", nil, nil, createdAt, nil, nil, "plain", 3, nil, 0, nil, "launch-day", 0, nil)
+	mock.ExpectQuery("SELECT (.+) FROM messages m (.+) JOIN message_slugs s ON s.message_id = m.id WHERE s.tenant_id=\\? AND s.slug=\\?").
+		WithArgs("default", "launch", "default", sqlmock.AnyArg(), true, "", false).
+		WillReturnRows(rows)
+
+	got, getErr := repo.GetBySlug(context.Background(), "launch")
+
+	assert.Nil(t, getErr)
+	assert.EqualValues(t, 4, got.Id)
+	assert.Equal(t, "launch-day", got.Slug)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_GetBySlug_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	mock.ExpectQuery("SELECT (.+) FROM messages").
+		WithArgs("default", "nope", "default", sqlmock.AnyArg(), true, "", false).
+		WillReturnError(sql.ErrNoRows)
+
+	got, getErr := repo.GetBySlug(context.Background(), "nope")
+
+	assert.Nil(t, got)
+	assert.NotNil(t, getErr)
+	assert.EqualValues(t, http.StatusNotFound, getErr.Status())
+}
+
+func TestMessageRepo_Similar(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	body := "Team lunch on Friday at noon"
+	simhash := fingerprint_utils.SimHash(body)
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(2, "default", "Lunch", "Team lunch on Friday, at noon!", nil, nil, "published", nil, nil, time.Now(), nil, nil, "plain", 1, nil, 0, nil, "lunch", 0, nil)
+	mock.ExpectQuery("SELECT (.+) FROM messages m (.+) WHERE m.tenant_id=\\? AND m.id<>\\? (.+) BIT_COUNT\\(m.simhash \\^ \\?\\) <= \\?").
+		WithArgs("default", 1, sqlmock.AnyArg(), true, "", false, fingerprint_utils.ContentHash(body),
+			fingerprint_utils.Band(simhash, 0), fingerprint_utils.Band(simhash, 1), fingerprint_utils.Band(simhash, 2), fingerprint_utils.Band(simhash, 3),
+			fingerprint_utils.Band(simhash, 4), fingerprint_utils.Band(simhash, 5), fingerprint_utils.Band(simhash, 6), fingerprint_utils.Band(simhash, 7),
+			int64(simhash), NearDuplicateDistance, int64(simhash), 10).
+		WillReturnRows(rows)
+
+	similar, similarErr := repo.Similar(context.Background(), &Message{Id: 1, Body: body}, 10)
+
+	assert.Nil(t, similarErr)
+	assert.Equal(t, 1, len(similar))
+	assert.EqualValues(t, 2, similar[0].Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestMessageRepo_Get_InvalidPrepare(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -62,15 +207,47 @@ func TestMessageRepo_Get_InvalidPrepare(t *testing.T) {
 
 	mock.ExpectPrepare("SELECT (.+) FROM wrong_table").
 		ExpectQuery().
-		WithArgs(1).
+		WithArgs(1, "default", sqlmock.AnyArg(), true, "", false).
 		WillReturnError(fmt.Errorf("prepare error"))
 
-	got, err := repo.Get(1)
+	got, err := repo.Get(context.Background(), 1)
 	assert.Nil(t, got)
 	assert.Error(t, err)
 	assert.Equal(t, "server_error", err.Error())
 }
 
+func expectNewSlug(mock sqlmock.Sqlmock, tenantId string, slug string, messageId int64) {
+	mock.ExpectQuery("SELECT slug, message_id, base FROM message_slugs WHERE tenant_id=\\? AND \\(slug=\\? OR slug LIKE \\?\\) FOR UPDATE").
+		WithArgs(tenantId, slug, slug+"-%").
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "message_id", "base"}))
+	mock.ExpectExec("INSERT INTO message_slugs").WithArgs(tenantId, slug, slug, messageId, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET slug=\\?").WithArgs(slug, messageId).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+// expectSnapshot expects the message to be read for the audit log, and
+// finds it when status is not empty.
+func expectSnapshot(mock sqlmock.Sqlmock, messageId int64, tenantId string, status string) {
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"})
+	if status != "" {
+		rows.AddRow(messageId, tenantId, "title", "body", nil, nil, status, nil, nil, created_at, nil, nil, "plain", 1, nil, 0, nil, "title", 0, nil)
+	}
+	mock.ExpectQuery("SELECT (.+) FROM messages m (.+) WHERE m.id=\\? AND m.tenant_id=\\? GROUP BY m.id").
+		WithArgs(messageId, tenantId).
+		WillReturnRows(rows)
+}
+
+// expectAudit expects the first entry of the tenant's audit log.
+func expectAudit(mock sqlmock.Sqlmock, tenantId string, messageId int64, action string) {
+	mock.ExpectQuery("SELECT hash FROM audit_log WHERE tenant_id=\\? ORDER BY id DESC LIMIT 1 FOR UPDATE").WithArgs(tenantId).
+		WillReturnRows(sqlmock.NewRows([]string{"hash"}))
+	mock.ExpectExec("INSERT INTO audit_log").
+		WithArgs(tenantId, messageId, action, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), AuditGenesisHash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
 func TestMessageRepo_Create_OK(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -78,17 +255,22 @@ func TestMessageRepo_Create_OK(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	tm := time.Now()
+	mock.ExpectBegin()
 	mock.ExpectPrepare("INSERT INTO messages").
 		ExpectExec().
-		WithArgs("title", "body", tm).
+		WithArgs("default", "title", "body", "", nil, nil, nil, "", nil, nil, tm, nil, fingerprint_utils.ContentHash("body"), int64(fingerprint_utils.SimHash("body"))).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectNewSlug(mock, "default", "title", 1)
+	expectSnapshot(mock, 1, "default", "draft")
+	expectAudit(mock, "default", 1, AuditActionCreate)
+	mock.ExpectCommit()
 
 	input := &Message{
 		Title:     "title",
 		Body:      "body",
 		CreatedAt: tm,
 	}
-	msg, err := repo.Create(input)
+	msg, err := repo.Create(context.Background(), input)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, msg)
@@ -98,6 +280,81 @@ func TestMessageRepo_Create_OK(t *testing.T) {
 	assert.WithinDuration(t, tm, msg.CreatedAt, time.Second)
 }
 
+func TestMessageRepo_Create_SetsTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	tm := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO messages").
+		ExpectExec().
+		WithArgs("acme", "title", "body", "", "user-1", nil, nil, "", nil, nil, tm, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectNewSlug(mock, "acme", "title", 1)
+	expectSnapshot(mock, 1, "acme", "draft")
+	expectAudit(mock, "acme", 1, AuditActionCreate)
+	mock.ExpectCommit()
+
+	input := &Message{Title: "title", Body: "body", AuthorId: "user-1", CreatedAt: tm}
+	msg, err := repo.Create(tenant_utils.WithTenant(context.Background(), "acme"), input)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", msg.TenantId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_Create_WithTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	tm := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO messages").
+		ExpectExec().
+		WithArgs("default", "title", "body", "", nil, nil, "news", "", nil, nil, tm, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec("INSERT INTO tags").WithArgs("default", "alpha").WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectExec("INSERT INTO message_tags").WithArgs(7, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO tags").WithArgs("default", "beta").WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec("INSERT INTO message_tags").WithArgs(7, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNewSlug(mock, "default", "title", 7)
+	expectSnapshot(mock, 7, "default", "draft")
+	expectAudit(mock, "default", 7, AuditActionCreate)
+	mock.ExpectCommit()
+
+	input := &Message{Title: "title", Body: "body", Category: "news", Tags: []string{"alpha", "beta"}, CreatedAt: tm}
+	msg, err := repo.Create(context.Background(), input)
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, msg.Id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_Create_TagFailureRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	tm := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO messages").
+		ExpectExec().
+		WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec("INSERT INTO tags").WillReturnError(errors.New("tags table is gone"))
+	mock.ExpectRollback()
+
+	msg, createErr := repo.Create(context.Background(), &Message{Title: "title", Body: "body", Tags: []string{"alpha"}, CreatedAt: tm})
+
+	assert.Nil(t, msg)
+	assert.NotNil(t, createErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestMessageRepo_Create_EmptyTitle(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -105,9 +362,10 @@ func TestMessageRepo_Create_EmptyTitle(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	tm := time.Now()
+	mock.ExpectBegin()
 	mock.ExpectPrepare("INSERT INTO messages").
 		ExpectExec().
-		WithArgs("title", "body", tm).
+		WithArgs("default", "title", "body", "", nil, nil, nil, "", nil, nil, tm, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnError(errors.New("empty title"))
 
 	input := &Message{
@@ -115,7 +373,7 @@ func TestMessageRepo_Create_EmptyTitle(t *testing.T) {
 		Body:      "body",
 		CreatedAt: tm,
 	}
-	msg, err := repo.Create(input)
+	msg, err := repo.Create(context.Background(), input)
 	assert.Nil(t, msg)
 	assert.Error(t, err)
 	assert.Equal(t, "server_error", err.Error())
@@ -128,9 +386,10 @@ func TestMessageRepo_Create_EmptyBody(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	tm := time.Now()
+	mock.ExpectBegin()
 	mock.ExpectPrepare("INSERT INTO messages").
 		ExpectExec().
-		WithArgs("title", "body", tm).
+		WithArgs("default", "title", "body", "", nil, nil, nil, "", nil, nil, tm, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnError(errors.New("empty body"))
 
 	input := &Message{
@@ -138,7 +397,7 @@ func TestMessageRepo_Create_EmptyBody(t *testing.T) {
 		Body:      "",
 		CreatedAt: tm,
 	}
-	msg, err := repo.Create(input)
+	msg, err := repo.Create(context.Background(), input)
 	assert.Nil(t, msg)
 	assert.Error(t, err)
 	assert.Equal(t, "server_error", err.Error())
@@ -151,9 +410,10 @@ func TestMessageRepo_Create_InvalidSQL(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	tm := time.Now()
+	mock.ExpectBegin()
 	mock.ExpectPrepare("INSERT INTO wrong_table").
 		ExpectExec().
-		WithArgs("title", "body", tm).
+		WithArgs("default", "title", "body", "", nil, nil, nil, "", nil, nil, tm, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
 		WillReturnError(errors.New("invalid sql query"))
 
 	input := &Message{
@@ -161,7 +421,7 @@ func TestMessageRepo_Create_InvalidSQL(t *testing.T) {
 		Body:      "body",
 		CreatedAt: tm,
 	}
-	msg, err := repo.Create(input)
+	msg, err := repo.Create(context.Background(), input)
 	assert.Nil(t, msg)
 	assert.Error(t, err)
 	assert.Equal(t, "server_error", err.Error())
@@ -175,12 +435,21 @@ func TestUpdate_Success(t *testing.T) {
 	defer db.Close()
 	repo := NewMessageRepository(db)
 
-	msg := &Message{Id: 1, Title: "update title", Body: "update body"}
+	msg := &Message{Id: 1, TenantId: "default", Title: "update title", Slug: "update-title-2", Body: "update body"}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
 	mock.ExpectPrepare("UPDATE messages").
-		ExpectExec().WithArgs("update title", "update body", 1).
+		ExpectExec().WithArgs("update title", "update body", nil, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "default", 0).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM message_tags").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("SELECT slug, message_id, base FROM message_slugs").WithArgs("default", "update-title", "update-title-%").
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "message_id", "base"}).AddRow("update-title", 4, "update-title").
+			AddRow("update-title-2", 1, "update-title"))
+	expectSnapshot(mock, 1, "default", "published")
+	expectAudit(mock, "default", 1, AuditActionUpdate)
+	mock.ExpectCommit()
 
-	got, err := repo.Update(msg)
+	got, err := repo.Update(context.Background(), msg)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -189,6 +458,148 @@ func TestUpdate_Success(t *testing.T) {
 	}
 }
 
+func TestUpdate_NewTitle_GetsFreeSlug(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	msg := &Message{Id: 1, TenantId: "default", Title: "Launch!", Slug: "draft", Body: "body", Version: 2}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
+	mock.ExpectPrepare("UPDATE messages").
+		ExpectExec().WithArgs("Launch!", "body", nil, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "default", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM message_tags").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT slug, message_id, base FROM message_slugs").WithArgs("default", "launch", "launch-%").
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "message_id", "base"}).AddRow("launch", 5, "launch").AddRow("launch-2", 6, "launch").
+			AddRow("launch-pad", 7, "launch-pad"))
+	mock.ExpectExec("INSERT INTO message_slugs").WithArgs("default", "launch-3", "launch", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET slug=\\?").WithArgs("launch-3", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 1, "default", "published")
+	expectAudit(mock, "default", 1, AuditActionUpdate)
+	mock.ExpectCommit()
+
+	got, updateErr := repo.Update(context.Background(), msg)
+
+	assert.Nil(t, updateErr)
+	assert.Equal(t, "launch-3", got.Slug)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdate_OldTitle_TakesBackOwnSlug(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	msg := &Message{Id: 1, TenantId: "default", Title: "Launch", Slug: "launch-day", Body: "body", Version: 2}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
+	mock.ExpectPrepare("UPDATE messages").
+		ExpectExec().WithArgs("Launch", "body", nil, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "default", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM message_tags").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT slug, message_id, base FROM message_slugs").WithArgs("default", "launch", "launch-%").
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "message_id", "base"}).AddRow("launch", 1, "launch").AddRow("launch-day", 1, "launch-day"))
+	mock.ExpectExec("UPDATE messages SET slug=\\?").WithArgs("launch", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 1, "default", "published")
+	expectAudit(mock, "default", 1, AuditActionUpdate)
+	mock.ExpectCommit()
+
+	got, updateErr := repo.Update(context.Background(), msg)
+
+	assert.Nil(t, updateErr)
+	assert.Equal(t, "launch", got.Slug)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdate_TitleEndingInNumber_IsNotAVariant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	msg := &Message{Id: 1, TenantId: "default", Title: "Release", Slug: "release-2024", Body: "body", Version: 2}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
+	mock.ExpectPrepare("UPDATE messages").
+		ExpectExec().WithArgs("Release", "body", nil, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "default", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM message_tags").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT slug, message_id, base FROM message_slugs").WithArgs("default", "release", "release-%").
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "message_id", "base"}).AddRow("release", 5, "release").
+			AddRow("release-2024", 1, "release-2024"))
+	mock.ExpectExec("INSERT INTO message_slugs").WithArgs("default", "release-2", "release", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET slug=\\?").WithArgs("release-2", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 1, "default", "published")
+	expectAudit(mock, "default", 1, AuditActionUpdate)
+	mock.ExpectCommit()
+
+	got, updateErr := repo.Update(context.Background(), msg)
+
+	assert.Nil(t, updateErr)
+	assert.Equal(t, "release-2", got.Slug)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdate_NumericTitle_SkipsVariantOfAnotherBase(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	msg := &Message{Id: 1, TenantId: "default", Title: "Top 10", Slug: "draft", Body: "body", Version: 2}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
+	mock.ExpectPrepare("UPDATE messages").
+		ExpectExec().WithArgs("Top 10", "body", nil, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "default", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM message_tags").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT slug, message_id, base FROM message_slugs").WithArgs("default", "top-10", "top-10-%").
+		WillReturnRows(sqlmock.NewRows([]string{"slug", "message_id", "base"}).AddRow("top-10", 5, "top"))
+	mock.ExpectExec("INSERT INTO message_slugs").WithArgs("default", "top-10-2", "top-10", 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET slug=\\?").WithArgs("top-10-2", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 1, "default", "published")
+	expectAudit(mock, "default", 1, AuditActionUpdate)
+	mock.ExpectCommit()
+
+	got, updateErr := repo.Update(context.Background(), msg)
+
+	assert.Nil(t, updateErr)
+	assert.Equal(t, "top-10-2", got.Slug)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdate_StaleVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	msg := &Message{Id: 1, Title: "update title", Body: "update body", Format: "markdown", Version: 3}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
+	mock.ExpectPrepare("UPDATE messages SET (.+) version=version\\+1 WHERE id=\\? AND tenant_id=\\? AND version=\\?").
+		ExpectExec().WithArgs("update title", "update body", nil, "markdown", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "default", 3).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	got, updateErr := repo.Update(context.Background(), msg)
+
+	assert.Nil(t, got)
+	assert.EqualValues(t, http.StatusConflict, updateErr.Status())
+	assert.EqualValues(t, 3, msg.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdate_InvalidSQL(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -198,11 +609,13 @@ func TestUpdate_InvalidSQL(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	msg := &Message{Id: 1, Title: "update title", Body: "update body"}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
 	mock.ExpectPrepare("UPDATER messages").
-		ExpectExec().WithArgs("update title", "update body", 1).
+		ExpectExec().WithArgs("update title", "update body", nil, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "default", 0).
 		WillReturnError(errors.New("invalid SQL"))
 
-	_, err = repo.Update(msg)
+	_, err = repo.Update(context.Background(), msg)
 	if err == nil {
 		t.Error("expected error but got none")
 	}
@@ -217,11 +630,13 @@ func TestUpdate_InvalidId(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	msg := &Message{Id: 0, Title: "update title", Body: "update body"}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 0, "default", "published")
 	mock.ExpectPrepare("UPDATE messages").
-		ExpectExec().WithArgs("update title", "update body", 0).
+		ExpectExec().WithArgs("update title", "update body", nil, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 0, "default", 0).
 		WillReturnError(errors.New("invalid update id"))
 
-	_, err = repo.Update(msg)
+	_, err = repo.Update(context.Background(), msg)
 	if err == nil {
 		t.Error("expected error but got none")
 	}
@@ -236,11 +651,13 @@ func TestUpdate_EmptyTitle(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	msg := &Message{Id: 1, Title: "", Body: "update body"}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
 	mock.ExpectPrepare("UPDATE messages").
-		ExpectExec().WithArgs("", "update body", 1).
+		ExpectExec().WithArgs("", "update body", nil, 1, "default").
 		WillReturnError(errors.New("Please enter a valid title"))
 
-	_, err = repo.Update(msg)
+	_, err = repo.Update(context.Background(), msg)
 	if err == nil {
 		t.Error("expected error but got none")
 	}
@@ -255,11 +672,13 @@ func TestUpdate_EmptyBody(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	msg := &Message{Id: 1, Title: "update title", Body: ""}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
 	mock.ExpectPrepare("UPDATE messages").
-		ExpectExec().WithArgs("update title", "", 1).
+		ExpectExec().WithArgs("update title", "", nil, 1, "default").
 		WillReturnError(errors.New("Please enter a valid body"))
 
-	_, err = repo.Update(msg)
+	_, err = repo.Update(context.Background(), msg)
 	if err == nil {
 		t.Error("expected error but got none")
 	}
@@ -274,11 +693,13 @@ func TestUpdate_UpdateFailed(t *testing.T) {
 	repo := NewMessageRepository(db)
 
 	msg := &Message{Id: 1, Title: "update title", Body: "update body"}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
 	mock.ExpectPrepare("UPDATE messages").
-		ExpectExec().WithArgs("update title", "update body", 1).
+		ExpectExec().WithArgs("update title", "update body", nil, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, "default", 0).
 		WillReturnError(errors.New("Update failed"))
 
-	_, err = repo.Update(msg)
+	_, err = repo.Update(context.Background(), msg)
 	if err == nil {
 		t.Error("expected error but got none")
 	}
@@ -292,14 +713,54 @@ func TestDelete_Success(t *testing.T) {
 	defer db.Close()
 	repo := NewMessageRepository(db)
 
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(r.id\\) FROM messages m").WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectSnapshot(mock, 1, "default", "published")
 	mock.ExpectPrepare("DELETE FROM messages").
-		ExpectExec().WithArgs(1).
+		ExpectExec().WithArgs(1, "default").
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE attachments SET deleted_at=\\?").WithArgs(sqlmock.AnyArg(), 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	expectSnapshot(mock, 1, "default", "")
+	expectAudit(mock, "default", 1, AuditActionDelete)
+	mock.ExpectCommit()
 
-	err = repo.Delete(1)
+	err = repo.Delete(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDelete_WithReplies_LeavesTombstone(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("unexpected error when opening stub db: %v", err)
+	}
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(r.id\\) FROM messages m").WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	expectSnapshot(mock, 1, "default", "published")
+	mock.ExpectExec("UPDATE messages SET title=NULL, body=NULL, category=NULL, metadata=NULL, content_hash=NULL, simhash=NULL, deleted_at=\\?").
+		WithArgs(sqlmock.AnyArg(), 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM message_tags").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM message_translations WHERE message_id=\\?").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE attachments SET deleted_at=\\?").WithArgs(sqlmock.AnyArg(), 1, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	expectSnapshot(mock, 1, "default", "published")
+	expectAudit(mock, "default", 1, AuditActionDelete)
+	mock.ExpectCommit()
+
+	err = repo.Delete(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestDelete_NotFound(t *testing.T) {
@@ -310,11 +771,15 @@ func TestDelete_NotFound(t *testing.T) {
 	defer db.Close()
 	repo := NewMessageRepository(db)
 
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(r.id\\) FROM messages m").WithArgs(100, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectSnapshot(mock, 100, "default", "published")
 	mock.ExpectPrepare("DELETE FROM messages").
-		ExpectExec().WithArgs(100).
+		ExpectExec().WithArgs(100, "default").
 		WillReturnError(errors.New("Row not found"))
 
-	err = repo.Delete(100)
+	err = repo.Delete(context.Background(), 100)
 	if err == nil {
 		t.Error("expected error but got none")
 	}
@@ -328,11 +793,15 @@ func TestDelete_InvalidSQL(t *testing.T) {
 	defer db.Close()
 	repo := NewMessageRepository(db)
 
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT COUNT\\(r.id\\) FROM messages m").WithArgs(1, "default").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	expectSnapshot(mock, 1, "default", "published")
 	mock.ExpectPrepare("DELETE FROMSSSS messages").
-		ExpectExec().WithArgs(1).
+		ExpectExec().WithArgs(1, "default").
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
-	err = repo.Delete(1)
+	err = repo.Delete(context.Background(), 1)
 	if err == nil {
 		t.Error("expected error but got none")
 	}
@@ -348,3 +817,289 @@ func TestMessageRepo_Initialize(t *testing.T) {
 	dbConnect := MessageRepo.Initialize(dbdriver, username, password, port, host, database)
 	fmt.Println("this is the pool: ", dbConnect)
 }
+
+func TestMessageRepo_CountByAuthorSince(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	since := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COUNT(.+) FROM messages WHERE tenant_id=(.+) AND author_id").
+		WithArgs("default", "user-1", since).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+
+	count, countErr := repo.CountByAuthorSince(context.Background(), "user-1", since)
+	assert.Nil(t, countErr)
+	assert.Equal(t, 4, count)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_UpdateStatus_Conflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	mock.ExpectBegin()
+	expectSnapshot(mock, 1, "default", "published")
+	mock.ExpectExec("UPDATE messages SET status").
+		WithArgs("published", created_at, 1, "default", "scheduled").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	updateErr := repo.UpdateStatus(context.Background(), &Message{Id: 1, Status: "published", PublishAt: &created_at}, "scheduled")
+	assert.NotNil(t, updateErr)
+	assert.Equal(t, "conflict", updateErr.Error())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_ListDueScheduled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(1, "acme", "first", "body", nil, nil, "scheduled", now, nil, now, nil, nil, "plain", 1, nil, 0, nil, nil, 0, nil).
+		AddRow(2, "globex", "second", "body", "user-1", nil, "scheduled", now, nil, now, nil, nil, "plain", 1, nil, 0, nil, nil, 0, "launch")
+	mock.ExpectQuery("SELECT (.+) FROM messages m (.+) WHERE m.status='scheduled' AND m.publish_at <=").
+		WithArgs(now, now, 10).
+		WillReturnRows(rows)
+
+	due, listErr := repo.ListDueScheduled(context.Background(), now, 10)
+	assert.Nil(t, listErr)
+	assert.Equal(t, 2, len(due))
+	assert.Equal(t, "globex", due[1].TenantId)
+	assert.Equal(t, "user-1", due[1].AuthorId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_ListByAuthor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(8, "acme", nil, nil, "user-1", nil, "published", nil, nil, created_at, nil, created_at, "plain", 2, nil, 0, nil, nil, 1, nil)
+	mock.ExpectQuery("SELECT (.+) FROM messages m (.+) WHERE m.tenant_id=\\? AND m.author_id=\\? AND m.id>\\? GROUP BY m.id ORDER BY m.id LIMIT \\?").
+		WithArgs("acme", "user-1", 7, 100).
+		WillReturnRows(rows)
+
+	messages, listErr := repo.ListByAuthor(tenant_utils.WithTenant(context.Background(), "acme"), "user-1", 7, 100)
+	assert.Nil(t, listErr)
+	assert.Equal(t, 1, len(messages))
+	assert.True(t, messages[0].Deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_ListAfter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	expiresAt := created_at.Add(time.Hour)
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(8, "acme", "Launch day", "body", "user-1", nil, "published", nil, expiresAt, created_at, nil, nil, "plain", 1, nil, 0, nil, "launch-day", 0, "news,release")
+	mock.ExpectQuery("SELECT (.+) FROM messages m (.+) WHERE m.tenant_id=\\? AND m.id>\\? AND m.deleted_at IS NULL GROUP BY m.id ORDER BY m.id LIMIT \\?").
+		WithArgs("acme", 7, 100).
+		WillReturnRows(rows)
+
+	messages, listErr := repo.ListAfter(tenant_utils.WithTenant(context.Background(), "acme"), 7, 100)
+	assert.Nil(t, listErr)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, []string{"news", "release"}, messages[0].Tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_GetByTitle(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(4, "acme", "Launch day", "body", nil, nil, "draft", nil, nil, created_at, nil, nil, "plain", 3, nil, 0, nil, "launch-day", 0, nil)
+	mock.ExpectQuery("SELECT (.+) FROM messages m (.+) WHERE m.tenant_id=\\? AND m.title=\\? AND m.deleted_at IS NULL GROUP BY m.id").
+		WithArgs("acme", "Launch day").
+		WillReturnRows(rows)
+
+	got, getErr := repo.GetByTitle(tenant_utils.WithTenant(context.Background(), "acme"), "Launch day")
+
+	assert.Nil(t, getErr)
+	assert.EqualValues(t, 4, got.Id)
+	assert.Equal(t, 3, got.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_GetByTitle_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	mock.ExpectQuery("SELECT (.+) FROM messages").
+		WithArgs("default", "nope").
+		WillReturnError(sql.ErrNoRows)
+
+	got, getErr := repo.GetByTitle(context.Background(), "nope")
+
+	assert.Nil(t, got)
+	assert.NotNil(t, getErr)
+	assert.EqualValues(t, http.StatusNotFound, getErr.Status())
+}
+
+func TestMessageRepo_ListExpired(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(1, "acme", "notice", "body", nil, "notice", "published", now, now, now, nil, nil, "plain", 1, nil, 0, nil, nil, 0, nil)
+	mock.ExpectQuery("SELECT (.+) FROM messages m (.+) WHERE m.expires_at <= (.+) AND m.status <> 'archived'").
+		WithArgs(now, 10).
+		WillReturnRows(rows)
+
+	expired, listErr := repo.ListExpired(context.Background(), now, 10)
+	assert.Nil(t, listErr)
+	assert.Equal(t, 1, len(expired))
+	assert.NotNil(t, expired[0].ExpiresAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_List_Filters(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(3, "acme", "launch", "body", nil, "news", "published", now, nil, now, nil, nil, "plain", 1, nil, 0, nil, nil, 0, "alpha,beta")
+	mock.ExpectQuery("SELECT (.+) WHERE m.tenant_id=(.+) AND m.category=\\? AND EXISTS (.+) AND EXISTS (.+) GROUP BY m.id ORDER BY m.id DESC LIMIT \\? OFFSET \\?").
+		WithArgs("acme", sqlmock.AnyArg(), true, "", false, "news", "alpha", "beta", 20, 40).
+		WillReturnRows(rows)
+
+	ctx := tenant_utils.WithTenant(context.Background(), "acme")
+	messages, listErr := repo.List(ctx, MessageFilter{Tags: []string{"alpha", "beta"}, Category: "news", Limit: 20, Offset: 40})
+	assert.Nil(t, listErr)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, []string{"alpha", "beta"}, messages[0].Tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_List_FiltersOnMetadata(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(3, "acme", "launch", "body", nil, nil, "published", now, nil, now, nil, nil, "plain", 1, `{"priority":"high","team":"ops"}`, 0, nil, nil, 0, nil)
+	mock.ExpectQuery("SELECT (.+) AND JSON_UNQUOTE\\(JSON_EXTRACT\\(m.metadata, '\\$.priority'\\)\\)=\\? AND JSON_UNQUOTE\\(JSON_EXTRACT\\(m.metadata, '\\$.team'\\)\\)=\\? GROUP BY").
+		WithArgs("acme", sqlmock.AnyArg(), true, "", false, "high", "ops", 50, 0).
+		WillReturnRows(rows)
+
+	ctx := tenant_utils.WithTenant(context.Background(), "acme")
+	messages, listErr := repo.List(ctx, MessageFilter{Metadata: map[string]string{"team": "ops", "priority": "high"}, Limit: 50})
+	assert.Nil(t, listErr)
+	assert.Equal(t, 1, len(messages))
+	assert.JSONEq(t, `{"priority":"high","team":"ops"}`, string(messages[0].Metadata))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_List_RejectsMetadataPath(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	_, listErr := repo.List(context.Background(), MessageFilter{Metadata: map[string]string{"a')) OR 1=1 --": "x"}, Limit: 50})
+	assert.NotNil(t, listErr)
+	assert.EqualValues(t, http.StatusBadRequest, listErr.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_ListTags(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	mock.ExpectQuery("SELECT t.name, COUNT(.+) FROM tags t").
+		WithArgs("default", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count"}).AddRow("alpha", 3).AddRow("beta", 1))
+
+	tags, listErr := repo.ListTags(context.Background())
+	assert.Nil(t, listErr)
+	assert.Equal(t, []TagCount{{Name: "alpha", Count: 3}, {Name: "beta", Count: 1}}, tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_Thread(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(1, "acme", nil, nil, nil, nil, "published", now, nil, now, nil, now, "plain", 1, nil, 0, nil, nil, 1, nil).
+		AddRow(2, "acme", "reply", "body", "user-1", nil, "published", now, nil, now, 1, nil, "plain", 1, nil, 0, nil, nil, 0, nil)
+	mock.ExpectQuery("WITH RECURSIVE thread (.+) JOIN thread th ON th.id = m.id WHERE m.tenant_id=\\?").
+		WithArgs(1, "acme", 2, "acme", sqlmock.AnyArg(), true, "", false).
+		WillReturnRows(rows)
+
+	messages, threadErr := repo.Thread(tenant_utils.WithTenant(context.Background(), "acme"), 1, 2)
+	assert.Nil(t, threadErr)
+	assert.Equal(t, 2, len(messages))
+	assert.True(t, messages[0].Deleted)
+	assert.Equal(t, "", messages[0].Title)
+	assert.Equal(t, 1, messages[0].ReplyCount)
+	assert.EqualValues(t, 1, *messages[1].ParentId)
+	assert.False(t, messages[1].Deleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessage_Validate_NormalizesTags(t *testing.T) {
+	msg := &Message{Title: "title", Body: "body", Category: " News ", Tags: []string{"SQL", " go", "sql"}}
+
+	err := msg.Validate()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "news", msg.Category)
+	assert.Equal(t, []string{"go", "sql"}, msg.Tags)
+}
+
+func TestMessage_Validate_TooManyTags(t *testing.T) {
+	msg := &Message{Title: "title", Body: "body"}
+	for i := 0; i <= MaxMessageTags; i++ {
+		msg.Tags = append(msg.Tags, fmt.Sprintf("tag-%d", i))
+	}
+
+	err := msg.Validate()
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+}
+
+func TestMessage_Validate_Format(t *testing.T) {
+	msg := &Message{Title: "title", Body: "body"}
+	assert.Nil(t, msg.Validate())
+	assert.Equal(t, MessageFormatPlain, msg.Format)
+
+	msg.Format = " Markdown "
+	assert.Nil(t, msg.Validate())
+	assert.Equal(t, MessageFormatMarkdown, msg.Format)
+
+	msg.Format = "html"
+	err := msg.Validate()
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+}
@@ -0,0 +1,133 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+var (
+	ModerationRepo moderationRepoInterface = &moderationRepo{}
+)
+
+const (
+	// queryFlagMessage starts a new review, discarding the decision of any
+	// earlier one.
+	queryFlagMessage   = "REPLACE INTO message_moderation(message_id, tenant_id, flags, flagged_at) VALUES(?, ?, ?, ?);"
+	queryHoldMessage   = "UPDATE messages SET status=?, publish_at=? WHERE id=? AND tenant_id=?;"
+	queryGetModeration = "SELECT mm.message_id, mm.flags, mm.flagged_at, mm.decision, mm.reason, mm.moderator_id, mm.decided_at " +
+		"FROM message_moderation mm WHERE mm.message_id=? AND mm.tenant_id=?;"
+	queryDecideStatus = "UPDATE messages SET status=?, publish_at=? WHERE id=? AND tenant_id=? AND status='pending_review' AND deleted_at IS NULL;"
+	queryDecide       = "UPDATE message_moderation SET decision=?, reason=?, moderator_id=?, decided_at=? WHERE message_id=?;"
+)
+
+// moderationRepoInterface reads reviews and records the decisions of
+// moderators. Reviews are started along with the message write that held
+// the message back, see writeModeration.
+type moderationRepoInterface interface {
+	Get(ctx context.Context, messageId int64) (*Moderation, error_utils.MessageErr)
+	Decide(ctx context.Context, msg *Message, moderation *Moderation) error_utils.MessageErr
+}
+
+type moderationRepo struct {
+	db *sql.DB
+}
+
+func NewModerationRepository(db *sql.DB) moderationRepoInterface {
+	return &moderationRepo{db: db}
+}
+
+// writeModeration starts the review of msg inside tx when the checks held
+// it back. With hold set the message is moved to its held status too, for
+// writes that do not save the status themselves.
+func writeModeration(ctx context.Context, tx *sql.Tx, msg *Message, hold bool) error_utils.MessageErr {
+	if msg.Moderation == nil {
+		return nil
+	}
+	flags, err := json.Marshal(msg.Moderation.Flags)
+	if err != nil {
+		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save moderation: %s", err.Error()))
+	}
+	if _, err := tx.ExecContext(ctx, queryFlagMessage, msg.Id, msg.TenantId, flags, msg.Moderation.FlaggedAt); err != nil {
+		return error_formats.ParseError(err)
+	}
+	if hold {
+		if _, err := tx.ExecContext(ctx, queryHoldMessage, msg.Status, nullTime(msg.PublishAt), msg.Id, tenant_utils.TenantFrom(ctx)); err != nil {
+			return error_formats.ParseError(err)
+		}
+	}
+	msg.Moderation.MessageId = msg.Id
+	return nil
+}
+
+// Get returns the latest review of a message of the tenant.
+func (mr *moderationRepo) Get(ctx context.Context, messageId int64) (*Moderation, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("moderationRepo", "get", time.Now())
+	ctx, span := startRepoSpan(ctx, "moderationRepo", "get", queryGetModeration)
+	defer span.End()
+
+	var moderation Moderation
+	var flags []byte
+	var decision, reason, moderatorId sql.NullString
+	var decidedAt sql.NullTime
+	err := mr.db.QueryRowContext(ctx, queryGetModeration, messageId, tenant_utils.TenantFrom(ctx)).
+		Scan(&moderation.MessageId, &flags, &moderation.FlaggedAt, &decision, &reason, &moderatorId, &decidedAt)
+	if err != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if err := json.Unmarshal(flags, &moderation.Flags); err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to get moderation: %s", err.Error())))
+	}
+	moderation.Decision = decision.String
+	moderation.Reason = reason.String
+	moderation.ModeratorId = moderatorId.String
+	if decidedAt.Valid {
+		moderation.DecidedAt = &decidedAt.Time
+	}
+	return &moderation, nil
+}
+
+// Decide moves msg, which must still be pending review, to msg.Status and
+// msg.PublishAt, and records the decision on its review. A conflict is
+// returned when another moderator decided first.
+func (mr *moderationRepo) Decide(ctx context.Context, msg *Message, moderation *Moderation) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("moderationRepo", "decide", time.Now())
+	ctx, span := startRepoSpan(ctx, "moderationRepo", "decide", queryDecideStatus)
+	defer span.End()
+
+	tx, err := mr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to decide on message: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	before, snapshotErr := snapshotMessage(ctx, tx, msg.Id, tenantId)
+	if snapshotErr != nil {
+		return recordRepoErr(span, snapshotErr)
+	}
+	result, err := tx.ExecContext(ctx, queryDecideStatus, msg.Status, nullTime(msg.PublishAt), msg.Id, tenantId)
+	if err != nil {
+		return recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return recordRepoErr(span, error_utils.NewConflictError("message is no longer pending review"))
+	}
+	if _, err := tx.ExecContext(ctx, queryDecide, moderation.Decision, nullString(moderation.Reason), nullString(moderation.ModeratorId),
+		nullTime(moderation.DecidedAt), msg.Id); err != nil {
+		return recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if err := auditChange(ctx, tx, AuditActionUpdate, msg.Id, tenantId, before); err != nil {
+		return recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to decide on message: %s", err.Error())))
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package domain
+
+import (
+	"strings"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/moderation_utils"
+	"time"
+)
+
+const (
+	ModerationApproved = "approved"
+	ModerationRejected = "rejected"
+
+	maxModerationReasonLength = 500
+)
+
+// Moderation is the review of a message that was held back: why the checks
+// flagged it and, once a moderator looked at it, what they decided.
+type Moderation struct {
+	MessageId   int64                   `json:"message_id"`
+	Flags       []moderation_utils.Flag `json:"flags"`
+	FlaggedAt   time.Time               `json:"flagged_at"`
+	Decision    string                  `json:"decision,omitempty"`
+	Reason      string                  `json:"reason,omitempty"`
+	ModeratorId string                  `json:"moderator_id,omitempty"`
+	DecidedAt   *time.Time              `json:"decided_at,omitempty"`
+}
+
+// ValidateDecision checks the reason given for a decision. Rejections must
+// say why.
+func (m *Moderation) ValidateDecision() error_utils.MessageErr {
+	m.Reason = strings.TrimSpace(m.Reason)
+	if m.Decision == ModerationRejected && m.Reason == "" {
+		return error_utils.NewUnprocessibleEntityError("Please enter a reason for the rejection")
+	}
+	if len(m.Reason) > maxModerationReasonLength {
+		return error_utils.NewUnprocessibleEntityError("reason is too long")
+	}
+	return nil
+}
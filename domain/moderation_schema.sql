@@ -0,0 +1,12 @@
+  CREATE TABLE `message_moderation` (
+  `message_id` INT NOT NULL,
+  `tenant_id` VARCHAR(64) NOT NULL,
+  `flags` JSON NOT NULL,
+  `flagged_at` TIMESTAMP NULL,
+  `decision` VARCHAR(16) NULL,
+  `reason` VARCHAR(500) NULL,
+  `moderator_id` VARCHAR(255) NULL,
+  `decided_at` TIMESTAMP NULL,
+  PRIMARY KEY (`message_id`),
+  INDEX `tenant_flagged_idx` (`tenant_id` ASC, `flagged_at` ASC),
+  CONSTRAINT `message_moderation_message_fk` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE);
@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/utils/moderation_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+func TestModerationRepo_Create_HeldMessageStartsReview(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	flaggedAt := time.Now()
+	msg := &Message{Title: "title", Body: "body", Status: MessageStatusPendingReview, CreatedAt: flaggedAt,
+		Moderation: &Moderation{Flags: []moderation_utils.Flag{{Check: "word_filter", Reason: `matches "body"`}}, FlaggedAt: flaggedAt}}
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO messages").
+		ExpectExec().
+		WithArgs("default", "title", "body", "", nil, nil, nil, MessageStatusPendingReview, nil, nil, flaggedAt, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	expectNewSlug(mock, "default", "title", 3)
+	mock.ExpectExec("REPLACE INTO message_moderation").
+		WithArgs(3, "default", []byte(`[{"check":"word_filter","reason":"matches \"body\""}]`), flaggedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 3, "default", MessageStatusPendingReview)
+	expectAudit(mock, "default", 3, AuditActionCreate)
+	mock.ExpectCommit()
+
+	created, createErr := repo.Create(context.Background(), msg)
+
+	assert.Nil(t, createErr)
+	assert.EqualValues(t, 3, created.Moderation.MessageId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationRepo_UpdateStatus_HeldMessageStartsReview(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	flaggedAt := time.Now()
+	msg := &Message{Id: 3, TenantId: "default", Status: MessageStatusPendingReview,
+		Moderation: &Moderation{Flags: []moderation_utils.Flag{{Check: "word_filter", Reason: `matches "body"`}}, FlaggedAt: flaggedAt}}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 3, "default", MessageStatusDraft)
+	mock.ExpectExec("UPDATE messages SET status").
+		WithArgs(MessageStatusPendingReview, nil, 3, "default", MessageStatusDraft).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("REPLACE INTO message_moderation").
+		WithArgs(3, "default", []byte(`[{"check":"word_filter","reason":"matches \"body\""}]`), flaggedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 3, "default", MessageStatusPendingReview)
+	expectAudit(mock, "default", 3, AuditActionUpdate)
+	mock.ExpectCommit()
+
+	assert.Nil(t, repo.UpdateStatus(context.Background(), msg, MessageStatusDraft))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationRepo_Get(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewModerationRepository(db)
+
+	flaggedAt := time.Now()
+	rows := sqlmock.NewRows([]string{"message_id", "flags", "flagged_at", "decision", "reason", "moderator_id", "decided_at"}).
+		AddRow(3, `[{"check":"spam_score","reason":"spam score 0.75 reaches 0.50"}]`, flaggedAt, "rejected", "advertising", "mod-1", flaggedAt)
+	mock.ExpectQuery("SELECT (.+) FROM message_moderation mm WHERE mm.message_id=\\? AND mm.tenant_id=\\?").
+		WithArgs(3, "acme").
+		WillReturnRows(rows)
+
+	moderation, getErr := repo.Get(tenant_utils.WithTenant(context.Background(), "acme"), 3)
+
+	assert.Nil(t, getErr)
+	assert.Equal(t, []moderation_utils.Flag{{Check: "spam_score", Reason: "spam score 0.75 reaches 0.50"}}, moderation.Flags)
+	assert.Equal(t, ModerationRejected, moderation.Decision)
+	assert.Equal(t, "advertising", moderation.Reason)
+	assert.Equal(t, "mod-1", moderation.ModeratorId)
+	assert.NotNil(t, moderation.DecidedAt)
+}
+
+func TestModerationRepo_Get_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewModerationRepository(db)
+
+	mock.ExpectQuery("SELECT (.+) FROM message_moderation").WithArgs(3, "default").WillReturnError(sql.ErrNoRows)
+
+	_, getErr := repo.Get(context.Background(), 3)
+
+	assert.NotNil(t, getErr)
+	assert.EqualValues(t, http.StatusNotFound, getErr.Status())
+}
+
+func TestModerationRepo_Decide(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewModerationRepository(db)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	expectSnapshot(mock, 3, "default", MessageStatusPendingReview)
+	mock.ExpectExec("UPDATE messages SET status=\\?, publish_at=\\? WHERE id=\\? AND tenant_id=\\? AND status='pending_review'").
+		WithArgs(MessageStatusPublished, now, 3, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE message_moderation SET decision=\\?").
+		WithArgs(ModerationApproved, nil, "mod-1", now, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 3, "default", MessageStatusPublished)
+	expectAudit(mock, "default", 3, AuditActionUpdate)
+	mock.ExpectCommit()
+
+	decideErr := repo.Decide(context.Background(), &Message{Id: 3, Status: MessageStatusPublished, PublishAt: &now},
+		&Moderation{Decision: ModerationApproved, ModeratorId: "mod-1", DecidedAt: &now})
+
+	assert.Nil(t, decideErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationRepo_Decide_AlreadyDecided(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewModerationRepository(db)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	expectSnapshot(mock, 3, "default", MessageStatusPendingReview)
+	mock.ExpectExec("UPDATE messages SET status=\\?").
+		WithArgs(MessageStatusRejected, nil, 3, "default").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	decideErr := repo.Decide(context.Background(), &Message{Id: 3, Status: MessageStatusRejected},
+		&Moderation{Decision: ModerationRejected, Reason: "spam", DecidedAt: &now})
+
+	assert.NotNil(t, decideErr)
+	assert.EqualValues(t, http.StatusConflict, decideErr.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestModerationRepo_Update_HeldMessageLeavesPublished(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	flaggedAt := time.Now()
+	msg := &Message{Id: 3, TenantId: "default", Title: "title", Slug: "title", Body: "body", Status: MessageStatusPendingReview, Version: 2,
+		Moderation: &Moderation{Flags: []moderation_utils.Flag{}, FlaggedAt: flaggedAt}}
+	mock.ExpectBegin()
+	expectSnapshot(mock, 3, "default", MessageStatusPublished)
+	mock.ExpectPrepare("UPDATE messages").
+		ExpectExec().WithArgs("title", "body", nil, "", nil, sqlmock.AnyArg(), sqlmock.AnyArg(), 3, "default", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM message_tags").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("REPLACE INTO message_moderation").WithArgs(3, "default", []byte("[]"), flaggedAt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET status=\\?, publish_at=\\? WHERE id=\\? AND tenant_id=\\?;").WithArgs(MessageStatusPendingReview, nil, 3, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectSnapshot(mock, 3, "default", MessageStatusPendingReview)
+	expectAudit(mock, "default", 3, AuditActionUpdate)
+	mock.ExpectCommit()
+
+	_, updateErr := repo.Update(context.Background(), msg)
+
+	assert.Nil(t, updateErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,171 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+// MaxPinnedMessages caps the pins in one scope.
+const MaxPinnedMessages = 25
+
+var (
+	PinRepo pinRepoInterface = &pinRepo{}
+)
+
+const (
+	// queryLockPins locks the pins of a scope, and the gap where new ones
+	// would go, so that pin changes in one scope happen one at a time.
+	queryLockPins  = "SELECT message_id, position FROM message_pins WHERE tenant_id=? AND scope=? ORDER BY position, message_id FOR UPDATE;"
+	queryInsertPin = "INSERT INTO message_pins(tenant_id, scope, message_id, position, pinned_at) VALUES(?, ?, ?, ?, ?);"
+	queryDeletePin = "DELETE FROM message_pins WHERE tenant_id=? AND scope=? AND message_id=?;"
+	queryMovePin   = "UPDATE message_pins SET position=? WHERE tenant_id=? AND scope=? AND message_id=?;"
+)
+
+// pinRepoInterface keeps, per tenant, ordered lists of pinned messages. The
+// scope of a list is a category, or "" for the tenant as a whole. Positions
+// start at 1 and have no gaps; every change rewrites them in a single
+// transaction.
+type pinRepoInterface interface {
+	// Pin adds the message at position, or moves it there when it is
+	// already pinned. Positions past the end, and 0, mean the end.
+	Pin(ctx context.Context, scope string, messageId int64, position int) error_utils.MessageErr
+	Unpin(ctx context.Context, scope string, messageId int64) error_utils.MessageErr
+	// Reorder sets the order of the pins in scope; messageIds must list
+	// every pinned message exactly once.
+	Reorder(ctx context.Context, scope string, messageIds []int64) error_utils.MessageErr
+}
+
+type pinRepo struct {
+	db *sql.DB
+}
+
+func NewPinRepository(db *sql.DB) pinRepoInterface {
+	return &pinRepo{db: db}
+}
+
+type pin struct {
+	messageId int64
+	position  int
+}
+
+func (pr *pinRepo) Pin(ctx context.Context, scope string, messageId int64, position int) error_utils.MessageErr {
+	return pr.change(ctx, "pin", scope, func(tx *sql.Tx, pins []pin) ([]int64, error_utils.MessageErr) {
+		order := make([]int64, 0, len(pins)+1)
+		for _, p := range pins {
+			if p.messageId != messageId {
+				order = append(order, p.messageId)
+			}
+		}
+		if len(order) == len(pins) {
+			if len(pins) >= MaxPinnedMessages {
+				return nil, error_utils.NewUnprocessibleEntityError(fmt.Sprintf("at most %d messages can be pinned", MaxPinnedMessages))
+			}
+			if _, err := tx.ExecContext(ctx, queryInsertPin, tenant_utils.TenantFrom(ctx), scope, messageId, 0, time.Now()); err != nil {
+				return nil, error_formats.ParseError(err)
+			}
+		}
+		at := len(order)
+		if position > 0 && position <= len(order) {
+			at = position - 1
+		}
+		order = append(order[:at], append([]int64{messageId}, order[at:]...)...)
+		return order, nil
+	})
+}
+
+func (pr *pinRepo) Unpin(ctx context.Context, scope string, messageId int64) error_utils.MessageErr {
+	return pr.change(ctx, "unpin", scope, func(tx *sql.Tx, pins []pin) ([]int64, error_utils.MessageErr) {
+		order := make([]int64, 0, len(pins))
+		for _, p := range pins {
+			if p.messageId != messageId {
+				order = append(order, p.messageId)
+			}
+		}
+		if len(order) == len(pins) {
+			return nil, error_utils.NewNotFoundError("message is not pinned")
+		}
+		if _, err := tx.ExecContext(ctx, queryDeletePin, tenant_utils.TenantFrom(ctx), scope, messageId); err != nil {
+			return nil, error_formats.ParseError(err)
+		}
+		return order, nil
+	})
+}
+
+func (pr *pinRepo) Reorder(ctx context.Context, scope string, messageIds []int64) error_utils.MessageErr {
+	return pr.change(ctx, "reorder", scope, func(tx *sql.Tx, pins []pin) ([]int64, error_utils.MessageErr) {
+		pinned := make(map[int64]bool, len(pins))
+		for _, p := range pins {
+			pinned[p.messageId] = true
+		}
+		if len(messageIds) != len(pins) {
+			return nil, error_utils.NewConflictError("message_ids must list every pinned message exactly once")
+		}
+		for _, id := range messageIds {
+			if !pinned[id] {
+				return nil, error_utils.NewConflictError("message_ids must list every pinned message exactly once")
+			}
+			delete(pinned, id)
+		}
+		return messageIds, nil
+	})
+}
+
+// change locks the pins of scope, lets apply insert or delete rows and
+// return the new order, and then moves every pin whose position changed.
+func (pr *pinRepo) change(ctx context.Context, operation string, scope string, apply func(*sql.Tx, []pin) ([]int64, error_utils.MessageErr)) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("pinRepo", operation, time.Now())
+	ctx, span := startRepoSpan(ctx, "pinRepo", operation, queryLockPins)
+	defer span.End()
+
+	tx, err := pr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to change pins: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	rows, err := tx.QueryContext(ctx, queryLockPins, tenantId, scope)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to change pins: %s", err.Error())))
+	}
+	var pins []pin
+	for rows.Next() {
+		var p pin
+		if err := rows.Scan(&p.messageId, &p.position); err != nil {
+			rows.Close()
+			return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to change pins: %s", err.Error())))
+		}
+		pins = append(pins, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to change pins: %s", err.Error())))
+	}
+
+	order, applyErr := apply(tx, pins)
+	if applyErr != nil {
+		return recordRepoErr(span, applyErr)
+	}
+	positions := make(map[int64]int, len(pins))
+	for _, p := range pins {
+		positions[p.messageId] = p.position
+	}
+	for i, id := range order {
+		if positions[id] == i+1 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, queryMovePin, i+1, tenantId, scope, id); err != nil {
+			return recordRepoErr(span, error_formats.ParseError(err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to change pins: %s", err.Error())))
+	}
+	return nil
+}
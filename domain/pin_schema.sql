@@ -0,0 +1,9 @@
+  CREATE TABLE `message_pins` (
+  `tenant_id` VARCHAR(64) NOT NULL,
+  `scope` VARCHAR(50) NOT NULL DEFAULT '',
+  `message_id` INT NOT NULL,
+  `position` INT NOT NULL,
+  `pinned_at` TIMESTAMP NULL,
+  PRIMARY KEY (`tenant_id`, `scope`, `message_id`),
+  INDEX `message_idx` (`message_id` ASC),
+  CONSTRAINT `message_pins_message_fk` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE);
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+func expectLockedPins(mock sqlmock.Sqlmock, tenantId string, scope string, messageIds ...int64) {
+	rows := sqlmock.NewRows([]string{"message_id", "position"})
+	for i, id := range messageIds {
+		rows.AddRow(id, i+1)
+	}
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT message_id, position FROM message_pins WHERE tenant_id=\\? AND scope=\\? (.+) FOR UPDATE").
+		WithArgs(tenantId, scope).
+		WillReturnRows(rows)
+}
+
+func TestPinRepo_Pin_InsertsAtPosition(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPinRepository(db)
+
+	expectLockedPins(mock, "acme", "news", 7, 8)
+	mock.ExpectExec("INSERT INTO message_pins").WithArgs("acme", "news", 9, 0, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE message_pins SET position=\\?").WithArgs(2, "acme", "news", 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE message_pins SET position=\\?").WithArgs(3, "acme", "news", 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	pinErr := repo.Pin(tenant_utils.WithTenant(context.Background(), "acme"), "news", 9, 2)
+
+	assert.Nil(t, pinErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPinRepo_Pin_MovesPinnedMessageToEnd(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPinRepository(db)
+
+	expectLockedPins(mock, "default", "", 7, 8, 9)
+	mock.ExpectExec("UPDATE message_pins SET position=\\?").WithArgs(1, "default", "", 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE message_pins SET position=\\?").WithArgs(2, "default", "", 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE message_pins SET position=\\?").WithArgs(3, "default", "", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	pinErr := repo.Pin(context.Background(), "", 7, 0)
+
+	assert.Nil(t, pinErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPinRepo_Unpin_ClosesGap(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPinRepository(db)
+
+	expectLockedPins(mock, "default", "", 7, 8, 9)
+	mock.ExpectExec("DELETE FROM message_pins").WithArgs("default", "", 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE message_pins SET position=\\?").WithArgs(2, "default", "", 9).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, repo.Unpin(context.Background(), "", 8))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPinRepo_Unpin_NotPinned(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPinRepository(db)
+
+	expectLockedPins(mock, "default", "", 7)
+	mock.ExpectRollback()
+
+	unpinErr := repo.Unpin(context.Background(), "", 8)
+
+	assert.NotNil(t, unpinErr)
+	assert.EqualValues(t, http.StatusNotFound, unpinErr.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPinRepo_Reorder_RequiresEveryPin(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPinRepository(db)
+
+	for _, ids := range [][]int64{{7}, {7, 7}, {7, 10}} {
+		expectLockedPins(mock, "default", "", 7, 8)
+		mock.ExpectRollback()
+
+		reorderErr := repo.Reorder(context.Background(), "", ids)
+
+		assert.NotNil(t, reorderErr)
+		assert.EqualValues(t, http.StatusConflict, reorderErr.Status())
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPinRepo_Reorder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewPinRepository(db)
+
+	expectLockedPins(mock, "default", "", 7, 8)
+	mock.ExpectExec("UPDATE message_pins SET position=\\?").WithArgs(1, "default", "", 8).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE message_pins SET position=\\?").WithArgs(2, "default", "", 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Nil(t, repo.Reorder(context.Background(), "", []int64{8, 7}))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepo_List_PinnedFirst(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewMessageRepository(db)
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"Id", "TenantId", "Title", "Body", "AuthorId", "Category", "Status", "PublishAt", "ExpiresAt", "CreatedAt", "ParentId", "DeletedAt", "Format", "Version", "Metadata", "ReadCount", "Reactions", "Slug", "ReplyCount", "Tags"}).
+		AddRow(3, "acme", "pinned", "body", nil, "news", "published", now, nil, now, nil, nil, "plain", 1, nil, 0, nil, nil, 0, nil)
+	mock.ExpectQuery("SELECT (.+) AND m.category=\\? GROUP BY m.id ORDER BY COALESCE\\(\\(SELECT p.position FROM message_pins p (.+)\\), 2147483647\\), m.id DESC LIMIT").
+		WithArgs("acme", sqlmock.AnyArg(), true, "", false, "news", "news", 50, 0).
+		WillReturnRows(rows)
+
+	ctx := tenant_utils.WithTenant(context.Background(), "acme")
+	messages, listErr := repo.List(ctx, MessageFilter{Category: "news", PinnedFirst: true, Limit: 50})
+
+	assert.Nil(t, listErr)
+	assert.Equal(t, 1, len(messages))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/ratelimit_utils"
+	"time"
+)
+
+const (
+	querySelectRateLimitBucket = "SELECT tokens, updated_at FROM rate_limit_buckets WHERE bucket_key=? FOR UPDATE;"
+	queryUpsertRateLimitBucket = "INSERT INTO rate_limit_buckets(bucket_key, tokens, updated_at) VALUES(?, ?, ?) ON DUPLICATE KEY UPDATE tokens=VALUES(tokens), updated_at=VALUES(updated_at);"
+	queryDeleteIdleBuckets     = "DELETE FROM rate_limit_buckets WHERE updated_at < ? LIMIT ?;"
+
+	// rateLimitSweepBatch is how many buckets Sweep deletes per statement,
+	// so that it never holds many row locks at once.
+	rateLimitSweepBatch = 1000
+)
+
+type rateLimitStore struct {
+	db *sql.DB
+}
+
+// NewRateLimitStore returns a rate limit store shared by every replica that
+// uses db. Each Take locks the bucket row for the duration of a transaction.
+// Idle buckets stay until swept; the store is a ratelimit_utils.Sweeper.
+func NewRateLimitStore(db *sql.DB) ratelimit_utils.Store {
+	return &rateLimitStore{db: db}
+}
+
+func (s *rateLimitStore) Take(ctx context.Context, key string, limit ratelimit_utils.Limit, now time.Time) (ratelimit_utils.Result, error) {
+	defer metrics_utils.ObserveRepoOperation("rateLimitStore", "take", time.Now())
+	ctx, span := startRepoSpan(ctx, "rateLimitStore", "take", querySelectRateLimitBucket)
+	defer span.End()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return ratelimit_utils.Result{}, err
+	}
+	defer tx.Rollback()
+
+	tokens := float64(limit.Burst)
+	updated := now
+	// Two replicas creating the same bucket at once may both see no row; the
+	// upsert below then keeps the last write, which at worst lets one extra
+	// request through.
+	err = tx.QueryRowContext(ctx, querySelectRateLimitBucket, key).Scan(&tokens, &updated)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return ratelimit_utils.Result{}, err
+	}
+
+	tokens, result := ratelimit_utils.TakeToken(tokens, updated, limit, now)
+	if _, err := tx.ExecContext(ctx, queryUpsertRateLimitBucket, key, tokens, now); err != nil {
+		return ratelimit_utils.Result{}, err
+	}
+	return result, tx.Commit()
+}
+
+// Sweep deletes idle buckets in batches.
+func (s *rateLimitStore) Sweep(ctx context.Context, idleBefore time.Time) (int, error) {
+	defer metrics_utils.ObserveRepoOperation("rateLimitStore", "sweep", time.Now())
+	ctx, span := startRepoSpan(ctx, "rateLimitStore", "sweep", queryDeleteIdleBuckets)
+	defer span.End()
+
+	swept := 0
+	for {
+		result, err := s.db.ExecContext(ctx, queryDeleteIdleBuckets, idleBefore, rateLimitSweepBatch)
+		if err != nil {
+			return swept, err
+		}
+		deleted, err := result.RowsAffected()
+		if err != nil {
+			return swept, err
+		}
+		swept += int(deleted)
+		if deleted < rateLimitSweepBatch {
+			return swept, nil
+		}
+	}
+}
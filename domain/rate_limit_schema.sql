@@ -0,0 +1,6 @@
+  CREATE TABLE `rate_limit_buckets` (
+  `bucket_key` VARCHAR(255) NOT NULL,
+  `tokens` DOUBLE NOT NULL,
+  `updated_at` TIMESTAMP(6) NOT NULL,
+  PRIMARY KEY (`bucket_key`),
+  INDEX `updated_at_idx` (`updated_at` ASC));
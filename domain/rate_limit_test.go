@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/utils/ratelimit_utils"
+	"time"
+)
+
+func TestRateLimitStore_Take(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	store := NewRateLimitStore(db)
+
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT tokens, updated_at FROM rate_limit_buckets").
+		WithArgs("messages.create|ip:1.2.3.4").
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "updated_at"}).AddRow(0.5, now))
+	mock.ExpectExec("INSERT INTO rate_limit_buckets").
+		WithArgs("messages.create|ip:1.2.3.4", 0.5, now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := store.Take(context.Background(), "messages.create|ip:1.2.3.4", ratelimit_utils.Limit{Rate: 1, Burst: 2}, now)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 500*time.Millisecond, result.RetryAfter)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRateLimitStore_Sweep(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	store := NewRateLimitStore(db).(ratelimit_utils.Sweeper)
+
+	idleBefore := time.Now().Add(-time.Minute)
+	mock.ExpectExec("DELETE FROM rate_limit_buckets WHERE updated_at < \\? LIMIT \\?").
+		WithArgs(idleBefore, rateLimitSweepBatch).
+		WillReturnResult(sqlmock.NewResult(0, rateLimitSweepBatch))
+	mock.ExpectExec("DELETE FROM rate_limit_buckets WHERE updated_at < \\? LIMIT \\?").
+		WithArgs(idleBefore, rateLimitSweepBatch).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	swept, err := store.Sweep(context.Background(), idleBefore)
+	assert.NoError(t, err)
+	assert.Equal(t, rateLimitSweepBatch+3, swept)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,189 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+var (
+	ReactionRepo reactionRepoInterface = &reactionRepo{}
+)
+
+const (
+	// queryLockMessage serializes engagement writes on a message, so the
+	// counters on the message row always match the rows they count. Only
+	// messages the caller may read can be locked, as Get would find them.
+	queryLockMessage    = "SELECT m.id FROM messages m WHERE m.id=? AND m.tenant_id=? AND " + visible + " AND " + readable + " FOR UPDATE;"
+	queryInsertReaction = "INSERT IGNORE INTO message_reactions(message_id, user_id, emoji, created_at) VALUES(?, ?, ?, ?);"
+	queryDeleteReaction = "DELETE FROM message_reactions WHERE message_id=? AND user_id=? AND emoji=?;"
+	queryCountReaction  = "UPDATE messages SET reaction_counts=JSON_SET(COALESCE(reaction_counts, JSON_OBJECT()), ?, " +
+		"COALESCE(JSON_EXTRACT(reaction_counts, ?), 0) + ?) WHERE id=?;"
+	queryInsertRead = "INSERT IGNORE INTO message_reads(message_id, user_id, read_at) VALUES(?, ?, ?);"
+	queryCountRead  = "UPDATE messages SET read_count=read_count+1 WHERE id=?;"
+
+	queryLockUserReactions = "SELECT r.message_id, r.emoji FROM message_reactions r JOIN messages m ON m.id = r.message_id " +
+		"WHERE r.user_id=? AND m.tenant_id=? ORDER BY r.message_id FOR UPDATE;"
+	queryDeleteUserReactions = "DELETE r FROM message_reactions r JOIN messages m ON m.id = r.message_id WHERE r.user_id=? AND m.tenant_id=?;"
+	// queryUncountUserReads runs before the reads are deleted; a user reads
+	// a message at most once, so every message is touched once.
+	queryUncountUserReads = "UPDATE messages m JOIN message_reads r ON r.message_id = m.id SET m.read_count=m.read_count-1 WHERE r.user_id=? AND m.tenant_id=?;"
+	queryDeleteUserReads  = "DELETE r FROM message_reads r JOIN messages m ON m.id = r.message_id WHERE r.user_id=? AND m.tenant_id=?;"
+)
+
+// reactionRepoInterface records reactions and reads per user. Each method
+// reports whether it changed anything, so repeating a call is harmless and
+// leaves the counts on the message untouched.
+type reactionRepoInterface interface {
+	AddReaction(ctx context.Context, messageId int64, userId string, emoji string) (bool, error_utils.MessageErr)
+	RemoveReaction(ctx context.Context, messageId int64, userId string, emoji string) (bool, error_utils.MessageErr)
+	MarkRead(ctx context.Context, messageId int64, userId string) (bool, error_utils.MessageErr)
+	// EraseUser removes every reaction and read of the user in the tenant,
+	// and takes them out of the counts, returning how many of each it
+	// removed.
+	EraseUser(ctx context.Context, userId string) (int, int, error_utils.MessageErr)
+}
+
+type reactionRepo struct {
+	db *sql.DB
+}
+
+func NewReactionRepository(db *sql.DB) reactionRepoInterface {
+	return &reactionRepo{db: db}
+}
+
+func (rr *reactionRepo) AddReaction(ctx context.Context, messageId int64, userId string, emoji string) (bool, error_utils.MessageErr) {
+	return rr.record(ctx, "add_reaction", messageId,
+		func(tx *sql.Tx) (sql.Result, error) {
+			return tx.ExecContext(ctx, queryInsertReaction, messageId, userId, emoji, time.Now())
+		},
+		func(tx *sql.Tx) error {
+			return countReaction(ctx, tx, messageId, emoji, 1)
+		})
+}
+
+func (rr *reactionRepo) RemoveReaction(ctx context.Context, messageId int64, userId string, emoji string) (bool, error_utils.MessageErr) {
+	return rr.record(ctx, "remove_reaction", messageId,
+		func(tx *sql.Tx) (sql.Result, error) {
+			return tx.ExecContext(ctx, queryDeleteReaction, messageId, userId, emoji)
+		},
+		func(tx *sql.Tx) error {
+			return countReaction(ctx, tx, messageId, emoji, -1)
+		})
+}
+
+func (rr *reactionRepo) MarkRead(ctx context.Context, messageId int64, userId string) (bool, error_utils.MessageErr) {
+	return rr.record(ctx, "mark_read", messageId,
+		func(tx *sql.Tx) (sql.Result, error) {
+			return tx.ExecContext(ctx, queryInsertRead, messageId, userId, time.Now())
+		},
+		func(tx *sql.Tx) error {
+			_, err := tx.ExecContext(ctx, queryCountRead, messageId)
+			return err
+		})
+}
+
+func (rr *reactionRepo) EraseUser(ctx context.Context, userId string) (int, int, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("reactionRepo", "erase_user", time.Now())
+	ctx, span := startRepoSpan(ctx, "reactionRepo", "erase_user", queryLockUserReactions)
+	defer span.End()
+
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to erase reactions: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	tenantId := tenant_utils.TenantFrom(ctx)
+	type reaction struct {
+		messageId int64
+		emoji     string
+	}
+	rows, err := tx.QueryContext(ctx, queryLockUserReactions, userId, tenantId)
+	if err != nil {
+		return 0, 0, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	var reactions []reaction
+	for rows.Next() {
+		var r reaction
+		if err := rows.Scan(&r.messageId, &r.emoji); err != nil {
+			rows.Close()
+			return 0, 0, recordRepoErr(span, error_formats.ParseError(err))
+		}
+		reactions = append(reactions, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	for _, r := range reactions {
+		if err := countReaction(ctx, tx, r.messageId, r.emoji, -1); err != nil {
+			return 0, 0, recordRepoErr(span, error_formats.ParseError(err))
+		}
+	}
+	if _, err := tx.ExecContext(ctx, queryDeleteUserReactions, userId, tenantId); err != nil {
+		return 0, 0, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	result, err := tx.ExecContext(ctx, queryUncountUserReads, userId, tenantId)
+	if err != nil {
+		return 0, 0, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	reads, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if _, err := tx.ExecContext(ctx, queryDeleteUserReads, userId, tenantId); err != nil {
+		return 0, 0, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to erase reactions: %s", err.Error())))
+	}
+	return len(reactions), int(reads), nil
+}
+
+// record locks the message, applies change and, when change affected a
+// row, updates the counters with count, all in one transaction.
+func (rr *reactionRepo) record(ctx context.Context, operation string, messageId int64, change func(*sql.Tx) (sql.Result, error), count func(*sql.Tx) error) (bool, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("reactionRepo", operation, time.Now())
+	ctx, span := startRepoSpan(ctx, "reactionRepo", operation, queryLockMessage)
+	defer span.End()
+
+	tx, err := rr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save reaction: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	var id int64
+	args := append([]interface{}{messageId, tenant_utils.TenantFrom(ctx), time.Now()}, readableArgs(ctx)...)
+	if err := tx.QueryRowContext(ctx, queryLockMessage, args...).Scan(&id); err != nil {
+		return false, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	result, err := change(tx)
+	if err != nil {
+		return false, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return false, nil
+	}
+	if err := count(tx); err != nil {
+		return false, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if err := tx.Commit(); err != nil {
+		return false, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save reaction: %s", err.Error())))
+	}
+	return true, nil
+}
+
+func countReaction(ctx context.Context, tx *sql.Tx, messageId int64, emoji string, delta int) error {
+	// ValidReaction rules out quotes and backslashes, so emoji is safe
+	// inside the quoted path member.
+	path := `$."` + emoji + `"`
+	_, err := tx.ExecContext(ctx, queryCountReaction, path, path, delta, messageId)
+	return err
+}
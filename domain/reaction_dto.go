@@ -0,0 +1,27 @@
+package domain
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const maxReactionLength = 32
+
+// ValidReaction accepts an emoji, possibly a sequence joined with ZWJ or
+// modifiers, or a lowercase short code such as "+1" or "tada".
+func ValidReaction(emoji string) bool {
+	if emoji == "" || len(emoji) > maxReactionLength || !utf8.ValidString(emoji) {
+		return false
+	}
+	for _, r := range emoji {
+		if r < utf8.RuneSelf {
+			if !strings.ContainsRune("abcdefghijklmnopqrstuvwxyz0123456789_+-", r) {
+				return false
+			}
+		} else if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,16 @@
+  CREATE TABLE `message_reactions` (
+  `message_id` INT NOT NULL,
+  `user_id` VARCHAR(255) NOT NULL,
+  `emoji` VARCHAR(32) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL,
+  `created_at` TIMESTAMP NULL,
+  PRIMARY KEY (`message_id`, `user_id`, `emoji`),
+  INDEX `user_id_idx` (`user_id` ASC),
+  CONSTRAINT `message_reactions_message_fk` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE) DEFAULT CHARSET=utf8mb4;
+
+  CREATE TABLE `message_reads` (
+  `message_id` INT NOT NULL,
+  `user_id` VARCHAR(255) NOT NULL,
+  `read_at` TIMESTAMP NULL,
+  PRIMARY KEY (`message_id`, `user_id`),
+  INDEX `user_id_idx` (`user_id` ASC),
+  CONSTRAINT `message_reads_message_fk` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE) DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,167 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/tenant_utils"
+)
+
+func TestReactionRepo_AddReaction_CountsOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewReactionRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.id FROM messages m WHERE m.id=\\? AND m.tenant_id=\\? AND m.deleted_at IS NULL AND (.+) FOR UPDATE").
+		WithArgs(4, "acme", sqlmock.AnyArg(), true, "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec("INSERT IGNORE INTO message_reactions").
+		WithArgs(4, "user-1", "👍", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET reaction_counts=JSON_SET").
+		WithArgs(`$."👍"`, `$."👍"`, 1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	added, addErr := repo.AddReaction(tenant_utils.WithTenant(context.Background(), "acme"), 4, "user-1", "👍")
+
+	assert.Nil(t, addErr)
+	assert.True(t, added)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReactionRepo_AddReaction_FourByteEmoji(t *testing.T) {
+	config, err := mysql.ParseDSN(dataSourceName("user", "secret", "3306", "mysql", "main"))
+	assert.NoError(t, err)
+	assert.Equal(t, "utf8mb4", config.Params["charset"])
+
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewReactionRepository(db)
+
+	emoji := "\U0001F389"
+	assert.Equal(t, 4, len(emoji))
+	assert.True(t, ValidReaction(emoji))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.id FROM messages").WithArgs(4, "default", sqlmock.AnyArg(), true, "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec("INSERT IGNORE INTO message_reactions").
+		WithArgs(4, "user-1", emoji, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET reaction_counts=JSON_SET").
+		WithArgs(`$."`+emoji+`"`, `$."`+emoji+`"`, 1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	added, addErr := repo.AddReaction(context.Background(), 4, "user-1", emoji)
+
+	assert.Nil(t, addErr)
+	assert.True(t, added)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReactionRepo_AddReaction_Repeated(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewReactionRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.id FROM messages").WithArgs(4, "default", sqlmock.AnyArg(), true, "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec("INSERT IGNORE INTO message_reactions").
+		WithArgs(4, "user-1", "tada", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	added, addErr := repo.AddReaction(context.Background(), 4, "user-1", "tada")
+
+	assert.Nil(t, addErr)
+	assert.False(t, added)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReactionRepo_RemoveReaction_Decrements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewReactionRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.id FROM messages").WithArgs(4, "default", sqlmock.AnyArg(), true, "", false).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec("DELETE FROM message_reactions WHERE message_id=\\? AND user_id=\\? AND emoji=\\?").
+		WithArgs(4, "user-1", "tada").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET reaction_counts=JSON_SET").
+		WithArgs(`$."tada"`, `$."tada"`, -1, 4).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	removed, removeErr := repo.RemoveReaction(context.Background(), 4, "user-1", "tada")
+
+	assert.Nil(t, removeErr)
+	assert.True(t, removed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReactionRepo_MarkRead_MessageNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewReactionRepository(db)
+
+	// Another author's draft, or an expired message, is not found either.
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT m.id FROM messages").WithArgs(4, "acme", sqlmock.AnyArg(), false, "user-1", false).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	ctx := auth_utils.WithPrincipal(tenant_utils.WithTenant(context.Background(), "acme"), &auth_utils.Principal{Subject: "user-1"})
+	_, readErr := repo.MarkRead(ctx, 4, "user-1")
+
+	assert.NotNil(t, readErr)
+	assert.EqualValues(t, http.StatusNotFound, readErr.Status())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidReaction(t *testing.T) {
+	for _, emoji := range []string{"👍", "👩‍💻", "👍🏽", "+1", "tada", "thumbs_up"} {
+		assert.True(t, ValidReaction(emoji), emoji)
+	}
+	for _, emoji := range []string{"", "Tada", "a b", `x"`, `\u`, "👍\n", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"} {
+		assert.False(t, ValidReaction(emoji), emoji)
+	}
+}
+
+func TestReactionRepo_EraseUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewReactionRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT r.message_id, r.emoji FROM message_reactions r (.+) FOR UPDATE").WithArgs("user-1", "acme").
+		WillReturnRows(sqlmock.NewRows([]string{"message_id", "emoji"}).AddRow(4, "👍").AddRow(4, "tada"))
+	mock.ExpectExec("UPDATE messages SET reaction_counts").WithArgs(`$."👍"`, `$."👍"`, -1, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET reaction_counts").WithArgs(`$."tada"`, `$."tada"`, -1, 4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE r FROM message_reactions r").WithArgs("user-1", "acme").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE messages m JOIN message_reads r (.+) SET m.read_count=m.read_count-1").WithArgs("user-1", "acme").
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("DELETE r FROM message_reads r").WithArgs("user-1", "acme").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	reactions, reads, eraseErr := repo.EraseUser(tenant_utils.WithTenant(context.Background(), "acme"), "user-1")
+
+	assert.Nil(t, eraseErr)
+	assert.Equal(t, 2, reactions)
+	assert.Equal(t, 3, reads)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
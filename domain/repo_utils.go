@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/tracing_utils"
+	"time"
+)
+
+func startRepoSpan(ctx context.Context, repo string, operation string, query string) (context.Context, trace.Span) {
+	return tracing_utils.Tracer().Start(ctx, repo+"."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "mysql"),
+			attribute.String("db.operation.name", operation),
+			attribute.String("db.query.text", query),
+		),
+	)
+}
+
+// nullString stores empty strings as NULL.
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+// nullJSON stores empty JSON documents as NULL.
+func nullJSON(value json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(value), Valid: len(value) > 0}
+}
+
+// nullTime stores nil times as NULL.
+func nullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *value, Valid: true}
+}
+
+// nullInt64 stores nil ids as NULL.
+func nullInt64(value *int64) sql.NullInt64 {
+	if value == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: *value, Valid: true}
+}
+
+// timePtr is the scanning counterpart of nullTime.
+func timePtr(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
+
+func recordRepoErr(span trace.Span, err error_utils.MessageErr) error_utils.MessageErr {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Message())
+	return err
+}
@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"context"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/fingerprint_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+// querySimilarMessages finds the messages whose body has the same content
+// hash, or a SimHash close enough to share one of its bands, closest first.
+const querySimilarMessages = selectMessages + " WHERE m.tenant_id=? AND m.id<>? AND " + visible + " AND " + readable + " AND (m.content_hash=? OR " +
+	"((m.simhash_band0=? OR m.simhash_band1=? OR m.simhash_band2=? OR m.simhash_band3=? OR " +
+	"m.simhash_band4=? OR m.simhash_band5=? OR m.simhash_band6=? OR m.simhash_band7=?) AND BIT_COUNT(m.simhash ^ ?) <= ?)) " +
+	"GROUP BY m.id ORDER BY BIT_COUNT(m.simhash ^ ?), m.id DESC LIMIT ?;"
+
+// fingerprintArgs are the content_hash and simhash column values of body.
+// The SimHash is stored as a signed BIGINT because the driver rejects
+// uint64 values with the high bit set; MySQL bit operations treat it as
+// unsigned anyway.
+func fingerprintArgs(body string) (string, int64) {
+	return fingerprint_utils.ContentHash(body), int64(fingerprint_utils.SimHash(body))
+}
+
+// Similar lists up to limit messages of the tenant the caller may see, other
+// than msg itself, whose body duplicates or nearly duplicates the body of msg.
+func (mr *messageRepo) Similar(ctx context.Context, msg *Message, limit int) ([]Message, error_utils.MessageErr) {
+	contentHash, simhash := fingerprintArgs(msg.Body)
+	args := append([]interface{}{tenant_utils.TenantFrom(ctx), msg.Id, time.Now()}, readableArgs(ctx)...)
+	args = append(args, contentHash)
+	for i := 0; i < fingerprint_utils.Bands; i++ {
+		args = append(args, fingerprint_utils.Band(uint64(simhash), i))
+	}
+	args = append(args, simhash, NearDuplicateDistance, simhash, limit)
+	return mr.list(ctx, "similar", querySimilarMessages, args...)
+}
@@ -0,0 +1,18 @@
+package domain
+
+import "testing-project/utils/fingerprint_utils"
+
+// NearDuplicateDistance is the most bits in which the SimHash fingerprints
+// of two bodies may differ for them to count as near duplicates. The banded
+// columns that index fingerprints cannot find pairs further apart.
+const NearDuplicateDistance = fingerprint_utils.Bands - 1
+
+// SimilarMessage is a message whose body duplicates, or nearly duplicates,
+// the body of another one.
+type SimilarMessage struct {
+	Message
+	// Distance is the number of bits the SimHash fingerprints differ in.
+	Distance int `json:"distance"`
+	// Exact is set when the normalized bodies are identical.
+	Exact bool `json:"exact"`
+}
@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/slug_utils"
+	"time"
+)
+
+const (
+	// queryClaimedSlugs reads the slugs, past and present, that could collide
+	// with base or one of its numbered variants, with the base each was made
+	// from.
+	queryClaimedSlugs = "SELECT slug, message_id, base FROM message_slugs WHERE tenant_id=? AND (slug=? OR slug LIKE ?) FOR UPDATE;"
+	queryInsertSlug   = "INSERT INTO message_slugs(tenant_id, slug, base, message_id, created_at) VALUES(?, ?, ?, ?, ?);"
+	querySetSlug      = "UPDATE messages SET slug=? WHERE id=?;"
+)
+
+// slugClaim is the message a slug belongs to and the base it was made from:
+// the slug itself, or what it is a numbered variant of.
+type slugClaim struct {
+	messageId int64
+	base      string
+}
+
+// writeSlug gives msg the slug of its title inside tx. A slug, once used,
+// stays with its message for good so that old links keep working: a title
+// whose slug belongs to another message gets the first free numbered
+// variant, such as "launch-2", while a message may take back its own. A
+// message keeps a numbered variant made from the same base; a title that
+// merely ends in a number, such as "Top 10", is a base of its own.
+func writeSlug(ctx context.Context, tx *sql.Tx, msg *Message) error_utils.MessageErr {
+	base := slug_utils.Slugify(msg.Title)
+	if msg.Slug == base {
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, queryClaimedSlugs, msg.TenantId, base, base+"-%")
+	if err != nil {
+		return error_formats.ParseError(err)
+	}
+	claims := make(map[string]slugClaim)
+	for rows.Next() {
+		var slug string
+		var claim slugClaim
+		if err := rows.Scan(&slug, &claim.messageId, &claim.base); err != nil {
+			rows.Close()
+			return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save slug: %s", err.Error()))
+		}
+		claims[slug] = claim
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save slug: %s", err.Error()))
+	}
+	if current, ok := claims[msg.Slug]; ok && current.messageId == msg.Id && current.base == base {
+		return nil
+	}
+
+	slug := base
+	for n := 2; claims[slug].messageId != 0 && claims[slug].messageId != msg.Id; n++ {
+		slug = base + "-" + strconv.Itoa(n)
+	}
+	if claims[slug].messageId == 0 {
+		if _, err := tx.ExecContext(ctx, queryInsertSlug, msg.TenantId, slug, base, msg.Id, time.Now()); err != nil {
+			return error_formats.ParseError(err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, querySetSlug, slug, msg.Id); err != nil {
+		return error_formats.ParseError(err)
+	}
+	msg.Slug = slug
+	return nil
+}
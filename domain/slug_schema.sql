@@ -0,0 +1,9 @@
+  CREATE TABLE `message_slugs` (
+  `tenant_id` VARCHAR(64) NOT NULL,
+  `slug` VARCHAR(120) NOT NULL,
+  `base` VARCHAR(120) NOT NULL,
+  `message_id` INT NOT NULL,
+  `created_at` TIMESTAMP NULL,
+  PRIMARY KEY (`tenant_id`, `slug`),
+  INDEX `message_idx` (`message_id` ASC),
+  CONSTRAINT `message_slugs_message_fk` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE);
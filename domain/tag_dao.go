@@ -0,0 +1,71 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+const (
+	queryUpsertTag         = "INSERT INTO tags(tenant_id, name) VALUES(?, ?) ON DUPLICATE KEY UPDATE id=LAST_INSERT_ID(id);"
+	queryInsertMessageTag  = "INSERT INTO message_tags(message_id, tag_id) VALUES(?, ?);"
+	queryDeleteMessageTags = "DELETE FROM message_tags WHERE message_id=?;"
+	queryListTags          = "SELECT t.name, COUNT(*) FROM tags t JOIN message_tags mt ON mt.tag_id = t.id JOIN messages m ON m.id = mt.message_id " +
+		"WHERE t.tenant_id=? AND " + notExpired + " GROUP BY t.id, t.name ORDER BY COUNT(*) DESC, t.name;"
+)
+
+// writeTags links msg to its tags inside tx, creating tags the tenant has not
+// used before. With replace set the previous links are removed first.
+func writeTags(ctx context.Context, tx *sql.Tx, msg *Message, replace bool) error_utils.MessageErr {
+	if replace {
+		if _, err := tx.ExecContext(ctx, queryDeleteMessageTags, msg.Id); err != nil {
+			return error_formats.ParseError(err)
+		}
+	}
+	for _, tag := range msg.Tags {
+		result, err := tx.ExecContext(ctx, queryUpsertTag, msg.TenantId, tag)
+		if err != nil {
+			return error_formats.ParseError(err)
+		}
+		tagId, err := result.LastInsertId()
+		if err != nil {
+			return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save tag: %s", err.Error()))
+		}
+		if _, err := tx.ExecContext(ctx, queryInsertMessageTag, msg.Id, tagId); err != nil {
+			return error_formats.ParseError(err)
+		}
+	}
+	return nil
+}
+
+// ListTags returns the tenant's tags in use by unexpired messages, most used
+// first.
+func (mr *messageRepo) ListTags(ctx context.Context) ([]TagCount, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("messageRepo", "list_tags", time.Now())
+	ctx, span := startRepoSpan(ctx, "messageRepo", "list_tags", queryListTags)
+	defer span.End()
+
+	rows, err := mr.db.QueryContext(ctx, queryListTags, tenant_utils.TenantFrom(ctx), time.Now())
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list tags: %s", err.Error())))
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list tags: %s", err.Error())))
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list tags: %s", err.Error())))
+	}
+	return tags, nil
+}
@@ -0,0 +1,7 @@
+package domain
+
+// TagCount is a tag along with the number of live messages carrying it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
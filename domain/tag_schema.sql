@@ -0,0 +1,14 @@
+  CREATE TABLE `tags` (
+  `id` INT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL,
+  `name` VARCHAR(50) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE INDEX `tenant_name_UNIQUE` (`tenant_id` ASC, `name` ASC));
+
+  CREATE TABLE `message_tags` (
+  `message_id` INT NOT NULL,
+  `tag_id` INT NOT NULL,
+  PRIMARY KEY (`message_id`, `tag_id`),
+  INDEX `tag_idx` (`tag_id` ASC),
+  CONSTRAINT `message_tags_message_fk` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE,
+  CONSTRAINT `message_tags_tag_fk` FOREIGN KEY (`tag_id`) REFERENCES `tags` (`id`) ON DELETE CASCADE);
@@ -0,0 +1,186 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+var (
+	TranslationRepo translationRepoInterface = &translationRepo{}
+)
+
+const (
+	queryListTranslations  = "SELECT t.message_id, t.locale, t.title, t.body, t.updated_at FROM message_translations t JOIN messages m ON m.id = t.message_id WHERE t.message_id=? AND m.tenant_id=? ORDER BY t.locale;"
+	queryUpsertTranslation = "INSERT INTO message_translations(message_id, locale, title, body, updated_at) VALUES(?, ?, ?, ?, ?) " +
+		"ON DUPLICATE KEY UPDATE title=VALUES(title), body=VALUES(body), updated_at=VALUES(updated_at);"
+	queryDeleteTranslation = "DELETE FROM message_translations WHERE message_id=? AND locale=?;"
+	// queryLockTranslation reads a translation as it stands inside a change
+	// to it, for the audit log.
+	queryLockTranslation  = "SELECT message_id, locale, title, body, updated_at FROM message_translations WHERE message_id=? AND locale=? FOR UPDATE;"
+	queryDropTranslations = "DELETE FROM message_translations WHERE message_id=?;"
+	// queryBumpVersion claims the message for a translation change, which
+	// moves it to its next version just like an update of the original.
+	queryBumpVersion = "UPDATE messages SET version=version+1 WHERE id=? AND tenant_id=? AND version=? AND deleted_at IS NULL;"
+)
+
+// translationRepoInterface stores the translations of a message. The table
+// has no tenant of its own: every query goes through the message, which is
+// scoped to the tenant of the context. Changing a translation moves the
+// message to its next version, with the same conflict check as Update, and
+// is audited along with it.
+type translationRepoInterface interface {
+	ListByMessage(ctx context.Context, messageId int64) ([]Translation, error_utils.MessageErr)
+	Upsert(ctx context.Context, msg *Message, translation *Translation) error_utils.MessageErr
+	Delete(ctx context.Context, msg *Message, locale string) error_utils.MessageErr
+}
+
+type translationRepo struct {
+	db *sql.DB
+}
+
+func NewTranslationRepository(db *sql.DB) translationRepoInterface {
+	return &translationRepo{db: db}
+}
+
+func (tr *translationRepo) ListByMessage(ctx context.Context, messageId int64) ([]Translation, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("translationRepo", "list", time.Now())
+	ctx, span := startRepoSpan(ctx, "translationRepo", "list", queryListTranslations)
+	defer span.End()
+
+	rows, err := tr.db.QueryContext(ctx, queryListTranslations, messageId, tenant_utils.TenantFrom(ctx))
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list translations: %s", err.Error())))
+	}
+	defer rows.Close()
+
+	translations := []Translation{}
+	for rows.Next() {
+		var translation Translation
+		if err := rows.Scan(&translation.MessageId, &translation.Locale, &translation.Title, &translation.Body, &translation.UpdatedAt); err != nil {
+			return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list translations: %s", err.Error())))
+		}
+		translations = append(translations, translation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list translations: %s", err.Error())))
+	}
+	return translations, nil
+}
+
+// Upsert creates or replaces the translation for its locale. When the
+// moderation checks held the translation back, msg is held for review in
+// the same transaction.
+func (tr *translationRepo) Upsert(ctx context.Context, msg *Message, translation *Translation) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("translationRepo", "upsert", time.Now())
+	ctx, span := startRepoSpan(ctx, "translationRepo", "upsert", queryUpsertTranslation)
+	defer span.End()
+
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save translation: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	if err := bumpVersion(ctx, tx, msg); err != nil {
+		return recordRepoErr(span, err)
+	}
+	before, snapshotErr := snapshotTranslation(ctx, tx, msg.Id, translation.Locale)
+	if snapshotErr != nil {
+		return recordRepoErr(span, snapshotErr)
+	}
+	if _, err := tx.ExecContext(ctx, queryUpsertTranslation, msg.Id, translation.Locale, translation.Title, translation.Body, translation.UpdatedAt); err != nil {
+		return recordRepoErr(span, error_formats.ParseError(err))
+	}
+	if err := writeModeration(ctx, tx, msg, true); err != nil {
+		return recordRepoErr(span, err)
+	}
+	after, auditErr := auditValue(translation)
+	if auditErr != nil {
+		return recordRepoErr(span, auditErr)
+	}
+	if err := writeAudit(ctx, tx, AuditActionTranslate, msg.Id, tenant_utils.TenantFrom(ctx), before, after); err != nil {
+		return recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save translation: %s", err.Error())))
+	}
+	msg.Version++
+	return nil
+}
+
+// Delete removes the translation for locale, or reports it as not found.
+func (tr *translationRepo) Delete(ctx context.Context, msg *Message, locale string) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("translationRepo", "delete", time.Now())
+	ctx, span := startRepoSpan(ctx, "translationRepo", "delete", queryDeleteTranslation)
+	defer span.End()
+
+	tx, err := tr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete translation: %s", err.Error())))
+	}
+	defer tx.Rollback()
+
+	if err := bumpVersion(ctx, tx, msg); err != nil {
+		return recordRepoErr(span, err)
+	}
+	before, snapshotErr := snapshotTranslation(ctx, tx, msg.Id, locale)
+	if snapshotErr != nil {
+		return recordRepoErr(span, snapshotErr)
+	}
+	if before == nil {
+		return recordRepoErr(span, error_utils.NewNotFoundError("no translation for given locale"))
+	}
+	if _, err := tx.ExecContext(ctx, queryDeleteTranslation, msg.Id, locale); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete translation: %s", err.Error())))
+	}
+	if err := writeAudit(ctx, tx, AuditActionTranslate, msg.Id, tenant_utils.TenantFrom(ctx), before, nil); err != nil {
+		return recordRepoErr(span, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to delete translation: %s", err.Error())))
+	}
+	msg.Version++
+	return nil
+}
+
+// snapshotTranslation returns the translation of a message for locale as
+// JSON as it stands in tx, or nil when there is none.
+func snapshotTranslation(ctx context.Context, tx *sql.Tx, messageId int64, locale string) (json.RawMessage, error_utils.MessageErr) {
+	var translation Translation
+	err := tx.QueryRowContext(ctx, queryLockTranslation, messageId, locale).
+		Scan(&translation.MessageId, &translation.Locale, &translation.Title, &translation.Body, &translation.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to audit translation: %s", err.Error()))
+	}
+	return auditValue(translation)
+}
+
+func bumpVersion(ctx context.Context, tx *sql.Tx, msg *Message) error_utils.MessageErr {
+	result, err := tx.ExecContext(ctx, queryBumpVersion, msg.Id, tenant_utils.TenantFrom(ctx), msg.Version)
+	if err != nil {
+		return error_formats.ParseError(err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		return error_utils.NewConflictError("message was changed by another request")
+	}
+	return nil
+}
+
+// dropTranslations removes every translation of a message, inside the
+// transaction that turns it into a tombstone.
+func dropTranslations(ctx context.Context, tx *sql.Tx, messageId int64) error_utils.MessageErr {
+	if _, err := tx.ExecContext(ctx, queryDropTranslations, messageId); err != nil {
+		return error_formats.ParseError(err)
+	}
+	return nil
+}
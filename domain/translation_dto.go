@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"strings"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// Translation is the title and body of a message in another locale. The
+// format, category and tags are shared with the original.
+type Translation struct {
+	MessageId int64     `json:"message_id"`
+	Locale    string    `json:"locale"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (t *Translation) Validate() error_utils.MessageErr {
+	t.Title = strings.TrimSpace(t.Title)
+	t.Body = strings.TrimSpace(t.Body)
+	if t.Title == "" {
+		return error_utils.NewUnprocessibleEntityError("Please enter a valid title")
+	}
+	if t.Body == "" {
+		return error_utils.NewUnprocessibleEntityError("Please enter a valid body")
+	}
+	return nil
+}
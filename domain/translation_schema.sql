@@ -0,0 +1,8 @@
+  CREATE TABLE `message_translations` (
+  `message_id` INT NOT NULL,
+  `locale` VARCHAR(35) NOT NULL,
+  `title` VARCHAR(100) NOT NULL,
+  `body` VARCHAR(200) NOT NULL,
+  `updated_at` TIMESTAMP NULL,
+  PRIMARY KEY (`message_id`, `locale`),
+  CONSTRAINT `message_translations_message_fk` FOREIGN KEY (`message_id`) REFERENCES `messages` (`id`) ON DELETE CASCADE);
@@ -0,0 +1,174 @@
+package domain
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/utils/moderation_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+func TestTranslationRepo_Upsert_BumpsVersion(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewTranslationRepository(db)
+
+	tm := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE messages SET version=version\\+1 WHERE id=\\? AND tenant_id=\\? AND version=\\?").
+		WithArgs(4, "acme", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTranslationSnapshot(mock, 4, "fr", false)
+	mock.ExpectExec("INSERT INTO message_translations(.+) ON DUPLICATE KEY UPDATE").
+		WithArgs(4, "fr", "Bonjour", "le monde", tm).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "acme", 4, AuditActionTranslate)
+	mock.ExpectCommit()
+
+	msg := &Message{Id: 4, Version: 2}
+	upsertErr := repo.Upsert(tenant_utils.WithTenant(context.Background(), "acme"), msg, &Translation{
+		Locale: "fr", Title: "Bonjour", Body: "le monde", UpdatedAt: tm,
+	})
+
+	assert.Nil(t, upsertErr)
+	assert.Equal(t, 3, msg.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTranslationRepo_Upsert_HoldsFlaggedMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewTranslationRepository(db)
+
+	tm := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE messages SET version=version\\+1").
+		WithArgs(4, "acme", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTranslationSnapshot(mock, 4, "fr", true)
+	mock.ExpectExec("INSERT INTO message_translations").
+		WithArgs(4, "fr", "Soirée", "Casino ce soir", tm).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("REPLACE INTO message_moderation").WithArgs(4, "acme", []byte("[]"), tm).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE messages SET status=\\?, publish_at=\\? WHERE id=\\? AND tenant_id=\\?;").
+		WithArgs(MessageStatusPendingReview, nil, 4, "acme").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "acme", 4, AuditActionTranslate)
+	mock.ExpectCommit()
+
+	msg := &Message{Id: 4, TenantId: "acme", Status: MessageStatusPendingReview, Version: 2,
+		Moderation: &Moderation{Flags: []moderation_utils.Flag{}, FlaggedAt: tm}}
+	upsertErr := repo.Upsert(tenant_utils.WithTenant(context.Background(), "acme"), msg, &Translation{
+		Locale: "fr", Title: "Soirée", Body: "Casino ce soir", UpdatedAt: tm,
+	})
+
+	assert.Nil(t, upsertErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTranslationRepo_Upsert_Conflict(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewTranslationRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE messages SET version=version\\+1").
+		WithArgs(4, "default", 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	msg := &Message{Id: 4, Version: 2}
+	upsertErr := repo.Upsert(context.Background(), msg, &Translation{Locale: "fr", Title: "Bonjour", Body: "le monde"})
+
+	assert.NotNil(t, upsertErr)
+	assert.EqualValues(t, http.StatusConflict, upsertErr.Status())
+	assert.Equal(t, 2, msg.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTranslationRepo_Delete_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewTranslationRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE messages SET version=version\\+1").
+		WithArgs(4, "default", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTranslationSnapshot(mock, 4, "de", false)
+	mock.ExpectRollback()
+
+	msg := &Message{Id: 4, Version: 2}
+	deleteErr := repo.Delete(context.Background(), msg, "de")
+
+	assert.NotNil(t, deleteErr)
+	assert.EqualValues(t, http.StatusNotFound, deleteErr.Status())
+	assert.Equal(t, 2, msg.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTranslationRepo_Delete_Audited(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewTranslationRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE messages SET version=version\\+1").
+		WithArgs(4, "default", 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectTranslationSnapshot(mock, 4, "de", true)
+	mock.ExpectExec("DELETE FROM message_translations WHERE message_id=\\? AND locale=\\?").
+		WithArgs(4, "de").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectAudit(mock, "default", 4, AuditActionTranslate)
+	mock.ExpectCommit()
+
+	msg := &Message{Id: 4, Version: 2}
+	deleteErr := repo.Delete(context.Background(), msg, "de")
+
+	assert.Nil(t, deleteErr)
+	assert.Equal(t, 3, msg.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectTranslationSnapshot expects the translation to be read for the
+// audit log, and finds it when exists is set.
+func expectTranslationSnapshot(mock sqlmock.Sqlmock, messageId int64, locale string, exists bool) {
+	rows := sqlmock.NewRows([]string{"message_id", "locale", "title", "body", "updated_at"})
+	if exists {
+		rows.AddRow(messageId, locale, "title", "body", time.Now())
+	}
+	mock.ExpectQuery("SELECT (.+) FROM message_translations WHERE message_id=\\? AND locale=\\? FOR UPDATE").
+		WithArgs(messageId, locale).
+		WillReturnRows(rows)
+}
+
+func TestTranslationRepo_ListByMessage_ScopedToTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewTranslationRepository(db)
+
+	rows := sqlmock.NewRows([]string{"message_id", "locale", "title", "body", "updated_at"}).
+		AddRow(4, "de", "Hallo", "Welt", time.Now()).
+		AddRow(4, "fr", "Bonjour", "le monde", time.Now())
+	mock.ExpectQuery("SELECT (.+) FROM message_translations t JOIN messages m (.+) WHERE t.message_id=\\? AND m.tenant_id=\\?").
+		WithArgs(4, "acme").
+		WillReturnRows(rows)
+
+	translations, listErr := repo.ListByMessage(tenant_utils.WithTenant(context.Background(), "acme"), 4)
+
+	assert.Nil(t, listErr)
+	assert.Equal(t, 2, len(translations))
+	assert.Equal(t, "fr", translations[1].Locale)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
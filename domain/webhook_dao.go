@@ -0,0 +1,276 @@
+package domain
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"testing-project/utils/error_formats"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+var (
+	WebhookRepo webhookRepoInterface = &webhookRepo{}
+)
+
+const (
+	selectWebhooks               = "SELECT id, tenant_id, url, events, secret, active, failure_count, created_at FROM webhooks"
+	queryGetWebhook              = selectWebhooks + " WHERE id=? AND tenant_id=?;"
+	queryListWebhooks            = selectWebhooks + " WHERE tenant_id=? ORDER BY id;"
+	queryListActiveWebhooksEvent = selectWebhooks + " WHERE tenant_id=? AND active=1 AND FIND_IN_SET(?, events) > 0;"
+	queryInsertWebhook           = "INSERT INTO webhooks(tenant_id, url, events, secret, active, failure_count, created_at) VALUES(?, ?, ?, ?, ?, 0, ?);"
+	queryUpdateWebhook           = "UPDATE webhooks SET url=?, events=?, active=?, failure_count=? WHERE id=? AND tenant_id=?;"
+	queryDeleteWebhook           = "DELETE FROM webhooks WHERE id=? AND tenant_id=?;"
+	queryWebhookSucceeded        = "UPDATE webhooks SET failure_count=0 WHERE id=? AND tenant_id=?;"
+	queryWebhookFailed           = "UPDATE webhooks SET failure_count=failure_count+1, active=(active AND failure_count < ?) WHERE id=? AND tenant_id=?;"
+	queryInsertWebhookDelivery   = "INSERT INTO webhook_deliveries(tenant_id, webhook_id, delivery_id, event, attempt, status_code, error, succeeded, duration_ms, created_at) " +
+		"VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?);"
+	// maxDeliveryErrorLength is the size, in characters, of
+	// webhook_deliveries.error.
+	maxDeliveryErrorLength     = 512
+	queryListWebhookDeliveries = "SELECT id, tenant_id, webhook_id, delivery_id, event, attempt, status_code, error, succeeded, duration_ms, created_at " +
+		"FROM webhook_deliveries WHERE webhook_id=? AND tenant_id=? ORDER BY id DESC LIMIT ?;"
+)
+
+// webhookRepoInterface stores the webhooks of the tenant in ctx and their
+// deliveries. Webhooks of other tenants are reported as not found.
+type webhookRepoInterface interface {
+	Get(context.Context, int64) (*Webhook, error_utils.MessageErr)
+	List(context.Context) ([]Webhook, error_utils.MessageErr)
+	ListActiveByEvent(context.Context, string) ([]Webhook, error_utils.MessageErr)
+	Create(context.Context, *Webhook) (*Webhook, error_utils.MessageErr)
+	Update(context.Context, *Webhook) (*Webhook, error_utils.MessageErr)
+	Delete(context.Context, int64) error_utils.MessageErr
+	RecordSuccess(context.Context, int64) error_utils.MessageErr
+	RecordFailure(ctx context.Context, webhookId int64, disableAfter int) error_utils.MessageErr
+	RecordDelivery(context.Context, *WebhookDelivery) error_utils.MessageErr
+	ListDeliveries(ctx context.Context, webhookId int64, limit int) ([]WebhookDelivery, error_utils.MessageErr)
+}
+
+type webhookRepo struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) webhookRepoInterface {
+	return &webhookRepo{db: db}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWebhook(row rowScanner) (*Webhook, error) {
+	var hook Webhook
+	var events string
+	if err := row.Scan(&hook.Id, &hook.TenantId, &hook.Url, &events, &hook.Secret, &hook.Active, &hook.FailureCount, &hook.CreatedAt); err != nil {
+		return nil, err
+	}
+	hook.Events = strings.Split(events, ",")
+	return &hook, nil
+}
+
+func (wr *webhookRepo) Get(ctx context.Context, webhookId int64) (*Webhook, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("webhookRepo", "get", time.Now())
+	ctx, span := startRepoSpan(ctx, "webhookRepo", "get", queryGetWebhook)
+	defer span.End()
+
+	stmt, err := wr.db.PrepareContext(ctx, queryGetWebhook)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare webhook: %s", err.Error())))
+	}
+	defer stmt.Close()
+
+	hook, getErr := scanWebhook(stmt.QueryRowContext(ctx, webhookId, tenant_utils.TenantFrom(ctx)))
+	if getErr != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(getErr))
+	}
+	return hook, nil
+}
+
+func (wr *webhookRepo) List(ctx context.Context) ([]Webhook, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("webhookRepo", "list", time.Now())
+	ctx, span := startRepoSpan(ctx, "webhookRepo", "list", queryListWebhooks)
+	defer span.End()
+
+	hooks, err := wr.query(ctx, queryListWebhooks, tenant_utils.TenantFrom(ctx))
+	if err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	return hooks, nil
+}
+
+func (wr *webhookRepo) ListActiveByEvent(ctx context.Context, eventType string) ([]Webhook, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("webhookRepo", "list_active_by_event", time.Now())
+	ctx, span := startRepoSpan(ctx, "webhookRepo", "list_active_by_event", queryListActiveWebhooksEvent)
+	defer span.End()
+
+	hooks, err := wr.query(ctx, queryListActiveWebhooksEvent, tenant_utils.TenantFrom(ctx), eventType)
+	if err != nil {
+		return nil, recordRepoErr(span, err)
+	}
+	return hooks, nil
+}
+
+func (wr *webhookRepo) query(ctx context.Context, query string, args ...interface{}) ([]Webhook, error_utils.MessageErr) {
+	rows, err := wr.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list webhooks: %s", err.Error()))
+	}
+	defer rows.Close()
+
+	hooks := make([]Webhook, 0)
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, error_formats.ParseError(err)
+		}
+		hooks = append(hooks, *hook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, error_formats.ParseError(err)
+	}
+	return hooks, nil
+}
+
+func (wr *webhookRepo) Create(ctx context.Context, hook *Webhook) (*Webhook, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("webhookRepo", "create", time.Now())
+	ctx, span := startRepoSpan(ctx, "webhookRepo", "create", queryInsertWebhook)
+	defer span.End()
+
+	stmt, err := wr.db.PrepareContext(ctx, queryInsertWebhook)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare webhook to save: %s", err.Error())))
+	}
+	defer stmt.Close()
+
+	hook.TenantId = tenant_utils.TenantFrom(ctx)
+	insertResult, createErr := stmt.ExecContext(ctx, hook.TenantId, hook.Url, strings.Join(hook.Events, ","), hook.Secret, hook.Active, hook.CreatedAt)
+	if createErr != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(createErr))
+	}
+	hookId, err := insertResult.LastInsertId()
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save webhook: %s", err.Error())))
+	}
+	hook.Id = hookId
+	return hook, nil
+}
+
+func (wr *webhookRepo) Update(ctx context.Context, hook *Webhook) (*Webhook, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("webhookRepo", "update", time.Now())
+	ctx, span := startRepoSpan(ctx, "webhookRepo", "update", queryUpdateWebhook)
+	defer span.End()
+
+	stmt, err := wr.db.PrepareContext(ctx, queryUpdateWebhook)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare webhook to update: %s", err.Error())))
+	}
+	defer stmt.Close()
+
+	if _, updateErr := stmt.ExecContext(ctx, hook.Url, strings.Join(hook.Events, ","), hook.Active, hook.FailureCount, hook.Id,
+		tenant_utils.TenantFrom(ctx)); updateErr != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(updateErr))
+	}
+	return hook, nil
+}
+
+func (wr *webhookRepo) Delete(ctx context.Context, webhookId int64) error_utils.MessageErr {
+	return wr.exec(ctx, "delete", queryDeleteWebhook, webhookId, tenant_utils.TenantFrom(ctx))
+}
+
+func (wr *webhookRepo) RecordSuccess(ctx context.Context, webhookId int64) error_utils.MessageErr {
+	return wr.exec(ctx, "record_success", queryWebhookSucceeded, webhookId, tenant_utils.TenantFrom(ctx))
+}
+
+// RecordFailure counts one more failed delivery and deactivates the webhook
+// once disableAfter consecutive deliveries have failed.
+func (wr *webhookRepo) RecordFailure(ctx context.Context, webhookId int64, disableAfter int) error_utils.MessageErr {
+	return wr.exec(ctx, "record_failure", queryWebhookFailed, disableAfter, webhookId, tenant_utils.TenantFrom(ctx))
+}
+
+func (wr *webhookRepo) exec(ctx context.Context, operation string, query string, args ...interface{}) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("webhookRepo", operation, time.Now())
+	ctx, span := startRepoSpan(ctx, "webhookRepo", operation, query)
+	defer span.End()
+
+	stmt, err := wr.db.PrepareContext(ctx, query)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare webhook statement: %s", err.Error())))
+	}
+	defer stmt.Close()
+
+	if _, err := stmt.ExecContext(ctx, args...); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to update webhook: %s", err.Error())))
+	}
+	return nil
+}
+
+func (wr *webhookRepo) RecordDelivery(ctx context.Context, delivery *WebhookDelivery) error_utils.MessageErr {
+	defer metrics_utils.ObserveRepoOperation("webhookRepo", "record_delivery", time.Now())
+	ctx, span := startRepoSpan(ctx, "webhookRepo", "record_delivery", queryInsertWebhookDelivery)
+	defer span.End()
+
+	stmt, err := wr.db.PrepareContext(ctx, queryInsertWebhookDelivery)
+	if err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to prepare webhook delivery: %s", err.Error())))
+	}
+	defer stmt.Close()
+
+	var deliveryErr sql.NullString
+	if delivery.Error != "" {
+		deliveryErr = sql.NullString{String: truncateRunes(delivery.Error, maxDeliveryErrorLength), Valid: true}
+	}
+	delivery.TenantId = tenant_utils.TenantFrom(ctx)
+	insertResult, insertErr := stmt.ExecContext(ctx, delivery.TenantId, delivery.WebhookId, delivery.DeliveryId, delivery.Event, delivery.Attempt,
+		delivery.StatusCode, deliveryErr, delivery.Succeeded, delivery.DurationMs, delivery.CreatedAt)
+	if insertErr != nil {
+		return recordRepoErr(span, error_formats.ParseError(insertErr))
+	}
+	if delivery.Id, err = insertResult.LastInsertId(); err != nil {
+		return recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to save webhook delivery: %s", err.Error())))
+	}
+	return nil
+}
+
+func (wr *webhookRepo) ListDeliveries(ctx context.Context, webhookId int64, limit int) ([]WebhookDelivery, error_utils.MessageErr) {
+	defer metrics_utils.ObserveRepoOperation("webhookRepo", "list_deliveries", time.Now())
+	ctx, span := startRepoSpan(ctx, "webhookRepo", "list_deliveries", queryListWebhookDeliveries)
+	defer span.End()
+
+	rows, err := wr.db.QueryContext(ctx, queryListWebhookDeliveries, webhookId, tenant_utils.TenantFrom(ctx), limit)
+	if err != nil {
+		return nil, recordRepoErr(span, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to list webhook deliveries: %s", err.Error())))
+	}
+	defer rows.Close()
+
+	deliveries := make([]WebhookDelivery, 0)
+	for rows.Next() {
+		var delivery WebhookDelivery
+		var deliveryErr sql.NullString
+		if err := rows.Scan(&delivery.Id, &delivery.TenantId, &delivery.WebhookId, &delivery.DeliveryId, &delivery.Event, &delivery.Attempt,
+			&delivery.StatusCode, &deliveryErr, &delivery.Succeeded, &delivery.DurationMs, &delivery.CreatedAt); err != nil {
+			return nil, recordRepoErr(span, error_formats.ParseError(err))
+		}
+		delivery.Error = deliveryErr.String
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, recordRepoErr(span, error_formats.ParseError(err))
+	}
+	return deliveries, nil
+}
+
+// truncateRunes cuts s to at most max characters, keeping them whole.
+func truncateRunes(s string, max int) string {
+	count := 0
+	for i := range s {
+		if count == max {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
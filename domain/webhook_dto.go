@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"net/url"
+	"strings"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// Webhook receives the events of the tenant that registered it.
+type Webhook struct {
+	Id           int64     `json:"id"`
+	TenantId     string    `json:"tenant_id"`
+	Url          string    `json:"url"`
+	Events       []string  `json:"events"`
+	Secret       string    `json:"secret,omitempty"`
+	Active       bool      `json:"active"`
+	FailureCount int       `json:"failure_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type WebhookDelivery struct {
+	Id         int64     `json:"id"`
+	TenantId   string    `json:"tenant_id"`
+	WebhookId  int64     `json:"webhook_id"`
+	DeliveryId string    `json:"delivery_id"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	Succeeded  bool      `json:"succeeded"`
+	DurationMs int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (w *Webhook) Validate() error_utils.MessageErr {
+	w.Url = strings.TrimSpace(w.Url)
+	parsed, err := url.Parse(w.Url)
+	if w.Url == "" || err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return error_utils.NewUnprocessibleEntityError("Please enter a valid http(s) url")
+	}
+	events := w.Events[:0]
+	for _, event := range w.Events {
+		if event = strings.TrimSpace(event); event != "" {
+			events = append(events, event)
+		}
+	}
+	w.Events = events
+	if len(w.Events) == 0 {
+		return error_utils.NewUnprocessibleEntityError("Please subscribe to at least one event")
+	}
+	return nil
+}
+
+// Subscribes reports whether the webhook wants events of eventType.
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
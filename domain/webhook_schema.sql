@@ -0,0 +1,27 @@
+  CREATE TABLE `webhooks` (
+  `id` INT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+  `url` VARCHAR(2048) NOT NULL,
+  `events` VARCHAR(255) NOT NULL,
+  `secret` VARCHAR(128) NOT NULL,
+  `active` TINYINT(1) NOT NULL DEFAULT 1,
+  `failure_count` INT NOT NULL DEFAULT 0,
+  `created_at` TIMESTAMP NULL,
+  PRIMARY KEY (`id`),
+  INDEX `webhooks_tenant_idx` (`tenant_id` ASC, `active` ASC));
+
+  CREATE TABLE `webhook_deliveries` (
+  `id` BIGINT NOT NULL AUTO_INCREMENT,
+  `tenant_id` VARCHAR(64) NOT NULL DEFAULT 'default',
+  `webhook_id` INT NOT NULL,
+  `delivery_id` VARCHAR(64) NOT NULL,
+  `event` VARCHAR(64) NOT NULL,
+  `attempt` INT NOT NULL,
+  `status_code` INT NOT NULL DEFAULT 0,
+  `error` VARCHAR(512) NULL,
+  `succeeded` TINYINT(1) NOT NULL,
+  `duration_ms` INT NOT NULL,
+  `created_at` TIMESTAMP NULL,
+  PRIMARY KEY (`id`),
+  INDEX `webhook_deliveries_webhook_idx` (`tenant_id` ASC, `webhook_id` ASC, `id` DESC),
+  CONSTRAINT `webhook_deliveries_webhook_fk` FOREIGN KEY (`webhook_id`) REFERENCES `webhooks` (`id`) ON DELETE CASCADE);
@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"context"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+func TestWebhookRepo_Create_OK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewWebhookRepository(db)
+
+	tm := time.Now()
+	mock.ExpectPrepare("INSERT INTO webhooks").
+		ExpectExec().
+		WithArgs("acme", "https://example.com/hook", "created,deleted", "s3cret", true, tm).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	hook, err := repo.Create(tenant_utils.WithTenant(context.Background(), "acme"), &Webhook{
+		Url:       "https://example.com/hook",
+		Events:    []string{"created", "deleted"},
+		Secret:    "s3cret",
+		Active:    true,
+		CreatedAt: tm,
+	})
+
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, hook.Id)
+	assert.Equal(t, "acme", hook.TenantId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepo_ListActiveByEvent_OK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewWebhookRepository(db)
+
+	rows := sqlmock.NewRows([]string{"id", "tenant_id", "url", "events", "secret", "active", "failure_count", "created_at"}).
+		AddRow(1, "acme", "https://a.example.com", "created,updated", "a", true, 0, time.Now()).
+		AddRow(2, "acme", "https://b.example.com", "created", "b", true, 2, time.Now())
+	mock.ExpectQuery("SELECT (.+) FROM webhooks WHERE tenant_id=\\? AND active=1 AND FIND_IN_SET").
+		WithArgs("acme", "created").
+		WillReturnRows(rows)
+
+	hooks, err := repo.ListActiveByEvent(tenant_utils.WithTenant(context.Background(), "acme"), "created")
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(hooks))
+	assert.EqualValues(t, []string{"created", "updated"}, hooks[0].Events)
+	assert.EqualValues(t, 2, hooks[1].FailureCount)
+}
+
+func TestWebhookRepo_Get_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewWebhookRepository(db)
+
+	mock.ExpectPrepare("SELECT (.+) FROM webhooks WHERE id=\\? AND tenant_id=\\?").
+		ExpectQuery().
+		WithArgs(1, "globex").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "url", "events", "secret", "active", "failure_count", "created_at"}))
+
+	hook, err := repo.Get(tenant_utils.WithTenant(context.Background(), "globex"), 1)
+	assert.Nil(t, hook)
+	assert.Error(t, err)
+	assert.Equal(t, "not_found", err.Error())
+}
+
+func TestWebhookRepo_RecordFailure_OK(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewWebhookRepository(db)
+
+	mock.ExpectPrepare("UPDATE webhooks SET failure_count=failure_count\\+1").
+		ExpectExec().
+		WithArgs(5, 9, "default").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.RecordFailure(context.Background(), 9, 5)
+	assert.Nil(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepo_Deliveries_ScopedByTenant(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewWebhookRepository(db)
+	ctx := tenant_utils.WithTenant(context.Background(), "acme")
+
+	tm := time.Now()
+	mock.ExpectPrepare("INSERT INTO webhook_deliveries").
+		ExpectExec().
+		WithArgs("acme", 9, "d-1", "message.created", 1, 500, "unexpected status 500", false, 12, tm).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectQuery("SELECT (.+) FROM webhook_deliveries WHERE webhook_id=\\? AND tenant_id=\\?").
+		WithArgs(9, "acme", 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "webhook_id", "delivery_id", "event", "attempt", "status_code", "error",
+			"succeeded", "duration_ms", "created_at"}).
+			AddRow(4, "acme", 9, "d-1", "message.created", 1, 500, "unexpected status 500", false, 12, tm))
+
+	recordErr := repo.RecordDelivery(ctx, &WebhookDelivery{WebhookId: 9, DeliveryId: "d-1", Event: "message.created", Attempt: 1,
+		StatusCode: 500, Error: "unexpected status 500", DurationMs: 12, CreatedAt: tm})
+	deliveries, listErr := repo.ListDeliveries(ctx, 9, 20)
+
+	assert.Nil(t, recordErr)
+	assert.Nil(t, listErr)
+	assert.Equal(t, 1, len(deliveries))
+	assert.Equal(t, "acme", deliveries[0].TenantId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepo_RecordDelivery_TruncatesError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+	repo := NewWebhookRepository(db)
+
+	tm := time.Now()
+	mock.ExpectPrepare("INSERT INTO webhook_deliveries").
+		ExpectExec().
+		WithArgs("default", 9, "d-1", "message.created", 1, 0, strings.Repeat("é", 512), false, 3, tm).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+
+	recordErr := repo.RecordDelivery(context.Background(), &WebhookDelivery{WebhookId: 9, DeliveryId: "d-1", Event: "message.created", Attempt: 1,
+		Error: strings.Repeat("é", 600), DurationMs: 3, CreatedAt: tm})
+
+	assert.Nil(t, recordErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhook_Validate(t *testing.T) {
+	hook := &Webhook{Url: "https://example.com", Events: []string{" created ", ""}}
+	assert.Nil(t, hook.Validate())
+	assert.EqualValues(t, []string{"created"}, hook.Events)
+
+	hook = &Webhook{Url: "example.com", Events: []string{"created"}}
+	assert.NotNil(t, hook.Validate())
+
+	hook = &Webhook{Url: "https://example.com"}
+	err := hook.Validate()
+	assert.NotNil(t, err)
+	assert.EqualValues(t, "Please subscribe to at least one event", err.Message())
+}
@@ -0,0 +1,90 @@
+package integration_tests
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/controllers"
+	"testing-project/domain"
+	"testing-project/services"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/rabbitmq_utils"
+	"time"
+)
+
+type apiKeyRepoStub struct {
+	key *domain.ApiKey
+}
+
+func (m *apiKeyRepoStub) Get(ctx context.Context, id int64) (*domain.ApiKey, error_utils.MessageErr) {
+	return m.key, nil
+}
+func (m *apiKeyRepoStub) GetByPrefix(ctx context.Context, prefix string) (*domain.ApiKey, error_utils.MessageErr) {
+	if m.key == nil || m.key.Prefix != prefix {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	return m.key, nil
+}
+func (m *apiKeyRepoStub) List(ctx context.Context) ([]domain.ApiKey, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *apiKeyRepoStub) Create(ctx context.Context, key *domain.ApiKey) (*domain.ApiKey, error_utils.MessageErr) {
+	key.Id = 5
+	m.key = key
+	return key, nil
+}
+func (m *apiKeyRepoStub) Revoke(ctx context.Context, id int64, at time.Time) error_utils.MessageErr {
+	return nil
+}
+func (m *apiKeyRepoStub) TouchLastUsed(ctx context.Context, id int64, at time.Time) error_utils.MessageErr {
+	return nil
+}
+
+func TestApiKey_ScopesAreEnforcedPerRoute(t *testing.T) {
+	domain.ApiKeyRepo = &apiKeyRepoStub{}
+	domain.MessageRepo = &mockRepo{}
+	rabbitmq_utils.PublishToQueue = func(ctx context.Context, eventType string, message string) {}
+
+	_, plaintext, err := services.ApiKeysService.MintApiKey(context.Background(), &domain.ApiKey{
+		Name:   "reader",
+		Scopes: []string{auth_utils.ScopeMessagesRead},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error minting key: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	authenticate := auth_utils.Middleware(auth_utils.Authenticators{ApiKey: services.ApiKeysService})
+	r.GET("/messages/ping", authenticate, auth_utils.RequireScope(auth_utils.ScopeMessagesRead), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	r.DELETE("/messages/:message_id", authenticate, auth_utils.RequireScope(auth_utils.ScopeMessagesDelete), controllers.DeleteMessage)
+
+	req, _ := http.NewRequest(http.MethodGet, "/messages/ping", nil)
+	req.Header.Set(auth_utils.ApiKeyHeader, plaintext)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNoContent {
+		t.Errorf("Expected read scope to be accepted, got %d", resp.Code)
+	}
+
+	req, _ = http.NewRequest(http.MethodDelete, "/messages/1", nil)
+	req.Header.Set(auth_utils.ApiKeyHeader, plaintext)
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != http.StatusForbidden {
+		t.Errorf("Expected missing delete scope to be rejected with 403, got %d", resp.Code)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/messages/ping", nil)
+	req.Header.Set(auth_utils.ApiKeyHeader, "wsk_unknown_key")
+	resp = httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Errorf("Expected unknown key to be rejected with 401, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing-project/controllers"
+	"testing-project/domain"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/rabbitmq_utils"
+	"time"
+)
+
+type authFixture struct {
+	key    *rsa.PrivateKey
+	router *gin.Engine
+}
+
+func newAuthFixture(t *testing.T, useURL bool) *authFixture {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test-key",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+
+	config := auth_utils.JWTConfig{Issuer: "https://issuer.test", Audience: "writing-service"}
+	if useURL {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(jwks)
+		}))
+		t.Cleanup(server.Close)
+		config.JWKSURL = server.URL
+	} else {
+		config.JWKSFile = filepath.Join(t.TempDir(), "jwks.json")
+		if err := os.WriteFile(config.JWKSFile, jwks, 0600); err != nil {
+			t.Fatalf("failed to write jwks: %v", err)
+		}
+	}
+	authenticator, err := auth_utils.NewJWTAuthenticator(config)
+	if err != nil {
+		t.Fatalf("failed to create authenticator: %v", err)
+	}
+
+	domain.MessageRepo = &mockRepo{}
+	rabbitmq_utils.PublishToQueue = func(ctx context.Context, eventType string, message string) {}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/messages", auth_utils.Middleware(auth_utils.Authenticators{Bearer: authenticator}), controllers.CreateMessage)
+	return &authFixture{key: key, router: r}
+}
+
+func (f *authFixture) token(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(f.key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func (f *authFixture) post(token string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"title": "Authored", "body": "Authored body"})
+	req, _ := http.NewRequest(http.MethodPost, "/messages", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp := httptest.NewRecorder()
+	f.router.ServeHTTP(resp, req)
+	return resp
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   "https://issuer.test",
+		"aud":   "writing-service",
+		"sub":   "user-42",
+		"scope": "messages openid",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestAuth_CreateMessage_SetsAuthorFromToken(t *testing.T) {
+	for _, useURL := range []bool{false, true} {
+		f := newAuthFixture(t, useURL)
+
+		resp := f.post(f.token(t, validClaims()))
+
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("Expected status 201 Created, got %d: %s", resp.Code, resp.Body.String())
+		}
+		var created domain.Message
+		if err := json.Unmarshal(resp.Body.Bytes(), &created); err != nil {
+			t.Fatalf("Invalid response JSON: %s", err)
+		}
+		if created.AuthorId != "user-42" {
+			t.Errorf("Expected author_id 'user-42', got '%s'", created.AuthorId)
+		}
+	}
+}
+
+func TestAuth_CreateMessage_RejectsBadTokens(t *testing.T) {
+	f := newAuthFixture(t, false)
+
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "someone-else"
+	wrongIssuer := validClaims()
+	wrongIssuer["iss"] = "https://evil.test"
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	cases := map[string]string{
+		"missing":        "",
+		"garbage":        "not-a-jwt",
+		"wrong audience": f.token(t, wrongAudience),
+		"wrong issuer":   f.token(t, wrongIssuer),
+		"expired":        f.token(t, expired),
+	}
+	for name, token := range cases {
+		resp := f.post(token)
+		if resp.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status 401, got %d", name, resp.Code)
+		}
+		if resp.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: expected a WWW-Authenticate challenge", name)
+		}
+	}
+}
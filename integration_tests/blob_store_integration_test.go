@@ -0,0 +1,101 @@
+package integration_tests
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"testing-project/utils/blob_utils"
+)
+
+// fakeS3 stands in for an S3-compatible service: it keeps objects in memory
+// and rejects requests that are not signed with Signature Version 4.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+var sigV4Authorization = regexp.MustCompile(`^AWS4-HMAC-SHA256 Credential=test-key/\d{8}/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`)
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sigV4Authorization.MatchString(r.Header.Get("Authorization")) || r.Header.Get("X-Amz-Date") == "" {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		s.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := s.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func exerciseBlobStore(t *testing.T, store blob_utils.BlobStore) {
+	ctx := context.Background()
+	content := "%PDF-1.4 attachment"
+
+	assert.NoError(t, store.Put(ctx, "acme/4/abc", strings.NewReader(content), int64(len(content)), "application/pdf"))
+	reader, err := store.Get(ctx, "acme/4/abc")
+	assert.NoError(t, err)
+	stored, _ := io.ReadAll(reader)
+	reader.Close()
+	assert.Equal(t, content, string(stored))
+
+	assert.NoError(t, store.Delete(ctx, "acme/4/abc"))
+	_, err = store.Get(ctx, "acme/4/abc")
+	assert.Equal(t, blob_utils.ErrBlobNotFound, err)
+	assert.NoError(t, store.Delete(ctx, "acme/4/abc"))
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := blob_utils.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	exerciseBlobStore(t, store)
+	assert.Error(t, store.Put(context.Background(), "../escape", strings.NewReader("x"), 1, "text/plain"))
+}
+
+func TestS3BlobStore(t *testing.T) {
+	backend := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	store, err := blob_utils.NewS3Store(blob_utils.S3Config{
+		Endpoint:        server.URL,
+		Region:          "eu-west-1",
+		Bucket:          "attachments",
+		AccessKeyId:     "test-key",
+		SecretAccessKey: "test-secret",
+	})
+	assert.NoError(t, err)
+
+	exerciseBlobStore(t, store)
+}
+
+func TestS3BlobStore_RejectedCredentials(t *testing.T) {
+	backend := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(backend)
+	defer server.Close()
+
+	store, _ := blob_utils.NewS3Store(blob_utils.S3Config{Endpoint: server.URL, Region: "us-east-1", Bucket: "attachments", AccessKeyId: "other"})
+
+	err := store.Put(context.Background(), "acme/4/abc", strings.NewReader("x"), 1, "application/pdf")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "403")
+}
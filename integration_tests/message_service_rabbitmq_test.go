@@ -1,6 +1,7 @@
 package integration_tests
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"strings"
@@ -9,23 +10,66 @@ import (
 	"testing-project/services"
 	"testing-project/utils/error_utils"
 	"testing-project/utils/rabbitmq_utils"
+	"time"
 )
 
 type mockRepo struct{}
 
-func (m *mockRepo) Get(id int64) (*domain.Message, error_utils.MessageErr) {
+func (m *mockRepo) Get(ctx context.Context, id int64) (*domain.Message, error_utils.MessageErr) {
 	return nil, nil
 }
-func (m *mockRepo) Create(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+func (m *mockRepo) GetBySlug(ctx context.Context, slug string) (*domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) GetByTitle(ctx context.Context, title string) (*domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) Similar(ctx context.Context, msg *domain.Message, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) Create(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
 	msg.Id = 999
 	return msg, nil
 }
-func (m *mockRepo) Update(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+func (m *mockRepo) Update(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) Delete(ctx context.Context, id int64) error_utils.MessageErr {
+	return nil
+}
+func (m *mockRepo) CountByAuthorSince(ctx context.Context, authorId string, since time.Time) (int, error_utils.MessageErr) {
+	return 0, nil
+}
+func (m *mockRepo) ListByAuthor(ctx context.Context, authorId string, afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
 	return nil, nil
 }
-func (m *mockRepo) Delete(id int64) error_utils.MessageErr {
+func (m *mockRepo) ListAfter(ctx context.Context, afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) Anonymize(ctx context.Context, id int64) error_utils.MessageErr {
 	return nil
 }
+func (m *mockRepo) UpdateStatus(ctx context.Context, msg *domain.Message, fromStatus string) error_utils.MessageErr {
+	return nil
+}
+func (m *mockRepo) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) List(ctx context.Context, filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) ListTags(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) Thread(ctx context.Context, rootId int64, depth int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *mockRepo) Pinned(ctx context.Context, scope string) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
 func (m *mockRepo) Initialize(_, _, _, _, _, _ string) *sql.DB {
 	return nil
 }
@@ -36,7 +80,7 @@ func TestCreateMessage_PublishesToRabbitMQ(t *testing.T) {
 	called := false
 	var published string
 
-	rabbitmq_utils.PublishToQueue = func(message string) {
+	rabbitmq_utils.PublishToQueue = func(ctx context.Context, eventType string, message string) {
 		called = true
 		published = message
 	}
@@ -46,7 +90,7 @@ func TestCreateMessage_PublishesToRabbitMQ(t *testing.T) {
 		Body:  "This is the body",
 	}
 
-	created, err := services.MessagesService.CreateMessage(msg)
+	created, err := services.MessagesService.CreateMessage(context.Background(), msg)
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -2,6 +2,7 @@ package integration_tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"net/http"
@@ -15,7 +16,7 @@ import (
 func TestCreateMessage_Integration(t *testing.T) {
 	domain.MessageRepo = &mockRepo{}
 
-	rabbitmq_utils.PublishToQueue = func(message string) {
+	rabbitmq_utils.PublishToQueue = func(ctx context.Context, eventType string, message string) {
 		t.Logf("Mock RabbitMQ called with message: %s", message)
 	}
 
@@ -0,0 +1,118 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing-project/controllers"
+	"testing-project/domain"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/rabbitmq_utils"
+	"testing-project/utils/ratelimit_utils"
+)
+
+func rateLimitedRouter(limit ratelimit_utils.Limit) *gin.Engine {
+	domain.MessageRepo = &mockRepo{}
+	rabbitmq_utils.PublishToQueue = func(ctx context.Context, eventType string, message string) {}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	identify := func(c *gin.Context) {
+		if subject := c.GetHeader("X-Test-Subject"); subject != "" {
+			principal := &auth_utils.Principal{Subject: subject}
+			c.Request = c.Request.WithContext(auth_utils.WithPrincipal(c.Request.Context(), principal))
+		}
+		c.Next()
+	}
+	r.POST("/messages", identify, ratelimit_utils.Middleware(ratelimit_utils.NewMemoryStore(), "messages.create", limit), controllers.CreateMessage)
+	return r
+}
+
+func postMessage(r *gin.Engine, subject string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]string{"title": "Limited", "body": "Limited body"})
+	req, _ := http.NewRequest(http.MethodPost, "/messages", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	if subject != "" {
+		req.Header.Set("X-Test-Subject", subject)
+	}
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestCreateMessage_RateLimited(t *testing.T) {
+	r := rateLimitedRouter(ratelimit_utils.Limit{Rate: 0.001, Burst: 2})
+
+	for i, remaining := range []string{"1", "0"} {
+		resp := postMessage(r, "user-1")
+		if resp.Code != http.StatusCreated {
+			t.Fatalf("request %d: expected status 201 Created, got %d", i, resp.Code)
+		}
+		if got := resp.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: expected RateLimit-Limit 2, got %q", i, got)
+		}
+		if got := resp.Header().Get("RateLimit-Remaining"); got != remaining {
+			t.Errorf("request %d: expected RateLimit-Remaining %s, got %q", i, remaining, got)
+		}
+	}
+
+	resp := postMessage(r, "user-1")
+	if resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected status 429 Too Many Requests, got %d", resp.Code)
+	}
+	if resp.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header on the rejected request")
+	}
+	var errBody map[string]interface{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &errBody); err != nil {
+		t.Fatalf("Invalid JSON error body: %v", err)
+	}
+	if errBody["error"] != "too_many_requests" {
+		t.Errorf("Expected too_many_requests error, got %v", errBody["error"])
+	}
+}
+
+func TestCreateMessage_RateLimitedPerClient(t *testing.T) {
+	r := rateLimitedRouter(ratelimit_utils.Limit{Rate: 0.001, Burst: 1})
+
+	if resp := postMessage(r, "user-1"); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 Created for user-1, got %d", resp.Code)
+	}
+	if resp := postMessage(r, "user-1"); resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected user-1 to be limited, got %d", resp.Code)
+	}
+	if resp := postMessage(r, "user-2"); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected user-2 to have its own bucket, got %d", resp.Code)
+	}
+	if resp := postMessage(r, ""); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected anonymous callers to be limited by IP separately, got %d", resp.Code)
+	}
+}
+
+func TestCreateMessage_RateLimitIgnoresUntrustedForwardedFor(t *testing.T) {
+	r := rateLimitedRouter(ratelimit_utils.Limit{Rate: 0.001, Burst: 1})
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, forwardedFor := range []string{"203.0.113.1", "203.0.113.2"} {
+		body, _ := json.Marshal(map[string]string{"title": "Limited", "body": "Limited body"})
+		req := httptest.NewRequest(http.MethodPost, "/messages", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+
+		expected := http.StatusCreated
+		if i > 0 {
+			expected = http.StatusTooManyRequests
+		}
+		if resp.Code != expected {
+			t.Fatalf("request %d: expected status %d, got %d", i, expected, resp.Code)
+		}
+	}
+}
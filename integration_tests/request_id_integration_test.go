@@ -0,0 +1,117 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/controllers"
+	"testing-project/domain"
+	"testing-project/utils/audit_utils"
+	"testing-project/utils/logger_utils"
+	"testing-project/utils/rabbitmq_utils"
+	"unicode/utf8"
+)
+
+func TestCreateMessage_PropagatesRequestID(t *testing.T) {
+	var logs bytes.Buffer
+	logger_utils.Init(&logs, slog.LevelInfo)
+	defer logger_utils.Init(&bytes.Buffer{}, slog.LevelInfo)
+
+	domain.MessageRepo = &mockRepo{}
+
+	var published string
+	rabbitmq_utils.PublishToQueue = func(ctx context.Context, eventType string, message string) {
+		published = message
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(logger_utils.RequestIDMiddleware(), logger_utils.GinMiddleware())
+	r.POST("/messages", controllers.CreateMessage)
+
+	body, _ := json.Marshal(map[string]string{"title": "With id", "body": "With id body"})
+	req, _ := http.NewRequest(http.MethodPost, "/messages", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(logger_utils.RequestIDHeader, "req-123")
+	resp := httptest.NewRecorder()
+
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 Created, got %d", resp.Code)
+	}
+	if got := resp.Header().Get(logger_utils.RequestIDHeader); got != "req-123" {
+		t.Errorf("Expected request id to be echoed back, got %q", got)
+	}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal([]byte(published), &payload); err != nil {
+		t.Fatalf("Invalid JSON published: %v", err)
+	}
+	if payload["request_id"] != "req-123" {
+		t.Errorf("Expected request id in published event, got %v", payload["request_id"])
+	}
+
+	var line map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(logs.String())), &line); err != nil {
+		t.Fatalf("Expected a single JSON access log line, got %q", logs.String())
+	}
+	if line["request_id"] != "req-123" {
+		t.Errorf("Expected request id in access log, got %v", line["request_id"])
+	}
+}
+
+func TestRequestIDMiddleware_GeneratesID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(logger_utils.RequestIDMiddleware())
+	var seen string
+	r.GET("/ping", func(c *gin.Context) {
+		seen = logger_utils.RequestIDFrom(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, "/ping", nil)
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+
+	if seen == "" {
+		t.Fatal("Expected a generated request id in the request context")
+	}
+	if resp.Header().Get(logger_utils.RequestIDHeader) != seen {
+		t.Errorf("Expected generated id %q on the response, got %q", seen, resp.Header().Get(logger_utils.RequestIDHeader))
+	}
+}
+
+func TestAuditSource_CutsUserAgentOnCharacters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+	var source audit_utils.Source
+	r.GET("/source", audit_utils.Middleware(), func(c *gin.Context) {
+		source = audit_utils.SourceFrom(c.Request.Context())
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/source", nil)
+	req.Header.Set("User-Agent", "a"+strings.Repeat("é", 300))
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !utf8.ValidString(source.UserAgent) {
+		t.Errorf("Expected a valid UTF-8 user agent, got %q", source.UserAgent)
+	}
+	if got := utf8.RuneCountInString(source.UserAgent); got != 255 {
+		t.Errorf("Expected the user agent cut to 255 characters, got %d", got)
+	}
+	if source.ClientIP != "192.0.2.1" {
+		t.Errorf("Expected the peer address as client IP, got %q", source.ClientIP)
+	}
+}
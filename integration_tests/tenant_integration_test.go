@@ -0,0 +1,266 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"testing-project/controllers"
+	"testing-project/domain"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/rabbitmq_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+// tenantRepo mimics the tenant scoping of the MySQL repository: messages of
+// another tenant are not found and titles are unique per tenant.
+type tenantRepo struct {
+	mu       sync.Mutex
+	lastId   int64
+	messages map[int64]domain.Message
+}
+
+func (r *tenantRepo) find(ctx context.Context, id int64) (*domain.Message, error_utils.MessageErr) {
+	msg, ok := r.messages[id]
+	if !ok || msg.TenantId != tenant_utils.TenantFrom(ctx) {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	return &msg, nil
+}
+
+func (r *tenantRepo) Get(ctx context.Context, id int64) (*domain.Message, error_utils.MessageErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.find(ctx, id)
+}
+func (r *tenantRepo) GetBySlug(ctx context.Context, slug string) (*domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) GetByTitle(ctx context.Context, title string) (*domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) Similar(ctx context.Context, msg *domain.Message, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) Create(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	msg.TenantId = tenant_utils.TenantFrom(ctx)
+	for _, existing := range r.messages {
+		if existing.TenantId == msg.TenantId && existing.Title == msg.Title {
+			return nil, error_utils.NewInternalServerError("title already taken")
+		}
+	}
+	r.lastId++
+	msg.Id = r.lastId
+	r.messages[msg.Id] = *msg
+	return msg, nil
+}
+func (r *tenantRepo) Update(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.find(ctx, msg.Id); err != nil {
+		return nil, err
+	}
+	r.messages[msg.Id] = *msg
+	return msg, nil
+}
+func (r *tenantRepo) Delete(ctx context.Context, id int64) error_utils.MessageErr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.find(ctx, id); err != nil {
+		return err
+	}
+	delete(r.messages, id)
+	return nil
+}
+func (r *tenantRepo) CountByAuthorSince(ctx context.Context, authorId string, since time.Time) (int, error_utils.MessageErr) {
+	return 0, nil
+}
+func (r *tenantRepo) ListByAuthor(ctx context.Context, authorId string, afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) ListAfter(ctx context.Context, afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) Anonymize(ctx context.Context, id int64) error_utils.MessageErr {
+	return nil
+}
+func (r *tenantRepo) UpdateStatus(ctx context.Context, msg *domain.Message, fromStatus string) error_utils.MessageErr {
+	return nil
+}
+func (r *tenantRepo) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) List(ctx context.Context, filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) ListTags(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) Thread(ctx context.Context, rootId int64, depth int) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) Pinned(ctx context.Context, scope string) ([]domain.Message, error_utils.MessageErr) {
+	return nil, nil
+}
+func (r *tenantRepo) Initialize(_, _, _, _, _, _ string) *sql.DB {
+	return nil
+}
+
+func tenantRouter() (*gin.Engine, *[]string) {
+	domain.MessageRepo = &tenantRepo{messages: make(map[int64]domain.Message)}
+	var routingKeys []string
+	rabbitmq_utils.PublishToQueue = func(ctx context.Context, eventType string, message string) {
+		routingKeys = append(routingKeys, rabbitmq_utils.RoutingKey(tenant_utils.TenantFrom(ctx), eventType))
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	identify := func(c *gin.Context) {
+		if subject := c.GetHeader("X-Test-Subject"); subject != "" {
+			principal := &auth_utils.Principal{Subject: subject, TenantId: c.GetHeader("X-Test-Tenant"),
+				Scopes: strings.Fields(c.GetHeader("X-Test-Scopes"))}
+			c.Request = c.Request.WithContext(auth_utils.WithPrincipal(c.Request.Context(), principal))
+		}
+		c.Next()
+	}
+	tenant := tenant_utils.Middleware()
+	r.POST("/messages", identify, tenant, controllers.CreateMessage)
+	r.PUT("/messages/:message_id", identify, tenant, controllers.UpdateMessage)
+	r.DELETE("/messages/:message_id", identify, tenant, controllers.DeleteMessage)
+	return r, &routingKeys
+}
+
+func tenantRequest(r *gin.Engine, method string, target string, tenantId string, body interface{}) *httptest.ResponseRecorder {
+	raw, _ := json.Marshal(body)
+	req, _ := http.NewRequest(method, target, bytes.NewBuffer(raw))
+	req.Header.Set("Content-Type", "application/json")
+	if tenantId != "" {
+		req.Header.Set(tenant_utils.TenantHeader, tenantId)
+	}
+	resp := httptest.NewRecorder()
+	r.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestTenants_TitlesAreUniquePerTenant(t *testing.T) {
+	r, routingKeys := tenantRouter()
+	welcome := map[string]string{"title": "Welcome", "body": "Hello"}
+
+	if resp := tenantRequest(r, http.MethodPost, "/messages", "acme", welcome); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 Created for acme, got %d", resp.Code)
+	}
+	if resp := tenantRequest(r, http.MethodPost, "/messages", "globex", welcome); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 Created for globex, got %d", resp.Code)
+	}
+	if resp := tenantRequest(r, http.MethodPost, "/messages", "acme", welcome); resp.Code == http.StatusCreated {
+		t.Fatal("Expected a duplicate title within acme to be rejected")
+	}
+
+	expected := []string{"acme.message.created", "globex.message.created"}
+	if fmt.Sprint(*routingKeys) != fmt.Sprint(expected) {
+		t.Errorf("Expected routing keys %v, got %v", expected, *routingKeys)
+	}
+}
+
+func TestTenants_CrossTenantAccessIsNotFound(t *testing.T) {
+	r, _ := tenantRouter()
+	resp := tenantRequest(r, http.MethodPost, "/messages", "acme", map[string]string{"title": "Secret", "body": "Acme only"})
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 Created, got %d", resp.Code)
+	}
+	var created domain.Message
+	json.Unmarshal(resp.Body.Bytes(), &created)
+	if created.TenantId != "acme" {
+		t.Errorf("Expected tenant acme, got %q", created.TenantId)
+	}
+	target := fmt.Sprintf("/messages/%d", created.Id)
+
+	update := map[string]string{"title": "Stolen", "body": "Globex was here"}
+	if resp := tenantRequest(r, http.MethodPut, target, "globex", update); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected cross-tenant update to return 404, got %d", resp.Code)
+	}
+	if resp := tenantRequest(r, http.MethodDelete, target, "", nil); resp.Code != http.StatusNotFound {
+		t.Errorf("Expected delete from the default tenant to return 404, got %d", resp.Code)
+	}
+	if resp := tenantRequest(r, http.MethodDelete, target, "acme", nil); resp.Code != http.StatusOK {
+		t.Errorf("Expected delete within acme to succeed, got %d", resp.Code)
+	}
+}
+
+func TestTenants_CredentialTenantWins(t *testing.T) {
+	r, routingKeys := tenantRouter()
+	body := map[string]string{"title": "From token", "body": "Scoped by the token"}
+
+	req := func(header string) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(body)
+		req, _ := http.NewRequest(http.MethodPost, "/messages", bytes.NewBuffer(raw))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-Subject", "user-1")
+		req.Header.Set("X-Test-Tenant", "acme")
+		if header != "" {
+			req.Header.Set(tenant_utils.TenantHeader, header)
+		}
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := req("globex"); resp.Code != http.StatusForbidden {
+		t.Errorf("Expected a header naming another tenant to be rejected with 403, got %d", resp.Code)
+	}
+	if resp := req(""); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 Created, got %d", resp.Code)
+	}
+	if len(*routingKeys) != 1 || (*routingKeys)[0] != "acme.message.created" {
+		t.Errorf("Expected the token's tenant in the routing key, got %v", *routingKeys)
+	}
+}
+
+func TestTenants_OnlyAdminsWithoutTenantPickOne(t *testing.T) {
+	r, routingKeys := tenantRouter()
+
+	req := func(scopes string, title string) *httptest.ResponseRecorder {
+		raw, _ := json.Marshal(map[string]string{"title": title, "body": "No tenant in the credentials"})
+		req, _ := http.NewRequest(http.MethodPost, "/messages", bytes.NewBuffer(raw))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Test-Subject", "user-1")
+		req.Header.Set("X-Test-Scopes", scopes)
+		req.Header.Set(tenant_utils.TenantHeader, "acme")
+		resp := httptest.NewRecorder()
+		r.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := req(auth_utils.ScopeMessagesWrite, "Member"); resp.Code != http.StatusForbidden {
+		t.Errorf("Expected a tenant-less non-admin picking a tenant to be rejected with 403, got %d", resp.Code)
+	}
+	if resp := req(auth_utils.AdminScope, "Admin"); resp.Code != http.StatusCreated {
+		t.Fatalf("Expected a tenant-less admin to pick a tenant, got %d", resp.Code)
+	}
+	if len(*routingKeys) != 1 || (*routingKeys)[0] != "acme.message.created" {
+		t.Errorf("Expected the admin's message in acme, got %v", *routingKeys)
+	}
+}
+
+func TestTenants_InvalidTenantId(t *testing.T) {
+	r, _ := tenantRouter()
+
+	resp := tenantRequest(r, http.MethodPost, "/messages", "acme.#", map[string]string{"title": "Wildcard", "body": "Nope"})
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 Bad Request, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package integration_tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/streadway/amqp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing-project/controllers"
+	"testing-project/domain"
+	"testing-project/utils/rabbitmq_utils"
+	"testing-project/utils/tracing_utils"
+)
+
+func TestCreateMessage_PropagatesTraceContextToBroker(t *testing.T) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	domain.MessageRepo = &mockRepo{}
+
+	headers := amqp.Table{}
+	rabbitmq_utils.PublishToQueue = func(ctx context.Context, eventType string, message string) {
+		tracing_utils.InjectAMQPHeaders(ctx, headers)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(tracing_utils.GinMiddleware())
+	r.POST("/messages", controllers.CreateMessage)
+
+	body, _ := json.Marshal(map[string]string{"title": "Traced", "body": "Traced body"})
+	req, _ := http.NewRequest(http.MethodPost, "/messages", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	resp := httptest.NewRecorder()
+
+	r.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201 Created, got %d", resp.Code)
+	}
+	traceparent, _ := headers["traceparent"].(string)
+	if !strings.Contains(traceparent, "4bf92f3577b34da6a3ce929d0e0e4736") {
+		t.Errorf("Expected published headers to continue the incoming trace, got %q", traceparent)
+	}
+	if strings.HasSuffix(traceparent, "00f067aa0ba902b7-01") {
+		t.Errorf("Expected a child span id in published headers, got %q", traceparent)
+	}
+}
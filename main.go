@@ -1,11 +1,11 @@
 package main
 
 import (
-	"fmt"
+	"log/slog"
 	"testing-project/app"
 )
 
 func main() {
-	fmt.Println("Welcome to the app")
+	slog.Info("Welcome to the app")
 	app.StartApp()
 }
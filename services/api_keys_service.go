@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing-project/domain"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+const (
+	apiKeyTokenPrefix     = "wsk"
+	apiKeyTouchInterval   = time.Minute
+	apiKeyPrincipalPrefix = "apikey:"
+)
+
+var (
+	ApiKeysService apiKeysServiceInterface = &apiKeysService{}
+
+	// apiKeyScopes are the scopes an API key may be granted.
+	apiKeyScopes = map[string]bool{
+		auth_utils.ScopeMessagesRead:     true,
+		auth_utils.ScopeMessagesWrite:    true,
+		auth_utils.ScopeMessagesDelete:   true,
+		auth_utils.ScopeMessagesModerate: true,
+	}
+)
+
+type apiKeysService struct{}
+
+type apiKeysServiceInterface interface {
+	MintApiKey(context.Context, *domain.ApiKey) (*domain.ApiKey, string, error_utils.MessageErr)
+	ListApiKeys(context.Context) ([]domain.ApiKey, error_utils.MessageErr)
+	RevokeApiKey(context.Context, int64) error_utils.MessageErr
+	Authenticate(context.Context, string) (*auth_utils.Principal, error_utils.MessageErr)
+}
+
+// MintApiKey stores a new key and returns it along with its plaintext value,
+// which is not kept anywhere and cannot be shown again.
+func (a *apiKeysService) MintApiKey(ctx context.Context, key *domain.ApiKey) (*domain.ApiKey, string, error_utils.MessageErr) {
+	if err := key.Validate(); err != nil {
+		return nil, "", err
+	}
+	for _, scope := range key.Scopes {
+		if !apiKeyScopes[scope] {
+			return nil, "", error_utils.NewUnprocessibleEntityError(fmt.Sprintf("unknown scope %q", scope))
+		}
+	}
+	if key.TenantId != "" {
+		if err := tenant_utils.Validate(key.TenantId); err != nil {
+			return nil, "", error_utils.NewUnprocessibleEntityError(err.Message())
+		}
+	}
+
+	prefix, err := randomToken(6, hex.EncodeToString)
+	if err != nil {
+		return nil, "", error_utils.NewInternalServerError(fmt.Sprintf("error when trying to generate api key: %s", err.Error()))
+	}
+	secret, err := randomToken(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return nil, "", error_utils.NewInternalServerError(fmt.Sprintf("error when trying to generate api key: %s", err.Error()))
+	}
+	plaintext := apiKeyTokenPrefix + "_" + prefix + "_" + secret
+
+	key.Prefix = prefix
+	key.Hash = hashApiKey(plaintext)
+	key.CreatedBy = ""
+	if principal := auth_utils.PrincipalFrom(ctx); principal != nil {
+		key.CreatedBy = principal.Subject
+	}
+	key.LastUsedAt = nil
+	key.RevokedAt = nil
+	key.CreatedAt = time.Now()
+
+	created, createErr := domain.ApiKeyRepo.Create(ctx, key)
+	if createErr != nil {
+		return nil, "", createErr
+	}
+	return created, plaintext, nil
+}
+
+func (a *apiKeysService) ListApiKeys(ctx context.Context) ([]domain.ApiKey, error_utils.MessageErr) {
+	return domain.ApiKeyRepo.List(ctx)
+}
+
+func (a *apiKeysService) RevokeApiKey(ctx context.Context, keyId int64) error_utils.MessageErr {
+	key, err := domain.ApiKeyRepo.Get(ctx, keyId)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	return domain.ApiKeyRepo.Revoke(ctx, key.Id, time.Now())
+}
+
+// Authenticate resolves a plaintext API key to a principal carrying the
+// key's scopes.
+func (a *apiKeysService) Authenticate(ctx context.Context, plaintext string) (*auth_utils.Principal, error_utils.MessageErr) {
+	parts := strings.SplitN(plaintext, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyTokenPrefix {
+		return nil, error_utils.NewUnauthorizedError("invalid api key")
+	}
+	key, err := domain.ApiKeyRepo.GetByPrefix(ctx, parts[1])
+	if err != nil {
+		if err.Status() == http.StatusNotFound {
+			return nil, error_utils.NewUnauthorizedError("invalid api key")
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hashApiKey(plaintext))) != 1 {
+		return nil, error_utils.NewUnauthorizedError("invalid api key")
+	}
+	now := time.Now()
+	if !key.Usable(now) {
+		return nil, error_utils.NewUnauthorizedError("api key is expired or revoked")
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := domain.ApiKeyRepo.TouchLastUsed(ctx, key.Id, now); err != nil {
+			slog.WarnContext(ctx, "failed to record api key use", "api_key_id", key.Id, "error", err.Message())
+		}
+	}
+	return &auth_utils.Principal{
+		Subject:  apiKeyPrincipalPrefix + strconv.FormatInt(key.Id, 10),
+		Scopes:   key.Scopes,
+		TenantId: key.TenantId,
+	}, nil
+}
+
+func hashApiKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(size int, encode func([]byte) string) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+type apiKeyRepoMock struct {
+	keys    []*domain.ApiKey
+	touched []int64
+	revoked []int64
+}
+
+func (m *apiKeyRepoMock) Get(ctx context.Context, id int64) (*domain.ApiKey, error_utils.MessageErr) {
+	for _, key := range m.keys {
+		if key.Id == id {
+			return key, nil
+		}
+	}
+	return nil, error_utils.NewNotFoundError("no record matching given id")
+}
+func (m *apiKeyRepoMock) GetByPrefix(ctx context.Context, prefix string) (*domain.ApiKey, error_utils.MessageErr) {
+	for _, key := range m.keys {
+		if key.Prefix == prefix {
+			return key, nil
+		}
+	}
+	return nil, error_utils.NewNotFoundError("no record matching given id")
+}
+func (m *apiKeyRepoMock) List(ctx context.Context) ([]domain.ApiKey, error_utils.MessageErr) {
+	return nil, nil
+}
+func (m *apiKeyRepoMock) Create(ctx context.Context, key *domain.ApiKey) (*domain.ApiKey, error_utils.MessageErr) {
+	key.Id = int64(len(m.keys) + 1)
+	m.keys = append(m.keys, key)
+	return key, nil
+}
+func (m *apiKeyRepoMock) Revoke(ctx context.Context, id int64, at time.Time) error_utils.MessageErr {
+	m.revoked = append(m.revoked, id)
+	return nil
+}
+func (m *apiKeyRepoMock) TouchLastUsed(ctx context.Context, id int64, at time.Time) error_utils.MessageErr {
+	m.touched = append(m.touched, id)
+	return nil
+}
+
+func TestApiKeysService_MintAndAuthenticate(t *testing.T) {
+	repo := &apiKeyRepoMock{}
+	domain.ApiKeyRepo = repo
+
+	key, plaintext, err := ApiKeysService.MintApiKey(asPrincipal("ops", auth_utils.AdminScope), &domain.ApiKey{
+		Name:     "nightly import",
+		Scopes:   []string{auth_utils.ScopeMessagesRead, auth_utils.ScopeMessagesWrite},
+		TenantId: "acme",
+	})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(plaintext, "wsk_"+key.Prefix+"_"))
+	assert.NotContains(t, key.Hash, plaintext)
+	assert.EqualValues(t, "ops", key.CreatedBy)
+
+	principal, err := ApiKeysService.Authenticate(context.Background(), plaintext)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "apikey:1", principal.Subject)
+	assert.EqualValues(t, "acme", principal.TenantId)
+	assert.True(t, principal.HasScope(auth_utils.ScopeMessagesWrite))
+	assert.False(t, principal.HasScope(auth_utils.ScopeMessagesDelete))
+	assert.EqualValues(t, []int64{1}, repo.touched)
+}
+
+func TestApiKeysService_MintApiKey_UnknownScope(t *testing.T) {
+	domain.ApiKeyRepo = &apiKeyRepoMock{}
+
+	key, _, err := ApiKeysService.MintApiKey(context.Background(), &domain.ApiKey{Name: "job", Scopes: []string{"admin"}})
+
+	assert.Nil(t, key)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.EqualValues(t, `unknown scope "admin"`, err.Message())
+}
+
+func TestApiKeysService_MintApiKey_InvalidTenant(t *testing.T) {
+	domain.ApiKeyRepo = &apiKeyRepoMock{}
+
+	key, _, err := ApiKeysService.MintApiKey(context.Background(), &domain.ApiKey{
+		Name: "job", Scopes: []string{auth_utils.ScopeMessagesRead}, TenantId: "acme.#",
+	})
+
+	assert.Nil(t, key)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+}
+
+func TestApiKeysService_Authenticate_Rejects(t *testing.T) {
+	repo := &apiKeyRepoMock{}
+	domain.ApiKeyRepo = repo
+	_, plaintext, _ := ApiKeysService.MintApiKey(context.Background(), &domain.ApiKey{Name: "job", Scopes: []string{auth_utils.ScopeMessagesRead}})
+
+	_, err := ApiKeysService.Authenticate(context.Background(), plaintext+"x")
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+
+	_, err = ApiKeysService.Authenticate(context.Background(), "wsk_000000000000_nope")
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+
+	past := time.Now().Add(-time.Minute)
+	repo.keys[0].ExpiresAt = &past
+	_, err = ApiKeysService.Authenticate(context.Background(), plaintext)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, "api key is expired or revoked", err.Message())
+
+	repo.keys[0].ExpiresAt = nil
+	repo.keys[0].RevokedAt = &past
+	_, err = ApiKeysService.Authenticate(context.Background(), plaintext)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+}
+
+func TestApiKeysService_Authenticate_ThrottlesLastUsed(t *testing.T) {
+	repo := &apiKeyRepoMock{}
+	domain.ApiKeyRepo = repo
+	_, plaintext, _ := ApiKeysService.MintApiKey(context.Background(), &domain.ApiKey{Name: "job", Scopes: []string{auth_utils.ScopeMessagesRead}})
+	recent := time.Now().Add(-time.Second)
+	repo.keys[0].LastUsedAt = &recent
+
+	_, err := ApiKeysService.Authenticate(context.Background(), plaintext)
+
+	assert.Nil(t, err)
+	assert.Empty(t, repo.touched)
+}
+
+func TestApiKeysService_RevokeApiKey(t *testing.T) {
+	repo := &apiKeyRepoMock{}
+	domain.ApiKeyRepo = repo
+	key, _, _ := ApiKeysService.MintApiKey(context.Background(), &domain.ApiKey{Name: "job", Scopes: []string{auth_utils.ScopeMessagesRead}})
+
+	assert.Nil(t, ApiKeysService.RevokeApiKey(context.Background(), key.Id))
+	assert.EqualValues(t, []int64{key.Id}, repo.revoked)
+
+	err := ApiKeysService.RevokeApiKey(context.Background(), 99)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+}
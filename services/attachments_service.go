@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"testing-project/domain"
+	"testing-project/utils/blob_utils"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+const (
+	maxAttachmentNameLength = 255
+	blobCleanupBatchSize    = 100
+)
+
+var (
+	AttachmentsService attachmentsServiceInterface = &attachmentsService{}
+
+	// Blobs holds the bytes of every attachment.
+	Blobs blob_utils.BlobStore
+
+	// MaxAttachmentSize is the largest upload accepted, in bytes.
+	MaxAttachmentSize int64 = 10 << 20
+
+	// AllowedAttachmentTypes are the MIME types an upload may have. The type
+	// is sniffed from the content; the one the client declares is ignored.
+	AllowedAttachmentTypes = map[string]bool{
+		"image/png":       true,
+		"image/jpeg":      true,
+		"image/gif":       true,
+		"image/webp":      true,
+		"application/pdf": true,
+	}
+
+	// blobCleanup wakes the blob cleanup job early after a delete.
+	blobCleanup = make(chan struct{}, 1)
+)
+
+type attachmentsService struct{}
+
+type attachmentsServiceInterface interface {
+	UploadAttachment(ctx context.Context, msgId int64, fileName string, content io.Reader, size int64) (*domain.Attachment, error_utils.MessageErr)
+	ListAttachments(ctx context.Context, msgId int64) ([]domain.Attachment, error_utils.MessageErr)
+	OpenAttachment(ctx context.Context, msgId int64, attachmentId int64) (*domain.Attachment, io.ReadCloser, error_utils.MessageErr)
+	DeleteAttachment(ctx context.Context, msgId int64, attachmentId int64) error_utils.MessageErr
+}
+
+// UploadAttachment stores content, size bytes long, as an attachment of the
+// message. Only the message's author may add attachments.
+func (s *attachmentsService) UploadAttachment(ctx context.Context, msgId int64, fileName string, content io.Reader, size int64) (*domain.Attachment, error_utils.MessageErr) {
+	msg, err := domain.MessageRepo.Get(ctx, msgId)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeWrite(ctx, msg); err != nil {
+		return nil, err
+	}
+	if size > MaxAttachmentSize {
+		return nil, error_utils.NewPayloadTooLargeError(fmt.Sprintf("attachments may be at most %d bytes", MaxAttachmentSize))
+	}
+	if size <= 0 {
+		return nil, error_utils.NewBadRequestError("attachment is empty")
+	}
+
+	head := make([]byte, 512)
+	n, readErr := io.ReadFull(content, head)
+	if readErr != nil && readErr != io.ErrUnexpectedEOF {
+		return nil, error_utils.NewBadRequestError("could not read attachment")
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !AllowedAttachmentTypes[contentType] {
+		return nil, error_utils.NewUnsupportedMediaTypeError(fmt.Sprintf("attachments of type %s are not allowed", contentType))
+	}
+
+	attachment := &domain.Attachment{
+		MessageId:   msg.Id,
+		FileName:    attachmentName(fileName),
+		ContentType: contentType,
+		Size:        size,
+		BlobKey:     blobKey(tenant_utils.TenantFrom(ctx), msg.Id),
+		CreatedAt:   time.Now(),
+	}
+	if err := Blobs.Put(ctx, attachment.BlobKey, io.MultiReader(bytes.NewReader(head), content), size, contentType); err != nil {
+		slog.ErrorContext(ctx, "failed to store attachment", "message_id", msg.Id, "error", err)
+		return nil, error_utils.NewInternalServerError("error when trying to store attachment")
+	}
+	created, createErr := domain.AttachmentRepo.Create(ctx, attachment)
+	if createErr != nil {
+		if err := Blobs.Delete(ctx, attachment.BlobKey); err != nil {
+			slog.WarnContext(ctx, "failed to remove orphaned blob", "key", attachment.BlobKey, "error", err)
+		}
+		return nil, createErr
+	}
+	return created, nil
+}
+
+func (s *attachmentsService) ListAttachments(ctx context.Context, msgId int64) ([]domain.Attachment, error_utils.MessageErr) {
+	if _, err := domain.MessageRepo.Get(ctx, msgId); err != nil {
+		return nil, err
+	}
+	return domain.AttachmentRepo.ListByMessage(ctx, msgId)
+}
+
+// OpenAttachment returns the attachment with a reader over its bytes, which
+// the caller must close.
+func (s *attachmentsService) OpenAttachment(ctx context.Context, msgId int64, attachmentId int64) (*domain.Attachment, io.ReadCloser, error_utils.MessageErr) {
+	if _, err := domain.MessageRepo.Get(ctx, msgId); err != nil {
+		return nil, nil, err
+	}
+	attachment, err := domain.AttachmentRepo.Get(ctx, msgId, attachmentId)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, blobErr := Blobs.Get(ctx, attachment.BlobKey)
+	if blobErr != nil {
+		if errors.Is(blobErr, blob_utils.ErrBlobNotFound) {
+			return nil, nil, error_utils.NewNotFoundError("attachment content is missing")
+		}
+		slog.ErrorContext(ctx, "failed to read attachment", "attachment_id", attachmentId, "error", blobErr)
+		return nil, nil, error_utils.NewInternalServerError("error when trying to read attachment")
+	}
+	return attachment, content, nil
+}
+
+func (s *attachmentsService) DeleteAttachment(ctx context.Context, msgId int64, attachmentId int64) error_utils.MessageErr {
+	msg, err := domain.MessageRepo.Get(ctx, msgId)
+	if err != nil {
+		return err
+	}
+	if err := authorizeWrite(ctx, msg); err != nil {
+		return err
+	}
+	if err := domain.AttachmentRepo.Delete(ctx, msgId, attachmentId); err != nil {
+		return err
+	}
+	requestBlobCleanup()
+	return nil
+}
+
+// attachmentName keeps the last path element of the name the client sent.
+func attachmentName(fileName string) string {
+	name := path.Base(strings.ReplaceAll(strings.TrimSpace(fileName), "\\", "/"))
+	if name == "." || name == "/" {
+		name = "attachment"
+	}
+	if len(name) > maxAttachmentNameLength {
+		name = name[len(name)-maxAttachmentNameLength:]
+	}
+	return name
+}
+
+func blobKey(tenantId string, msgId int64) string {
+	random := make([]byte, 16)
+	rand.Read(random)
+	return fmt.Sprintf("%s/%d/%s", tenantId, msgId, hex.EncodeToString(random))
+}
+
+func requestBlobCleanup() {
+	select {
+	case blobCleanup <- struct{}{}:
+	default:
+	}
+}
+
+// RunBlobCleanup removes the blobs of deleted attachments, and of the
+// attachments of deleted messages, every interval until ctx is done. A
+// delete wakes it early. Blobs that fail to delete are retried next round.
+func RunBlobCleanup(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-blobCleanup:
+		}
+		cleanupBlobs(ctx)
+	}
+}
+
+// cleanupBlobs purges deleted attachments and returns how many it purged.
+func cleanupBlobs(ctx context.Context) int {
+	purged := 0
+	for {
+		deleted, err := domain.AttachmentRepo.ListDeleted(ctx, blobCleanupBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to list deleted attachments", "error", err.Message())
+			return purged
+		}
+		progressed := false
+		for _, attachment := range deleted {
+			if err := Blobs.Delete(ctx, attachment.BlobKey); err != nil {
+				slog.ErrorContext(ctx, "failed to delete blob", "attachment_id", attachment.Id, "key", attachment.BlobKey, "error", err)
+				continue
+			}
+			if err := domain.AttachmentRepo.Purge(ctx, attachment.Id); err != nil {
+				slog.ErrorContext(ctx, "failed to purge attachment", "attachment_id", attachment.Id, "error", err.Message())
+				continue
+			}
+			progressed = true
+			purged++
+		}
+		if len(deleted) < blobCleanupBatchSize || !progressed {
+			return purged
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/blob_utils"
+	"testing-project/utils/error_utils"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+type memoryBlobs struct {
+	blobs     map[string][]byte
+	deleteErr error
+}
+
+func newMemoryBlobs() *memoryBlobs {
+	return &memoryBlobs{blobs: make(map[string][]byte)}
+}
+
+func (b *memoryBlobs) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(body)
+	b.blobs[key] = data
+	return err
+}
+
+func (b *memoryBlobs) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := b.blobs[key]
+	if !ok {
+		return nil, blob_utils.ErrBlobNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *memoryBlobs) Delete(ctx context.Context, key string) error {
+	if b.deleteErr != nil {
+		return b.deleteErr
+	}
+	delete(b.blobs, key)
+	return nil
+}
+
+type attachmentRepoMock struct {
+	created []domain.Attachment
+	deleted []domain.Attachment
+	purged  []int64
+}
+
+func (r *attachmentRepoMock) Get(ctx context.Context, messageId int64, attachmentId int64) (*domain.Attachment, error_utils.MessageErr) {
+	for _, attachment := range r.created {
+		if attachment.Id == attachmentId && attachment.MessageId == messageId {
+			return &attachment, nil
+		}
+	}
+	return nil, error_utils.NewNotFoundError("no record matching given id")
+}
+func (r *attachmentRepoMock) ListByMessage(ctx context.Context, messageId int64) ([]domain.Attachment, error_utils.MessageErr) {
+	return r.created, nil
+}
+func (r *attachmentRepoMock) Create(ctx context.Context, attachment *domain.Attachment) (*domain.Attachment, error_utils.MessageErr) {
+	attachment.Id = int64(len(r.created) + 1)
+	r.created = append(r.created, *attachment)
+	return attachment, nil
+}
+func (r *attachmentRepoMock) Delete(ctx context.Context, messageId int64, attachmentId int64) error_utils.MessageErr {
+	return nil
+}
+func (r *attachmentRepoMock) ListDeleted(ctx context.Context, limit int) ([]domain.Attachment, error_utils.MessageErr) {
+	return r.deleted, nil
+}
+func (r *attachmentRepoMock) Purge(ctx context.Context, attachmentId int64) error_utils.MessageErr {
+	r.purged = append(r.purged, attachmentId)
+	return nil
+}
+
+func setupAttachments() (*memoryBlobs, *attachmentRepoMock) {
+	blobs, repo := newMemoryBlobs(), &attachmentRepoMock{}
+	Blobs = blobs
+	domain.AttachmentRepo = repo
+	domain.MessageRepo = &getDBMock{}
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: messageId, TenantId: "default"}, nil
+	}
+	return blobs, repo
+}
+
+func TestAttachmentsService_Upload_SniffsType(t *testing.T) {
+	blobs, repo := setupAttachments()
+	content := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{1}, 1000)...)
+
+	attachment, err := AttachmentsService.UploadAttachment(context.Background(), 4, `C:\photos\cat.png`, bytes.NewReader(content), int64(len(content)))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "image/png", attachment.ContentType)
+	assert.Equal(t, "cat.png", attachment.FileName)
+	assert.True(t, strings.HasPrefix(attachment.BlobKey, "default/4/"))
+	assert.Equal(t, content, blobs.blobs[attachment.BlobKey])
+	assert.Equal(t, 1, len(repo.created))
+
+	_, reader, openErr := AttachmentsService.OpenAttachment(context.Background(), 4, attachment.Id)
+	assert.Nil(t, openErr)
+	stored, _ := io.ReadAll(reader)
+	assert.Equal(t, content, stored)
+}
+
+func TestAttachmentsService_Upload_RejectsType(t *testing.T) {
+	blobs, _ := setupAttachments()
+	content := []byte("#!/bin/sh\necho hello\n")
+
+	_, err := AttachmentsService.UploadAttachment(context.Background(), 4, "cat.png", bytes.NewReader(content), int64(len(content)))
+
+	assert.EqualValues(t, http.StatusUnsupportedMediaType, err.Status())
+	assert.Equal(t, 0, len(blobs.blobs))
+}
+
+func TestAttachmentsService_Upload_TooLarge(t *testing.T) {
+	setupAttachments()
+
+	_, err := AttachmentsService.UploadAttachment(context.Background(), 4, "big.png", bytes.NewReader(pngHeader), MaxAttachmentSize+1)
+
+	assert.EqualValues(t, http.StatusRequestEntityTooLarge, err.Status())
+}
+
+func TestCleanupBlobs_PurgesDeletedAttachments(t *testing.T) {
+	blobs, repo := setupAttachments()
+	blobs.blobs["default/4/a"] = []byte("a")
+	repo.deleted = []domain.Attachment{{Id: 1, BlobKey: "default/4/a"}, {Id: 2, BlobKey: "default/4/gone"}}
+
+	purged := cleanupBlobs(context.Background())
+
+	assert.Equal(t, 2, purged)
+	assert.Equal(t, []int64{1, 2}, repo.purged)
+	assert.Equal(t, 0, len(blobs.blobs))
+}
+
+func TestCleanupBlobs_KeepsRowWhenBlobDeleteFails(t *testing.T) {
+	blobs, repo := setupAttachments()
+	blobs.deleteErr = errors.New("bucket unavailable")
+	repo.deleted = []domain.Attachment{{Id: 1, BlobKey: "default/4/a"}}
+
+	purged := cleanupBlobs(context.Background())
+
+	assert.Equal(t, 0, purged)
+	assert.Equal(t, 0, len(repo.purged))
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+// auditChainPage is how many entries VerifyChain reads at a time.
+const auditChainPage = 500
+
+var (
+	AuditService auditServiceInterface = &auditService{}
+)
+
+type auditService struct{}
+
+// ChainReport is the outcome of walking a tenant's audit log. BrokenAt is
+// the id of the first entry whose hash, or link to the entry before, does
+// not hold; every entry from there on is suspect.
+type ChainReport struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// auditServiceInterface reads the audit log of the caller's tenant.
+type auditServiceInterface interface {
+	ListEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error_utils.MessageErr)
+	VerifyChain(ctx context.Context) (*ChainReport, error_utils.MessageErr)
+}
+
+// ListEntries lists the entries matching filter, newest first.
+func (s *auditService) ListEntries(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error_utils.MessageErr) {
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = defaultListLimit
+	case filter.Limit < 0 || filter.Limit > maxListLimit:
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+	}
+	if filter.Offset < 0 {
+		return nil, error_utils.NewBadRequestError("offset must not be negative")
+	}
+	if filter.Action != "" && !domain.ValidAuditAction(filter.Action) {
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("unknown action %q", filter.Action))
+	}
+	if filter.Since != nil && filter.Until != nil && !filter.Since.Before(*filter.Until) {
+		return nil, error_utils.NewBadRequestError("since must be before until")
+	}
+	return domain.AuditRepo.List(ctx, filter)
+}
+
+// VerifyChain walks the whole audit log of the tenant, oldest first, and
+// recomputes every hash. It stops at the first entry that does not hold.
+func (s *auditService) VerifyChain(ctx context.Context) (*ChainReport, error_utils.MessageErr) {
+	report := &ChainReport{Valid: true}
+	prevHash := domain.AuditGenesisHash
+	var afterId int64
+	for {
+		entries, err := domain.AuditRepo.Chain(ctx, afterId, auditChainPage)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			entry := &entries[i]
+			switch {
+			case entry.PrevHash != prevHash:
+				report.Valid, report.BrokenAt, report.Reason = false, entry.Id, "previous hash does not match"
+			case entry.ComputeHash() != entry.Hash:
+				report.Valid, report.BrokenAt, report.Reason = false, entry.Id, "hash does not match"
+			case !entry.ValuesMatch():
+				report.Valid, report.BrokenAt, report.Reason = false, entry.Id, "values do not match their hashes"
+			}
+			if !report.Valid {
+				return report, nil
+			}
+			report.Entries++
+			prevHash = entry.Hash
+			afterId = entry.Id
+		}
+		if len(entries) < auditChainPage {
+			return report, nil
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+type auditRepoMock struct {
+	entries  []domain.AuditEntry
+	filter   domain.AuditFilter
+	redacted []int64
+}
+
+func (r *auditRepoMock) List(ctx context.Context, filter domain.AuditFilter) ([]domain.AuditEntry, error_utils.MessageErr) {
+	r.filter = filter
+	newestFirst := []domain.AuditEntry{}
+	for i := len(r.entries) - 1; i >= 0; i-- {
+		newestFirst = append(newestFirst, r.entries[i])
+	}
+	return newestFirst, nil
+}
+func (r *auditRepoMock) Chain(ctx context.Context, afterId int64, limit int) ([]domain.AuditEntry, error_utils.MessageErr) {
+	chain := []domain.AuditEntry{}
+	for _, entry := range r.entries {
+		if entry.Id > afterId && len(chain) < limit {
+			chain = append(chain, entry)
+		}
+	}
+	return chain, nil
+}
+
+func (r *auditRepoMock) Redact(ctx context.Context, msgId int64) (int, error_utils.MessageErr) {
+	r.redacted = append(r.redacted, msgId)
+	count := 0
+	for _, entry := range r.entries {
+		if entry.MessageId == msgId {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// auditChain builds count correctly chained entries.
+func auditChain(count int) []domain.AuditEntry {
+	entries := make([]domain.AuditEntry, count)
+	prevHash := domain.AuditGenesisHash
+	for i := range entries {
+		entries[i] = domain.AuditEntry{Id: int64(i + 1), TenantId: "default", MessageId: 7, Action: domain.AuditActionUpdate,
+			After: json.RawMessage(`{"id":7}`), AfterHash: domain.AuditValueHash(json.RawMessage(`{"id":7}`)), CreatedAt: time.Date(2024, 1, 3, 0, 0, i, 0, time.UTC), PrevHash: prevHash}
+		entries[i].Hash = entries[i].ComputeHash()
+		prevHash = entries[i].Hash
+	}
+	return entries
+}
+
+func TestAuditService_ListEntries_DefaultsLimit(t *testing.T) {
+	repo := &auditRepoMock{}
+	domain.AuditRepo = repo
+
+	_, err := AuditService.ListEntries(context.Background(), domain.AuditFilter{Action: domain.AuditActionRestore})
+
+	assert.Nil(t, err)
+	assert.Equal(t, defaultListLimit, repo.filter.Limit)
+}
+
+func TestAuditService_ListEntries_UnknownAction(t *testing.T) {
+	domain.AuditRepo = &auditRepoMock{}
+
+	_, err := AuditService.ListEntries(context.Background(), domain.AuditFilter{Action: "publish"})
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, err.Status())
+}
+
+func TestAuditService_VerifyChain_Valid(t *testing.T) {
+	domain.AuditRepo = &auditRepoMock{entries: auditChain(auditChainPage + 3)}
+
+	report, err := AuditService.VerifyChain(context.Background())
+
+	assert.Nil(t, err)
+	assert.True(t, report.Valid)
+	assert.Equal(t, auditChainPage+3, report.Entries)
+}
+
+func TestAuditService_VerifyChain_EditedEntry(t *testing.T) {
+	entries := auditChain(5)
+	entries[2].After = json.RawMessage(`{"id":8}`)
+	domain.AuditRepo = &auditRepoMock{entries: entries}
+
+	report, err := AuditService.VerifyChain(context.Background())
+
+	assert.Nil(t, err)
+	assert.False(t, report.Valid)
+	assert.EqualValues(t, 3, report.BrokenAt)
+	assert.Equal(t, 2, report.Entries)
+}
+
+func TestAuditService_VerifyChain_RedactedEntry(t *testing.T) {
+	entries := auditChain(5)
+	redactedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	entries[2].After, entries[2].RedactedAt = nil, &redactedAt
+	domain.AuditRepo = &auditRepoMock{entries: entries}
+
+	report, err := AuditService.VerifyChain(context.Background())
+
+	assert.Nil(t, err)
+	assert.True(t, report.Valid)
+	assert.Equal(t, 5, report.Entries)
+}
+
+func TestAuditService_VerifyChain_RedactedEntryRewritten(t *testing.T) {
+	entries := auditChain(5)
+	redactedAt := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	entries[2].After, entries[2].RedactedAt = json.RawMessage(`{"id":8}`), &redactedAt
+	domain.AuditRepo = &auditRepoMock{entries: entries}
+
+	report, err := AuditService.VerifyChain(context.Background())
+
+	assert.Nil(t, err)
+	assert.False(t, report.Valid)
+	assert.EqualValues(t, 3, report.BrokenAt)
+	assert.Equal(t, "values do not match their hashes", report.Reason)
+}
+
+func TestAuditService_VerifyChain_RemovedEntry(t *testing.T) {
+	entries := auditChain(5)
+	entries = append(entries[:1], entries[2:]...)
+	domain.AuditRepo = &auditRepoMock{entries: entries}
+
+	report, err := AuditService.VerifyChain(context.Background())
+
+	assert.Nil(t, err)
+	assert.False(t, report.Valid)
+	assert.EqualValues(t, 3, report.BrokenAt)
+	assert.Equal(t, "previous hash does not match", report.Reason)
+}
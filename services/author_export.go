@@ -0,0 +1,108 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+)
+
+const (
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatZIP    = "zip"
+)
+
+// exportWriter lays out an export. Records come in sections of one kind,
+// and files come last.
+type exportWriter interface {
+	Section(kind string) error
+	Record(record interface{}) error
+	File(name string, content io.Reader) error
+	Close() error
+}
+
+func newExportWriter(format string, w io.Writer) exportWriter {
+	if format == ExportFormatZIP {
+		return &zipExport{archive: zip.NewWriter(w)}
+	}
+	return &ndjsonExport{w: w}
+}
+
+// ndjsonExport writes one JSON object per line, tagged with its kind.
+// Files are written inline, base64 encoded.
+type ndjsonExport struct {
+	w    io.Writer
+	kind string
+}
+
+func (e *ndjsonExport) Section(kind string) error {
+	e.kind = kind
+	return nil
+}
+
+func (e *ndjsonExport) Record(record interface{}) error {
+	line, err := json.Marshal(map[string]interface{}{"type": e.kind, "data": record})
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(line, '\n'))
+	return err
+}
+
+func (e *ndjsonExport) File(name string, content io.Reader) error {
+	quoted, err := json.Marshal(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(e.w, `{"type":"file","name":`+string(quoted)+`,"content_base64":"`); err != nil {
+		return err
+	}
+	encoder := base64.NewEncoder(base64.StdEncoding, e.w)
+	if _, err := io.Copy(encoder, content); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err = io.WriteString(e.w, "\"}\n")
+	return err
+}
+
+func (e *ndjsonExport) Close() error {
+	return nil
+}
+
+// zipExport writes every section to <kind>s.ndjson in the archive, and
+// every file under its own name.
+type zipExport struct {
+	archive *zip.Writer
+	entry   io.Writer
+}
+
+func (e *zipExport) Section(kind string) error {
+	entry, err := e.archive.Create(kind + "s.ndjson")
+	e.entry = entry
+	return err
+}
+
+func (e *zipExport) Record(record interface{}) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = e.entry.Write(append(line, '\n'))
+	return err
+}
+
+func (e *zipExport) File(name string, content io.Reader) error {
+	entry, err := e.archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, content)
+	return err
+}
+
+func (e *zipExport) Close() error {
+	return e.archive.Close()
+}
@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"testing-project/domain"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/blob_utils"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/logger_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+const (
+	ErasureModeAnonymize = "anonymize"
+	ErasureModeDelete    = "delete"
+
+	// authorPageSize is how many messages, or audit entries of a message,
+	// exports and erasures read at a time.
+	authorPageSize = 100
+)
+
+var (
+	AuthorsService authorsServiceInterface = &authorsService{}
+
+	// ErasureReportKey is the key erasure reports are signed with. It is
+	// held by the server only, so a report cannot be altered and signed
+	// again by whoever holds it.
+	ErasureReportKey []byte
+)
+
+type authorsService struct{}
+
+// ErasureReport records what an erasure did. Verified is set when no
+// message of the tenant was attributed to the author any more afterwards.
+// Every change was also written to the audit log, the before and after
+// values of the messages' entries were then redacted, counted in
+// AuditRedacted, and AuditHash is the hash the log ended at, which
+// GET /audit/verify can be checked against. Retained lists what the
+// erasure kept. Signature is the HMAC-SHA256, under ErasureReportKey, of
+// the report as JSON without the signature, so that a stored copy can be
+// checked for changes with POST /erasure-reports/verify.
+type ErasureReport struct {
+	AuthorId         string    `json:"author_id"`
+	TenantId         string    `json:"tenant_id"`
+	Mode             string    `json:"mode"`
+	RequestId        string    `json:"request_id,omitempty"`
+	MessageIds       []int64   `json:"message_ids"`
+	Anonymized       int       `json:"anonymized"`
+	Deleted          int       `json:"deleted"`
+	ReactionsRemoved int       `json:"reactions_removed"`
+	ReadsRemoved     int       `json:"reads_removed"`
+	AuditRedacted    int       `json:"audit_redacted"`
+	Retained         []string  `json:"retained"`
+	Verified         bool      `json:"verified"`
+	AuditHash        string    `json:"audit_hash,omitempty"`
+	StartedAt        time.Time `json:"started_at"`
+	CompletedAt      time.Time `json:"completed_at"`
+	Signature        string    `json:"signature"`
+}
+
+// ComputeSignature returns the signature the report should carry.
+func (r ErasureReport) ComputeSignature() string {
+	r.Signature = ""
+	encoded, _ := json.Marshal(r)
+	mac := hmac.New(sha256.New, ErasureReportKey)
+	mac.Write(encoded)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether the report carries the signature this
+// server would give it.
+func (r ErasureReport) VerifySignature() bool {
+	return hmac.Equal([]byte(r.Signature), []byte(r.ComputeSignature()))
+}
+
+// erasureRetained lists what an erasure in mode keeps.
+func erasureRetained(mode string) []string {
+	retained := []string{
+		"audit log: the actor, client IP, user agent and request id of each change, as the record of who made it; the values of the messages' entries are redacted",
+	}
+	if mode == ErasureModeAnonymize {
+		return append([]string{
+			"messages: their content, category, tags, metadata, translations and attachments, no longer attributed to the author",
+		}, retained...)
+	}
+	return append([]string{
+		"messages with replies: tombstones with their id, parent and creation time, no longer attributed to the author",
+	}, retained...)
+}
+
+// exportedAttachment is an attachment as listed in an export, with the
+// name of its file in the export.
+type exportedAttachment struct {
+	domain.Attachment
+	File string `json:"file"`
+}
+
+// authorsServiceInterface serves requests of data subjects: the authors of
+// messages in the caller's tenant. Only the author, or an admin, may make
+// them.
+type authorsServiceInterface interface {
+	// ExportAuthor writes every message of the author, with its
+	// translations, revisions and attachments, to w in format.
+	ExportAuthor(ctx context.Context, authorId string, format string, w io.Writer) error_utils.MessageErr
+	// EraseAuthor removes the author from the tenant: their messages are
+	// detached from them or deleted, depending on mode, and their
+	// reactions and reads are removed.
+	EraseAuthor(ctx context.Context, authorId string, mode string) (*ErasureReport, error_utils.MessageErr)
+	// VerifyErasureReport reports whether report was signed by this server
+	// for the caller's tenant and is unchanged since.
+	VerifyErasureReport(ctx context.Context, report ErasureReport) bool
+}
+
+// ValidExportFormat reports whether ExportAuthor can write format.
+func ValidExportFormat(format string) bool {
+	return format == ExportFormatNDJSON || format == ExportFormatZIP
+}
+
+// ExportAuthor writes the messages, then their translations, revisions and
+// attachment listings, and then the attachment files. Revisions are the
+// audit log entries of the messages, newest first. An error after the
+// first write leaves the export cut short.
+func (s *authorsService) ExportAuthor(ctx context.Context, authorId string, format string, w io.Writer) error_utils.MessageErr {
+	if err := authorizeAuthor(ctx, authorId); err != nil {
+		return err
+	}
+	if !ValidExportFormat(format) {
+		return error_utils.NewBadRequestError(fmt.Sprintf("format must be %s or %s", ExportFormatNDJSON, ExportFormatZIP))
+	}
+	export := newExportWriter(format, w)
+	sections := []struct {
+		kind  string
+		write func(domain.Message) error
+	}{
+		{"message", func(msg domain.Message) error {
+			return export.Record(msg)
+		}},
+		{"translation", func(msg domain.Message) error {
+			translations, err := domain.TranslationRepo.ListByMessage(ctx, msg.Id)
+			if err != nil {
+				return err
+			}
+			for _, translation := range translations {
+				if err := export.Record(translation); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+		{"revision", func(msg domain.Message) error {
+			for offset := 0; ; offset += authorPageSize {
+				entries, err := domain.AuditRepo.List(ctx, domain.AuditFilter{MessageId: msg.Id, Limit: authorPageSize, Offset: offset})
+				if err != nil {
+					return err
+				}
+				for _, entry := range entries {
+					if err := export.Record(entry); err != nil {
+						return err
+					}
+				}
+				if len(entries) < authorPageSize {
+					return nil
+				}
+			}
+		}},
+		{"attachment", func(msg domain.Message) error {
+			attachments, err := domain.AttachmentRepo.ListByMessage(ctx, msg.Id)
+			if err != nil {
+				return err
+			}
+			for _, attachment := range attachments {
+				if err := export.Record(exportedAttachment{attachment, exportFileName(attachment)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	}
+	for _, section := range sections {
+		if err := export.Section(section.kind); err != nil {
+			return authorDataErr(ctx, err)
+		}
+		if err := eachAuthorMessage(ctx, authorId, section.write); err != nil {
+			return authorDataErr(ctx, err)
+		}
+	}
+	if err := eachAuthorMessage(ctx, authorId, func(msg domain.Message) error {
+		return exportFiles(ctx, export, msg.Id)
+	}); err != nil {
+		return authorDataErr(ctx, err)
+	}
+	if err := export.Close(); err != nil {
+		return authorDataErr(ctx, err)
+	}
+	return nil
+}
+
+func exportFiles(ctx context.Context, export exportWriter, msgId int64) error {
+	attachments, err := domain.AttachmentRepo.ListByMessage(ctx, msgId)
+	if err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		content, err := Blobs.Get(ctx, attachment.BlobKey)
+		if errors.Is(err, blob_utils.ErrBlobNotFound) {
+			slog.WarnContext(ctx, "attachment content is missing from export", "attachment_id", attachment.Id)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = export.File(exportFileName(attachment), content)
+		content.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportFileName(attachment domain.Attachment) string {
+	return fmt.Sprintf("attachments/%d/%d-%s", attachment.MessageId, attachment.Id, path.Base(attachment.FileName))
+}
+
+// authorDataErr passes on repository errors as they are and hides the
+// details of any other, such as a failed write to the client.
+func authorDataErr(ctx context.Context, err error) error_utils.MessageErr {
+	if messageErr, ok := err.(error_utils.MessageErr); ok {
+		return messageErr
+	}
+	slog.ErrorContext(ctx, "failed to process author data", "error", err)
+	return error_utils.NewInternalServerError("error when trying to process author data")
+}
+
+// eachAuthorMessage calls fn with every message of the author, by id. fn
+// may change the message so that it no longer belongs to the author.
+func eachAuthorMessage(ctx context.Context, authorId string, fn func(domain.Message) error) error {
+	var afterId int64
+	for {
+		messages, err := domain.MessageRepo.ListByAuthor(ctx, authorId, afterId, authorPageSize)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			if err := fn(msg); err != nil {
+				return err
+			}
+			afterId = msg.Id
+		}
+		if len(messages) < authorPageSize {
+			return nil
+		}
+	}
+}
+
+// EraseAuthor anonymizes every message of the author, or deletes it in
+// delete mode, redacts the values of its audit entries and sends an erased
+// event for each, and then removes their reactions and reads. Messages with
+// replies stay as anonymous tombstones. The rest of the audit log, being
+// tamper-evident, is kept as the record of the erasure. An erasure that
+// fails part way can be run again.
+func (s *authorsService) EraseAuthor(ctx context.Context, authorId string, mode string) (*ErasureReport, error_utils.MessageErr) {
+	if err := authorizeAuthor(ctx, authorId); err != nil {
+		return nil, err
+	}
+	if mode != ErasureModeAnonymize && mode != ErasureModeDelete {
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("mode must be %s or %s", ErasureModeAnonymize, ErasureModeDelete))
+	}
+	report := &ErasureReport{
+		AuthorId:   authorId,
+		TenantId:   tenant_utils.TenantFrom(ctx),
+		Mode:       mode,
+		RequestId:  logger_utils.RequestIDFrom(ctx),
+		MessageIds: []int64{},
+		Retained:   erasureRetained(mode),
+		StartedAt:  time.Now().UTC(),
+	}
+	err := eachAuthorMessage(ctx, authorId, func(msg domain.Message) error {
+		if mode == ErasureModeDelete && !msg.Deleted {
+			if err := domain.MessageRepo.Delete(ctx, msg.Id); err != nil {
+				return err
+			}
+			report.Deleted++
+		} else {
+			report.Anonymized++
+		}
+		// A deleted message with replies is left as a tombstone, which
+		// still names its author.
+		if err := domain.MessageRepo.Anonymize(ctx, msg.Id); err != nil && err.Status() != http.StatusNotFound {
+			return err
+		}
+		redacted, err := domain.AuditRepo.Redact(ctx, msg.Id)
+		if err != nil {
+			return err
+		}
+		report.AuditRedacted += redacted
+		report.MessageIds = append(report.MessageIds, msg.Id)
+		sendEventWithChanges(ctx, MessageEventErased, &domain.Message{Id: msg.Id, TenantId: msg.TenantId, ParentId: msg.ParentId,
+			CreatedAt: msg.CreatedAt, Tags: []string{}}, map[string]interface{}{"mode": mode})
+		return nil
+	})
+	if report.Deleted > 0 {
+		requestBlobCleanup()
+	}
+	if err != nil {
+		return nil, authorDataErr(ctx, err)
+	}
+	var eraseErr error_utils.MessageErr
+	if report.ReactionsRemoved, report.ReadsRemoved, eraseErr = domain.ReactionRepo.EraseUser(ctx, authorId); eraseErr != nil {
+		return nil, eraseErr
+	}
+	remaining, listErr := domain.MessageRepo.ListByAuthor(ctx, authorId, 0, 1)
+	if listErr != nil {
+		return nil, listErr
+	}
+	report.Verified = len(remaining) == 0
+	head, auditErr := domain.AuditRepo.List(ctx, domain.AuditFilter{Limit: 1})
+	if auditErr != nil {
+		return nil, auditErr
+	}
+	if len(head) > 0 {
+		report.AuditHash = head[0].Hash
+	}
+	report.CompletedAt = time.Now().UTC()
+	report.Signature = report.ComputeSignature()
+	slog.InfoContext(ctx, "erased author", "author_id", authorId, "mode", mode, "messages", len(report.MessageIds), "verified", report.Verified)
+	return report, nil
+}
+
+func (s *authorsService) VerifyErasureReport(ctx context.Context, report ErasureReport) bool {
+	return report.TenantId == tenant_utils.TenantFrom(ctx) && report.VerifySignature()
+}
+
+// authorizeAuthor lets only the author, or an admin, act on the data of
+// the author. Calls without a principal come from the service itself and
+// are trusted.
+func authorizeAuthor(ctx context.Context, authorId string) error_utils.MessageErr {
+	if strings.TrimSpace(authorId) == "" {
+		return error_utils.NewBadRequestError("author id must not be empty")
+	}
+	principal := auth_utils.PrincipalFrom(ctx)
+	if principal == nil || principal.IsAdmin() || principal.Subject == authorId {
+		return nil
+	}
+	return error_utils.NewForbiddenError("you are not allowed to act for this author")
+}
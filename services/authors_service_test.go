@@ -0,0 +1,202 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+// mockAuthorMessages serves the messages as those of their authors, and
+// lets Anonymize and Delete change them the way the repository would.
+func mockAuthorMessages(messages ...domain.Message) map[int64]*domain.Message {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	stored := make(map[int64]*domain.Message, len(messages))
+	for i := range messages {
+		stored[messages[i].Id] = &messages[i]
+	}
+	listByAuthorDomain = func(authorId string, afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
+		found := []domain.Message{}
+		for _, msg := range messages {
+			if current, ok := stored[msg.Id]; ok && current.AuthorId == authorId && msg.Id > afterId && len(found) < limit {
+				found = append(found, *current)
+			}
+		}
+		return found, nil
+	}
+	deleteMessageDomain = func(messageId int64) error_utils.MessageErr {
+		delete(stored, messageId)
+		return nil
+	}
+	anonymizeDomain = func(messageId int64) error_utils.MessageErr {
+		msg, ok := stored[messageId]
+		if !ok {
+			return error_utils.NewNotFoundError("no record matching given id")
+		}
+		msg.AuthorId = ""
+		return nil
+	}
+	domain.ReactionRepo = newReactionRepoMock()
+	domain.AuditRepo = &auditRepoMock{entries: auditChain(2)}
+	domain.TranslationRepo = &translationRepoMock{}
+	domain.AttachmentRepo = &attachmentRepoMock{}
+	return stored
+}
+
+func TestAuthorsService_ExportAuthor_NDJSON(t *testing.T) {
+	mockAuthorMessages(domain.Message{Id: 4, TenantId: "default", AuthorId: "user-1", Title: "title", Body: "body"})
+	blobs := newMemoryBlobs()
+	Blobs = blobs
+	blobs.blobs["default/4/abc"] = []byte("png bytes")
+	domain.AttachmentRepo = &attachmentRepoMock{created: []domain.Attachment{
+		{Id: 2, MessageId: 4, FileName: "cat.png", ContentType: "image/png", BlobKey: "default/4/abc"}}}
+	domain.TranslationRepo = &translationRepoMock{translations: []domain.Translation{{MessageId: 4, Locale: "de", Title: "Titel", Body: "Text"}}}
+
+	var out bytes.Buffer
+	err := AuthorsService.ExportAuthor(asPrincipal("user-1"), "user-1", ExportFormatNDJSON, &out)
+
+	assert.Nil(t, err)
+	var types []string
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		types = append(types, line["type"].(string))
+		lines = append(lines, line)
+	}
+	assert.Equal(t, []string{"message", "translation", "revision", "revision", "attachment", "file"}, types)
+	assert.Equal(t, "attachments/4/2-cat.png", lines[4]["data"].(map[string]interface{})["file"])
+	assert.Equal(t, "attachments/4/2-cat.png", lines[5]["name"])
+	content, decodeErr := base64.StdEncoding.DecodeString(lines[5]["content_base64"].(string))
+	assert.NoError(t, decodeErr)
+	assert.Equal(t, "png bytes", string(content))
+}
+
+func TestAuthorsService_ExportAuthor_ZIP(t *testing.T) {
+	mockAuthorMessages(domain.Message{Id: 4, AuthorId: "user-1", Title: "title", Body: "body"},
+		domain.Message{Id: 5, AuthorId: "user-2", Title: "other", Body: "body"})
+	Blobs = newMemoryBlobs()
+
+	var out bytes.Buffer
+	err := AuthorsService.ExportAuthor(context.Background(), "user-1", ExportFormatZIP, &out)
+
+	assert.Nil(t, err)
+	archive, zipErr := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	assert.NoError(t, zipErr)
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{"messages.ndjson", "translations.ndjson", "revisions.ndjson", "attachments.ndjson"}, names)
+	messages, _ := archive.File[0].Open()
+	data, _ := io.ReadAll(messages)
+	var msg domain.Message
+	assert.NoError(t, json.Unmarshal(bytes.TrimSpace(data), &msg))
+	assert.EqualValues(t, 4, msg.Id)
+}
+
+func TestAuthorsService_ExportAuthor_OtherAuthor(t *testing.T) {
+	mockAuthorMessages()
+
+	err := AuthorsService.ExportAuthor(asPrincipal("user-2"), "user-1", ExportFormatNDJSON, io.Discard)
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusForbidden, err.Status())
+}
+
+func TestAuthorsService_EraseAuthor_Anonymize(t *testing.T) {
+	stored := mockAuthorMessages(domain.Message{Id: 4, AuthorId: "user-1"}, domain.Message{Id: 5, AuthorId: "user-2"},
+		domain.Message{Id: 6, AuthorId: "user-1", Title: "secret"})
+	reactions := newReactionRepoMock()
+	reactions.reactions["user-1/👍"] = true
+	reactions.reactions["user-2/👍"] = true
+	domain.ReactionRepo = reactions
+
+	report, err := AuthorsService.EraseAuthor(asPrincipal("user-1"), "user-1", ErasureModeAnonymize)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{4, 6}, report.MessageIds)
+	assert.Equal(t, 2, report.Anonymized)
+	assert.Equal(t, 1, report.ReactionsRemoved)
+	assert.True(t, report.Verified)
+	assert.Equal(t, auditChain(2)[1].Hash, report.AuditHash)
+	assert.True(t, report.VerifySignature())
+	assert.Equal(t, 0, report.AuditRedacted)
+	assert.Equal(t, erasureRetained(ErasureModeAnonymize), report.Retained)
+	assert.Equal(t, "", stored[6].AuthorId)
+	assert.Equal(t, "user-2", stored[5].AuthorId)
+	assert.Equal(t, 2, len(publishedMessages))
+	event := publishedEvent(t, 1)
+	assert.Equal(t, MessageEventErased, event["event"])
+	assert.Equal(t, map[string]interface{}{"mode": ErasureModeAnonymize}, event["changes"])
+	assert.Equal(t, "", event["data"].(map[string]interface{})["title"])
+}
+
+func TestAuthorsService_EraseAuthor_DeleteKeepsAnonymousTombstones(t *testing.T) {
+	stored := mockAuthorMessages(domain.Message{Id: 4, AuthorId: "user-1"}, domain.Message{Id: 6, AuthorId: "user-1", Deleted: true})
+	audit := &auditRepoMock{entries: []domain.AuditEntry{{Id: 1, MessageId: 4}, {Id: 2, MessageId: 4}, {Id: 3, MessageId: 6}}}
+	domain.AuditRepo = audit
+
+	report, err := AuthorsService.EraseAuthor(context.Background(), "user-1", ErasureModeDelete)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Deleted)
+	assert.Equal(t, 1, report.Anonymized)
+	assert.NotContains(t, stored, int64(4))
+	assert.Equal(t, "", stored[6].AuthorId)
+	assert.True(t, report.Verified)
+	assert.Equal(t, []int64{4, 6}, audit.redacted)
+	assert.Equal(t, 3, report.AuditRedacted)
+	assert.Contains(t, report.Retained[0], "tombstones")
+}
+
+func TestAuthorsService_EraseAuthor_UnknownMode(t *testing.T) {
+	mockAuthorMessages()
+
+	_, err := AuthorsService.EraseAuthor(context.Background(), "user-1", "shred")
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, err.Status())
+}
+
+func TestErasureReport_Signature_DetectsChanges(t *testing.T) {
+	ErasureReportKey = []byte("server key")
+	report := ErasureReport{AuthorId: "user-1", Mode: ErasureModeDelete, MessageIds: []int64{4}, CompletedAt: time.Now()}
+	report.Signature = report.ComputeSignature()
+	assert.True(t, report.VerifySignature())
+
+	changed := report
+	changed.MessageIds = []int64{}
+	assert.False(t, changed.VerifySignature())
+	// Without the key, a changed report cannot be given a valid signature.
+	unkeyed := sha256.Sum256([]byte("forged"))
+	changed.Signature = hex.EncodeToString(unkeyed[:])
+	assert.False(t, changed.VerifySignature())
+	ErasureReportKey = []byte("other key")
+	assert.False(t, report.VerifySignature())
+}
+
+func TestAuthorsService_VerifyErasureReport_OtherTenant(t *testing.T) {
+	ErasureReportKey = []byte("server key")
+	report := ErasureReport{AuthorId: "user-1", TenantId: "acme", Mode: ErasureModeDelete, MessageIds: []int64{4}}
+	report.Signature = report.ComputeSignature()
+
+	assert.False(t, AuthorsService.VerifyErasureReport(context.Background(), report))
+	assert.True(t, AuthorsService.VerifyErasureReport(tenant_utils.WithTenant(context.Background(), "acme"), report))
+}
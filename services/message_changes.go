@@ -0,0 +1,57 @@
+package services
+
+import (
+	"testing-project/domain"
+)
+
+type fieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type tagsChange struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+// messageChanges describes how an update changed a message, keyed by field.
+// Tags are reported as the tags added and removed.
+func messageChanges(before *domain.Message, after *domain.Message) map[string]interface{} {
+	changes := make(map[string]interface{})
+	for field, values := range map[string][2]string{
+		"title":    {before.Title, after.Title},
+		"body":     {before.Body, after.Body},
+		"format":   {before.Format, after.Format},
+		"category": {before.Category, after.Category},
+		"metadata": {string(before.Metadata), string(after.Metadata)},
+	} {
+		if values[0] != values[1] {
+			changes[field] = fieldChange{From: values[0], To: values[1]}
+		}
+	}
+	added, removed := diffTags(before.Tags, after.Tags)
+	if len(added) > 0 || len(removed) > 0 {
+		changes["tags"] = tagsChange{Added: added, Removed: removed}
+	}
+	return changes
+}
+
+func diffTags(before []string, after []string) (added []string, removed []string) {
+	had := make(map[string]bool, len(before))
+	for _, tag := range before {
+		had[tag] = true
+	}
+	has := make(map[string]bool, len(after))
+	for _, tag := range after {
+		has[tag] = true
+		if !had[tag] {
+			added = append(added, tag)
+		}
+	}
+	for _, tag := range before {
+		if !has[tag] {
+			removed = append(removed, tag)
+		}
+	}
+	return added, removed
+}
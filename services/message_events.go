@@ -0,0 +1,193 @@
+package services
+
+import (
+	"sync"
+	"testing-project/domain"
+)
+
+const (
+	defaultReplaySize    = 256
+	subscriberBufferSize = 64
+	MessageEventCreated  = "created"
+	MessageEventUpdated  = "updated"
+	MessageEventDeleted  = "deleted"
+
+	// MessageEventPublished is sent when a draft or scheduled message goes
+	// live, whether explicitly or through the publish scheduler. No event
+	// is sent about such a message before then.
+	MessageEventPublished = "published"
+
+	// MessageEventExpired is sent by the reaper when it removes or archives
+	// a message past its expires_at.
+	MessageEventExpired = "expired"
+
+	// MessageEventReacted is sent when a user adds or removes a reaction.
+	MessageEventReacted = "reacted"
+
+	// MessageEventFlagged is sent instead of created or updated when the
+	// moderation checks hold a message back for review. Like every event
+	// about a held or rejected message it leaves out the content, so that
+	// consumers never see unreviewed content.
+	MessageEventFlagged = "flagged"
+
+	// MessageEventApproved and MessageEventRejected are sent when a
+	// moderator decides on a message held for review.
+	MessageEventApproved = "approved"
+	MessageEventRejected = "rejected"
+
+	// MessageEventErased is sent for every message of an author whose data
+	// was erased. It only carries the id of the message, so that consumers
+	// can drop their copies.
+	MessageEventErased = "erased"
+)
+
+var (
+	MessageEvents messageEventsInterface = NewMessageEventsHub(defaultReplaySize)
+
+	// messageEventTypes lists every event type sendEvent may emit.
+	messageEventTypes = map[string]bool{
+		MessageEventCreated:   true,
+		MessageEventUpdated:   true,
+		MessageEventDeleted:   true,
+		MessageEventPublished: true,
+		MessageEventExpired:   true,
+		MessageEventReacted:   true,
+		MessageEventFlagged:   true,
+		MessageEventApproved:  true,
+		MessageEventRejected:  true,
+		MessageEventErased:    true,
+	}
+)
+
+// MessageEvent is a single change notification as seen by in-process
+// subscribers such as the SSE stream.
+type MessageEvent struct {
+	Id      int64
+	Type    string
+	Message domain.Message
+}
+
+type messageEventsInterface interface {
+	Publish(string, *domain.Message) MessageEvent
+	Subscribe(tenantId string, lastEventId int64, types []string) *Subscription
+}
+
+// Subscription delivers events published after it was created, preceded by
+// the buffered events newer than the requested Last-Event-ID.
+//
+// Events is closed when the subscriber falls too far behind or Close is
+// called; the client is expected to reconnect with the last id it saw.
+type Subscription struct {
+	Replay []MessageEvent
+	Events <-chan MessageEvent
+
+	events   chan MessageEvent
+	tenantId string
+	types    map[string]bool
+	hub      *messageEventsHub
+	once     sync.Once
+}
+
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+// wants reports whether event matches the subscription. An empty tenantId
+// subscribes to every tenant.
+func (s *Subscription) wants(event MessageEvent) bool {
+	if s.tenantId != "" && event.Message.TenantId != s.tenantId {
+		return false
+	}
+	return len(s.types) == 0 || s.types[event.Type]
+}
+
+type messageEventsHub struct {
+	mu          sync.Mutex
+	lastId      int64
+	buffer      []MessageEvent
+	next        int
+	full        bool
+	subscribers map[*Subscription]struct{}
+}
+
+// NewMessageEventsHub returns a hub that keeps the last replaySize events
+// in memory for clients resuming with Last-Event-ID.
+func NewMessageEventsHub(replaySize int) messageEventsInterface {
+	if replaySize <= 0 {
+		replaySize = defaultReplaySize
+	}
+	return &messageEventsHub{
+		buffer:      make([]MessageEvent, replaySize),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+func (h *messageEventsHub) Publish(eventType string, message *domain.Message) MessageEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.lastId++
+	event := MessageEvent{Id: h.lastId, Type: eventType, Message: *message}
+	h.buffer[h.next] = event
+	h.next = (h.next + 1) % len(h.buffer)
+	if h.next == 0 {
+		h.full = true
+	}
+
+	for sub := range h.subscribers {
+		if !sub.wants(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			h.drop(sub)
+		}
+	}
+	return event
+}
+
+func (h *messageEventsHub) Subscribe(tenantId string, lastEventId int64, types []string) *Subscription {
+	sub := &Subscription{
+		events:   make(chan MessageEvent, subscriberBufferSize),
+		tenantId: tenantId,
+		types:    make(map[string]bool, len(types)),
+		hub:      h,
+	}
+	sub.Events = sub.events
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lastEventId > 0 {
+		for _, event := range h.ordered() {
+			if event.Id > lastEventId && sub.wants(event) {
+				sub.Replay = append(sub.Replay, event)
+			}
+		}
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// ordered returns the buffered events oldest first. Callers hold h.mu.
+func (h *messageEventsHub) ordered() []MessageEvent {
+	if !h.full {
+		return h.buffer[:h.next]
+	}
+	return append(append([]MessageEvent{}, h.buffer[h.next:]...), h.buffer[:h.next]...)
+}
+
+func (h *messageEventsHub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(sub)
+}
+
+// drop unregisters sub and closes its channel. Callers hold h.mu.
+func (h *messageEventsHub) drop(sub *Subscription) {
+	delete(h.subscribers, sub)
+	sub.once.Do(func() { close(sub.events) })
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+)
+
+func TestMessageEventsHub_DeliversToSubscribers(t *testing.T) {
+	hub := NewMessageEventsHub(4)
+	sub := hub.Subscribe("", 0, nil)
+	defer sub.Close()
+
+	hub.Publish(MessageEventCreated, &domain.Message{Id: 1, Title: "the title"})
+
+	event := <-sub.Events
+	assert.EqualValues(t, 1, event.Id)
+	assert.EqualValues(t, MessageEventCreated, event.Type)
+	assert.EqualValues(t, "the title", event.Message.Title)
+	assert.Empty(t, sub.Replay)
+}
+
+func TestMessageEventsHub_FiltersByType(t *testing.T) {
+	hub := NewMessageEventsHub(4)
+	sub := hub.Subscribe("", 0, []string{MessageEventDeleted})
+	defer sub.Close()
+
+	hub.Publish(MessageEventCreated, &domain.Message{Id: 1})
+	hub.Publish(MessageEventDeleted, &domain.Message{Id: 1})
+
+	event := <-sub.Events
+	assert.EqualValues(t, 2, event.Id)
+	assert.EqualValues(t, MessageEventDeleted, event.Type)
+	assert.Equal(t, 0, len(sub.Events))
+}
+
+func TestMessageEventsHub_ReplaysAfterLastEventId(t *testing.T) {
+	hub := NewMessageEventsHub(3)
+	for i := int64(1); i <= 5; i++ {
+		hub.Publish(MessageEventUpdated, &domain.Message{Id: i})
+	}
+
+	sub := hub.Subscribe("", 3, nil)
+	defer sub.Close()
+
+	assert.Equal(t, 2, len(sub.Replay))
+	assert.EqualValues(t, 4, sub.Replay[0].Id)
+	assert.EqualValues(t, 5, sub.Replay[1].Id)
+}
+
+func TestMessageEventsHub_ReplayIsBounded(t *testing.T) {
+	hub := NewMessageEventsHub(3)
+	for i := int64(1); i <= 5; i++ {
+		hub.Publish(MessageEventUpdated, &domain.Message{Id: i})
+	}
+
+	sub := hub.Subscribe("", 1, nil)
+	defer sub.Close()
+
+	assert.Equal(t, 3, len(sub.Replay))
+	assert.EqualValues(t, 3, sub.Replay[0].Id)
+	assert.EqualValues(t, 5, sub.Replay[2].Id)
+}
+
+func TestMessageEventsHub_DropsSlowSubscriber(t *testing.T) {
+	hub := NewMessageEventsHub(4)
+	sub := hub.Subscribe("", 0, nil)
+
+	for i := 0; i <= subscriberBufferSize; i++ {
+		hub.Publish(MessageEventCreated, &domain.Message{Id: int64(i)})
+	}
+
+	received := 0
+	for range sub.Events {
+		received++
+	}
+	assert.Equal(t, subscriberBufferSize, received)
+	sub.Close()
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// messageTransitions is the lifecycle state machine: the statuses a message
+// may move to from each status. Messages held for review only leave it
+// through a moderator's decision, and rejected ones through an edit that
+// puts them up for review again.
+var messageTransitions = map[string][]string{
+	domain.MessageStatusDraft:         {domain.MessageStatusScheduled, domain.MessageStatusPublished},
+	domain.MessageStatusScheduled:     {domain.MessageStatusDraft, domain.MessageStatusPublished},
+	domain.MessageStatusPublished:     {domain.MessageStatusArchived},
+	domain.MessageStatusArchived:      {domain.MessageStatusDraft},
+	domain.MessageStatusPendingReview: nil,
+	domain.MessageStatusRejected:      nil,
+}
+
+func isMessageStatus(status string) bool {
+	_, ok := messageTransitions[status]
+	return ok
+}
+
+func canTransition(from string, to string) bool {
+	for _, status := range messageTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// initialStatus validates the status a message is created with. Messages
+// created without one are published right away, as they always were.
+func initialStatus(message *domain.Message, now time.Time) error_utils.MessageErr {
+	switch message.Status {
+	case "", domain.MessageStatusPublished:
+		message.Status = domain.MessageStatusPublished
+		message.PublishAt = &now
+	case domain.MessageStatusDraft:
+		message.PublishAt = nil
+	case domain.MessageStatusScheduled:
+		return validatePublishAt(message.PublishAt, now)
+	default:
+		return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("a message cannot be created as %q", message.Status))
+	}
+	return nil
+}
+
+func validatePublishAt(publishAt *time.Time, now time.Time) error_utils.MessageErr {
+	if publishAt == nil || !publishAt.After(now) {
+		return error_utils.NewUnprocessibleEntityError("publish_at must be in the future")
+	}
+	return nil
+}
+
+// TransitionMessage moves a message to status. publishAt is required when
+// scheduling and ignored otherwise. A message published or scheduled is
+// moderated first, and held for review instead when the checks flag it.
+func (m *messagesService) TransitionMessage(ctx context.Context, msgId int64, status string, publishAt *time.Time) (*domain.Message, error_utils.MessageErr) {
+	current, err := domain.MessageRepo.Get(ctx, msgId)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeWrite(ctx, current); err != nil {
+		return nil, err
+	}
+	if !canTransition(current.Status, status) {
+		return nil, error_utils.NewConflictError(fmt.Sprintf("cannot move a message from %s to %s", current.Status, status))
+	}
+
+	now := time.Now()
+	from := current.Status
+	current.Status = status
+	switch status {
+	case domain.MessageStatusScheduled:
+		if err := validatePublishAt(publishAt, now); err != nil {
+			return nil, err
+		}
+		current.PublishAt = publishAt
+	case domain.MessageStatusPublished:
+		current.PublishAt = &now
+	case domain.MessageStatusDraft:
+		current.PublishAt = nil
+	}
+	held := moderate(current, now)
+	if err := domain.MessageRepo.UpdateStatus(ctx, current, from); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case held:
+		sendEventWithChanges(ctx, MessageEventFlagged, current, map[string]interface{}{"moderation": current.Moderation})
+	case status == domain.MessageStatusPublished:
+		sendEvent(ctx, MessageEventPublished, current)
+	default:
+		sendEvent(ctx, MessageEventUpdated, current)
+	}
+	return current, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+	"time"
+)
+
+func mockMessageWithStatus(status string) *[]string {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: messageId, Title: "the title", Body: "the body", Status: status}, nil
+	}
+	var transitions []string
+	updateStatusDomain = func(msg *domain.Message, fromStatus string) error_utils.MessageErr {
+		transitions = append(transitions, fromStatus+"->"+msg.Status)
+		return nil
+	}
+	return &transitions
+}
+
+func publishedEventTypes(t *testing.T) []string {
+	var types []string
+	for _, raw := range publishedMessages {
+		var event map[string]interface{}
+		assert.Nil(t, json.Unmarshal([]byte(raw), &event))
+		types = append(types, event["event"].(string))
+	}
+	return types
+}
+
+func TestMessagesService_CreateMessage_DefaultsToPublished(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body"})
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, domain.MessageStatusPublished, msg.Status)
+	assert.NotNil(t, msg.PublishAt)
+}
+
+func TestMessagesService_CreateMessage_ScheduledInThePast(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	past := time.Now().Add(-time.Hour)
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{
+		Title: "the title", Body: "the body", Status: domain.MessageStatusScheduled, PublishAt: &past,
+	})
+
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.EqualValues(t, "publish_at must be in the future", err.Message())
+}
+
+func TestMessagesService_CreateMessage_Archived(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{
+		Title: "the title", Body: "the body", Status: domain.MessageStatusArchived,
+	})
+
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+}
+
+func TestMessagesService_TransitionMessage_Publish(t *testing.T) {
+	transitions := mockMessageWithStatus(domain.MessageStatusDraft)
+
+	msg, err := MessagesService.TransitionMessage(context.Background(), 1, domain.MessageStatusPublished, nil)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, domain.MessageStatusPublished, msg.Status)
+	assert.NotNil(t, msg.PublishAt)
+	assert.EqualValues(t, []string{"draft->published"}, *transitions)
+	assert.EqualValues(t, []string{MessageEventPublished}, publishedEventTypes(t))
+}
+
+func TestMessagesService_TransitionMessage_Schedule(t *testing.T) {
+	transitions := mockMessageWithStatus(domain.MessageStatusDraft)
+	publishAt := time.Now().Add(time.Hour)
+
+	msg, err := MessagesService.TransitionMessage(context.Background(), 1, domain.MessageStatusScheduled, &publishAt)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, domain.MessageStatusScheduled, msg.Status)
+	assert.EqualValues(t, publishAt, *msg.PublishAt)
+	assert.EqualValues(t, []string{"draft->scheduled"}, *transitions)
+	assert.Empty(t, publishedEventTypes(t))
+}
+
+func TestMessagesService_TransitionMessage_NotAllowed(t *testing.T) {
+	transitions := mockMessageWithStatus(domain.MessageStatusArchived)
+
+	msg, err := MessagesService.TransitionMessage(context.Background(), 1, domain.MessageStatusPublished, nil)
+
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusConflict, err.Status())
+	assert.EqualValues(t, "cannot move a message from archived to published", err.Message())
+	assert.Empty(t, *transitions)
+	assert.Empty(t, publishedMessages)
+}
+
+func TestMessagesService_TransitionMessage_NotOwner(t *testing.T) {
+	mockMessageWithStatus(domain.MessageStatusDraft)
+
+	msg, err := MessagesService.TransitionMessage(asPrincipal("user-2"), 1, domain.MessageStatusPublished, nil)
+
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusForbidden, err.Status())
+}
+
+func TestPublishDueMessages(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	due := time.Now().Add(-time.Minute)
+	listDueDomain = func(now time.Time, limit int) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{
+			{Id: 1, TenantId: "acme", Status: domain.MessageStatusScheduled, PublishAt: &due},
+			{Id: 2, TenantId: "globex", Status: domain.MessageStatusScheduled, PublishAt: &due},
+		}, nil
+	}
+	updateStatusDomain = func(msg *domain.Message, fromStatus string) error_utils.MessageErr {
+		if msg.Id == 2 {
+			return error_utils.NewConflictError("message status was changed by another request")
+		}
+		assert.EqualValues(t, domain.MessageStatusScheduled, fromStatus)
+		assert.EqualValues(t, domain.MessageStatusPublished, msg.Status)
+		return nil
+	}
+
+	published := publishDueMessages(context.Background(), time.Now())
+
+	assert.Equal(t, 1, published)
+	assert.EqualValues(t, []string{MessageEventPublished}, publishedEventTypes(t))
+}
+
+func TestMessagesService_CreateMessage_DraftSendsNoEvent(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		msg.Id = 1
+		return msg, nil
+	}
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body", Status: domain.MessageStatusDraft})
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, domain.MessageStatusDraft, msg.Status)
+	assert.Empty(t, publishedEventTypes(t))
+}
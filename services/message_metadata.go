@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/xeipuuv/gojsonschema"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+const maxReportedSchemaErrors = 5
+
+var (
+	// MaxMetadataSize is the largest metadata object accepted, in bytes of
+	// compact JSON.
+	MaxMetadataSize = 4096
+
+	// MetadataSchemas holds a JSON Schema per category. Metadata of messages
+	// in a category with a schema must satisfy it.
+	MetadataSchemas = map[string]*gojsonschema.Schema{}
+
+	// FilterableMetadataKeys are the metadata keys messages may be listed by.
+	// Each should have an indexed generated column, see message_schema.sql.
+	FilterableMetadataKeys = map[string]bool{"priority": true}
+)
+
+// LoadMetadataSchemas reads one JSON Schema per category from dir, where
+// the file news.json holds the schema for the category news.
+func LoadMetadataSchemas(dir string) (map[string]*gojsonschema.Schema, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]*gojsonschema.Schema, len(paths))
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(source))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		category := strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".json"))
+		schemas[category] = schema
+	}
+	return schemas, nil
+}
+
+// checkMetadata compacts the metadata of message and checks it against the
+// size limit and the schema of its category. It runs after Validate, so the
+// category is already normalized.
+func checkMetadata(message *domain.Message) error_utils.MessageErr {
+	var fields map[string]interface{}
+	if trimmed := bytes.TrimSpace(message.Metadata); len(trimmed) == 0 || bytes.Equal(trimmed, []byte("null")) {
+		message.Metadata = nil
+	} else {
+		if err := json.Unmarshal(trimmed, &fields); err != nil || fields == nil {
+			return error_utils.NewUnprocessibleEntityError("metadata must be a JSON object")
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, trimmed); err != nil {
+			return error_utils.NewUnprocessibleEntityError("metadata must be a JSON object")
+		}
+		if compact.Len() > MaxMetadataSize {
+			return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("metadata must be at most %d bytes", MaxMetadataSize))
+		}
+		message.Metadata = compact.Bytes()
+	}
+
+	schema, ok := MetadataSchemas[message.Category]
+	if !ok {
+		return nil
+	}
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	result, err := schema.Validate(gojsonschema.NewGoLoader(fields))
+	if err != nil {
+		return error_utils.NewInternalServerError(fmt.Sprintf("error when trying to validate metadata: %s", err.Error()))
+	}
+	if result.Valid() {
+		return nil
+	}
+	var problems []string
+	for i, problem := range result.Errors() {
+		if i == maxReportedSchemaErrors {
+			break
+		}
+		problems = append(problems, problem.String())
+	}
+	return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("metadata does not match the schema for category %s: %s", message.Category, strings.Join(problems, "; ")))
+}
+
+// checkMetadataFilter only lets listings filter on FilterableMetadataKeys.
+func checkMetadataFilter(filter map[string]string) error_utils.MessageErr {
+	for key := range filter {
+		if !FilterableMetadataKeys[key] {
+			return error_utils.NewBadRequestError(fmt.Sprintf("metadata key %q is not filterable", key))
+		}
+	}
+	return nil
+}
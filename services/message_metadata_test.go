@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+)
+
+const newsSchema = `{
+	"type": "object",
+	"required": ["priority"],
+	"properties": {"priority": {"enum": ["low", "high"]}}
+}`
+
+func withMetadataSchemas(t *testing.T, schemas map[string]string) {
+	dir := t.TempDir()
+	for category, schema := range schemas {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, category+".json"), []byte(schema), 0o644))
+	}
+	loaded, err := LoadMetadataSchemas(dir)
+	assert.NoError(t, err)
+	previous := MetadataSchemas
+	MetadataSchemas = loaded
+	t.Cleanup(func() { MetadataSchemas = previous })
+}
+
+func TestMessagesService_CreateMessage_CompactsMetadata(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	var saved *domain.Message
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		saved = msg
+		return msg, nil
+	}
+
+	_, err := MessagesService.CreateMessage(context.Background(), &domain.Message{
+		Title: "title", Body: "body", Metadata: json.RawMessage(`{ "priority": "high",  "owner": {"team": "ops"} }`),
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"priority":"high","owner":{"team":"ops"}}`, string(saved.Metadata))
+}
+
+func TestMessagesService_CreateMessage_RejectsMetadata(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	previous := MaxMetadataSize
+	MaxMetadataSize = 32
+	defer func() { MaxMetadataSize = previous }()
+
+	for _, metadata := range []string{`[1, 2]`, `"text"`, `{"note": "` + strings.Repeat("x", 40) + `"}`} {
+		_, err := MessagesService.CreateMessage(context.Background(), &domain.Message{
+			Title: "title", Body: "body", Metadata: json.RawMessage(metadata),
+		})
+		assert.NotNil(t, err, metadata)
+		assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status(), metadata)
+	}
+}
+
+func TestMessagesService_CreateMessage_ChecksCategorySchema(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	withMetadataSchemas(t, map[string]string{"news": newsSchema})
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+
+	_, err := MessagesService.CreateMessage(context.Background(), &domain.Message{
+		Title: "title", Body: "body", Category: "News", Metadata: json.RawMessage(`{"priority": "urgent"}`),
+	})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.Contains(t, err.Message(), "schema for category news")
+
+	_, err = MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "title", Body: "body", Category: "news"})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Message(), "priority is required")
+
+	_, err = MessagesService.CreateMessage(context.Background(), &domain.Message{
+		Title: "title", Body: "body", Category: "news", Metadata: json.RawMessage(`{"priority": "low"}`),
+	})
+	assert.Nil(t, err)
+
+	_, err = MessagesService.CreateMessage(context.Background(), &domain.Message{
+		Title: "title", Body: "body", Category: "sports", Metadata: json.RawMessage(`{"priority": "urgent"}`),
+	})
+	assert.Nil(t, err)
+}
+
+func TestMessagesService_ListMessages_OnlyFilterableMetadataKeys(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	listMessagesDomain = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{}, nil
+	}
+
+	_, err := MessagesService.ListMessages(context.Background(), domain.MessageFilter{Metadata: map[string]string{"priority": "high"}})
+	assert.Nil(t, err)
+
+	_, err = MessagesService.ListMessages(context.Background(), domain.MessageFilter{Metadata: map[string]string{"owner": "ops"}})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, err.Status())
+}
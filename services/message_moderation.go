@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"testing-project/domain"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/moderation_utils"
+	"time"
+)
+
+var (
+	ModerationService moderationServiceInterface = &moderationService{}
+
+	// ModerationChecks run on every message created or edited. A message any
+	// of them flags is held for review instead of going live. There are no
+	// checks unless configured, and then nothing is held.
+	ModerationChecks []moderation_utils.Check
+)
+
+type moderationService struct{}
+
+// moderationServiceInterface lets moderators work through the messages held
+// for review.
+type moderationServiceInterface interface {
+	ListQueue(ctx context.Context, limit int, offset int) ([]domain.Message, error_utils.MessageErr)
+	GetModeration(ctx context.Context, msgId int64) (*domain.Moderation, error_utils.MessageErr)
+	ApproveMessage(ctx context.Context, msgId int64, reason string) (*domain.Message, error_utils.MessageErr)
+	RejectMessage(ctx context.Context, msgId int64, reason string) (*domain.Message, error_utils.MessageErr)
+}
+
+// moderate runs ModerationChecks on message and holds it for review when
+// any of them flags it. A message put back up for review after being
+// rejected is held whatever the checks say. A held message keeps a
+// publish_at in the future, so approving it schedules it again. Drafts
+// and archived messages are not live, so they are not held: a draft is
+// checked when it is published or scheduled instead.
+func moderate(message *domain.Message, now time.Time) bool {
+	if message.Status == domain.MessageStatusDraft || message.Status == domain.MessageStatusArchived {
+		return false
+	}
+	flags := moderation_utils.Run(ModerationChecks, message.Title, message.Body)
+	if len(flags) == 0 && message.Status != domain.MessageStatusRejected {
+		return false
+	}
+	if flags == nil {
+		flags = []moderation_utils.Flag{{Check: "resubmission", Reason: "rejected message was edited"}}
+	}
+	message.Status = domain.MessageStatusPendingReview
+	if message.PublishAt != nil && !message.PublishAt.After(now) {
+		message.PublishAt = nil
+	}
+	message.Moderation = &domain.Moderation{Flags: flags, FlaggedAt: now}
+	return true
+}
+
+// ListQueue lists the messages held for review, newest first.
+func (s *moderationService) ListQueue(ctx context.Context, limit int, offset int) ([]domain.Message, error_utils.MessageErr) {
+	return MessagesService.ListMessages(ctx, domain.MessageFilter{Status: domain.MessageStatusPendingReview, Limit: limit, Offset: offset})
+}
+
+// GetModeration returns why a message was held and, once decided, the
+// moderator's decision.
+func (s *moderationService) GetModeration(ctx context.Context, msgId int64) (*domain.Moderation, error_utils.MessageErr) {
+	return domain.ModerationRepo.Get(ctx, msgId)
+}
+
+// ApproveMessage lets a held message go live: it is published, or
+// scheduled again when its publish_at is still ahead.
+func (s *moderationService) ApproveMessage(ctx context.Context, msgId int64, reason string) (*domain.Message, error_utils.MessageErr) {
+	return s.decide(ctx, msgId, &domain.Moderation{Decision: domain.ModerationApproved, Reason: reason})
+}
+
+// RejectMessage keeps a held message from going live for the given
+// reason. Its author may edit it to put it up for review again.
+func (s *moderationService) RejectMessage(ctx context.Context, msgId int64, reason string) (*domain.Message, error_utils.MessageErr) {
+	return s.decide(ctx, msgId, &domain.Moderation{Decision: domain.ModerationRejected, Reason: reason})
+}
+
+func (s *moderationService) decide(ctx context.Context, msgId int64, moderation *domain.Moderation) (*domain.Message, error_utils.MessageErr) {
+	if err := moderation.ValidateDecision(); err != nil {
+		return nil, err
+	}
+	message, err := domain.MessageRepo.Get(ctx, msgId)
+	if err != nil {
+		return nil, err
+	}
+	if message.Status != domain.MessageStatusPendingReview {
+		return nil, error_utils.NewConflictError("message is not pending review")
+	}
+
+	now := time.Now()
+	moderation.MessageId = msgId
+	moderation.DecidedAt = &now
+	if principal := auth_utils.PrincipalFrom(ctx); principal != nil {
+		moderation.ModeratorId = principal.Subject
+	}
+	eventType := MessageEventApproved
+	switch {
+	case moderation.Decision == domain.ModerationRejected:
+		message.Status = domain.MessageStatusRejected
+		message.PublishAt = nil
+		eventType = MessageEventRejected
+	case message.PublishAt != nil && message.PublishAt.After(now):
+		message.Status = domain.MessageStatusScheduled
+	default:
+		message.Status = domain.MessageStatusPublished
+		message.PublishAt = &now
+	}
+	if err := domain.ModerationRepo.Decide(ctx, message, moderation); err != nil {
+		return nil, err
+	}
+
+	sendEventWithChanges(ctx, eventType, message, map[string]interface{}{"moderation": moderation})
+	return message, nil
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/moderation_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+	"time"
+)
+
+type moderationRepoMock struct {
+	decided *domain.Moderation
+	status  string
+}
+
+func (r *moderationRepoMock) Get(ctx context.Context, messageId int64) (*domain.Moderation, error_utils.MessageErr) {
+	return &domain.Moderation{MessageId: messageId}, nil
+}
+func (r *moderationRepoMock) Decide(ctx context.Context, msg *domain.Message, moderation *domain.Moderation) error_utils.MessageErr {
+	r.decided = moderation
+	r.status = msg.Status
+	return nil
+}
+
+func withWordFilter(t *testing.T, words ...string) {
+	filter, err := moderation_utils.NewWordFilter(words)
+	assert.NoError(t, err)
+	previous := ModerationChecks
+	ModerationChecks = []moderation_utils.Check{filter}
+	t.Cleanup(func() { ModerationChecks = previous })
+}
+
+func mockModeration(current *domain.Message) *moderationRepoMock {
+	domain.MessageRepo = &getDBMock{}
+	moderation := &moderationRepoMock{}
+	domain.ModerationRepo = moderation
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		copied := *current
+		return &copied, nil
+	}
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+	updateMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+	return moderation
+}
+
+func publishedEvent(t *testing.T, i int) map[string]interface{} {
+	var envelope map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(publishedMessages[i]), &envelope))
+	return envelope
+}
+
+func TestMessagesService_CreateMessage_HeldForReview(t *testing.T) {
+	withWordFilter(t, "casino")
+	mockModeration(&domain.Message{})
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "Weekend", Body: "Visit our CASINO tonight"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MessageStatusPendingReview, msg.Status)
+	assert.Nil(t, msg.PublishAt)
+	assert.Equal(t, []moderation_utils.Flag{{Check: "word_filter", Reason: `matches "casino"`}}, msg.Moderation.Flags)
+	assert.Equal(t, 1, len(publishedMessages))
+	event := publishedEvent(t, 0)
+	assert.Equal(t, MessageEventFlagged, event["event"])
+	data := event["data"].(map[string]interface{})
+	assert.Equal(t, "", data["title"])
+	assert.Equal(t, "", data["body"])
+	assert.NotNil(t, data["moderation"])
+}
+
+func TestMessagesService_CreateMessage_PassesModeration(t *testing.T) {
+	withWordFilter(t, "casino")
+	mockModeration(&domain.Message{})
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "Weekend", Body: "Board games on Saturday"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MessageStatusPublished, msg.Status)
+	assert.Nil(t, msg.Moderation)
+	assert.Equal(t, MessageEventCreated, publishedEvent(t, 0)["event"])
+}
+
+func TestMessagesService_UpdateMessage_HeldForReview(t *testing.T) {
+	withWordFilter(t, "casino")
+	publishAt := time.Now().Add(-time.Hour)
+	mockModeration(&domain.Message{Id: 3, Title: "Weekend", Body: "Board games", Status: domain.MessageStatusPublished, PublishAt: &publishAt})
+
+	msg, err := MessagesService.UpdateMessage(context.Background(), &domain.Message{Id: 3, Title: "Weekend", Body: "Casino night"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MessageStatusPendingReview, msg.Status)
+	assert.Nil(t, msg.PublishAt)
+	event := publishedEvent(t, 0)
+	assert.Equal(t, MessageEventFlagged, event["event"])
+	changes := event["changes"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"from": "published", "to": "pending_review"}, changes["status"])
+	assert.NotNil(t, changes["moderation"])
+	assert.Nil(t, changes["body"])
+	assert.Equal(t, "", event["data"].(map[string]interface{})["body"])
+}
+
+func TestMessagesService_CreateMessage_IgnoresServerFields(t *testing.T) {
+	mockModeration(&domain.Message{})
+	flags := []moderation_utils.Flag{{Check: "word_filter", Reason: "made up"}}
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "Weekend", Body: "Board games", Slug: "taken",
+		ReadCount: 40, Reactions: map[string]int{"👍": 9}, Deleted: true, SimilarTo: []int64{2}, Moderation: &domain.Moderation{Flags: flags}})
+
+	assert.Nil(t, err)
+	assert.Nil(t, msg.Moderation)
+	assert.Nil(t, msg.SimilarTo)
+	assert.Nil(t, msg.Reactions)
+	assert.Equal(t, 0, msg.ReadCount)
+	assert.Equal(t, "", msg.Slug)
+	assert.False(t, msg.Deleted)
+}
+
+func TestMessagesService_UpdateMessage_DraftIsNotHeld(t *testing.T) {
+	withWordFilter(t, "casino")
+	mockModeration(&domain.Message{Id: 3, Title: "Weekend", Body: "Board games", Status: domain.MessageStatusDraft})
+
+	msg, err := MessagesService.UpdateMessage(context.Background(), &domain.Message{Id: 3, Title: "Weekend", Body: "Casino night"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MessageStatusDraft, msg.Status)
+	assert.Nil(t, msg.Moderation)
+}
+
+func TestMessagesService_TransitionMessage_PublishingHoldsFlaggedDraft(t *testing.T) {
+	withWordFilter(t, "casino")
+	mockModeration(&domain.Message{Id: 3, Title: "Weekend", Body: "Casino night", Status: domain.MessageStatusDraft})
+	var saved *domain.Message
+	updateStatusDomain = func(msg *domain.Message, fromStatus string) error_utils.MessageErr {
+		saved = msg
+		return nil
+	}
+
+	msg, err := MessagesService.TransitionMessage(context.Background(), 3, domain.MessageStatusPublished, nil)
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MessageStatusPendingReview, saved.Status)
+	assert.Nil(t, saved.PublishAt)
+	assert.NotNil(t, msg.Moderation)
+	assert.Equal(t, MessageEventFlagged, publishedEvent(t, 0)["event"])
+}
+
+func TestMessagesService_UpdateMessage_RejectedIsResubmitted(t *testing.T) {
+	mockModeration(&domain.Message{Id: 3, Title: "Weekend", Body: "Casino night", Status: domain.MessageStatusRejected})
+
+	msg, err := MessagesService.UpdateMessage(context.Background(), &domain.Message{Id: 3, Title: "Weekend", Body: "Board games"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MessageStatusPendingReview, msg.Status)
+	assert.Equal(t, "resubmission", msg.Moderation.Flags[0].Check)
+}
+
+func TestModerationService_ApproveMessage(t *testing.T) {
+	moderation := mockModeration(&domain.Message{Id: 3, Status: domain.MessageStatusPendingReview})
+
+	msg, err := ModerationService.ApproveMessage(asPrincipal("mod-1", "messages:moderate"), 3, "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MessageStatusPublished, msg.Status)
+	assert.NotNil(t, msg.PublishAt)
+	assert.Equal(t, domain.MessageStatusPublished, moderation.status)
+	assert.Equal(t, domain.ModerationApproved, moderation.decided.Decision)
+	assert.Equal(t, "mod-1", moderation.decided.ModeratorId)
+	event := publishedEvent(t, 0)
+	assert.Equal(t, MessageEventApproved, event["event"])
+	assert.Equal(t, "approved", event["changes"].(map[string]interface{})["moderation"].(map[string]interface{})["decision"])
+}
+
+func TestModerationService_ApproveMessage_KeepsSchedule(t *testing.T) {
+	publishAt := time.Now().Add(time.Hour)
+	mockModeration(&domain.Message{Id: 3, Status: domain.MessageStatusPendingReview, PublishAt: &publishAt})
+
+	msg, err := ModerationService.ApproveMessage(context.Background(), 3, "fine")
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MessageStatusScheduled, msg.Status)
+	assert.Equal(t, publishAt, *msg.PublishAt)
+}
+
+func TestModerationService_RejectMessage(t *testing.T) {
+	moderation := mockModeration(&domain.Message{Id: 3, Status: domain.MessageStatusPendingReview})
+
+	msg, err := ModerationService.RejectMessage(context.Background(), 3, "  advertising ")
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.MessageStatusRejected, msg.Status)
+	assert.Equal(t, "advertising", moderation.decided.Reason)
+	assert.Equal(t, MessageEventRejected, publishedEvent(t, 0)["event"])
+}
+
+func TestModerationService_RejectMessage_RequiresReason(t *testing.T) {
+	mockModeration(&domain.Message{Id: 3, Status: domain.MessageStatusPendingReview})
+
+	_, err := ModerationService.RejectMessage(context.Background(), 3, " ")
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.Equal(t, 0, len(publishedMessages))
+}
+
+func TestModerationService_Decide_NotPending(t *testing.T) {
+	mockModeration(&domain.Message{Id: 3, Status: domain.MessageStatusPublished})
+
+	_, err := ModerationService.ApproveMessage(context.Background(), 3, "")
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusConflict, err.Status())
+}
+
+func TestMessagesService_TransitionMessage_PendingReviewIsStuck(t *testing.T) {
+	mockModeration(&domain.Message{Id: 3, Status: domain.MessageStatusPendingReview})
+
+	_, err := MessagesService.TransitionMessage(context.Background(), 3, domain.MessageStatusPublished, nil)
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusConflict, err.Status())
+}
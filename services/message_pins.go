@@ -0,0 +1,67 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	PinsService pinsServiceInterface = &pinsService{}
+)
+
+type pinsService struct{}
+
+// pinsServiceInterface manages the pinned messages of the caller's tenant.
+// A category of "" means the pins of the tenant as a whole; any other
+// category has its own pins, which only its messages may join. Changes
+// return the pins of the category in their new order.
+type pinsServiceInterface interface {
+	ListPinned(ctx context.Context, category string) ([]domain.Message, error_utils.MessageErr)
+	PinMessage(ctx context.Context, msgId int64, category string, position int) ([]domain.Message, error_utils.MessageErr)
+	UnpinMessage(ctx context.Context, msgId int64, category string) error_utils.MessageErr
+	ReorderPins(ctx context.Context, category string, msgIds []int64) ([]domain.Message, error_utils.MessageErr)
+}
+
+func (s *pinsService) ListPinned(ctx context.Context, category string) ([]domain.Message, error_utils.MessageErr) {
+	return domain.MessageRepo.Pinned(ctx, pinScope(category))
+}
+
+// PinMessage pins a message at position, counted from 1, or at the end
+// when position is 0. Pinning a pinned message moves it.
+func (s *pinsService) PinMessage(ctx context.Context, msgId int64, category string, position int) ([]domain.Message, error_utils.MessageErr) {
+	if position < 0 {
+		return nil, error_utils.NewUnprocessibleEntityError("position must not be negative")
+	}
+	scope := pinScope(category)
+	msg, err := domain.MessageRepo.Get(ctx, msgId)
+	if err != nil {
+		return nil, err
+	}
+	if scope != "" && msg.Category != scope {
+		return nil, error_utils.NewUnprocessibleEntityError("message is not in category " + scope)
+	}
+	if err := domain.PinRepo.Pin(ctx, scope, msgId, position); err != nil {
+		return nil, err
+	}
+	return domain.MessageRepo.Pinned(ctx, scope)
+}
+
+func (s *pinsService) UnpinMessage(ctx context.Context, msgId int64, category string) error_utils.MessageErr {
+	return domain.PinRepo.Unpin(ctx, pinScope(category), msgId)
+}
+
+// ReorderPins puts the pins of a category in the order of msgIds, which
+// must name every pinned message once. Nothing changes otherwise.
+func (s *pinsService) ReorderPins(ctx context.Context, category string, msgIds []int64) ([]domain.Message, error_utils.MessageErr) {
+	scope := pinScope(category)
+	if err := domain.PinRepo.Reorder(ctx, scope, msgIds); err != nil {
+		return nil, err
+	}
+	return domain.MessageRepo.Pinned(ctx, scope)
+}
+
+func pinScope(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
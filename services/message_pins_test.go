@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+type pinRepoMock struct {
+	scope    string
+	position int
+}
+
+func (r *pinRepoMock) Pin(ctx context.Context, scope string, messageId int64, position int) error_utils.MessageErr {
+	r.scope = scope
+	r.position = position
+	return nil
+}
+func (r *pinRepoMock) Unpin(ctx context.Context, scope string, messageId int64) error_utils.MessageErr {
+	r.scope = scope
+	return nil
+}
+func (r *pinRepoMock) Reorder(ctx context.Context, scope string, messageIds []int64) error_utils.MessageErr {
+	r.scope = scope
+	return nil
+}
+
+func TestPinsService_PinMessage_InCategory(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	pins := &pinRepoMock{}
+	domain.PinRepo = pins
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: messageId, Category: "news"}, nil
+	}
+	pinnedDomain = func(scope string) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 4, Category: scope}}, nil
+	}
+
+	messages, err := PinsService.PinMessage(context.Background(), 4, " News ", 2)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "news", pins.scope)
+	assert.Equal(t, 2, pins.position)
+	assert.Equal(t, 1, len(messages))
+}
+
+func TestPinsService_PinMessage_OtherCategory(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	pins := &pinRepoMock{}
+	domain.PinRepo = pins
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: messageId, Category: "sports"}, nil
+	}
+
+	_, err := PinsService.PinMessage(context.Background(), 4, "news", 0)
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.Equal(t, "", pins.scope)
+}
+
+func TestPinsService_PinMessage_NegativePosition(t *testing.T) {
+	_, err := PinsService.PinMessage(context.Background(), 4, "", -1)
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+}
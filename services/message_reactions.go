@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"testing-project/domain"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_utils"
+)
+
+var (
+	ReactionsService reactionsServiceInterface = &reactionsService{}
+)
+
+type reactionsService struct{}
+
+// reactionsServiceInterface records engagement per user. Every method is
+// idempotent and returns the message with its current counts.
+type reactionsServiceInterface interface {
+	AddReaction(ctx context.Context, msgId int64, emoji string) (*domain.Message, error_utils.MessageErr)
+	RemoveReaction(ctx context.Context, msgId int64, emoji string) (*domain.Message, error_utils.MessageErr)
+	MarkRead(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr)
+}
+
+type reactionChange struct {
+	Emoji  string `json:"emoji"`
+	UserId string `json:"user_id"`
+	Action string `json:"action"`
+}
+
+func (s *reactionsService) AddReaction(ctx context.Context, msgId int64, emoji string) (*domain.Message, error_utils.MessageErr) {
+	userId, err := reactingUser(ctx, emoji)
+	if err != nil {
+		return nil, err
+	}
+	added, err := domain.ReactionRepo.AddReaction(ctx, msgId, userId, emoji)
+	if err != nil {
+		return nil, err
+	}
+	return reacted(ctx, msgId, added, reactionChange{Emoji: emoji, UserId: userId, Action: "added"})
+}
+
+func (s *reactionsService) RemoveReaction(ctx context.Context, msgId int64, emoji string) (*domain.Message, error_utils.MessageErr) {
+	userId, err := reactingUser(ctx, emoji)
+	if err != nil {
+		return nil, err
+	}
+	removed, err := domain.ReactionRepo.RemoveReaction(ctx, msgId, userId, emoji)
+	if err != nil {
+		return nil, err
+	}
+	return reacted(ctx, msgId, removed, reactionChange{Emoji: emoji, UserId: userId, Action: "removed"})
+}
+
+func (s *reactionsService) MarkRead(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+	principal := auth_utils.PrincipalFrom(ctx)
+	if principal == nil {
+		return nil, error_utils.NewUnauthorizedError("read receipts need an authenticated user")
+	}
+	if _, err := domain.ReactionRepo.MarkRead(ctx, msgId, principal.Subject); err != nil {
+		return nil, err
+	}
+	return domain.MessageRepo.Get(ctx, msgId)
+}
+
+// reactingUser returns the caller, who must be authenticated since
+// reactions are counted per user.
+func reactingUser(ctx context.Context, emoji string) (string, error_utils.MessageErr) {
+	principal := auth_utils.PrincipalFrom(ctx)
+	if principal == nil {
+		return "", error_utils.NewUnauthorizedError("reactions need an authenticated user")
+	}
+	if !domain.ValidReaction(emoji) {
+		return "", error_utils.NewUnprocessibleEntityError("reaction must be an emoji or a lowercase short code such as +1")
+	}
+	return principal.Subject, nil
+}
+
+// reacted reads the message back and, when the reaction changed it, sends
+// a reacted event.
+func reacted(ctx context.Context, msgId int64, changed bool, change reactionChange) (*domain.Message, error_utils.MessageErr) {
+	msg, err := domain.MessageRepo.Get(ctx, msgId)
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		sendEventWithChanges(ctx, MessageEventReacted, msg, map[string]interface{}{"reaction": change})
+	}
+	return msg, nil
+}
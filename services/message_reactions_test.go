@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+)
+
+// reactionRepoMock keeps reactions in memory, keyed by user and emoji.
+type reactionRepoMock struct {
+	reactions map[string]bool
+	reads     map[string]bool
+}
+
+func newReactionRepoMock() *reactionRepoMock {
+	return &reactionRepoMock{reactions: make(map[string]bool), reads: make(map[string]bool)}
+}
+
+func (r *reactionRepoMock) AddReaction(ctx context.Context, messageId int64, userId string, emoji string) (bool, error_utils.MessageErr) {
+	if r.reactions[userId+"/"+emoji] {
+		return false, nil
+	}
+	r.reactions[userId+"/"+emoji] = true
+	return true, nil
+}
+func (r *reactionRepoMock) RemoveReaction(ctx context.Context, messageId int64, userId string, emoji string) (bool, error_utils.MessageErr) {
+	if !r.reactions[userId+"/"+emoji] {
+		return false, nil
+	}
+	delete(r.reactions, userId+"/"+emoji)
+	return true, nil
+}
+func (r *reactionRepoMock) MarkRead(ctx context.Context, messageId int64, userId string) (bool, error_utils.MessageErr) {
+	added := !r.reads[userId]
+	r.reads[userId] = true
+	return added, nil
+}
+
+func (r *reactionRepoMock) EraseUser(ctx context.Context, userId string) (int, int, error_utils.MessageErr) {
+	reactions, reads := 0, 0
+	for key := range r.reactions {
+		if strings.HasPrefix(key, userId+"/") {
+			delete(r.reactions, key)
+			reactions++
+		}
+	}
+	if r.reads[userId] {
+		delete(r.reads, userId)
+		reads++
+	}
+	return reactions, reads, nil
+}
+
+func mockReactedMessage() {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: messageId, Title: "title", Body: "body", Reactions: map[string]int{"👍": 1}}, nil
+	}
+}
+
+func TestReactionsService_AddReaction_SendsEventOnce(t *testing.T) {
+	mockReactedMessage()
+	domain.ReactionRepo = newReactionRepoMock()
+
+	msg, err := ReactionsService.AddReaction(asPrincipal("user-1"), 4, "👍")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, msg.Reactions["👍"])
+	_, err = ReactionsService.AddReaction(asPrincipal("user-1"), 4, "👍")
+	assert.Nil(t, err)
+
+	assert.Equal(t, 1, len(publishedMessages))
+	var event map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(publishedMessages[0]), &event))
+	assert.Equal(t, MessageEventReacted, event["event"])
+	assert.Equal(t, map[string]interface{}{"emoji": "👍", "user_id": "user-1", "action": "added"}, event["changes"].(map[string]interface{})["reaction"])
+}
+
+func TestReactionsService_RemoveReaction_WithoutReactionSendsNothing(t *testing.T) {
+	mockReactedMessage()
+	domain.ReactionRepo = newReactionRepoMock()
+
+	_, err := ReactionsService.RemoveReaction(asPrincipal("user-1"), 4, "tada")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(publishedMessages))
+}
+
+func TestReactionsService_RequiresUserAndValidEmoji(t *testing.T) {
+	mockReactedMessage()
+	domain.ReactionRepo = newReactionRepoMock()
+
+	_, err := ReactionsService.AddReaction(context.Background(), 4, "👍")
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+
+	_, err = ReactionsService.MarkRead(context.Background(), 4)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnauthorized, err.Status())
+
+	_, err = ReactionsService.AddReaction(asPrincipal("user-1"), 4, "not an emoji")
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+}
+
+func TestReactionsService_MarkRead_SendsNoEvent(t *testing.T) {
+	mockReactedMessage()
+	repo := newReactionRepoMock()
+	domain.ReactionRepo = repo
+
+	msg, err := ReactionsService.MarkRead(asPrincipal("user-1"), 4)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, 4, msg.Id)
+	assert.True(t, repo.reads["user-1"])
+	assert.Equal(t, 0, len(publishedMessages))
+}
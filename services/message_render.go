@@ -0,0 +1,87 @@
+package services
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"testing-project/domain"
+	"testing-project/utils/markdown_utils"
+)
+
+const renderCacheSize = 4096
+
+// renderedBodies caches rendered bodies by message version and locale. A
+// version never changes content, so entries need no invalidation; old versions simply
+// fall out of the cache.
+var renderedBodies = newRenderCache(renderCacheSize)
+
+// RenderBodies fills in BodyHtml on messages and, recursively, on their
+// replies.
+func RenderBodies(messages []domain.Message) {
+	for i := range messages {
+		RenderBody(&messages[i])
+		RenderBodies(messages[i].Replies)
+	}
+}
+
+// RenderBody fills in msg.BodyHtml according to msg.Format.
+func RenderBody(msg *domain.Message) {
+	if msg.Deleted {
+		return
+	}
+	key := fmt.Sprintf("%s/%d/%d/%s", msg.TenantId, msg.Id, msg.Version, msg.Locale)
+	if rendered, ok := renderedBodies.get(key); ok {
+		msg.BodyHtml = rendered
+		return
+	}
+	if msg.Format == domain.MessageFormatMarkdown {
+		msg.BodyHtml = markdown_utils.Markdown(msg.Body)
+	} else {
+		msg.BodyHtml = markdown_utils.Plain(msg.Body)
+	}
+	renderedBodies.add(key, msg.BodyHtml)
+}
+
+// renderCache is a fixed size, least recently used cache.
+type renderCache struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type renderEntry struct {
+	key  string
+	html string
+}
+
+func newRenderCache(size int) *renderCache {
+	return &renderCache{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (c *renderCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*renderEntry).html, true
+}
+
+func (c *renderCache) add(key string, html string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*renderEntry).html = html
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&renderEntry{key: key, html: html})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*renderEntry).key)
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"testing-project/domain"
+)
+
+func renderMarkdown(body string) string {
+	renderedBodies = newRenderCache(renderCacheSize)
+	msg := &domain.Message{Id: 1, Version: 1, Format: domain.MessageFormatMarkdown, Body: body}
+	RenderBody(msg)
+	return msg.BodyHtml
+}
+
+func TestRenderBody_Markdown(t *testing.T) {
+	html := renderMarkdown("# Release *notes*\n\nShipped **two** fixes and `go vet`.\n\n- one\n- two\n\n> quoted\n\n```\nif a < b {}\n```")
+
+	assert.Equal(t, "<h1>Release <em>notes</em></h1>\n"+
+		"<p>Shipped <strong>two</strong> fixes and <code>go vet</code>.</p>\n"+
+		"<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"+
+		"<blockquote>\n<p>quoted</p>\n</blockquote>\n"+
+		"<pre><code>if a &lt; b {}</code></pre>\n", html)
+}
+
+func TestRenderBody_EscapesRawHtml(t *testing.T) {
+	html := renderMarkdown(`<script>alert(1)</script> <img src=x onerror="alert(1)"> **<b>bold</b>**`)
+
+	assert.NotContains(t, html, "<script")
+	assert.NotContains(t, html, "<img")
+	assert.NotContains(t, html, "<b>")
+	assert.Contains(t, html, "&lt;script&gt;alert(1)&lt;/script&gt;")
+	assert.Contains(t, html, "<strong>&lt;b&gt;bold&lt;/b&gt;</strong>")
+}
+
+func TestRenderBody_Links(t *testing.T) {
+	assert.Equal(t, `<p>see <a href="https://example.com/a?b=1&amp;c=2" rel="nofollow noopener noreferrer">the docs</a></p>`+"\n",
+		renderMarkdown("see [the docs](https://example.com/a?b=1&c=2)"))
+	assert.Equal(t, `<p><a href="/messages/2" rel="nofollow noopener noreferrer">reply</a></p>`+"\n",
+		renderMarkdown("[reply](/messages/2)"))
+
+	for _, unsafe := range []string{"javascript:alert(1)", "JavaScript:alert(1)", "java\tscript:alert(1)", "data:text/html;base64,PHNjcmlwdD4=", "vbscript:x"} {
+		html := renderMarkdown("[click](" + unsafe + ")")
+		assert.Equal(t, "<p>click</p>\n", html, unsafe)
+	}
+	assert.NotContains(t, renderMarkdown(`[x](https://example.com/"onmouseover="alert(1))`), `"onmouseover`)
+}
+
+func TestRenderBody_Plain(t *testing.T) {
+	renderedBodies = newRenderCache(renderCacheSize)
+	msg := &domain.Message{Id: 1, Version: 1, Format: domain.MessageFormatPlain, Body: "**not bold** <i>\nsecond line\n\nnext"}
+
+	RenderBody(msg)
+
+	assert.Equal(t, "<p>**not bold** &lt;i&gt;<br>\nsecond line</p>\n<p>next</p>\n", msg.BodyHtml)
+}
+
+func TestRenderBody_CachedByVersion(t *testing.T) {
+	renderedBodies = newRenderCache(renderCacheSize)
+	first := &domain.Message{Id: 1, TenantId: "acme", Version: 1, Format: domain.MessageFormatMarkdown, Body: "*one*"}
+	RenderBody(first)
+
+	sameVersion := &domain.Message{Id: 1, TenantId: "acme", Version: 1, Format: domain.MessageFormatMarkdown, Body: "changed without a new version"}
+	RenderBody(sameVersion)
+	assert.Equal(t, first.BodyHtml, sameVersion.BodyHtml)
+
+	nextVersion := &domain.Message{Id: 1, TenantId: "acme", Version: 2, Format: domain.MessageFormatMarkdown, Body: "*two*"}
+	RenderBody(nextVersion)
+	assert.Equal(t, "<p><em>two</em></p>\n", nextVersion.BodyHtml)
+}
+
+func TestRenderCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newRenderCache(2)
+	cache.add("a", "A")
+	cache.add("b", "B")
+	cache.get("a")
+	cache.add("c", "C")
+
+	_, hasA := cache.get("a")
+	_, hasB := cache.get("b")
+	assert.True(t, hasA)
+	assert.False(t, hasB)
+}
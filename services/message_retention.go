@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+const messageReaperBatchSize = 100
+
+var (
+	// MessageRetention sets expires_at on messages created without one.
+	// The zero policy keeps messages forever.
+	MessageRetention RetentionPolicy
+)
+
+// RetentionPolicy is how long messages live by default. Categories override
+// Tenants, which override Default; a zero duration means no expiry.
+type RetentionPolicy struct {
+	Default    time.Duration
+	Tenants    map[string]time.Duration
+	Categories map[string]time.Duration
+}
+
+// TTL returns the default lifetime of a message owned by tenantId and filed
+// under category.
+func (p RetentionPolicy) TTL(tenantId string, category string) time.Duration {
+	if ttl, ok := p.Categories[category]; ok && category != "" {
+		return ttl
+	}
+	if ttl, ok := p.Tenants[tenantId]; ok {
+		return ttl
+	}
+	return p.Default
+}
+
+// ParseRetentionPolicy reads a policy written as
+// "*=720h,acme=24h,globex=0,category:notice=2h", where "*" is the default for
+// every other tenant and "category:" entries apply across tenants.
+func ParseRetentionPolicy(spec string) (RetentionPolicy, error) {
+	policy := RetentionPolicy{Tenants: make(map[string]time.Duration), Categories: make(map[string]time.Duration)}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return RetentionPolicy{}, fmt.Errorf("retention %q: expected key=duration", entry)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || ttl < 0 {
+			return RetentionPolicy{}, fmt.Errorf("retention %q: invalid duration", entry)
+		}
+		key = strings.TrimSpace(key)
+		if category, ok := strings.CutPrefix(key, "category:"); ok {
+			policy.Categories[strings.ToLower(category)] = ttl
+		} else if key == "*" {
+			policy.Default = ttl
+		} else {
+			policy.Tenants[key] = ttl
+		}
+	}
+	return policy, nil
+}
+
+// initialExpiry validates a requested expires_at, or applies the tenant's
+// retention default when none was given.
+func initialExpiry(ctx context.Context, message *domain.Message) error_utils.MessageErr {
+	if message.ExpiresAt == nil {
+		if ttl := MessageRetention.TTL(tenant_utils.TenantFrom(ctx), message.Category); ttl > 0 {
+			expiresAt := message.CreatedAt.Add(ttl)
+			message.ExpiresAt = &expiresAt
+		}
+		return nil
+	}
+	if !message.ExpiresAt.After(message.CreatedAt) {
+		return error_utils.NewUnprocessibleEntityError("expires_at must be in the future")
+	}
+	if message.PublishAt != nil && !message.ExpiresAt.After(*message.PublishAt) {
+		return error_utils.NewUnprocessibleEntityError("expires_at must be after publish_at")
+	}
+	return nil
+}
+
+// MessageReaperConfig tunes the reaper. Expired messages are deleted unless
+// Archive is set, in which case they are kept as archived but stay hidden.
+type MessageReaperConfig struct {
+	Interval time.Duration
+	Archive  bool
+}
+
+// RunMessageReaper removes expired messages every config.Interval until ctx
+// is done, emitting an expired event for each.
+func RunMessageReaper(ctx context.Context, config MessageReaperConfig) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			reapExpiredMessages(ctx, now, config.Archive)
+		}
+	}
+}
+
+// reapExpiredMessages handles every message expired at now and returns how
+// many it reaped.
+func reapExpiredMessages(ctx context.Context, now time.Time, archive bool) int {
+	reaped := 0
+	for {
+		expired, err := domain.MessageRepo.ListExpired(ctx, now, messageReaperBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to list expired messages", "error", err.Message())
+			return reaped
+		}
+		progressed := false
+		for i := range expired {
+			msg := &expired[i]
+			msgCtx := tenant_utils.WithTenant(ctx, msg.TenantId)
+			if err := reapMessage(msgCtx, msg, archive); err != nil {
+				if err.Status() != http.StatusConflict {
+					slog.ErrorContext(msgCtx, "failed to reap expired message", "message_id", msg.Id, "error", err.Message())
+				}
+				continue
+			}
+			progressed = true
+			reaped++
+			sendEvent(msgCtx, MessageEventExpired, msg)
+		}
+		if len(expired) < messageReaperBatchSize || !progressed {
+			return reaped
+		}
+	}
+}
+
+func reapMessage(ctx context.Context, msg *domain.Message, archive bool) error_utils.MessageErr {
+	if !archive {
+		if err := domain.MessageRepo.Delete(ctx, msg.Id); err != nil {
+			return err
+		}
+		requestBlobCleanup()
+		return nil
+	}
+	from := msg.Status
+	msg.Status = domain.MessageStatusArchived
+	return domain.MessageRepo.UpdateStatus(ctx, msg, from)
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+func TestParseRetentionPolicy(t *testing.T) {
+	policy, err := ParseRetentionPolicy("*=720h, acme=24h, globex=0, category:Notice=2h")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 720*time.Hour, policy.TTL("initech", ""))
+	assert.Equal(t, 24*time.Hour, policy.TTL("acme", "news"))
+	assert.Equal(t, time.Duration(0), policy.TTL("globex", ""))
+	assert.Equal(t, 2*time.Hour, policy.TTL("globex", "notice"))
+
+	_, err = ParseRetentionPolicy("acme=forever")
+	assert.NotNil(t, err)
+}
+
+func TestMessagesService_CreateMessage_AppliesRetention(t *testing.T) {
+	MessageRetention = RetentionPolicy{Tenants: map[string]time.Duration{"acme": time.Hour}}
+	defer func() { MessageRetention = RetentionPolicy{} }()
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+
+	msg, err := MessagesService.CreateMessage(tenant_utils.WithTenant(context.Background(), "acme"), &domain.Message{Title: "notice", Body: "temporary"})
+	assert.Nil(t, err)
+	assert.NotNil(t, msg.ExpiresAt)
+	assert.Equal(t, time.Hour, msg.ExpiresAt.Sub(msg.CreatedAt))
+
+	msg, err = MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "notice", Body: "forever"})
+	assert.Nil(t, err)
+	assert.Nil(t, msg.ExpiresAt)
+}
+
+func TestMessagesService_CreateMessage_ExpiresBeforePublish(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	publishAt := time.Now().Add(2 * time.Hour)
+	expiresAt := time.Now().Add(time.Hour)
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{
+		Title: "notice", Body: "too late", Status: domain.MessageStatusScheduled, PublishAt: &publishAt, ExpiresAt: &expiresAt,
+	})
+
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.EqualValues(t, "expires_at must be after publish_at", err.Message())
+}
+
+func mockExpiredMessages() {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	expired := time.Now().Add(-time.Minute)
+	listExpiredDomain = func(now time.Time, limit int) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{
+			{Id: 1, TenantId: "acme", Status: domain.MessageStatusPublished, ExpiresAt: &expired},
+			{Id: 2, TenantId: "acme", Status: domain.MessageStatusDraft, ExpiresAt: &expired},
+		}, nil
+	}
+}
+
+func TestReapExpiredMessages_Delete(t *testing.T) {
+	mockExpiredMessages()
+	var deleted []int64
+	deleteMessageDomain = func(messageId int64) error_utils.MessageErr {
+		deleted = append(deleted, messageId)
+		return nil
+	}
+
+	reaped := reapExpiredMessages(context.Background(), time.Now(), false)
+
+	assert.Equal(t, 2, reaped)
+	assert.EqualValues(t, []int64{1, 2}, deleted)
+	assert.EqualValues(t, []string{MessageEventExpired}, publishedEventTypes(t))
+}
+
+func TestReapExpiredMessages_Archive(t *testing.T) {
+	mockExpiredMessages()
+	var transitions []string
+	updateStatusDomain = func(msg *domain.Message, fromStatus string) error_utils.MessageErr {
+		transitions = append(transitions, fromStatus+"->"+msg.Status)
+		return nil
+	}
+
+	reaped := reapExpiredMessages(context.Background(), time.Now(), true)
+
+	assert.Equal(t, 2, reaped)
+	assert.EqualValues(t, []string{"published->archived", "draft->archived"}, transitions)
+	assert.EqualValues(t, []string{MessageEventExpired, MessageEventExpired}, publishedEventTypes(t))
+}
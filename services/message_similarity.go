@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/fingerprint_utils"
+)
+
+const (
+	DuplicatePolicyAllow  = "allow"
+	DuplicatePolicyWarn   = "warn"
+	DuplicatePolicyReject = "reject"
+
+	defaultSimilarLimit = 10
+	maxSimilarLimit     = 50
+	// duplicateCheckLimit caps the lookalikes listed when a new message is
+	// created under the warn policy.
+	duplicateCheckLimit = 5
+)
+
+// DuplicatePolicy decides what CreateMessage does with a message whose body
+// duplicates, or nearly duplicates, a body the tenant already has: allow
+// creates it without looking, warn creates it and lists the lookalikes in
+// SimilarTo, and reject refuses it.
+var DuplicatePolicy = DuplicatePolicyAllow
+
+// ParseDuplicatePolicy reads a DuplicatePolicy, allow when name is empty.
+func ParseDuplicatePolicy(name string) (string, error) {
+	switch name {
+	case "":
+		return DuplicatePolicyAllow, nil
+	case DuplicatePolicyAllow, DuplicatePolicyWarn, DuplicatePolicyReject:
+		return name, nil
+	}
+	return "", fmt.Errorf("duplicate policy %q: expected allow, warn or reject", name)
+}
+
+// checkDuplicates applies DuplicatePolicy to a message about to be created.
+func checkDuplicates(ctx context.Context, message *domain.Message) error_utils.MessageErr {
+	if DuplicatePolicy == DuplicatePolicyAllow {
+		return nil
+	}
+	similar, err := domain.MessageRepo.Similar(ctx, message, duplicateCheckLimit)
+	if err != nil {
+		return err
+	}
+	if len(similar) == 0 {
+		return nil
+	}
+	if DuplicatePolicy == DuplicatePolicyReject {
+		return error_utils.NewConflictError(fmt.Sprintf("message duplicates message %d", similar[0].Id))
+	}
+	for _, other := range similar {
+		message.SimilarTo = append(message.SimilarTo, other.Id)
+	}
+	slog.WarnContext(ctx, "message duplicates existing messages", "similar_to", message.SimilarTo)
+	return nil
+}
+
+// SimilarMessages lists up to limit messages whose body duplicates, or
+// nearly duplicates, the body of message msgId, closest first.
+func (m *messagesService) SimilarMessages(ctx context.Context, msgId int64, limit int) ([]domain.SimilarMessage, error_utils.MessageErr) {
+	switch {
+	case limit == 0:
+		limit = defaultSimilarLimit
+	case limit < 0 || limit > maxSimilarLimit:
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("limit must be between 1 and %d", maxSimilarLimit))
+	}
+	message, err := domain.MessageRepo.Get(ctx, msgId)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := domain.MessageRepo.Similar(ctx, message, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	contentHash, simhash := fingerprint_utils.ContentHash(message.Body), fingerprint_utils.SimHash(message.Body)
+	similar := make([]domain.SimilarMessage, 0, len(messages))
+	for _, other := range messages {
+		similar = append(similar, domain.SimilarMessage{
+			Message:  other,
+			Distance: fingerprint_utils.Distance(simhash, fingerprint_utils.SimHash(other.Body)),
+			Exact:    fingerprint_utils.ContentHash(other.Body) == contentHash,
+		})
+	}
+	return similar, nil
+}
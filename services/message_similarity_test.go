@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+)
+
+func mockSimilar(similar ...domain.Message) *bool {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	created := false
+	similarDomain = func(msg *domain.Message, limit int) ([]domain.Message, error_utils.MessageErr) {
+		return similar, nil
+	}
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		created = true
+		return msg, nil
+	}
+	return &created
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	policy, err := ParseDuplicatePolicy("")
+	assert.Nil(t, err)
+	assert.Equal(t, DuplicatePolicyAllow, policy)
+
+	policy, err = ParseDuplicatePolicy("reject")
+	assert.Nil(t, err)
+	assert.Equal(t, DuplicatePolicyReject, policy)
+
+	_, err = ParseDuplicatePolicy("block")
+	assert.NotNil(t, err)
+}
+
+func TestMessagesService_CreateMessage_DuplicateAllowed(t *testing.T) {
+	created := mockSimilar()
+	similarDomain = func(msg *domain.Message, limit int) ([]domain.Message, error_utils.MessageErr) {
+		t.Fatal("duplicates should not be looked up under the allow policy")
+		return nil, nil
+	}
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body"})
+
+	assert.Nil(t, err)
+	assert.Nil(t, msg.SimilarTo)
+	assert.True(t, *created)
+}
+
+func TestMessagesService_CreateMessage_DuplicateWarns(t *testing.T) {
+	DuplicatePolicy = DuplicatePolicyWarn
+	defer func() { DuplicatePolicy = DuplicatePolicyAllow }()
+	created := mockSimilar(domain.Message{Id: 4, Body: "the body"}, domain.Message{Id: 2, Body: "the body!"})
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{4, 2}, msg.SimilarTo)
+	assert.True(t, *created)
+	assert.Equal(t, 1, len(publishedMessages))
+}
+
+func TestMessagesService_CreateMessage_DuplicateRejected(t *testing.T) {
+	DuplicatePolicy = DuplicatePolicyReject
+	defer func() { DuplicatePolicy = DuplicatePolicyAllow }()
+	created := mockSimilar(domain.Message{Id: 4, Body: "the body"})
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body"})
+
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusConflict, err.Status())
+	assert.Equal(t, "message duplicates message 4", err.Message())
+	assert.False(t, *created)
+	assert.Equal(t, 0, len(publishedMessages))
+}
+
+func TestMessagesService_CreateMessage_NoDuplicate(t *testing.T) {
+	DuplicatePolicy = DuplicatePolicyReject
+	defer func() { DuplicatePolicy = DuplicatePolicyAllow }()
+	created := mockSimilar()
+
+	_, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "the title", Body: "the body"})
+
+	assert.Nil(t, err)
+	assert.True(t, *created)
+}
+
+func TestMessagesService_SimilarMessages(t *testing.T) {
+	mockSimilar()
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: messageId, Body: "Team lunch on Friday at noon"}, nil
+	}
+	var limit int
+	similarDomain = func(msg *domain.Message, l int) ([]domain.Message, error_utils.MessageErr) {
+		limit = l
+		return []domain.Message{
+			{Id: 4, Body: "Team lunch, on FRIDAY at noon."},
+			{Id: 2, Body: "Team lunch on Friday at noon in the park"},
+		}, nil
+	}
+
+	similar, err := MessagesService.SimilarMessages(context.Background(), 1, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, defaultSimilarLimit, limit)
+	assert.Equal(t, 2, len(similar))
+	assert.EqualValues(t, 4, similar[0].Id)
+	assert.True(t, similar[0].Exact)
+	assert.Equal(t, 0, similar[0].Distance)
+	assert.False(t, similar[1].Exact)
+	assert.True(t, similar[1].Distance > 0)
+}
+
+func TestMessagesService_SimilarMessages_InvalidLimit(t *testing.T) {
+	mockSimilar()
+
+	_, err := MessagesService.SimilarMessages(context.Background(), 1, maxSimilarLimit+1)
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, err.Status())
+}
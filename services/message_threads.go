@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+const (
+	defaultThreadDepth = 3
+	maxThreadDepth     = 10
+)
+
+// GetThread returns the message msgId with its replies nested up to depth
+// levels down. Deleted messages that still have replies appear as
+// tombstones, and the thread of a tombstone can be read like any other.
+func (m *messagesService) GetThread(ctx context.Context, msgId int64, depth int) (*domain.Message, error_utils.MessageErr) {
+	switch {
+	case depth == 0:
+		depth = defaultThreadDepth
+	case depth < 0 || depth > maxThreadDepth:
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("depth must be between 1 and %d", maxThreadDepth))
+	}
+	messages, err := domain.MessageRepo.Thread(ctx, msgId, depth)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 || messages[0].Id != msgId {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	return buildThread(messages), nil
+}
+
+// buildThread nests messages, ordered by id with the root first, under their
+// parents. Replies therefore come oldest first. Messages whose parent is
+// missing, because it expired, are left out.
+func buildThread(messages []domain.Message) *domain.Message {
+	children := make(map[int64][]int, len(messages))
+	for i, msg := range messages[1:] {
+		if msg.ParentId != nil {
+			children[*msg.ParentId] = append(children[*msg.ParentId], i+1)
+		}
+	}
+	var nest func(i int) domain.Message
+	nest = func(i int) domain.Message {
+		msg := messages[i]
+		for _, child := range children[msg.Id] {
+			msg.Replies = append(msg.Replies, nest(child))
+		}
+		return msg
+	}
+	root := nest(0)
+	return &root
+}
+
+// checkParent makes sure a reply points at a message the caller can see.
+func checkParent(ctx context.Context, message *domain.Message) error_utils.MessageErr {
+	if message.ParentId == nil {
+		return nil
+	}
+	if _, err := domain.MessageRepo.Get(ctx, *message.ParentId); err != nil {
+		if err.Status() == http.StatusNotFound {
+			return error_utils.NewUnprocessibleEntityError("parent message does not exist")
+		}
+		return err
+	}
+	return nil
+}
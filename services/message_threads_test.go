@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+)
+
+func int64Ptr(value int64) *int64 {
+	return &value
+}
+
+func TestMessagesService_GetThread_NestsReplies(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	var gotDepth int
+	threadDomain = func(rootId int64, depth int) ([]domain.Message, error_utils.MessageErr) {
+		gotDepth = depth
+		return []domain.Message{
+			{Id: 1, Deleted: true, ReplyCount: 2},
+			{Id: 2, ParentId: int64Ptr(1), ReplyCount: 1},
+			{Id: 3, ParentId: int64Ptr(1)},
+			{Id: 4, ParentId: int64Ptr(2)},
+			{Id: 6, ParentId: int64Ptr(5)},
+		}, nil
+	}
+
+	root, err := MessagesService.GetThread(context.Background(), 1, 0)
+
+	assert.Nil(t, err)
+	assert.Equal(t, defaultThreadDepth, gotDepth)
+	assert.True(t, root.Deleted)
+	assert.Equal(t, 2, len(root.Replies))
+	assert.EqualValues(t, 2, root.Replies[0].Id)
+	assert.EqualValues(t, 3, root.Replies[1].Id)
+	assert.Equal(t, 1, len(root.Replies[0].Replies))
+	assert.EqualValues(t, 4, root.Replies[0].Replies[0].Id)
+	assert.Nil(t, root.Replies[1].Replies)
+}
+
+func TestMessagesService_GetThread_NotFound(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	threadDomain = func(rootId int64, depth int) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{}, nil
+	}
+
+	root, err := MessagesService.GetThread(context.Background(), 1, 2)
+
+	assert.Nil(t, root)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+}
+
+func TestMessagesService_GetThread_InvalidDepth(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	threadDomain = func(rootId int64, depth int) ([]domain.Message, error_utils.MessageErr) {
+		t.Fatal("repository should not be called")
+		return nil, nil
+	}
+
+	_, err := MessagesService.GetThread(context.Background(), 1, maxThreadDepth+1)
+
+	assert.EqualValues(t, http.StatusBadRequest, err.Status())
+}
+
+func TestMessagesService_CreateMessage_UnknownParent(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewNotFoundError("no record matching given id")
+	}
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		t.Fatal("message should not be created")
+		return nil, nil
+	}
+
+	msg, err := MessagesService.CreateMessage(context.Background(), &domain.Message{Title: "reply", Body: "body", ParentId: int64Ptr(9)})
+
+	assert.Nil(t, msg)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.EqualValues(t, "parent message does not exist", err.Message())
+}
@@ -0,0 +1,519 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const (
+	ExportFormatCSV = "csv"
+
+	ImportConflictSkip   = "skip"
+	ImportConflictUpsert = "upsert"
+	ImportConflictFail   = "fail"
+
+	ImportRowCreated = "created"
+	ImportRowUpdated = "updated"
+	ImportRowSkipped = "skipped"
+	ImportRowInvalid = "invalid"
+	ImportRowFailed  = "failed"
+
+	// transferPageSize is how many messages an export reads at a time.
+	transferPageSize = 100
+	// maxImportLineSize bounds an NDJSON line, which leaves room for a
+	// message with metadata of MaxMetadataSize.
+	maxImportLineSize = 1 << 20
+)
+
+var (
+	TransferService transferServiceInterface = &transferService{}
+
+	// transferColumns are the CSV columns, in order. Tags are joined with
+	// commas, times are RFC 3339 and metadata is a JSON object.
+	transferColumns = []string{"id", "title", "body", "format", "author_id", "parent_id", "category", "tags", "status",
+		"publish_at", "expires_at", "created_at", "metadata", "slug", "version"}
+)
+
+type transferService struct{}
+
+// ImportOptions tune an import. OnConflict says what happens to a row
+// whose title is already taken in the tenant: skip leaves the existing
+// message alone, upsert replaces its content and fail stops the import.
+type ImportOptions struct {
+	Format         string
+	OnConflict     string
+	SuppressEvents bool
+}
+
+// ImportRow is the outcome of one row. Row is the line of the file the
+// row starts on, and Held is set when moderation held the message for
+// review.
+type ImportRow struct {
+	Row       int    `json:"row"`
+	Status    string `json:"status"`
+	Title     string `json:"title,omitempty"`
+	MessageId int64  `json:"message_id,omitempty"`
+	Held      bool   `json:"held,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportReport lists the outcome of every row read. Rows are imported one
+// at a time, so those before the row an import was aborted at stay
+// imported; Aborted is set and the rows after it are not listed. Held
+// counts the rows created or updated that are held for review.
+type ImportReport struct {
+	Created int         `json:"created"`
+	Updated int         `json:"updated"`
+	Held    int         `json:"held"`
+	Skipped int         `json:"skipped"`
+	Invalid int         `json:"invalid"`
+	Failed  int         `json:"failed"`
+	Aborted bool        `json:"aborted"`
+	Error   string      `json:"error,omitempty"`
+	Rows    []ImportRow `json:"rows"`
+}
+
+func (r *ImportReport) add(row ImportRow) {
+	switch row.Status {
+	case ImportRowCreated:
+		r.Created++
+	case ImportRowUpdated:
+		r.Updated++
+	case ImportRowSkipped:
+		r.Skipped++
+	case ImportRowInvalid:
+		r.Invalid++
+	case ImportRowFailed:
+		r.Failed++
+	}
+	if row.Held {
+		r.Held++
+	}
+	r.Rows = append(r.Rows, row)
+}
+
+// transferServiceInterface moves the messages of the caller's tenant
+// between environments.
+type transferServiceInterface interface {
+	// ExportMessages writes every message of the tenant to w in format,
+	// by id.
+	ExportMessages(ctx context.Context, format string, w io.Writer) error_utils.MessageErr
+	// ImportMessages creates or updates a message for every row of r, and
+	// reports what it did with each.
+	ImportMessages(ctx context.Context, r io.Reader, options ImportOptions) (*ImportReport, error_utils.MessageErr)
+}
+
+// ValidTransferFormat reports whether messages can be exported to and
+// imported from format.
+func ValidTransferFormat(format string) bool {
+	return format == ExportFormatNDJSON || format == ExportFormatCSV
+}
+
+func validConflictPolicy(policy string) bool {
+	return policy == ImportConflictSkip || policy == ImportConflictUpsert || policy == ImportConflictFail
+}
+
+// ExportMessages pages through the messages so that the export takes the
+// same memory however many there are. Expired messages are exported,
+// tombstones are not. An error after the first write leaves the export cut
+// short.
+func (s *transferService) ExportMessages(ctx context.Context, format string, w io.Writer) error_utils.MessageErr {
+	if !ValidTransferFormat(format) {
+		return error_utils.NewBadRequestError(fmt.Sprintf("format must be %s or %s", ExportFormatNDJSON, ExportFormatCSV))
+	}
+	export, err := newMessageExport(format, w)
+	if err != nil {
+		return transferErr(ctx, err)
+	}
+	var afterId int64
+	for {
+		messages, err := domain.MessageRepo.ListAfter(ctx, afterId, transferPageSize)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			if err := export.Write(msg); err != nil {
+				return transferErr(ctx, err)
+			}
+			afterId = msg.Id
+		}
+		if err := export.Flush(); err != nil {
+			return transferErr(ctx, err)
+		}
+		if len(messages) < transferPageSize {
+			return nil
+		}
+	}
+}
+
+// ImportMessages reads r one row at a time. Rows are checked as if they
+// were created through POST /messages, duplicate and moderation checks
+// included, except that the author, status and dates in the row are kept.
+// A published or scheduled row the checks flag is imported for review. Ids,
+// slugs and parents are not kept: the ids would clash with those of the
+// target. Rows that cannot be read or are invalid are reported and
+// skipped; the import is aborted when the body cannot be read any further
+// or the repository fails.
+func (s *transferService) ImportMessages(ctx context.Context, r io.Reader, options ImportOptions) (*ImportReport, error_utils.MessageErr) {
+	if !ValidTransferFormat(options.Format) {
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("format must be %s or %s", ExportFormatNDJSON, ExportFormatCSV))
+	}
+	if !validConflictPolicy(options.OnConflict) {
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("on_conflict must be %s, %s or %s", ImportConflictSkip, ImportConflictUpsert, ImportConflictFail))
+	}
+	rows, err := newMessageImport(options.Format, r)
+	if err != nil {
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("import could not be read: %s", err.Error()))
+	}
+
+	report := &ImportReport{Rows: []ImportRow{}}
+	for {
+		line, msg, err := rows.Next()
+		if err == io.EOF {
+			return report, nil
+		}
+		var invalid *invalidRowError
+		if errors.As(err, &invalid) {
+			report.add(ImportRow{Row: line, Status: ImportRowInvalid, Error: invalid.Error()})
+			continue
+		}
+		if err != nil {
+			slog.WarnContext(ctx, "import body could not be read", "row", line, "error", err)
+			report.Aborted = true
+			report.Error = fmt.Sprintf("import could not be read after row %d: %s", line, err.Error())
+			return report, nil
+		}
+		row, abort := importRow(ctx, msg, options)
+		row.Row = line
+		report.add(row)
+		if abort {
+			report.Aborted = true
+			return report, nil
+		}
+	}
+}
+
+// importRow imports one message and reports whether the import should
+// stop there.
+func importRow(ctx context.Context, row *domain.Message, options ImportOptions) (ImportRow, bool) {
+	message := &domain.Message{Title: row.Title, Body: row.Body, Format: row.Format, AuthorId: row.AuthorId, Category: row.Category,
+		Tags: row.Tags, Status: row.Status, PublishAt: row.PublishAt, ExpiresAt: row.ExpiresAt, CreatedAt: row.CreatedAt, Metadata: row.Metadata}
+	if err := prepareImport(ctx, message); err != nil {
+		return ImportRow{Status: ImportRowInvalid, Title: message.Title, Error: err.Message()}, false
+	}
+
+	existing, err := domain.MessageRepo.GetByTitle(ctx, message.Title)
+	if err != nil && err.Status() != http.StatusNotFound {
+		return failedRow(ctx, message, err)
+	}
+	if existing == nil {
+		if err := checkDuplicates(ctx, message); err != nil {
+			return failedRow(ctx, message, err)
+		}
+		held := moderate(message, time.Now())
+		created, err := domain.MessageRepo.Create(ctx, message)
+		if err != nil {
+			return failedRow(ctx, message, err)
+		}
+		switch {
+		case options.SuppressEvents:
+		case held:
+			sendEventWithChanges(ctx, MessageEventFlagged, created, map[string]interface{}{"moderation": created.Moderation})
+		default:
+			sendEvent(ctx, MessageEventCreated, created)
+		}
+		return ImportRow{Status: ImportRowCreated, Title: created.Title, MessageId: created.Id, Held: held}, false
+	}
+
+	switch options.OnConflict {
+	case ImportConflictSkip:
+		return ImportRow{Status: ImportRowSkipped, Title: existing.Title, MessageId: existing.Id}, false
+	case ImportConflictFail:
+		return ImportRow{Status: ImportRowFailed, Title: existing.Title, MessageId: existing.Id,
+			Error: fmt.Sprintf("a message titled %q already exists", existing.Title)}, true
+	}
+	previous := *existing
+	existing.Title = message.Title
+	existing.Body = message.Body
+	existing.Format = message.Format
+	existing.Category = message.Category
+	existing.Tags = message.Tags
+	existing.Metadata = message.Metadata
+	held := moderate(existing, time.Now())
+	updated, err := domain.MessageRepo.Update(ctx, existing)
+	if err != nil {
+		return failedRow(ctx, existing, err)
+	}
+	changes := messageChanges(&previous, updated)
+	switch {
+	case options.SuppressEvents:
+	case held:
+		if previous.Status != updated.Status {
+			changes["status"] = fieldChange{From: previous.Status, To: updated.Status}
+		}
+		changes["moderation"] = updated.Moderation
+		sendEventWithChanges(ctx, MessageEventFlagged, updated, changes)
+	default:
+		sendEventWithChanges(ctx, MessageEventUpdated, updated, changes)
+	}
+	return ImportRow{Status: ImportRowUpdated, Title: updated.Title, MessageId: updated.Id, Held: held}, false
+}
+
+// prepareImport checks a row and fills in what it leaves out: rows without
+// a creation time are created now, and published ones without a publish
+// time were published when they were created. Messages held for review
+// or rejected cannot be imported, since their moderation record is not.
+func prepareImport(ctx context.Context, message *domain.Message) error_utils.MessageErr {
+	if err := message.Validate(); err != nil {
+		return err
+	}
+	if err := checkMetadata(message); err != nil {
+		return err
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	switch message.Status {
+	case "", domain.MessageStatusPublished:
+		message.Status = domain.MessageStatusPublished
+		if message.PublishAt == nil {
+			publishAt := message.CreatedAt
+			message.PublishAt = &publishAt
+		}
+	case domain.MessageStatusDraft:
+		message.PublishAt = nil
+	case domain.MessageStatusScheduled:
+		if message.PublishAt == nil {
+			return error_utils.NewUnprocessibleEntityError("publish_at is required to import a scheduled message")
+		}
+	case domain.MessageStatusArchived:
+	default:
+		return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("a message cannot be imported as %q", message.Status))
+	}
+	return initialExpiry(ctx, message)
+}
+
+// failedRow reports a row the repository failed on. Server errors abort
+// the import, as every row after it would likely fail the same way.
+func failedRow(ctx context.Context, message *domain.Message, err error_utils.MessageErr) (ImportRow, bool) {
+	if err.Status() >= http.StatusInternalServerError {
+		slog.ErrorContext(ctx, "import aborted", "title", message.Title, "error", err.Message())
+	}
+	return ImportRow{Status: ImportRowFailed, Title: message.Title, MessageId: message.Id, Error: err.Message()},
+		err.Status() >= http.StatusInternalServerError
+}
+
+// transferErr passes on repository errors as they are and hides the
+// details of any other, such as a failed write to the client.
+func transferErr(ctx context.Context, err error) error_utils.MessageErr {
+	if messageErr, ok := err.(error_utils.MessageErr); ok {
+		return messageErr
+	}
+	slog.ErrorContext(ctx, "failed to transfer messages", "error", err)
+	return error_utils.NewInternalServerError("error when trying to transfer messages")
+}
+
+// messageExport writes messages in an export format. Flush is called
+// after every page.
+type messageExport interface {
+	Write(msg domain.Message) error
+	Flush() error
+}
+
+func newMessageExport(format string, w io.Writer) (messageExport, error) {
+	if format == ExportFormatCSV {
+		export := &csvExport{w: csv.NewWriter(w)}
+		return export, export.w.Write(transferColumns)
+	}
+	return &ndjsonMessageExport{encoder: json.NewEncoder(w)}, nil
+}
+
+// ndjsonMessageExport writes every message as a JSON line.
+type ndjsonMessageExport struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonMessageExport) Write(msg domain.Message) error {
+	return e.encoder.Encode(msg)
+}
+
+func (e *ndjsonMessageExport) Flush() error {
+	return nil
+}
+
+// csvExport writes every message as a record of transferColumns.
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e *csvExport) Write(msg domain.Message) error {
+	var parentId string
+	if msg.ParentId != nil {
+		parentId = strconv.FormatInt(*msg.ParentId, 10)
+	}
+	return e.w.Write([]string{strconv.FormatInt(msg.Id, 10), msg.Title, msg.Body, msg.Format, msg.AuthorId, parentId, msg.Category,
+		strings.Join(msg.Tags, ","), msg.Status, formatCSVTime(msg.PublishAt), formatCSVTime(msg.ExpiresAt), formatCSVTime(&msg.CreatedAt),
+		string(msg.Metadata), msg.Slug, strconv.Itoa(msg.Version)})
+}
+
+func (e *csvExport) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func formatCSVTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// invalidRowError is a row that could not be decoded. The rows after it
+// can still be read.
+type invalidRowError struct {
+	err error
+}
+
+func (e *invalidRowError) Error() string {
+	return e.err.Error()
+}
+
+// messageImport reads the rows of an import. Next returns the line a row
+// starts on along with the row, and io.EOF once there are no more.
+type messageImport interface {
+	Next() (int, *domain.Message, error)
+}
+
+func newMessageImport(format string, r io.Reader) (messageImport, error) {
+	if format == ExportFormatCSV {
+		return newCSVImport(r)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	return &ndjsonImport{scanner: scanner}, nil
+}
+
+// ndjsonImport reads a message from every line that is not blank.
+type ndjsonImport struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (i *ndjsonImport) Next() (int, *domain.Message, error) {
+	for i.scanner.Scan() {
+		i.line++
+		if len(strings.TrimSpace(i.scanner.Text())) == 0 {
+			continue
+		}
+		var msg domain.Message
+		if err := json.Unmarshal(i.scanner.Bytes(), &msg); err != nil {
+			return i.line, nil, &invalidRowError{fmt.Errorf("invalid json: %s", err.Error())}
+		}
+		return i.line, &msg, nil
+	}
+	if err := i.scanner.Err(); err != nil {
+		return i.line, nil, err
+	}
+	return i.line, nil, io.EOF
+}
+
+// csvImport reads a message from every record after the header, which
+// names the columns. An empty body has no rows. Columns not in
+// transferColumns are ignored, and so are id, parent_id, slug and version.
+// line is the line the last record read starts on.
+type csvImport struct {
+	r       *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+func newCSVImport(r io.Reader) (*csvImport, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	rows := &csvImport{r: reader, columns: columns}
+	if len(header) > 0 {
+		rows.line, _ = reader.FieldPos(0)
+	}
+	return rows, nil
+}
+
+func (i *csvImport) Next() (int, *domain.Message, error) {
+	record, err := i.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		i.line = parseErr.StartLine
+		return i.line, nil, &invalidRowError{err}
+	}
+	if err != nil {
+		return i.line, nil, err
+	}
+	i.line, _ = i.r.FieldPos(0)
+	msg, err := i.decode(record)
+	if err != nil {
+		return i.line, nil, &invalidRowError{err}
+	}
+	return i.line, msg, nil
+}
+
+func (i *csvImport) decode(record []string) (*domain.Message, error) {
+	field := func(name string) string {
+		if index, ok := i.columns[name]; ok {
+			return record[index]
+		}
+		return ""
+	}
+	msg := &domain.Message{Title: field("title"), Body: field("body"), Format: field("format"), AuthorId: field("author_id"),
+		Category: field("category"), Status: field("status")}
+	if tags := field("tags"); tags != "" {
+		msg.Tags = strings.Split(tags, ",")
+	}
+	if metadata := field("metadata"); metadata != "" {
+		msg.Metadata = json.RawMessage(metadata)
+	}
+	var err error
+	if msg.PublishAt, err = parseCSVTime("publish_at", field("publish_at")); err != nil {
+		return nil, err
+	}
+	if msg.ExpiresAt, err = parseCSVTime("expires_at", field("expires_at")); err != nil {
+		return nil, err
+	}
+	createdAt, err := parseCSVTime("created_at", field("created_at"))
+	if err != nil {
+		return nil, err
+	}
+	if createdAt != nil {
+		msg.CreatedAt = *createdAt
+	}
+	return msg, nil
+}
+
+func parseCSVTime(column string, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 time", column)
+	}
+	return &t, nil
+}
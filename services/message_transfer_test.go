@@ -0,0 +1,274 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+	"testing/iotest"
+	"time"
+)
+
+// mockTransfer stores messages by title, and gives the ones created ids
+// after those of existing.
+func mockTransfer(existing ...domain.Message) map[string]*domain.Message {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	stored := make(map[string]*domain.Message, len(existing))
+	var lastId int64
+	for i := range existing {
+		stored[existing[i].Title] = &existing[i]
+		lastId = existing[i].Id
+	}
+	getByTitleDomain = func(title string) (*domain.Message, error_utils.MessageErr) {
+		msg, ok := stored[title]
+		if !ok {
+			return nil, error_utils.NewNotFoundError("no record matching given id")
+		}
+		copied := *msg
+		return &copied, nil
+	}
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		lastId++
+		msg.Id, msg.Version = lastId, 1
+		stored[msg.Title] = msg
+		return msg, nil
+	}
+	updateMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		msg.Version++
+		stored[msg.Title] = msg
+		return msg, nil
+	}
+	return stored
+}
+
+func TestTransferService_ExportMessages_NDJSONPages(t *testing.T) {
+	mockTransfer()
+	var afterIds []int64
+	listAfterDomain = func(afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
+		afterIds = append(afterIds, afterId)
+		messages := []domain.Message{}
+		for id := afterId + 1; id <= 101 && len(messages) < limit; id++ {
+			messages = append(messages, domain.Message{Id: id, Title: "title", Body: "body"})
+		}
+		return messages, nil
+	}
+	var out bytes.Buffer
+
+	err := TransferService.ExportMessages(context.Background(), ExportFormatNDJSON, &out)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{0, 100}, afterIds)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, 101, len(lines))
+	var last domain.Message
+	assert.NoError(t, json.Unmarshal([]byte(lines[100]), &last))
+	assert.EqualValues(t, 101, last.Id)
+}
+
+func TestTransferService_ExportMessages_CSV(t *testing.T) {
+	mockTransfer()
+	createdAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	parentId := int64(2)
+	listAfterDomain = func(afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{{Id: 3, Title: "Launch, day", Body: "body", Format: "plain", ParentId: &parentId, Tags: []string{"news", "release"},
+			Status: domain.MessageStatusDraft, CreatedAt: createdAt, Metadata: json.RawMessage(`{"venue":"hall"}`), Slug: "launch-day", Version: 2}}, nil
+	}
+	var out bytes.Buffer
+
+	err := TransferService.ExportMessages(context.Background(), ExportFormatCSV, &out)
+
+	assert.Nil(t, err)
+	records, readErr := csv.NewReader(&out).ReadAll()
+	assert.NoError(t, readErr)
+	assert.Equal(t, [][]string{transferColumns, {"3", "Launch, day", "body", "plain", "", "2", "", "news,release", "draft", "", "",
+		"2024-03-01T09:30:00Z", `{"venue":"hall"}`, "launch-day", "2"}}, records)
+}
+
+func TestTransferService_ExportMessages_InvalidFormat(t *testing.T) {
+	err := TransferService.ExportMessages(context.Background(), "xml", &bytes.Buffer{})
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, err.Status())
+}
+
+func TestTransferService_ImportMessages_NDJSON(t *testing.T) {
+	stored := mockTransfer(domain.Message{Id: 1, Title: "Taken", Body: "old"})
+	body := `{"id":40,"title":"Launch","body":"We launch today","author_id":"user-1","status":"draft","slug":"old-slug","version":4}
+
+{"title":"No body","body":" "}
+{"title":
+{"title":"Taken","body":"new"}
+`
+
+	report, err := TransferService.ImportMessages(context.Background(), strings.NewReader(body), ImportOptions{Format: ExportFormatNDJSON, OnConflict: ImportConflictSkip})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ImportRow{
+		{Row: 1, Status: ImportRowCreated, Title: "Launch", MessageId: 2},
+		{Row: 3, Status: ImportRowInvalid, Title: "No body", Error: "Please enter a valid body"},
+		{Row: 4, Status: ImportRowInvalid, Error: "invalid json: unexpected end of JSON input"},
+		{Row: 5, Status: ImportRowSkipped, Title: "Taken", MessageId: 1},
+	}, report.Rows)
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 2, report.Invalid)
+	assert.Equal(t, 1, report.Skipped)
+	assert.False(t, report.Aborted)
+	assert.Equal(t, "user-1", stored["Launch"].AuthorId)
+	assert.Equal(t, "", stored["Launch"].Slug)
+	assert.Equal(t, "old", stored["Taken"].Body)
+	assert.Equal(t, 0, len(publishedMessages))
+}
+
+func TestTransferService_ImportMessages_Upsert(t *testing.T) {
+	stored := mockTransfer(domain.Message{Id: 1, Title: "Taken", Body: "old", Status: domain.MessageStatusPublished, Version: 3})
+
+	report, err := TransferService.ImportMessages(context.Background(), strings.NewReader(`{"title":"Taken","body":"new","status":"draft"}`),
+		ImportOptions{Format: ExportFormatNDJSON, OnConflict: ImportConflictUpsert})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ImportRow{{Row: 1, Status: ImportRowUpdated, Title: "Taken", MessageId: 1}}, report.Rows)
+	assert.Equal(t, "new", stored["Taken"].Body)
+	assert.Equal(t, domain.MessageStatusPublished, stored["Taken"].Status)
+	assert.Equal(t, 4, stored["Taken"].Version)
+	event := publishedEvent(t, 0)
+	assert.Equal(t, MessageEventUpdated, event["event"])
+	assert.NotNil(t, event["changes"].(map[string]interface{})["body"])
+}
+
+func TestTransferService_ImportMessages_FailStopsAtConflict(t *testing.T) {
+	mockTransfer(domain.Message{Id: 1, Title: "Taken", Body: "old"})
+	body := "{\"title\":\"First\",\"body\":\"body\"}\n{\"title\":\"Taken\",\"body\":\"new\"}\n{\"title\":\"Last\",\"body\":\"body\"}\n"
+
+	report, err := TransferService.ImportMessages(context.Background(), strings.NewReader(body), ImportOptions{Format: ExportFormatNDJSON, OnConflict: ImportConflictFail})
+
+	assert.Nil(t, err)
+	assert.True(t, report.Aborted)
+	assert.Equal(t, 2, len(report.Rows))
+	assert.Equal(t, ImportRow{Row: 2, Status: ImportRowFailed, Title: "Taken", MessageId: 1, Error: `a message titled "Taken" already exists`}, report.Rows[1])
+	assert.Equal(t, 1, report.Created)
+	assert.Equal(t, 1, report.Failed)
+}
+
+func TestTransferService_ImportMessages_CSVWithoutEvents(t *testing.T) {
+	stored := mockTransfer()
+	body := "status,title,body,tags,created_at,publish_at,metadata,unknown\n" +
+		"published,Launch,\"We launch, today\",\"news,release\",2024-03-01T09:30:00Z,,\"{\"\"venue\"\":\"\"hall\"\"}\",x\n" +
+		"pending_review,Held,body,,,,,x\n" +
+		"published,Late,body,,yesterday,,,x\n" +
+		"published,Short,body\n"
+
+	report, err := TransferService.ImportMessages(context.Background(), strings.NewReader(body),
+		ImportOptions{Format: ExportFormatCSV, OnConflict: ImportConflictFail, SuppressEvents: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ImportRow{
+		{Row: 2, Status: ImportRowCreated, Title: "Launch", MessageId: 1},
+		{Row: 3, Status: ImportRowInvalid, Title: "Held", Error: `a message cannot be imported as "pending_review"`},
+		{Row: 4, Status: ImportRowInvalid, Error: "created_at must be an RFC 3339 time"},
+		{Row: 5, Status: ImportRowInvalid, Error: "record on line 5: wrong number of fields"},
+	}, report.Rows)
+	launch := stored["Launch"]
+	assert.Equal(t, "We launch, today", launch.Body)
+	assert.Equal(t, []string{"news", "release"}, launch.Tags)
+	assert.Equal(t, json.RawMessage(`{"venue":"hall"}`), launch.Metadata)
+	assert.True(t, launch.CreatedAt.Equal(time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)))
+	assert.True(t, launch.PublishAt.Equal(launch.CreatedAt))
+	assert.Equal(t, 0, len(publishedMessages))
+}
+
+func TestTransferService_ImportMessages_AppliesCreatePolicies(t *testing.T) {
+	withWordFilter(t, "casino")
+	DuplicatePolicy = DuplicatePolicyReject
+	defer func() { DuplicatePolicy = DuplicatePolicyAllow }()
+	stored := mockTransfer()
+	similarDomain = func(msg *domain.Message, limit int) ([]domain.Message, error_utils.MessageErr) {
+		if msg.Body == "copied body" {
+			return []domain.Message{{Id: 9, Body: msg.Body}}, nil
+		}
+		return nil, nil
+	}
+	body := "{\"title\":\"Copy\",\"body\":\"copied body\"}\n{\"title\":\"Offer\",\"body\":\"Visit our casino\",\"status\":\"published\"}\n"
+
+	report, err := TransferService.ImportMessages(context.Background(), strings.NewReader(body), ImportOptions{Format: ExportFormatNDJSON, OnConflict: ImportConflictFail})
+
+	assert.Nil(t, err)
+	assert.False(t, report.Aborted)
+	assert.Equal(t, []ImportRow{
+		{Row: 1, Status: ImportRowFailed, Title: "Copy", Error: "message duplicates message 9"},
+		{Row: 2, Status: ImportRowCreated, Title: "Offer", MessageId: 1, Held: true},
+	}, report.Rows)
+	assert.Equal(t, 1, report.Held)
+	assert.Equal(t, domain.MessageStatusPendingReview, stored["Offer"].Status)
+	assert.Nil(t, stored["Offer"].PublishAt)
+	assert.Equal(t, MessageEventFlagged, publishedEvent(t, 0)["event"])
+}
+
+func TestTransferService_ImportMessages_CSVReadErrorReportsLastRow(t *testing.T) {
+	mockTransfer()
+	body := io.MultiReader(strings.NewReader("title,body\nFirst,body\n\nSecond,body\n"), iotest.ErrReader(errors.New("connection reset")))
+
+	report, err := TransferService.ImportMessages(context.Background(), body, ImportOptions{Format: ExportFormatCSV, OnConflict: ImportConflictFail})
+
+	assert.Nil(t, err)
+	assert.True(t, report.Aborted)
+	assert.Equal(t, 2, report.Created)
+	assert.Equal(t, "import could not be read after row 4: connection reset", report.Error)
+}
+
+func TestTransferService_ImportMessages_RepositoryFailureAborts(t *testing.T) {
+	mockTransfer()
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return nil, error_utils.NewInternalServerError("error when trying to save message")
+	}
+	body := "{\"title\":\"First\",\"body\":\"body\"}\n{\"title\":\"Second\",\"body\":\"body\"}\n"
+
+	report, err := TransferService.ImportMessages(context.Background(), strings.NewReader(body), ImportOptions{Format: ExportFormatNDJSON, OnConflict: ImportConflictSkip})
+
+	assert.Nil(t, err)
+	assert.True(t, report.Aborted)
+	assert.Equal(t, []ImportRow{{Row: 1, Status: ImportRowFailed, Title: "First", Error: "error when trying to save message"}}, report.Rows)
+}
+
+func TestTransferService_ImportMessages_InvalidOptions(t *testing.T) {
+	_, err := TransferService.ImportMessages(context.Background(), strings.NewReader(""), ImportOptions{Format: ExportFormatNDJSON, OnConflict: "replace"})
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusBadRequest, err.Status())
+}
+
+func TestTransferService_CSVRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(30 * 24 * time.Hour)
+	exported := domain.Message{Id: 7, Title: "Launch", Body: "body", Format: domain.MessageFormatMarkdown, AuthorId: "user-1", Category: "news",
+		Tags: []string{"release"}, Status: domain.MessageStatusArchived, ExpiresAt: &expiresAt, CreatedAt: createdAt, Version: 5}
+	stored := mockTransfer()
+	listAfterDomain = func(afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
+		return []domain.Message{exported}, nil
+	}
+	var out bytes.Buffer
+	assert.Nil(t, TransferService.ExportMessages(context.Background(), ExportFormatCSV, &out))
+
+	report, err := TransferService.ImportMessages(context.Background(), &out, ImportOptions{Format: ExportFormatCSV, OnConflict: ImportConflictFail})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Created)
+	imported := stored["Launch"]
+	assert.Equal(t, exported.Format, imported.Format)
+	assert.Equal(t, exported.AuthorId, imported.AuthorId)
+	assert.Equal(t, exported.Category, imported.Category)
+	assert.Equal(t, exported.Tags, imported.Tags)
+	assert.Equal(t, exported.Status, imported.Status)
+	assert.True(t, imported.ExpiresAt.Equal(expiresAt))
+	assert.True(t, imported.CreatedAt.Equal(createdAt))
+}
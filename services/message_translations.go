@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"testing-project/utils/locale_utils"
+	"time"
+)
+
+var (
+	TranslationsService translationsServiceInterface = &translationsService{}
+
+	// DefaultLocale is the locale the title and body of every message are
+	// written in. Readers whose Accept-Language matches no translation get
+	// the original.
+	DefaultLocale = "en"
+)
+
+type translationsService struct{}
+
+type translationsServiceInterface interface {
+	LocalizeMessage(ctx context.Context, msg *domain.Message, acceptLanguage string) error_utils.MessageErr
+	ListTranslations(ctx context.Context, msgId int64) ([]domain.Translation, error_utils.MessageErr)
+	PutTranslation(ctx context.Context, msgId int64, translation *domain.Translation) (*domain.Translation, error_utils.MessageErr)
+	DeleteTranslation(ctx context.Context, msgId int64, locale string) error_utils.MessageErr
+}
+
+// LocalizeMessage swaps in the translation of msg that best matches an
+// Accept-Language header and sets msg.Locale. The original is kept when no
+// translation matches better than DefaultLocale.
+func (s *translationsService) LocalizeMessage(ctx context.Context, msg *domain.Message, acceptLanguage string) error_utils.MessageErr {
+	msg.Locale = DefaultLocale
+	if strings.TrimSpace(acceptLanguage) == "" {
+		return nil
+	}
+	translations, err := domain.TranslationRepo.ListByMessage(ctx, msg.Id)
+	if err != nil {
+		return err
+	}
+	available := []string{DefaultLocale}
+	for _, translation := range translations {
+		available = append(available, translation.Locale)
+	}
+	locale := locale_utils.Negotiate(acceptLanguage, available, DefaultLocale)
+	for i := range translations {
+		if translations[i].Locale == locale {
+			translate(msg, &translations[i])
+		}
+	}
+	return nil
+}
+
+func (s *translationsService) ListTranslations(ctx context.Context, msgId int64) ([]domain.Translation, error_utils.MessageErr) {
+	if _, err := domain.MessageRepo.Get(ctx, msgId); err != nil {
+		return nil, err
+	}
+	return domain.TranslationRepo.ListByMessage(ctx, msgId)
+}
+
+// PutTranslation creates or replaces the translation of a message for one
+// locale. Only the message's author may translate it. A translation the
+// moderation checks flag holds the whole message for review, just like an
+// edit of the original would.
+func (s *translationsService) PutTranslation(ctx context.Context, msgId int64, translation *domain.Translation) (*domain.Translation, error_utils.MessageErr) {
+	locale, err := translationLocale(translation.Locale)
+	if err != nil {
+		return nil, err
+	}
+	translation.Locale = locale
+	if err := translation.Validate(); err != nil {
+		return nil, err
+	}
+	msg, previous, err := messageInLocale(ctx, msgId, locale)
+	if err != nil {
+		return nil, err
+	}
+	translation.MessageId = msg.Id
+	translation.UpdatedAt = time.Now()
+	translate(msg, translation)
+	held := moderate(msg, translation.UpdatedAt)
+	if err := domain.TranslationRepo.Upsert(ctx, msg, translation); err != nil {
+		return nil, err
+	}
+
+	changes := messageChanges(previous, msg)
+	if held {
+		if previous.Status != msg.Status {
+			changes["status"] = fieldChange{From: previous.Status, To: msg.Status}
+		}
+		changes["moderation"] = msg.Moderation
+		sendEventWithChanges(ctx, MessageEventFlagged, msg, changes)
+	} else {
+		sendEventWithChanges(ctx, MessageEventUpdated, msg, changes)
+	}
+	return translation, nil
+}
+
+// DeleteTranslation removes the translation of a message for one locale, so
+// readers of that locale get the original again.
+func (s *translationsService) DeleteTranslation(ctx context.Context, msgId int64, locale string) error_utils.MessageErr {
+	locale, err := translationLocale(locale)
+	if err != nil {
+		return err
+	}
+	msg, previous, err := messageInLocale(ctx, msgId, locale)
+	if err != nil {
+		return err
+	}
+	if err := domain.TranslationRepo.Delete(ctx, msg, locale); err != nil {
+		return err
+	}
+
+	msg.Locale = locale
+	sendEventWithChanges(ctx, MessageEventUpdated, msg, messageChanges(previous, msg))
+	return nil
+}
+
+// translationLocale canonicalizes the locale of a translation. The default
+// locale cannot be translated into: that text is the message itself.
+func translationLocale(locale string) (string, error_utils.MessageErr) {
+	canonical, ok := locale_utils.Canonical(locale)
+	if !ok {
+		return "", error_utils.NewUnprocessibleEntityError(fmt.Sprintf("%q is not a valid locale", locale))
+	}
+	if strings.EqualFold(canonical, DefaultLocale) {
+		return "", error_utils.NewUnprocessibleEntityError(fmt.Sprintf("%s is the default locale, update the message instead", DefaultLocale))
+	}
+	return canonical, nil
+}
+
+// messageInLocale reads a message the caller may change, along with a copy
+// showing how readers of locale currently see it.
+func messageInLocale(ctx context.Context, msgId int64, locale string) (*domain.Message, *domain.Message, error_utils.MessageErr) {
+	msg, err := domain.MessageRepo.Get(ctx, msgId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := authorizeWrite(ctx, msg); err != nil {
+		return nil, nil, err
+	}
+	translations, err := domain.TranslationRepo.ListByMessage(ctx, msgId)
+	if err != nil {
+		return nil, nil, err
+	}
+	previous := *msg
+	for i := range translations {
+		if translations[i].Locale == locale {
+			translate(&previous, &translations[i])
+		}
+	}
+	return msg, &previous, nil
+}
+
+func translate(msg *domain.Message, translation *domain.Translation) {
+	msg.Title = translation.Title
+	msg.Body = translation.Body
+	msg.Locale = translation.Locale
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+)
+
+type translationRepoMock struct {
+	translations []domain.Translation
+	upserted     []domain.Translation
+	deleted      []string
+	held         string
+}
+
+func (r *translationRepoMock) ListByMessage(ctx context.Context, messageId int64) ([]domain.Translation, error_utils.MessageErr) {
+	return r.translations, nil
+}
+func (r *translationRepoMock) Upsert(ctx context.Context, msg *domain.Message, translation *domain.Translation) error_utils.MessageErr {
+	r.upserted = append(r.upserted, *translation)
+	if msg.Moderation != nil {
+		r.held = msg.Status
+	}
+	msg.Version++
+	return nil
+}
+func (r *translationRepoMock) Delete(ctx context.Context, msg *domain.Message, locale string) error_utils.MessageErr {
+	for _, translation := range r.translations {
+		if translation.Locale == locale {
+			r.deleted = append(r.deleted, locale)
+			msg.Version++
+			return nil
+		}
+	}
+	return error_utils.NewNotFoundError("no translation for given locale")
+}
+
+func translatedMessage() *domain.Message {
+	return &domain.Message{Id: 4, Title: "Hello", Body: "world", Format: domain.MessageFormatPlain, Version: 2}
+}
+
+func TestTranslationsService_LocalizeMessage_Negotiates(t *testing.T) {
+	domain.TranslationRepo = &translationRepoMock{translations: []domain.Translation{
+		{MessageId: 4, Locale: "de", Title: "Hallo", Body: "Welt"},
+		{MessageId: 4, Locale: "fr", Title: "Bonjour", Body: "le monde"},
+	}}
+
+	for header, want := range map[string]string{
+		"":                         "en",
+		"fr":                       "fr",
+		"fr-CA, de;q=0.8":          "fr",
+		"es, de;q=0.5, fr;q=0.4":   "de",
+		"en-GB, fr;q=0.9":          "en",
+		"ja, *;q=0.5":              "en",
+		"fr;q=0, de;q=0.1":         "de",
+		"not a language, de;q=0.2": "de",
+	} {
+		msg := translatedMessage()
+		err := TranslationsService.LocalizeMessage(context.Background(), msg, header)
+		assert.Nil(t, err)
+		assert.Equal(t, want, msg.Locale, header)
+	}
+
+	msg := translatedMessage()
+	TranslationsService.LocalizeMessage(context.Background(), msg, "fr")
+	assert.Equal(t, "Bonjour", msg.Title)
+	assert.Equal(t, "le monde", msg.Body)
+}
+
+func TestTranslationsService_PutTranslation_SendsLocaleInEvent(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	repo := &translationRepoMock{}
+	domain.TranslationRepo = repo
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return translatedMessage(), nil
+	}
+
+	translation, err := TranslationsService.PutTranslation(context.Background(), 4, &domain.Translation{
+		Locale: "pt_br", Title: " Olá ", Body: "mundo",
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "pt-BR", translation.Locale)
+	assert.Equal(t, "Olá", translation.Title)
+	assert.Equal(t, 1, len(repo.upserted))
+	assert.Equal(t, 1, len(publishedMessages))
+	var event map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(publishedMessages[0]), &event))
+	assert.Equal(t, MessageEventUpdated, event["event"])
+	assert.Equal(t, "pt-BR", event["locale"])
+	assert.EqualValues(t, 3, event["data"].(map[string]interface{})["version"])
+	assert.Equal(t, map[string]interface{}{"from": "Hello", "to": "Olá"}, event["changes"].(map[string]interface{})["title"])
+}
+
+func TestTranslationsService_PutTranslation_HeldForReview(t *testing.T) {
+	withWordFilter(t, "casino")
+	domain.MessageRepo = &getDBMock{}
+	repo := &translationRepoMock{}
+	domain.TranslationRepo = repo
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		msg := translatedMessage()
+		msg.Status = domain.MessageStatusPublished
+		return msg, nil
+	}
+
+	_, err := TranslationsService.PutTranslation(context.Background(), 4, &domain.Translation{Locale: "fr", Title: "Soirée", Body: "Casino ce soir"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(repo.upserted))
+	assert.Equal(t, domain.MessageStatusPendingReview, repo.held)
+	event := publishedEvent(t, 0)
+	assert.Equal(t, MessageEventFlagged, event["event"])
+	assert.Equal(t, "", event["data"].(map[string]interface{})["body"])
+}
+
+func TestTranslationsService_PutTranslation_RejectsLocales(t *testing.T) {
+	domain.TranslationRepo = &translationRepoMock{}
+
+	for _, locale := range []string{"en", "EN", "english!", ""} {
+		_, err := TranslationsService.PutTranslation(context.Background(), 4, &domain.Translation{Locale: locale, Title: "t", Body: "b"})
+		assert.NotNil(t, err, locale)
+		assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status(), locale)
+	}
+}
+
+func TestTranslationsService_DeleteTranslation_RestoresOriginal(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	repo := &translationRepoMock{translations: []domain.Translation{{MessageId: 4, Locale: "fr", Title: "Bonjour", Body: "le monde"}}}
+	domain.TranslationRepo = repo
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return translatedMessage(), nil
+	}
+
+	err := TranslationsService.DeleteTranslation(context.Background(), 4, "FR")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"fr"}, repo.deleted)
+	var event map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(publishedMessages[0]), &event))
+	assert.Equal(t, "fr", event["locale"])
+	assert.Equal(t, map[string]interface{}{"from": "Bonjour", "to": "Hello"}, event["changes"].(map[string]interface{})["title"])
+}
+
+func TestTranslationsService_DeleteTranslation_NotFound(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	domain.TranslationRepo = &translationRepoMock{}
+	publishedMessages = nil
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return translatedMessage(), nil
+	}
+
+	err := TranslationsService.DeleteTranslation(context.Background(), 4, "de")
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+	assert.Equal(t, 0, len(publishedMessages))
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+)
+
+func asPrincipal(subject string, scopes ...string) context.Context {
+	return auth_utils.WithPrincipal(context.Background(), &auth_utils.Principal{Subject: subject, Scopes: scopes})
+}
+
+func mockOwnedMessage(authorId string) {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: messageId, Title: "former title", Body: "former body", AuthorId: authorId}, nil
+	}
+	updateMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+	deleteMessageDomain = func(messageId int64) error_utils.MessageErr {
+		return nil
+	}
+}
+
+func TestMessagesService_CreateMessage_SetsAuthorFromPrincipal(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	var saved *domain.Message
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		saved = msg
+		return msg, nil
+	}
+
+	request := &domain.Message{Title: "the title", Body: "the body", AuthorId: "someone-else"}
+	msg, err := MessagesService.CreateMessage(asPrincipal("user-1"), request)
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, "user-1", msg.AuthorId)
+	assert.EqualValues(t, "user-1", saved.AuthorId)
+}
+
+func TestMessagesService_UpdateMessage_Owner(t *testing.T) {
+	mockOwnedMessage("user-1")
+
+	msg, err := MessagesService.UpdateMessage(asPrincipal("user-1"), &domain.Message{Id: 1, Title: "new title", Body: "new body"})
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, "new title", msg.Title)
+	assert.EqualValues(t, "user-1", msg.AuthorId)
+	assert.Equal(t, 1, len(publishedMessages))
+}
+
+func TestMessagesService_UpdateMessage_NotOwner(t *testing.T) {
+	mockOwnedMessage("user-1")
+
+	msg, err := MessagesService.UpdateMessage(asPrincipal("user-2"), &domain.Message{Id: 1, Title: "new title", Body: "new body"})
+
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusForbidden, err.Status())
+	assert.EqualValues(t, "forbidden", err.Error())
+	assert.Equal(t, 0, len(publishedMessages))
+}
+
+func TestMessagesService_UpdateMessage_Admin(t *testing.T) {
+	mockOwnedMessage("user-1")
+
+	msg, err := MessagesService.UpdateMessage(asPrincipal("ops", auth_utils.AdminScope), &domain.Message{Id: 1, Title: "new title", Body: "new body"})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, msg)
+}
+
+func TestMessagesService_DeleteMessage_NotOwner(t *testing.T) {
+	mockOwnedMessage("user-1")
+
+	err := MessagesService.DeleteMessage(asPrincipal("user-2"), 1)
+
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusForbidden, err.Status())
+	assert.Equal(t, 0, len(publishedMessages))
+}
+
+func TestMessagesService_DeleteMessage_Unowned_RequiresAdmin(t *testing.T) {
+	mockOwnedMessage("")
+
+	err := MessagesService.DeleteMessage(asPrincipal("user-2"), 1)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusForbidden, err.Status())
+
+	err = MessagesService.DeleteMessage(asPrincipal("ops", auth_utils.AdminScope), 1)
+	assert.Nil(t, err)
+}
+
+func mockDraft(authorId string) {
+	domain.MessageRepo = &getDBMock{}
+	draft := &domain.Message{Id: 1, Title: "draft title", Body: "draft body", AuthorId: authorId, Status: domain.MessageStatusDraft}
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return draft, nil
+	}
+	getBySlugDomain = func(slug string) (*domain.Message, error_utils.MessageErr) {
+		return draft, nil
+	}
+}
+
+func TestMessagesService_GetMessage_DraftOfOtherAuthor(t *testing.T) {
+	mockDraft("user-1")
+
+	msg, err := MessagesService.GetMessage(asPrincipal("user-2", auth_utils.ScopeMessagesRead), 1)
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+
+	msg, err = MessagesService.GetMessageBySlug(asPrincipal("user-2", auth_utils.ScopeMessagesRead), "draft-title")
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusNotFound, err.Status())
+}
+
+func TestMessagesService_GetMessage_OwnDraft(t *testing.T) {
+	mockDraft("user-1")
+
+	msg, err := MessagesService.GetMessage(asPrincipal("user-1", auth_utils.ScopeMessagesRead), 1)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "draft body", msg.Body)
+
+	msg, err = MessagesService.GetMessage(asPrincipal("ops", auth_utils.AdminScope), 1)
+	assert.Nil(t, err)
+	assert.NotNil(t, msg)
+}
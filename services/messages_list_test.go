@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+)
+
+func TestMessagesService_ListMessages_Defaults(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	var got domain.MessageFilter
+	listMessagesDomain = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		got = filter
+		return []domain.Message{{Id: 1}}, nil
+	}
+
+	messages, err := MessagesService.ListMessages(context.Background(), domain.MessageFilter{Tags: []string{" Go "}, Category: " News"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, defaultListLimit, got.Limit)
+	assert.Equal(t, []string{"go"}, got.Tags)
+	assert.Equal(t, "news", got.Category)
+}
+
+func TestMessagesService_ListMessages_InvalidFilter(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	listMessagesDomain = func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+		t.Fatal("repository should not be called")
+		return nil, nil
+	}
+
+	for name, filter := range map[string]domain.MessageFilter{
+		"limit too large": {Limit: maxListLimit + 1},
+		"negative offset": {Offset: -1},
+		"unknown status":  {Status: "deleted"},
+	} {
+		_, err := MessagesService.ListMessages(context.Background(), filter)
+		assert.NotNil(t, err, name)
+		assert.EqualValues(t, http.StatusBadRequest, err.Status(), name)
+	}
+}
+
+func TestMessagesService_UpdateMessage_ReportsTagChanges(t *testing.T) {
+	domain.MessageRepo = &getDBMock{}
+	publishedMessages = nil
+	utils.PublishToQueue = mockPublishToQueue
+
+	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
+		return &domain.Message{Id: 1, Title: "title", Body: "body", Format: "plain", Category: "news", Tags: []string{"go", "sql"}}, nil
+	}
+	updateMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+
+	_, err := MessagesService.UpdateMessage(context.Background(), &domain.Message{Id: 1, Title: "title", Body: "body", Category: "news", Tags: []string{"Go", "rabbitmq"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(publishedMessages))
+
+	var brokerMsg struct {
+		Changes map[string]map[string]interface{} `json:"changes"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(publishedMessages[0]), &brokerMsg))
+	assert.Equal(t, 1, len(brokerMsg.Changes))
+	assert.Equal(t, []interface{}{"rabbitmq"}, brokerMsg.Changes["tags"]["added"])
+	assert.Equal(t, []interface{}{"sql"}, brokerMsg.Changes["tags"]["removed"])
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	utils "testing-project/utils/rabbitmq_utils"
+	"time"
+)
+
+func mockDailyCount(count int) *string {
+	domain.MessageRepo = &getDBMock{}
+	utils.PublishToQueue = mockPublishToQueue
+	publishedMessages = nil
+	var countedAuthor string
+	countByAuthorDomain = func(authorId string, since time.Time) (int, error_utils.MessageErr) {
+		countedAuthor = authorId
+		return count, nil
+	}
+	createMessageDomain = func(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+		return msg, nil
+	}
+	return &countedAuthor
+}
+
+func TestMessagesService_CreateMessage_UnderDailyQuota(t *testing.T) {
+	DailyMessageQuota = 3
+	defer func() { DailyMessageQuota = 0 }()
+	countedAuthor := mockDailyCount(2)
+
+	msg, err := MessagesService.CreateMessage(asPrincipal("user-1"), &domain.Message{Title: "the title", Body: "the body"})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, msg)
+	assert.EqualValues(t, "user-1", *countedAuthor)
+}
+
+func TestMessagesService_CreateMessage_DailyQuotaExceeded(t *testing.T) {
+	DailyMessageQuota = 3
+	defer func() { DailyMessageQuota = 0 }()
+	mockDailyCount(3)
+
+	msg, err := MessagesService.CreateMessage(asPrincipal("user-1"), &domain.Message{Title: "the title", Body: "the body"})
+
+	assert.Nil(t, msg)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusTooManyRequests, err.Status())
+	assert.EqualValues(t, "daily message quota exceeded", err.Message())
+	assert.Equal(t, 0, len(publishedMessages))
+}
+
+func TestMessagesService_CreateMessage_QuotaDisabled(t *testing.T) {
+	countedAuthor := mockDailyCount(100)
+
+	msg, err := MessagesService.CreateMessage(asPrincipal("user-1"), &domain.Message{Title: "the title", Body: "the body"})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, msg)
+	assert.EqualValues(t, "", *countedAuthor)
+}
@@ -1,86 +1,311 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
 	"testing-project/domain"
+	"testing-project/utils/auth_utils"
 	"testing-project/utils/error_utils"
+	"testing-project/utils/logger_utils"
 	utils "testing-project/utils/rabbitmq_utils"
+	"testing-project/utils/tenant_utils"
 	"time"
 )
 
 var (
 	MessagesService messageServiceInterface = &messagesService{}
+
+	// DailyMessageQuota caps how many messages one author may create per UTC
+	// day. Zero disables the quota.
+	DailyMessageQuota = 0
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
 )
 
 type messagesService struct{}
 
 type messageServiceInterface interface {
-	GetMessage(int64) (*domain.Message, error_utils.MessageErr)
-	CreateMessage(*domain.Message) (*domain.Message, error_utils.MessageErr)
-	UpdateMessage(*domain.Message) (*domain.Message, error_utils.MessageErr)
-	DeleteMessage(int64) error_utils.MessageErr
+	GetMessage(context.Context, int64) (*domain.Message, error_utils.MessageErr)
+	GetMessageBySlug(ctx context.Context, slug string) (*domain.Message, error_utils.MessageErr)
+	CreateMessage(context.Context, *domain.Message) (*domain.Message, error_utils.MessageErr)
+	UpdateMessage(context.Context, *domain.Message) (*domain.Message, error_utils.MessageErr)
+	DeleteMessage(context.Context, int64) error_utils.MessageErr
+	TransitionMessage(ctx context.Context, msgId int64, status string, publishAt *time.Time) (*domain.Message, error_utils.MessageErr)
+	ListMessages(context.Context, domain.MessageFilter) ([]domain.Message, error_utils.MessageErr)
+	ListTags(context.Context) ([]domain.TagCount, error_utils.MessageErr)
+	GetThread(ctx context.Context, msgId int64, depth int) (*domain.Message, error_utils.MessageErr)
+	SimilarMessages(ctx context.Context, msgId int64, limit int) ([]domain.SimilarMessage, error_utils.MessageErr)
+}
+
+// GetMessage returns a message the caller may see: one that is published,
+// or their own. Any other is reported as not found.
+func (m *messagesService) GetMessage(ctx context.Context, msgId int64) (*domain.Message, error_utils.MessageErr) {
+	msg, err := domain.MessageRepo.Get(ctx, msgId)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeRead(ctx, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
 }
 
-func (m *messagesService) GetMessage(msgId int64) (*domain.Message, error_utils.MessageErr) {
-	return domain.MessageRepo.Get(msgId)
+// GetMessageBySlug finds a message by its current slug or one it had before
+// its title changed.
+func (m *messagesService) GetMessageBySlug(ctx context.Context, slug string) (*domain.Message, error_utils.MessageErr) {
+	msg, err := domain.MessageRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if err := authorizeRead(ctx, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
 }
 
-func (m *messagesService) CreateMessage(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+// CreateMessage keeps only the fields of message a client may set: the
+// author, slug, counters, moderation and the like are the server's.
+func (m *messagesService) CreateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+	message = &domain.Message{Title: message.Title, Body: message.Body, Format: message.Format, ParentId: message.ParentId,
+		Category: message.Category, Tags: message.Tags, Status: message.Status, PublishAt: message.PublishAt, ExpiresAt: message.ExpiresAt,
+		Metadata: message.Metadata}
 	if err := message.Validate(); err != nil {
 		return nil, err
 	}
+	if err := checkMetadata(message); err != nil {
+		return nil, err
+	}
+	if principal := auth_utils.PrincipalFrom(ctx); principal != nil {
+		message.AuthorId = principal.Subject
+	}
+	if err := checkDailyQuota(ctx, message.AuthorId); err != nil {
+		return nil, err
+	}
+	if err := checkParent(ctx, message); err != nil {
+		return nil, err
+	}
 	message.CreatedAt = time.Now()
-	message, err := domain.MessageRepo.Create(message)
+	if err := initialStatus(message, message.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := initialExpiry(ctx, message); err != nil {
+		return nil, err
+	}
+	if err := checkDuplicates(ctx, message); err != nil {
+		return nil, err
+	}
+	held := moderate(message, message.CreatedAt)
+	message, err := domain.MessageRepo.Create(ctx, message)
 	if err != nil {
 		return nil, err
 	}
 
-	sendEvent("created", message)
+	if held {
+		sendEventWithChanges(ctx, MessageEventFlagged, message, map[string]interface{}{"moderation": message.Moderation})
+	} else {
+		sendEvent(ctx, MessageEventCreated, message)
+	}
 	return message, nil
 }
 
-func (m *messagesService) UpdateMessage(message *domain.Message) (*domain.Message, error_utils.MessageErr) {
+// UpdateMessage replaces the title, body, format, category, tags and
+// metadata of a message.
+func (m *messagesService) UpdateMessage(ctx context.Context, message *domain.Message) (*domain.Message, error_utils.MessageErr) {
 	if err := message.Validate(); err != nil {
 		return nil, err
 	}
-	current, err := domain.MessageRepo.Get(message.Id)
+	if err := checkMetadata(message); err != nil {
+		return nil, err
+	}
+	current, err := domain.MessageRepo.Get(ctx, message.Id)
 	if err != nil {
 		return nil, err
 	}
+	if err := authorizeWrite(ctx, current); err != nil {
+		return nil, err
+	}
+	previous := *current
 	current.Title = message.Title
 	current.Body = message.Body
+	current.Format = message.Format
+	current.Category = message.Category
+	current.Tags = message.Tags
+	current.Metadata = message.Metadata
+	held := moderate(current, time.Now())
 
-	updated, err := domain.MessageRepo.Update(current)
+	updated, err := domain.MessageRepo.Update(ctx, current)
 	if err != nil {
 		return nil, err
 	}
 
-	sendEvent("updated", updated)
+	changes := messageChanges(&previous, updated)
+	if held {
+		if previous.Status != updated.Status {
+			changes["status"] = fieldChange{From: previous.Status, To: updated.Status}
+		}
+		changes["moderation"] = updated.Moderation
+		sendEventWithChanges(ctx, MessageEventFlagged, updated, changes)
+	} else {
+		sendEventWithChanges(ctx, MessageEventUpdated, updated, changes)
+	}
 	return updated, nil
 }
 
-func (m *messagesService) DeleteMessage(msgId int64) error_utils.MessageErr {
-	msg, err := domain.MessageRepo.Get(msgId)
+func (m *messagesService) DeleteMessage(ctx context.Context, msgId int64) error_utils.MessageErr {
+	msg, err := domain.MessageRepo.Get(ctx, msgId)
 	if err != nil {
 		return err
 	}
-	deleteErr := domain.MessageRepo.Delete(msg.Id)
+	if err := authorizeWrite(ctx, msg); err != nil {
+		return err
+	}
+	deleteErr := domain.MessageRepo.Delete(ctx, msg.Id)
 	if deleteErr != nil {
 		return deleteErr
 	}
+	requestBlobCleanup()
 
-	sendEvent("deleted", msg)
+	sendEvent(ctx, MessageEventDeleted, msg)
 	return nil
 }
 
-func sendEvent(eventType string, message *domain.Message) {
+func (m *messagesService) ListMessages(ctx context.Context, filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+	switch {
+	case filter.Limit == 0:
+		filter.Limit = defaultListLimit
+	case filter.Limit < 0 || filter.Limit > maxListLimit:
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("limit must be between 1 and %d", maxListLimit))
+	}
+	if filter.Offset < 0 {
+		return nil, error_utils.NewBadRequestError("offset must not be negative")
+	}
+	if filter.Status != "" && !isMessageStatus(filter.Status) {
+		return nil, error_utils.NewBadRequestError(fmt.Sprintf("unknown status %q", filter.Status))
+	}
+	filter.Category = strings.ToLower(strings.TrimSpace(filter.Category))
+	for i, tag := range filter.Tags {
+		filter.Tags[i] = strings.ToLower(strings.TrimSpace(tag))
+	}
+	if err := checkMetadataFilter(filter.Metadata); err != nil {
+		return nil, err
+	}
+	return domain.MessageRepo.List(ctx, filter)
+}
+
+func (m *messagesService) ListTags(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+	return domain.MessageRepo.ListTags(ctx)
+}
+
+// checkDailyQuota rejects a create once the author has reached
+// DailyMessageQuota messages today. Concurrent creates may overshoot by a few.
+func checkDailyQuota(ctx context.Context, authorId string) error_utils.MessageErr {
+	if DailyMessageQuota <= 0 || authorId == "" {
+		return nil
+	}
+	now := time.Now().UTC()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	count, err := domain.MessageRepo.CountByAuthorSince(ctx, authorId, startOfDay)
+	if err != nil {
+		return err
+	}
+	if count >= DailyMessageQuota {
+		return error_utils.NewTooManyRequestsError("daily message quota exceeded")
+	}
+	return nil
+}
+
+// authorizeRead reports a message the caller may not see as not found. The
+// repository already leaves such messages out of its reads; this keeps a
+// message from leaking should one get through all the same.
+func authorizeRead(ctx context.Context, message *domain.Message) error_utils.MessageErr {
+	if !domain.Readable(ctx, message) {
+		return error_utils.NewNotFoundError("no record matching given id")
+	}
+	return nil
+}
+
+// authorizeWrite lets only the author of message, or an admin, change it.
+// Calls without a principal come from the service itself and are trusted.
+func authorizeWrite(ctx context.Context, message *domain.Message) error_utils.MessageErr {
+	principal := auth_utils.PrincipalFrom(ctx)
+	if principal == nil || principal.IsAdmin() {
+		return nil
+	}
+	if message.AuthorId != "" && message.AuthorId == principal.Subject {
+		return nil
+	}
+	return error_utils.NewForbiddenError("you are not allowed to modify this message")
+}
+
+func sendEvent(ctx context.Context, eventType string, message *domain.Message) {
+	sendEventWithChanges(ctx, eventType, message, nil)
+}
+
+// sendEventWithChanges publishes an event whose envelope also lists the
+// fields the change touched, as built by messageChanges, and the locale they
+// are in: the translation's for translation changes, DefaultLocale otherwise.
+// Drafts and scheduled messages are private to their author, so no event is
+// sent about them until they are published. Events about messages held back
+// by moderation are withheld: they leave out the content.
+func sendEventWithChanges(ctx context.Context, eventType string, message *domain.Message, changes map[string]interface{}) {
+	switch message.Status {
+	case domain.MessageStatusDraft, domain.MessageStatusScheduled:
+		return
+	case domain.MessageStatusPendingReview, domain.MessageStatusRejected:
+		message, changes = withheld(message, changes)
+	}
+	MessageEvents.Publish(eventType, message)
+
+	if message.TenantId != "" {
+		ctx = tenant_utils.WithTenant(ctx, message.TenantId)
+	}
 	event := map[string]interface{}{
-		"event": eventType,
-		"data":  message,
+		"event":     eventType,
+		"tenant_id": tenant_utils.TenantFrom(ctx),
+		"data":      message,
+		"locale":    eventLocale(message),
+	}
+	if len(changes) > 0 {
+		event["changes"] = changes
+	}
+	if requestID := logger_utils.RequestIDFrom(ctx); requestID != "" {
+		event["request_id"] = requestID
 	}
 	jsonMsg, err := json.Marshal(event)
 	if err != nil {
+		slog.ErrorContext(ctx, "failed to encode event", "event", eventType, "error", err)
 		return
 	}
-	utils.PublishToQueue(string(jsonMsg))
+	utils.PublishToQueue(ctx, eventType, string(jsonMsg))
+	if WebhookDispatcher != nil {
+		WebhookDispatcher.Dispatch(ctx, eventType, jsonMsg)
+	}
+}
+
+// withheld strips an event about a message held back by moderation down to
+// what tells consumers it was held or decided on: the message without its
+// title, body and metadata, and only the status and moderation changes.
+func withheld(message *domain.Message, changes map[string]interface{}) (*domain.Message, map[string]interface{}) {
+	stripped := &domain.Message{Id: message.Id, TenantId: message.TenantId, AuthorId: message.AuthorId, ParentId: message.ParentId,
+		Status: message.Status, CreatedAt: message.CreatedAt, Version: message.Version, Tags: []string{}, Moderation: message.Moderation}
+	kept := make(map[string]interface{}, 2)
+	for _, field := range []string{"status", "moderation"} {
+		if change, ok := changes[field]; ok {
+			kept[field] = change
+		}
+	}
+	return stripped, kept
+}
+
+func eventLocale(message *domain.Message) string {
+	if message.Locale != "" {
+		return message.Locale
+	}
+	return DefaultLocale
 }
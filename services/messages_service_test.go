@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -20,22 +21,78 @@ var (
 	updateMessageDomain  func(msg *domain.Message) (*domain.Message, error_utils.MessageErr)
 	deleteMessageDomain  func(messageId int64) error_utils.MessageErr
 	getAllMessagesDomain func() ([]domain.Message, error_utils.MessageErr)
+	countByAuthorDomain  func(authorId string, since time.Time) (int, error_utils.MessageErr)
+	updateStatusDomain   func(msg *domain.Message, fromStatus string) error_utils.MessageErr
+	listDueDomain        func(now time.Time, limit int) ([]domain.Message, error_utils.MessageErr)
+	listExpiredDomain    func(now time.Time, limit int) ([]domain.Message, error_utils.MessageErr)
+	listMessagesDomain   func(filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr)
+	listTagsDomain       func() ([]domain.TagCount, error_utils.MessageErr)
+	threadDomain         func(rootId int64, depth int) ([]domain.Message, error_utils.MessageErr)
+	pinnedDomain         func(scope string) ([]domain.Message, error_utils.MessageErr)
+	getBySlugDomain      func(slug string) (*domain.Message, error_utils.MessageErr)
+	getByTitleDomain     func(title string) (*domain.Message, error_utils.MessageErr)
+	similarDomain        func(msg *domain.Message, limit int) ([]domain.Message, error_utils.MessageErr)
+	listByAuthorDomain   func(authorId string, afterId int64, limit int) ([]domain.Message, error_utils.MessageErr)
+	anonymizeDomain      func(messageId int64) error_utils.MessageErr
+	listAfterDomain      func(afterId int64, limit int) ([]domain.Message, error_utils.MessageErr)
 )
 
 type getDBMock struct{}
 
-func (m *getDBMock) Get(messageId int64) (*domain.Message, error_utils.MessageErr) {
+func (m *getDBMock) Get(ctx context.Context, messageId int64) (*domain.Message, error_utils.MessageErr) {
 	return getMessageDomain(messageId)
 }
-func (m *getDBMock) Create(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+func (m *getDBMock) GetBySlug(ctx context.Context, slug string) (*domain.Message, error_utils.MessageErr) {
+	return getBySlugDomain(slug)
+}
+func (m *getDBMock) GetByTitle(ctx context.Context, title string) (*domain.Message, error_utils.MessageErr) {
+	return getByTitleDomain(title)
+}
+func (m *getDBMock) Similar(ctx context.Context, msg *domain.Message, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return similarDomain(msg, limit)
+}
+func (m *getDBMock) Create(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
 	return createMessageDomain(msg)
 }
-func (m *getDBMock) Update(msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
+func (m *getDBMock) Update(ctx context.Context, msg *domain.Message) (*domain.Message, error_utils.MessageErr) {
 	return updateMessageDomain(msg)
 }
-func (m *getDBMock) Delete(messageId int64) error_utils.MessageErr {
+func (m *getDBMock) Delete(ctx context.Context, messageId int64) error_utils.MessageErr {
 	return deleteMessageDomain(messageId)
 }
+func (m *getDBMock) CountByAuthorSince(ctx context.Context, authorId string, since time.Time) (int, error_utils.MessageErr) {
+	return countByAuthorDomain(authorId, since)
+}
+func (m *getDBMock) ListByAuthor(ctx context.Context, authorId string, afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return listByAuthorDomain(authorId, afterId, limit)
+}
+func (m *getDBMock) ListAfter(ctx context.Context, afterId int64, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return listAfterDomain(afterId, limit)
+}
+func (m *getDBMock) Anonymize(ctx context.Context, messageId int64) error_utils.MessageErr {
+	return anonymizeDomain(messageId)
+}
+func (m *getDBMock) UpdateStatus(ctx context.Context, msg *domain.Message, fromStatus string) error_utils.MessageErr {
+	return updateStatusDomain(msg, fromStatus)
+}
+func (m *getDBMock) ListDueScheduled(ctx context.Context, now time.Time, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return listDueDomain(now, limit)
+}
+func (m *getDBMock) ListExpired(ctx context.Context, now time.Time, limit int) ([]domain.Message, error_utils.MessageErr) {
+	return listExpiredDomain(now, limit)
+}
+func (m *getDBMock) List(ctx context.Context, filter domain.MessageFilter) ([]domain.Message, error_utils.MessageErr) {
+	return listMessagesDomain(filter)
+}
+func (m *getDBMock) ListTags(ctx context.Context) ([]domain.TagCount, error_utils.MessageErr) {
+	return listTagsDomain()
+}
+func (m *getDBMock) Thread(ctx context.Context, rootId int64, depth int) ([]domain.Message, error_utils.MessageErr) {
+	return threadDomain(rootId, depth)
+}
+func (m *getDBMock) Pinned(ctx context.Context, scope string) ([]domain.Message, error_utils.MessageErr) {
+	return pinnedDomain(scope)
+}
 func (m *getDBMock) GetAll() ([]domain.Message, error_utils.MessageErr) {
 	return getAllMessagesDomain()
 }
@@ -45,7 +102,7 @@ func (m *getDBMock) Initialize(string, string, string, string, string, string) *
 
 var publishedMessages []string
 
-func mockPublishToQueue(msg string) {
+func mockPublishToQueue(ctx context.Context, eventType string, msg string) {
 	publishedMessages = append(publishedMessages, msg)
 }
 
@@ -61,7 +118,7 @@ func TestMessagesService_GetMessage_Success(t *testing.T) {
 			CreatedAt: tm,
 		}, nil
 	}
-	msg, err := MessagesService.GetMessage(1)
+	msg, err := MessagesService.GetMessage(context.Background(), 1)
 	fmt.Println("this is the message: ", msg)
 	assert.NotNil(t, msg)
 	assert.Nil(t, err)
@@ -77,7 +134,7 @@ func TestMessagesService_GetMessage_NotFoundID(t *testing.T) {
 	getMessageDomain = func(messageId int64) (*domain.Message, error_utils.MessageErr) {
 		return nil, error_utils.NewNotFoundError("the id is not found")
 	}
-	msg, err := MessagesService.GetMessage(1)
+	msg, err := MessagesService.GetMessage(context.Background(), 1)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, http.StatusNotFound, err.Status())
@@ -106,7 +163,7 @@ func TestMessagesService_CreateMessage_Success(t *testing.T) {
 		Body:      "the body",
 		CreatedAt: tm,
 	}
-	msg, err := MessagesService.CreateMessage(request)
+	msg, err := MessagesService.CreateMessage(context.Background(), request)
 
 	assert.NotNil(t, msg)
 	assert.Nil(t, err)
@@ -138,7 +195,7 @@ func TestMessagesService_CreateMessage_InvalidTitle(t *testing.T) {
 		CreatedAt: tm,
 	}
 
-	msg, err := MessagesService.CreateMessage(request)
+	msg, err := MessagesService.CreateMessage(context.Background(), request)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "Please enter a valid title", err.Message())
@@ -158,7 +215,7 @@ func TestMessagesService_CreateMessage_InvalidBody(t *testing.T) {
 		CreatedAt: tm,
 	}
 
-	msg, err := MessagesService.CreateMessage(request)
+	msg, err := MessagesService.CreateMessage(context.Background(), request)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "Please enter a valid body", err.Message())
@@ -180,7 +237,7 @@ func TestMessagesService_CreateMessage_Failure(t *testing.T) {
 		Body:      "the body",
 		CreatedAt: tm,
 	}
-	msg, err := MessagesService.CreateMessage(request)
+	msg, err := MessagesService.CreateMessage(context.Background(), request)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "title already taken", err.Message())
@@ -214,7 +271,7 @@ func TestMessagesService_UpdateMessage_Success(t *testing.T) {
 		Title: "the title update",
 		Body:  "the body update",
 	}
-	msg, err := MessagesService.UpdateMessage(request)
+	msg, err := MessagesService.UpdateMessage(context.Background(), request)
 
 	assert.NotNil(t, msg)
 	assert.Nil(t, err)
@@ -245,7 +302,7 @@ func TestMessagesService_UpdateMessage_EmptyTitle(t *testing.T) {
 		Body:  "the body",
 	}
 
-	msg, err := MessagesService.UpdateMessage(request)
+	msg, err := MessagesService.UpdateMessage(context.Background(), request)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
@@ -263,7 +320,7 @@ func TestMessagesService_UpdateMessage_EmptyBody(t *testing.T) {
 		Body:  "",
 	}
 
-	msg, err := MessagesService.UpdateMessage(request)
+	msg, err := MessagesService.UpdateMessage(context.Background(), request)
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
@@ -284,7 +341,7 @@ func TestMessagesService_UpdateMessage_Failure_Getting_Former_Message(t *testing
 		Title: "the title update",
 		Body:  "the body update",
 	}
-	msg, err := MessagesService.UpdateMessage(request)
+	msg, err := MessagesService.UpdateMessage(context.Background(), request)
 
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
@@ -314,7 +371,7 @@ func TestMessagesService_UpdateMessage_Failure_Updating_Message(t *testing.T) {
 		Title: "the title update",
 		Body:  "the body update",
 	}
-	msg, err := MessagesService.UpdateMessage(request)
+	msg, err := MessagesService.UpdateMessage(context.Background(), request)
 
 	assert.Nil(t, msg)
 	assert.NotNil(t, err)
@@ -343,7 +400,7 @@ func TestMessagesService_DeleteMessage_Success(t *testing.T) {
 		return nil
 	}
 
-	err := MessagesService.DeleteMessage(1)
+	err := MessagesService.DeleteMessage(context.Background(), 1)
 	assert.Nil(t, err)
 
 	assert.Equal(t, 1, len(publishedMessages))
@@ -369,7 +426,7 @@ func TestMessagesService_DeleteMessage_Error_Getting_Message(t *testing.T) {
 		return nil, error_utils.NewInternalServerError("Something went wrong getting message")
 	}
 
-	err := MessagesService.DeleteMessage(1)
+	err := MessagesService.DeleteMessage(context.Background(), 1)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "Something went wrong getting message", err.Message())
 	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
@@ -394,7 +451,7 @@ func TestMessagesService_DeleteMessage_Error_Deleting_Message(t *testing.T) {
 		return error_utils.NewInternalServerError("error deleting message")
 	}
 
-	err := MessagesService.DeleteMessage(1)
+	err := MessagesService.DeleteMessage(context.Background(), 1)
 	assert.NotNil(t, err)
 	assert.EqualValues(t, "error deleting message", err.Message())
 	assert.EqualValues(t, http.StatusInternalServerError, err.Status())
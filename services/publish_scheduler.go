@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testing-project/domain"
+	"testing-project/utils/tenant_utils"
+	"time"
+)
+
+const publishSchedulerBatchSize = 100
+
+// RunPublishScheduler publishes scheduled messages once their publish_at has
+// passed, checking every interval until ctx is done. Several replicas may
+// run it at once; each message is published by exactly one of them.
+func RunPublishScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			publishDueMessages(ctx, time.Now())
+		}
+	}
+}
+
+// publishDueMessages publishes every message due at now and returns how many
+// it published.
+func publishDueMessages(ctx context.Context, now time.Time) int {
+	published := 0
+	for {
+		due, err := domain.MessageRepo.ListDueScheduled(ctx, now, publishSchedulerBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to list scheduled messages", "error", err.Message())
+			return published
+		}
+		progressed := false
+		for i := range due {
+			msg := &due[i]
+			msgCtx := tenant_utils.WithTenant(ctx, msg.TenantId)
+			msg.Status = domain.MessageStatusPublished
+			if err := domain.MessageRepo.UpdateStatus(msgCtx, msg, domain.MessageStatusScheduled); err != nil {
+				if err.Status() != http.StatusConflict {
+					slog.ErrorContext(msgCtx, "failed to publish scheduled message", "message_id", msg.Id, "error", err.Message())
+				}
+				continue
+			}
+			progressed = true
+			published++
+			sendEvent(msgCtx, MessageEventPublished, msg)
+		}
+		if len(due) < publishSchedulerBatchSize || !progressed {
+			return published
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"testing-project/utils/ratelimit_utils"
+	"time"
+)
+
+// RunRateLimitSweep removes the rate limit buckets of store idle for longer
+// than idle every interval until ctx is done. A bucket idle that long is
+// full again, so removing it changes no limit.
+func RunRateLimitSweep(ctx context.Context, store ratelimit_utils.Sweeper, interval time.Duration, idle time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sweepRateLimits(ctx, store, now.Add(-idle))
+		}
+	}
+}
+
+// sweepRateLimits removes the rate limit buckets idle since before
+// idleBefore and returns how many it removed.
+func sweepRateLimits(ctx context.Context, store ratelimit_utils.Sweeper, idleBefore time.Time) int {
+	swept, err := store.Sweep(ctx, idleBefore)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to sweep rate limit buckets", "error", err)
+	}
+	return swept
+}
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type rateLimitSweeperMock struct {
+	idleBefore time.Time
+}
+
+func (m *rateLimitSweeperMock) Sweep(ctx context.Context, idleBefore time.Time) (int, error) {
+	m.idleBefore = idleBefore
+	return 4, nil
+}
+
+func TestRunRateLimitSweep(t *testing.T) {
+	sweeper := &rateLimitSweeperMock{}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan struct{})
+	go func() {
+		RunRateLimitSweep(ctx, sweeper, time.Millisecond, time.Hour)
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	<-done
+
+	assert.False(t, sweeper.idleBefore.IsZero())
+	assert.True(t, time.Since(sweeper.idleBefore) >= time.Hour)
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing-project/domain"
+	"testing-project/utils/logger_utils"
+	"time"
+)
+
+const (
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var (
+	// WebhookDispatcher is nil until StartApp wires it to the database, in
+	// which case no webhook deliveries are attempted.
+	WebhookDispatcher webhookDispatcherInterface
+)
+
+type webhookDispatcherInterface interface {
+	Dispatch(ctx context.Context, eventType string, payload []byte)
+	// Wait blocks until every event dispatched so far has been delivered
+	// or given up on.
+	Wait()
+	// Shutdown stops taking events and delivers those already queued. Once
+	// ctx is done, pending retries and requests are abandoned instead.
+	Shutdown(ctx context.Context)
+}
+
+// WebhookDispatcherConfig tunes delivery. Zero values fall back to the
+// defaults noted on each field.
+type WebhookDispatcherConfig struct {
+	// Client sends the deliveries; defaults to a client with a 10s timeout.
+	Client *http.Client
+	// MaxAttempts per delivery, including the first one; defaults to 5.
+	MaxAttempts int
+	// InitialBackoff before the first retry, doubled on every retry up to
+	// MaxBackoff; defaults to 1s and 1m.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DisableAfter consecutive failed deliveries the webhook is deactivated;
+	// defaults to 10.
+	DisableAfter int
+	// Workers deliver at most this many webhooks at once, retries and their
+	// backoff included; defaults to 8.
+	Workers int
+	// QueueSize events may wait for delivery; events beyond are dropped
+	// with an error logged. Defaults to 1000.
+	QueueSize int
+}
+
+// webhookEvent is an event waiting to be fanned out to its webhooks.
+type webhookEvent struct {
+	ctx       context.Context
+	eventType string
+	payload   []byte
+}
+
+// webhookDelivery is an event waiting to be delivered to one webhook.
+type webhookDelivery struct {
+	webhookEvent
+	hook domain.Webhook
+}
+
+type webhookDispatcher struct {
+	config     WebhookDispatcherConfig
+	events     chan webhookEvent
+	deliveries chan webhookDelivery
+	// ctx is canceled when a shutdown runs out of time.
+	ctx     context.Context
+	cancel  context.CancelFunc
+	pending sync.WaitGroup
+	workers sync.WaitGroup
+	mu      sync.RWMutex
+	closed  bool
+}
+
+func NewWebhookDispatcher(config WebhookDispatcherConfig) webhookDispatcherInterface {
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.InitialBackoff <= 0 {
+		config.InitialBackoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+	if config.DisableAfter <= 0 {
+		config.DisableAfter = 10
+	}
+	if config.Workers <= 0 {
+		config.Workers = 8
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 1000
+	}
+	d := &webhookDispatcher{
+		config:     config,
+		events:     make(chan webhookEvent, config.QueueSize),
+		deliveries: make(chan webhookDelivery),
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	d.workers.Add(1 + config.Workers)
+	go d.fanOut()
+	for i := 0; i < config.Workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// Dispatch queues payload for every active webhook subscribed to eventType.
+// The request that triggered the event does not wait for, and cannot
+// cancel, the deliveries.
+func (d *webhookDispatcher) Dispatch(ctx context.Context, eventType string, payload []byte) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		slog.ErrorContext(ctx, "webhook dispatcher is shut down, event dropped", "event", eventType)
+		return
+	}
+	d.pending.Add(1)
+	select {
+	case d.events <- webhookEvent{ctx: context.WithoutCancel(ctx), eventType: eventType, payload: payload}:
+	default:
+		d.pending.Done()
+		slog.ErrorContext(ctx, "webhook queue is full, event dropped", "event", eventType)
+	}
+}
+
+func (d *webhookDispatcher) Wait() {
+	d.pending.Wait()
+}
+
+func (d *webhookDispatcher) Shutdown(ctx context.Context) {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.events)
+	}
+	d.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		d.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.WarnContext(ctx, "webhook deliveries abandoned at shutdown")
+		d.cancel()
+		<-done
+	}
+	d.cancel()
+}
+
+// fanOut turns each queued event into a delivery per webhook, waiting for
+// a free worker for each.
+func (d *webhookDispatcher) fanOut() {
+	defer d.workers.Done()
+	defer close(d.deliveries)
+	for event := range d.events {
+		hooks, err := domain.WebhookRepo.ListActiveByEvent(event.ctx, event.eventType)
+		if err != nil {
+			slog.ErrorContext(event.ctx, "failed to load webhooks", "event", event.eventType, "error", err.Message())
+		}
+		for _, hook := range hooks {
+			d.pending.Add(1)
+			d.deliveries <- webhookDelivery{webhookEvent: event, hook: hook}
+		}
+		d.pending.Done()
+	}
+}
+
+func (d *webhookDispatcher) work() {
+	defer d.workers.Done()
+	for delivery := range d.deliveries {
+		if d.ctx.Err() != nil {
+			slog.WarnContext(delivery.ctx, "webhook delivery abandoned at shutdown", "webhook_id", delivery.hook.Id, "event", delivery.eventType)
+		} else {
+			d.deliver(delivery.ctx, delivery.hook, delivery.eventType, delivery.payload)
+		}
+		d.pending.Done()
+	}
+}
+
+func (d *webhookDispatcher) deliver(ctx context.Context, hook domain.Webhook, eventType string, payload []byte) {
+	deliveryId := newDeliveryId()
+	backoff := d.config.InitialBackoff
+
+	for attempt := 1; attempt <= d.config.MaxAttempts; attempt++ {
+		record := d.attempt(ctx, hook, deliveryId, eventType, payload)
+		record.Attempt = attempt
+		if err := domain.WebhookRepo.RecordDelivery(ctx, record); err != nil {
+			slog.ErrorContext(ctx, "failed to record webhook delivery", "webhook_id", hook.Id, "error", err.Message())
+		}
+		if record.Succeeded {
+			if hook.FailureCount > 0 {
+				if err := domain.WebhookRepo.RecordSuccess(ctx, hook.Id); err != nil {
+					slog.ErrorContext(ctx, "failed to reset webhook failures", "webhook_id", hook.Id, "error", err.Message())
+				}
+			}
+			return
+		}
+		slog.WarnContext(ctx, "webhook delivery failed", "webhook_id", hook.Id, "delivery_id", deliveryId,
+			"attempt", attempt, "status_code", record.StatusCode, "error", record.Error)
+
+		if attempt == d.config.MaxAttempts {
+			break
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-d.ctx.Done():
+			timer.Stop()
+			slog.WarnContext(ctx, "webhook delivery abandoned at shutdown", "webhook_id", hook.Id, "delivery_id", deliveryId)
+			return
+		}
+		if backoff *= 2; backoff > d.config.MaxBackoff {
+			backoff = d.config.MaxBackoff
+		}
+	}
+
+	if err := domain.WebhookRepo.RecordFailure(ctx, hook.Id, d.config.DisableAfter); err != nil {
+		slog.ErrorContext(ctx, "failed to record webhook failure", "webhook_id", hook.Id, "error", err.Message())
+		return
+	}
+	if hook.FailureCount+1 >= d.config.DisableAfter {
+		slog.WarnContext(ctx, "webhook disabled after repeated failures", "webhook_id", hook.Id, "url", hook.Url)
+	}
+}
+
+func (d *webhookDispatcher) attempt(ctx context.Context, hook domain.Webhook, deliveryId string, eventType string, payload []byte) *domain.WebhookDelivery {
+	record := &domain.WebhookDelivery{
+		WebhookId:  hook.Id,
+		DeliveryId: deliveryId,
+		Event:      eventType,
+		CreatedAt:  time.Now(),
+	}
+	start := time.Now()
+	defer func() { record.DurationMs = time.Since(start).Milliseconds() }()
+
+	requestCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	defer context.AfterFunc(d.ctx, stop)()
+	req, err := http.NewRequestWithContext(requestCtx, http.MethodPost, hook.Url, bytes.NewReader(payload))
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "writing-service-webhooks/1")
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookDeliveryHeader, deliveryId)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(hook.Secret, timestamp, payload))
+	if requestID := logger_utils.RequestIDFrom(ctx); requestID != "" {
+		req.Header.Set(logger_utils.RequestIDHeader, requestID)
+	}
+
+	resp, err := d.config.Client.Do(req)
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	record.StatusCode = resp.StatusCode
+	record.Succeeded = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !record.Succeeded {
+		record.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+	return record
+}
+
+// SignWebhookPayload returns the signature header value receivers verify:
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<payload>".
+func SignWebhookPayload(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newDeliveryId() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+type webhookRepoMock struct {
+	mu         sync.Mutex
+	hooks      []domain.Webhook
+	deliveries []domain.WebhookDelivery
+	successes  []int64
+	failures   []int64
+}
+
+func (m *webhookRepoMock) Get(ctx context.Context, id int64) (*domain.Webhook, error_utils.MessageErr) {
+	for _, hook := range m.hooks {
+		if hook.Id == id {
+			return &hook, nil
+		}
+	}
+	return nil, error_utils.NewNotFoundError("no record matching given id")
+}
+func (m *webhookRepoMock) List(ctx context.Context) ([]domain.Webhook, error_utils.MessageErr) {
+	return append([]domain.Webhook{}, m.hooks...), nil
+}
+func (m *webhookRepoMock) ListActiveByEvent(ctx context.Context, eventType string) ([]domain.Webhook, error_utils.MessageErr) {
+	var hooks []domain.Webhook
+	for _, hook := range m.hooks {
+		if hook.Active && hook.Subscribes(eventType) {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+func (m *webhookRepoMock) Create(ctx context.Context, hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+	hook.Id = int64(len(m.hooks) + 1)
+	m.hooks = append(m.hooks, *hook)
+	return hook, nil
+}
+func (m *webhookRepoMock) Update(ctx context.Context, hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+	return hook, nil
+}
+func (m *webhookRepoMock) Delete(ctx context.Context, id int64) error_utils.MessageErr {
+	return nil
+}
+func (m *webhookRepoMock) RecordSuccess(ctx context.Context, id int64) error_utils.MessageErr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.successes = append(m.successes, id)
+	return nil
+}
+func (m *webhookRepoMock) RecordFailure(ctx context.Context, id int64, disableAfter int) error_utils.MessageErr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures = append(m.failures, id)
+	return nil
+}
+func (m *webhookRepoMock) RecordDelivery(ctx context.Context, delivery *domain.WebhookDelivery) error_utils.MessageErr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, *delivery)
+	return nil
+}
+func (m *webhookRepoMock) ListDeliveries(ctx context.Context, id int64, limit int) ([]domain.WebhookDelivery, error_utils.MessageErr) {
+	return m.deliveries, nil
+}
+
+func newTestDispatcher() webhookDispatcherInterface {
+	return NewWebhookDispatcher(WebhookDispatcherConfig{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     2 * time.Millisecond,
+		DisableAfter:   2,
+	})
+}
+
+func TestWebhookDispatcher_DeliversSignedPayload(t *testing.T) {
+	var gotBody []byte
+	var gotHeaders http.Header
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeaders = r.Header.Clone()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	repo := &webhookRepoMock{hooks: []domain.Webhook{
+		{Id: 1, Url: receiver.URL, Events: []string{MessageEventCreated}, Secret: "s3cret", Active: true},
+		{Id: 2, Url: receiver.URL, Events: []string{MessageEventDeleted}, Secret: "other", Active: true},
+	}}
+	domain.WebhookRepo = repo
+
+	dispatcher := newTestDispatcher()
+	dispatcher.Dispatch(context.Background(), MessageEventCreated, []byte(`{"event":"created"}`))
+	dispatcher.Wait()
+
+	assert.EqualValues(t, `{"event":"created"}`, string(gotBody))
+	assert.EqualValues(t, MessageEventCreated, gotHeaders.Get(WebhookEventHeader))
+	timestamp := gotHeaders.Get(WebhookTimestampHeader)
+	assert.EqualValues(t, SignWebhookPayload("s3cret", timestamp, gotBody), gotHeaders.Get(WebhookSignatureHeader))
+
+	assert.Equal(t, 1, len(repo.deliveries))
+	assert.True(t, repo.deliveries[0].Succeeded)
+	assert.EqualValues(t, 1, repo.deliveries[0].WebhookId)
+	assert.EqualValues(t, http.StatusNoContent, repo.deliveries[0].StatusCode)
+	assert.Empty(t, repo.failures)
+}
+
+func TestWebhookDispatcher_RetriesWithBackoff(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	repo := &webhookRepoMock{hooks: []domain.Webhook{
+		{Id: 1, Url: receiver.URL, Events: []string{MessageEventUpdated}, Secret: "s3cret", Active: true, FailureCount: 1},
+	}}
+	domain.WebhookRepo = repo
+
+	dispatcher := newTestDispatcher()
+	dispatcher.Dispatch(context.Background(), MessageEventUpdated, []byte(`{}`))
+	dispatcher.Wait()
+
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 3, len(repo.deliveries))
+	assert.False(t, repo.deliveries[0].Succeeded)
+	assert.EqualValues(t, "unexpected status 503", repo.deliveries[0].Error)
+	assert.EqualValues(t, 3, repo.deliveries[2].Attempt)
+	assert.True(t, repo.deliveries[2].Succeeded)
+	assert.EqualValues(t, repo.deliveries[0].DeliveryId, repo.deliveries[2].DeliveryId)
+	assert.EqualValues(t, []int64{1}, repo.successes)
+	assert.Empty(t, repo.failures)
+}
+
+func TestWebhookDispatcher_RecordsFailureAfterLastAttempt(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	repo := &webhookRepoMock{hooks: []domain.Webhook{
+		{Id: 7, Url: receiver.URL, Events: []string{MessageEventDeleted}, Secret: "s3cret", Active: true},
+	}}
+	domain.WebhookRepo = repo
+
+	dispatcher := newTestDispatcher()
+	dispatcher.Dispatch(context.Background(), MessageEventDeleted, []byte(`{}`))
+	dispatcher.Wait()
+
+	assert.Equal(t, 3, len(repo.deliveries))
+	assert.EqualValues(t, []int64{7}, repo.failures)
+	assert.Empty(t, repo.successes)
+}
+
+func TestWebhookDispatcher_SkipsInactiveWebhooks(t *testing.T) {
+	called := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer receiver.Close()
+
+	domain.WebhookRepo = &webhookRepoMock{hooks: []domain.Webhook{
+		{Id: 1, Url: receiver.URL, Events: []string{MessageEventCreated}, Active: false},
+	}}
+
+	dispatcher := newTestDispatcher()
+	dispatcher.Dispatch(context.Background(), MessageEventCreated, []byte(`{}`))
+	dispatcher.Wait()
+
+	assert.False(t, called)
+}
+
+func TestWebhookDispatcher_BoundsConcurrentDeliveries(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	hooks := make([]domain.Webhook, 6)
+	for i := range hooks {
+		hooks[i] = domain.Webhook{Id: int64(i + 1), Url: receiver.URL, Events: []string{MessageEventCreated}, Active: true}
+	}
+	repo := &webhookRepoMock{hooks: hooks}
+	domain.WebhookRepo = repo
+
+	dispatcher := NewWebhookDispatcher(WebhookDispatcherConfig{Workers: 2})
+	dispatcher.Dispatch(context.Background(), MessageEventCreated, []byte(`{}`))
+	dispatcher.Dispatch(context.Background(), MessageEventCreated, []byte(`{}`))
+	dispatcher.Wait()
+
+	assert.Equal(t, 12, len(repo.deliveries))
+	assert.LessOrEqual(t, maxInFlight, 2)
+}
+
+func TestWebhookDispatcher_DropsEventsWhenQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer receiver.Close()
+
+	repo := &webhookRepoMock{hooks: []domain.Webhook{{Id: 1, Url: receiver.URL, Events: []string{MessageEventCreated}, Active: true}}}
+	domain.WebhookRepo = repo
+
+	dispatcher := NewWebhookDispatcher(WebhookDispatcherConfig{Workers: 1, QueueSize: 1})
+	for i := 0; i < 5; i++ {
+		dispatcher.Dispatch(context.Background(), MessageEventCreated, []byte(`{}`))
+	}
+	close(release)
+	dispatcher.Wait()
+
+	assert.Less(t, len(repo.deliveries), 5)
+}
+
+func TestWebhookDispatcher_ShutdownAbandonsBackoff(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	repo := &webhookRepoMock{hooks: []domain.Webhook{{Id: 1, Url: receiver.URL, Events: []string{MessageEventCreated}, Active: true}}}
+	domain.WebhookRepo = repo
+
+	dispatcher := NewWebhookDispatcher(WebhookDispatcherConfig{MaxAttempts: 3, InitialBackoff: time.Hour})
+	dispatcher.Dispatch(context.Background(), MessageEventCreated, []byte(`{}`))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	dispatcher.Shutdown(ctx)
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, len(repo.deliveries))
+	assert.Empty(t, repo.failures)
+	dispatcher.Dispatch(context.Background(), MessageEventCreated, []byte(`{}`))
+	dispatcher.Wait()
+	assert.Equal(t, 1, len(repo.deliveries))
+}
+
+func TestWebhookDispatcher_ShutdownDrainsQueue(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Millisecond)
+	}))
+	defer receiver.Close()
+
+	repo := &webhookRepoMock{hooks: []domain.Webhook{{Id: 1, Url: receiver.URL, Events: []string{MessageEventCreated}, Active: true}}}
+	domain.WebhookRepo = repo
+
+	dispatcher := NewWebhookDispatcher(WebhookDispatcherConfig{Workers: 1})
+	for i := 0; i < 3; i++ {
+		dispatcher.Dispatch(context.Background(), MessageEventCreated, []byte(`{}`))
+	}
+	dispatcher.Shutdown(context.Background())
+
+	assert.Equal(t, 3, len(repo.deliveries))
+}
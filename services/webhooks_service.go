@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"testing-project/domain"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const defaultDeliveriesLimit = 50
+
+var (
+	WebhooksService webhooksServiceInterface = &webhooksService{}
+)
+
+type webhooksService struct{}
+
+type webhooksServiceInterface interface {
+	GetWebhook(context.Context, int64) (*domain.Webhook, error_utils.MessageErr)
+	ListWebhooks(context.Context) ([]domain.Webhook, error_utils.MessageErr)
+	CreateWebhook(context.Context, *domain.Webhook) (*domain.Webhook, error_utils.MessageErr)
+	UpdateWebhook(context.Context, *domain.Webhook) (*domain.Webhook, error_utils.MessageErr)
+	DeleteWebhook(context.Context, int64) error_utils.MessageErr
+	ListDeliveries(context.Context, int64, int) ([]domain.WebhookDelivery, error_utils.MessageErr)
+}
+
+func (w *webhooksService) GetWebhook(ctx context.Context, webhookId int64) (*domain.Webhook, error_utils.MessageErr) {
+	hook, err := domain.WebhookRepo.Get(ctx, webhookId)
+	if err != nil {
+		return nil, err
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+func (w *webhooksService) ListWebhooks(ctx context.Context) ([]domain.Webhook, error_utils.MessageErr) {
+	hooks, err := domain.WebhookRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, nil
+}
+
+// CreateWebhook registers a new endpoint. The signing secret is only ever
+// returned in the response to this call.
+func (w *webhooksService) CreateWebhook(ctx context.Context, hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+	if err := validateWebhook(hook); err != nil {
+		return nil, err
+	}
+	if hook.Secret == "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, error_utils.NewInternalServerError(fmt.Sprintf("error when trying to generate webhook secret: %s", err.Error()))
+		}
+		hook.Secret = secret
+	}
+	hook.Active = true
+	hook.FailureCount = 0
+	hook.CreatedAt = time.Now()
+	return domain.WebhookRepo.Create(ctx, hook)
+}
+
+// UpdateWebhook replaces the url, events and active flag of a webhook.
+// Re-activating a disabled webhook clears its failure count.
+func (w *webhooksService) UpdateWebhook(ctx context.Context, hook *domain.Webhook) (*domain.Webhook, error_utils.MessageErr) {
+	if err := validateWebhook(hook); err != nil {
+		return nil, err
+	}
+	current, err := domain.WebhookRepo.Get(ctx, hook.Id)
+	if err != nil {
+		return nil, err
+	}
+	if hook.Active && !current.Active {
+		current.FailureCount = 0
+	}
+	current.Url = hook.Url
+	current.Events = hook.Events
+	current.Active = hook.Active
+
+	updated, err := domain.WebhookRepo.Update(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+	updated.Secret = ""
+	return updated, nil
+}
+
+func (w *webhooksService) DeleteWebhook(ctx context.Context, webhookId int64) error_utils.MessageErr {
+	hook, err := domain.WebhookRepo.Get(ctx, webhookId)
+	if err != nil {
+		return err
+	}
+	return domain.WebhookRepo.Delete(ctx, hook.Id)
+}
+
+func (w *webhooksService) ListDeliveries(ctx context.Context, webhookId int64, limit int) ([]domain.WebhookDelivery, error_utils.MessageErr) {
+	if _, err := domain.WebhookRepo.Get(ctx, webhookId); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 500 {
+		limit = defaultDeliveriesLimit
+	}
+	return domain.WebhookRepo.ListDeliveries(ctx, webhookId, limit)
+}
+
+func validateWebhook(hook *domain.Webhook) error_utils.MessageErr {
+	if err := hook.Validate(); err != nil {
+		return err
+	}
+	for _, event := range hook.Events {
+		if !messageEventTypes[event] {
+			return error_utils.NewUnprocessibleEntityError(fmt.Sprintf("unknown event type %q", event))
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"testing-project/domain"
+)
+
+func TestWebhooksService_CreateWebhook_Success(t *testing.T) {
+	domain.WebhookRepo = &webhookRepoMock{}
+
+	hook, err := WebhooksService.CreateWebhook(context.Background(), &domain.Webhook{
+		Url:    " https://partner.example.com/hooks ",
+		Events: []string{"created", "deleted"},
+	})
+
+	assert.Nil(t, err)
+	assert.NotNil(t, hook)
+	assert.EqualValues(t, 1, hook.Id)
+	assert.EqualValues(t, "https://partner.example.com/hooks", hook.Url)
+	assert.True(t, hook.Active)
+	assert.True(t, strings.HasPrefix(hook.Secret, "whsec_"))
+}
+
+func TestWebhooksService_CreateWebhook_UnknownEvent(t *testing.T) {
+	domain.WebhookRepo = &webhookRepoMock{}
+
+	hook, err := WebhooksService.CreateWebhook(context.Background(), &domain.Webhook{
+		Url:    "https://partner.example.com/hooks",
+		Events: []string{"exploded"},
+	})
+
+	assert.Nil(t, hook)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, http.StatusUnprocessableEntity, err.Status())
+	assert.EqualValues(t, `unknown event type "exploded"`, err.Message())
+}
+
+func TestWebhooksService_CreateWebhook_InvalidUrl(t *testing.T) {
+	hook, err := WebhooksService.CreateWebhook(context.Background(), &domain.Webhook{
+		Url:    "ftp://partner.example.com",
+		Events: []string{"created"},
+	})
+
+	assert.Nil(t, hook)
+	assert.NotNil(t, err)
+	assert.EqualValues(t, "Please enter a valid http(s) url", err.Message())
+}
+
+func TestWebhooksService_UpdateWebhook_ReactivationClearsFailures(t *testing.T) {
+	domain.WebhookRepo = &webhookRepoMock{hooks: []domain.Webhook{
+		{Id: 1, Url: "https://old.example.com", Events: []string{"created"}, Secret: "s3cret", Active: false, FailureCount: 10},
+	}}
+
+	hook, err := WebhooksService.UpdateWebhook(context.Background(), &domain.Webhook{
+		Id:     1,
+		Url:    "https://new.example.com",
+		Events: []string{"updated"},
+		Active: true,
+	})
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, "https://new.example.com", hook.Url)
+	assert.EqualValues(t, []string{"updated"}, hook.Events)
+	assert.True(t, hook.Active)
+	assert.EqualValues(t, 0, hook.FailureCount)
+	assert.Empty(t, hook.Secret)
+}
+
+func TestWebhooksService_GetWebhook_HidesSecret(t *testing.T) {
+	domain.WebhookRepo = &webhookRepoMock{hooks: []domain.Webhook{
+		{Id: 1, Url: "https://partner.example.com", Events: []string{"created"}, Secret: "s3cret", Active: true},
+	}}
+
+	hook, err := WebhooksService.GetWebhook(context.Background(), 1)
+
+	assert.Nil(t, err)
+	assert.Empty(t, hook.Secret)
+}
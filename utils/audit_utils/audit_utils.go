@@ -0,0 +1,58 @@
+package audit_utils
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+// maxUserAgentLength bounds the user agent kept for an audit entry.
+const maxUserAgentLength = 255
+
+// Source is where a request came from, as recorded in the audit log.
+type Source struct {
+	ClientIP  string
+	UserAgent string
+}
+
+type sourceKey struct{}
+
+// WithSource returns a copy of ctx carrying source.
+func WithSource(ctx context.Context, source Source) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFrom returns the source stored in ctx, empty for background jobs
+// such as the publish scheduler and the reaper.
+func SourceFrom(ctx context.Context) Source {
+	if ctx == nil {
+		return Source{}
+	}
+	source, _ := ctx.Value(sourceKey{}).(Source)
+	return source
+}
+
+// Middleware stores the client IP and user agent of the request in its
+// context. The client IP comes from forwarded headers only when the router
+// trusts the proxy that sent them. The user agent is made valid UTF-8 and
+// cut to maxUserAgentLength characters, so that it always fits its column.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userAgent := truncate(strings.ToValidUTF8(c.Request.UserAgent(), "\uFFFD"), maxUserAgentLength)
+		source := Source{ClientIP: c.ClientIP(), UserAgent: userAgent}
+		c.Request = c.Request.WithContext(WithSource(c.Request.Context(), source))
+		c.Next()
+	}
+}
+
+// truncate cuts s to at most max characters, keeping them whole.
+func truncate(s string, max int) string {
+	count := 0
+	for i := range s {
+		if count == max {
+			return s[:i]
+		}
+		count++
+	}
+	return s
+}
@@ -0,0 +1,130 @@
+package auth_utils
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"testing-project/utils/error_utils"
+)
+
+const (
+	ScopeMessagesRead   = "messages:read"
+	ScopeMessagesWrite  = "messages:write"
+	ScopeMessagesDelete = "messages:delete"
+	// ScopeMessagesModerate lets a caller decide on messages held for review.
+	ScopeMessagesModerate = "messages:moderate"
+
+	ApiKeyHeader = "X-API-Key"
+)
+
+// AdminScope is the scope that lets a caller act on resources it does not own.
+var AdminScope = "admin"
+
+type principalKey struct{}
+
+// Principal is the authenticated caller of a request. TenantId is set when
+// the credentials are bound to a single tenant.
+type Principal struct {
+	Subject  string
+	Scopes   []string
+	TenantId string
+}
+
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.HasScope(AdminScope)
+}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller stored in ctx, or nil for internal callers
+// such as background jobs that act on behalf of the service itself.
+func PrincipalFrom(ctx context.Context) *Principal {
+	if ctx == nil {
+		return nil
+	}
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Authenticator turns a bearer credential into a Principal.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error_utils.MessageErr)
+}
+
+// Authenticators are the credential types a route accepts. Either may be nil
+// when that kind of credential is not configured.
+type Authenticators struct {
+	Bearer Authenticator
+	ApiKey Authenticator
+}
+
+// Middleware rejects requests without a valid bearer token or API key and
+// stores the authenticated principal in the request context.
+func Middleware(authenticators Authenticators) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var authenticator Authenticator
+		var credential string
+		if key := strings.TrimSpace(c.GetHeader(ApiKeyHeader)); key != "" && authenticators.ApiKey != nil {
+			authenticator, credential = authenticators.ApiKey, key
+		} else if token, ok := bearerToken(c.GetHeader("Authorization")); ok && authenticators.Bearer != nil {
+			authenticator, credential = authenticators.Bearer, token
+		}
+		if authenticator == nil {
+			abort(c, error_utils.NewUnauthorizedError("missing credentials"))
+			return
+		}
+		principal, err := authenticator.Authenticate(c.Request.Context(), credential)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// RequireScope rejects authenticated requests whose principal lacks scope.
+// Admins are allowed through regardless.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := PrincipalFrom(c.Request.Context())
+		if principal == nil {
+			abort(c, error_utils.NewUnauthorizedError("authentication required"))
+			return
+		}
+		if !principal.HasScope(scope) && !principal.IsAdmin() {
+			abort(c, error_utils.NewForbiddenError("missing required scope "+scope))
+			return
+		}
+		c.Next()
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len(prefix):])
+	return token, token != ""
+}
+
+func abort(c *gin.Context, err error_utils.MessageErr) {
+	if err.Status() == http.StatusUnauthorized {
+		c.Header("WWW-Authenticate", `Bearer realm="writing-service"`)
+	}
+	c.AbortWithStatusJSON(err.Status(), err)
+}
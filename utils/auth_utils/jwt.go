@@ -0,0 +1,251 @@
+package auth_utils
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+const jwksRefreshInterval = time.Minute
+
+// JWTConfig describes which tokens are accepted. Exactly one of JWKSFile and
+// JWKSURL must be set; a URL is re-fetched when a token names an unknown key.
+type JWTConfig struct {
+	Issuer   string
+	Audience string
+	JWKSFile string
+	JWKSURL  string
+}
+
+type jwtAuthenticator struct {
+	config JWTConfig
+	client *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+}
+
+// NewJWTAuthenticator loads the signing keys and returns an Authenticator
+// validating RS*, PS* and ES* signed JWTs against them.
+func NewJWTAuthenticator(config JWTConfig) (Authenticator, error) {
+	if (config.JWKSFile == "") == (config.JWKSURL == "") {
+		return nil, errors.New("auth: exactly one of the JWKS file or URL must be configured")
+	}
+	a := &jwtAuthenticator{config: config, client: &http.Client{Timeout: 5 * time.Second}}
+	if err := a.refresh(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Scope    string   `json:"scope"`
+	Scp      []string `json:"scp"`
+	TenantId string   `json:"tenant_id"`
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, token string) (*Principal, error_utils.MessageErr) {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+	if a.config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(a.config.Issuer))
+	}
+	if a.config.Audience != "" {
+		options = append(options, jwt.WithAudience(a.config.Audience))
+	}
+
+	var claims tokenClaims
+	if _, err := jwt.ParseWithClaims(token, &claims, a.keyFor, options...); err != nil {
+		slog.DebugContext(ctx, "rejected bearer token", "error", err)
+		return nil, error_utils.NewUnauthorizedError("invalid bearer token")
+	}
+	if claims.Subject == "" {
+		return nil, error_utils.NewUnauthorizedError("bearer token has no subject")
+	}
+
+	scopes := append(strings.Fields(claims.Scope), claims.Scp...)
+	return &Principal{Subject: claims.Subject, Scopes: scopes, TenantId: claims.TenantId}, nil
+}
+
+func (a *jwtAuthenticator) keyFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := a.lookup(kid); ok {
+		return key, nil
+	}
+	if a.config.JWKSURL != "" && a.refreshDue() {
+		if err := a.refresh(); err != nil {
+			return nil, err
+		}
+		if key, ok := a.lookup(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup finds the key for kid. Tokens without a kid are accepted only when
+// the key set holds a single key.
+func (a *jwtAuthenticator) lookup(kid string) (crypto.PublicKey, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if kid == "" && len(a.keys) == 1 {
+		for _, key := range a.keys {
+			return key, true
+		}
+	}
+	key, ok := a.keys[kid]
+	return key, ok
+}
+
+func (a *jwtAuthenticator) refreshDue() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return time.Since(a.lastRefresh) >= jwksRefreshInterval
+}
+
+func (a *jwtAuthenticator) refresh() error {
+	raw, err := a.fetch()
+	if err != nil {
+		return fmt.Errorf("auth: loading JWKS: %w", err)
+	}
+	keys, err := ParseJWKS(raw)
+	if err != nil {
+		return fmt.Errorf("auth: parsing JWKS: %w", err)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.keys = keys
+	a.lastRefresh = time.Now()
+	return nil
+}
+
+func (a *jwtAuthenticator) fetch() ([]byte, error) {
+	if a.config.JWKSFile != "" {
+		return os.ReadFile(a.config.JWKSFile)
+	}
+	resp, err := a.client.Get(a.config.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseJWKS decodes the RSA and EC signing keys of a JSON Web Key Set,
+// indexed by key id. Encryption keys and unsupported key types are skipped.
+func ParseJWKS(raw []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		var err error
+		switch jwk.Kty {
+		case "RSA":
+			key, err = jwk.rsaKey()
+		case "EC":
+			key, err = jwk.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", jwk.Kid, err)
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no usable signing keys")
+	}
+	return keys, nil
+}
+
+func (k jsonWebKey) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() {
+		return nil, errors.New("exponent too large")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jsonWebKey) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, errors.New("point is not on curve")
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
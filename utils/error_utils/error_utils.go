@@ -52,6 +52,54 @@ func NewUnprocessibleEntityError(message string) MessageErr {
 	}
 }
 
+func NewUnauthorizedError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusUnauthorized,
+		ErrError:   "unauthorized",
+	}
+}
+
+func NewForbiddenError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusForbidden,
+		ErrError:   "forbidden",
+	}
+}
+
+func NewConflictError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusConflict,
+		ErrError:   "conflict",
+	}
+}
+
+func NewTooManyRequestsError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusTooManyRequests,
+		ErrError:   "too_many_requests",
+	}
+}
+
+func NewPayloadTooLargeError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusRequestEntityTooLarge,
+		ErrError:   "payload_too_large",
+	}
+}
+
+func NewUnsupportedMediaTypeError(message string) MessageErr {
+	return &messageErr{
+		ErrMessage: message,
+		ErrStatus:  http.StatusUnsupportedMediaType,
+		ErrError:   "unsupported_media_type",
+	}
+}
+
 func NewApiErrFromBytes(body []byte) (MessageErr, error) {
 	var result messageErr
 	if err := json.Unmarshal(body, &result); err != nil {
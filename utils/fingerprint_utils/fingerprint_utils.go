@@ -0,0 +1,97 @@
+package fingerprint_utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"golang.org/x/text/unicode/norm"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"unicode"
+)
+
+// Bands is the number of 8 bit bands a SimHash is split into. Two
+// fingerprints that differ in fewer than Bands bits agree on at least one
+// band, which lets a store find near duplicates through an index.
+const Bands = 8
+
+// shingleLength is the number of characters in each SimHash feature.
+const shingleLength = 4
+
+// Normalize reduces text to its lowercase words separated by single spaces,
+// so that case, punctuation, accents and spacing do not tell two texts
+// apart.
+func Normalize(text string) string {
+	var normalized strings.Builder
+	pendingSpace := false
+	for _, r := range norm.NFKD.String(strings.ToLower(text)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if pendingSpace && normalized.Len() > 0 {
+				normalized.WriteByte(' ')
+			}
+			pendingSpace = false
+			normalized.WriteRune(r)
+		case unicode.Is(unicode.Mn, r):
+			// Combining marks left over from decomposing accented letters.
+		default:
+			pendingSpace = true
+		}
+	}
+	return normalized.String()
+}
+
+// ContentHash is the hex encoded SHA-256 of the normalized text.
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(Normalize(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// SimHash is the 64 bit SimHash of the normalized text, with its runs of
+// shingleLength characters as features. Texts that share most of their
+// wording get fingerprints that differ in few bits, even when they are as
+// short as a message body.
+func SimHash(text string) uint64 {
+	runes := []rune(Normalize(text))
+	if len(runes) == 0 {
+		return 0
+	}
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	if len(runes) <= shingleLength {
+		add(string(runes))
+	}
+	for i := 0; i+shingleLength <= len(runes); i++ {
+		add(string(runes[i : i+shingleLength]))
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// Distance is the number of bits two SimHash fingerprints differ in.
+func Distance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Band returns the i-th 8 bit band of a fingerprint, counting from the
+// least significant bits.
+func Band(fingerprint uint64, i int) uint8 {
+	return uint8(fingerprint >> (8 * i))
+}
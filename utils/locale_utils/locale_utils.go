@@ -0,0 +1,87 @@
+package locale_utils
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// Canonical validates a BCP 47 language tag and writes it the usual way:
+// language lowercase, script titlecase, region uppercase, for example
+// "zh-Hant-TW". It reports false for anything that is not a language tag.
+func Canonical(tag string) (string, bool) {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	if len(tag) > 35 || !localePattern.MatchString(tag) {
+		return "", false
+	}
+	parts := strings.Split(strings.ToLower(tag), "-")
+	for i := 1; i < len(parts); i++ {
+		switch {
+		case len(parts[i]) == 4 && isAlpha(parts[i]):
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		case len(parts[i]) == 2 && isAlpha(parts[i]):
+			parts[i] = strings.ToUpper(parts[i])
+		}
+	}
+	return strings.Join(parts, "-"), true
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+type weightedTag struct {
+	tag    string
+	weight float64
+}
+
+// Negotiate picks the locale in available that best matches an
+// Accept-Language header, or returns fallback. Ranges are tried by
+// descending weight; a range matches a locale exactly, or matches a locale
+// it is a prefix of, or is matched by a locale that is a prefix of it, so
+// "en-GB" is served "en" and "en" is served "en-US". "*" and ranges with a
+// weight of zero never match.
+func Negotiate(acceptLanguage string, available []string, fallback string) string {
+	var ranges []weightedTag
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if canonical, ok := Canonical(tag); ok && weight > 0 {
+			ranges = append(ranges, weightedTag{tag: canonical, weight: weight})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].weight > ranges[j].weight })
+
+	for _, r := range ranges {
+		for _, locale := range available {
+			if strings.EqualFold(locale, r.tag) {
+				return locale
+			}
+		}
+		for _, locale := range available {
+			if hasTagPrefix(r.tag, locale) || hasTagPrefix(locale, r.tag) {
+				return locale
+			}
+		}
+	}
+	return fallback
+}
+
+func hasTagPrefix(tag string, prefix string) bool {
+	return len(tag) > len(prefix) && strings.EqualFold(tag[:len(prefix)], prefix) && tag[len(prefix)] == '-'
+}
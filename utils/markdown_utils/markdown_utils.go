@@ -0,0 +1,213 @@
+package markdown_utils
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// The renderers only ever emit the tags below. All input text is escaped,
+// so HTML written by users comes out as text, never as markup.
+//
+//	p br h1-h6 strong em code pre blockquote ul ol li a hr
+//
+// Links keep their href only for http, https and mailto URLs and relative
+// references, and always carry rel="nofollow noopener noreferrer".
+
+var (
+	headingLine     = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	unorderedItem   = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	orderedItem     = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+	thematicBreak   = regexp.MustCompile(`^\s{0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	blockquoteLine  = regexp.MustCompile(`^\s{0,3}>\s?(.*)$`)
+	codeFence       = regexp.MustCompile("^\\s{0,3}```")
+	safeLinkSchemes = map[string]bool{"http": true, "https": true, "mailto": true}
+)
+
+// Plain renders text as HTML paragraphs, keeping line breaks.
+func Plain(source string) string {
+	var out strings.Builder
+	for _, paragraph := range strings.Split(normalize(source), "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if strings.TrimSpace(paragraph) == "" {
+			continue
+		}
+		lines := strings.Split(paragraph, "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+		out.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+	return out.String()
+}
+
+// Markdown renders the commonly used subset of Markdown: ATX headings,
+// paragraphs, emphasis, inline code, fenced code blocks, block quotes, flat
+// lists, thematic breaks and inline links. Raw HTML is escaped.
+func Markdown(source string) string {
+	var out strings.Builder
+	renderBlocks(&out, strings.Split(normalize(source), "\n"))
+	return out.String()
+}
+
+func normalize(source string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(source, "\r\n", "\n"), "\r", "\n")
+}
+
+func renderBlocks(out *strings.Builder, lines []string) {
+	var paragraph []string
+	flush := func() {
+		if len(paragraph) > 0 {
+			out.WriteString("<p>" + renderInline(strings.Join(paragraph, "\n")) + "</p>\n")
+			paragraph = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+		case codeFence.MatchString(line):
+			flush()
+			var code []string
+			for i++; i < len(lines) && !codeFence.MatchString(lines[i]); i++ {
+				code = append(code, lines[i])
+			}
+			out.WriteString("<pre><code>" + html.EscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+		case headingLine.MatchString(line):
+			flush()
+			match := headingLine.FindStringSubmatch(line)
+			level := string(rune('0' + len(match[1])))
+			out.WriteString("<h" + level + ">" + renderInline(match[2]) + "</h" + level + ">\n")
+		case thematicBreak.MatchString(line):
+			flush()
+			out.WriteString("<hr>\n")
+		case blockquoteLine.MatchString(line):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && blockquoteLine.MatchString(lines[i]); i++ {
+				quoted = append(quoted, blockquoteLine.FindStringSubmatch(lines[i])[1])
+			}
+			i--
+			out.WriteString("<blockquote>\n")
+			renderBlocks(out, quoted)
+			out.WriteString("</blockquote>\n")
+		case unorderedItem.MatchString(line):
+			flush()
+			i = renderList(out, lines, i, "ul", unorderedItem)
+		case orderedItem.MatchString(line):
+			flush()
+			i = renderList(out, lines, i, "ol", orderedItem)
+		default:
+			paragraph = append(paragraph, strings.TrimSpace(line))
+		}
+	}
+	flush()
+}
+
+// renderList writes the list starting at lines[start] and returns the index
+// of its last line.
+func renderList(out *strings.Builder, lines []string, start int, tag string, item *regexp.Regexp) int {
+	out.WriteString("<" + tag + ">\n")
+	i := start
+	for ; i < len(lines) && item.MatchString(lines[i]); i++ {
+		out.WriteString("<li>" + renderInline(item.FindStringSubmatch(lines[i])[1]) + "</li>\n")
+	}
+	out.WriteString("</" + tag + ">\n")
+	return i - 1
+}
+
+// renderInline escapes text while turning code spans, links and emphasis
+// into markup.
+func renderInline(text string) string {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_[]()#+-.!>", text[i+1]) >= 0:
+			out.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+		case c == '`':
+			if end := strings.IndexByte(text[i+1:], '`'); end >= 0 {
+				out.WriteString("<code>" + html.EscapeString(text[i+1:i+1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+		case c == '[':
+			if label, target, n, ok := inlineLink(text[i:]); ok {
+				if href, safe := safeHref(target); safe {
+					out.WriteString(`<a href="` + html.EscapeString(href) + `" rel="nofollow noopener noreferrer">` + renderInline(label) + "</a>")
+				} else {
+					out.WriteString(renderInline(label))
+				}
+				i += n
+				continue
+			}
+		case c == '*' || c == '_':
+			if c == '_' && i > 0 && isWordByte(text[i-1]) {
+				break
+			}
+			double := string([]byte{c, c})
+			if strings.HasPrefix(text[i:], double) {
+				if end := strings.Index(text[i+2:], double); end > 0 {
+					out.WriteString("<strong>" + renderInline(text[i+2:i+2+end]) + "</strong>")
+					i += end + 4
+					continue
+				}
+			} else if end := strings.IndexByte(text[i+1:], c); end > 0 && text[i+1] != ' ' {
+				out.WriteString("<em>" + renderInline(text[i+1:i+1+end]) + "</em>")
+				i += end + 2
+				continue
+			}
+		}
+		out.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	return out.String()
+}
+
+// inlineLink parses "[label](target)" at the start of text and returns the
+// number of bytes it spans. Parentheses inside target must be balanced.
+func inlineLink(text string) (label string, target string, n int, ok bool) {
+	closeLabel := strings.Index(text, "](")
+	if closeLabel < 0 || strings.IndexByte(text[1:closeLabel], '[') >= 0 {
+		return "", "", 0, false
+	}
+	depth := 0
+	for i := closeLabel + 2; i < len(text); i++ {
+		switch text[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return text[1:closeLabel], strings.TrimSpace(text[closeLabel+2 : i]), i + 1, true
+			}
+			depth--
+		}
+	}
+	return "", "", 0, false
+}
+
+// safeHref accepts absolute http, https and mailto URLs and relative
+// references. Anything with whitespace or control characters is refused, as
+// browsers ignore those when reading a scheme.
+func safeHref(target string) (string, bool) {
+	if target == "" || strings.IndexFunc(target, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		return "", false
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	if parsed.Scheme == "" {
+		return target, !strings.Contains(strings.SplitN(target, "/", 2)[0], ":")
+	}
+	return target, safeLinkSchemes[strings.ToLower(parsed.Scheme)]
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
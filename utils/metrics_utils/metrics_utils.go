@@ -0,0 +1,103 @@
+package metrics_utils
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"strconv"
+	"time"
+)
+
+const namespace = "writing_service"
+
+var (
+	httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests handled, by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	repoOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repo_operation_duration_seconds",
+		Help:      "Latency of repository operations, by repository and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"repo", "operation"})
+
+	eventsPublishedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_published_total",
+		Help:      "Number of events handed to the broker, by event type and outcome.",
+	}, []string{"event", "outcome"})
+
+	eventPublishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_publish_duration_seconds",
+		Help:      "Latency of broker publishes, by event type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"event"})
+)
+
+// Handler serves every registered metric in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// GinMiddleware records the request count and latency of every request.
+// Requests that match no route are grouped under "unmatched" so that random
+// paths cannot blow up the label cardinality.
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDBStats exposes the connection pool statistics of db. Registering
+// the same database twice is not an error.
+func RegisterDBStats(db *sql.DB, dbName string) error {
+	if db == nil {
+		return nil
+	}
+	err := prometheus.Register(collectors.NewDBStatsCollector(db, dbName))
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if err != nil && !errors.As(err, &alreadyRegistered) {
+		return err
+	}
+	return nil
+}
+
+// ObserveRepoOperation records the time elapsed since start for a repository
+// operation. It is meant to be deferred at the top of a repository method.
+func ObserveRepoOperation(repo string, operation string, start time.Time) {
+	repoOperationDuration.WithLabelValues(repo, operation).Observe(time.Since(start).Seconds())
+}
+
+// ObservePublish records the outcome and latency of a single broker publish.
+func ObservePublish(eventType string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	eventsPublishedTotal.WithLabelValues(eventType, outcome).Inc()
+	eventPublishDuration.WithLabelValues(eventType).Observe(time.Since(start).Seconds())
+}
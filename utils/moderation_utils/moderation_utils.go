@@ -0,0 +1,203 @@
+package moderation_utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Flag is a reason to hold content back until a moderator has reviewed it.
+type Flag struct {
+	Check  string `json:"check"`
+	Reason string `json:"reason"`
+}
+
+// Check inspects the title and body of a message and returns the flags it
+// raises, if any.
+type Check interface {
+	Check(title string, body string) []Flag
+}
+
+// Run applies every check and gathers their flags.
+func Run(checks []Check, title string, body string) []Flag {
+	var flags []Flag
+	for _, check := range checks {
+		flags = append(flags, check.Check(title, body)...)
+	}
+	return flags
+}
+
+type wordFilter struct {
+	entries  []string
+	patterns []*regexp.Regexp
+}
+
+// NewWordFilter flags content containing any of entries. An entry written
+// as /expression/ is a regular expression; any other entry is a word or
+// phrase matched case-insensitively as a whole.
+func NewWordFilter(entries []string) (Check, error) {
+	filter := &wordFilter{}
+	for _, entry := range entries {
+		expression := `(?i)(^|[^\pL\pN])` + regexp.QuoteMeta(entry) + `([^\pL\pN]|$)`
+		if len(entry) > 2 && strings.HasPrefix(entry, "/") && strings.HasSuffix(entry, "/") {
+			expression = entry[1 : len(entry)-1]
+		}
+		pattern, err := regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("word filter %q: %w", entry, err)
+		}
+		filter.entries = append(filter.entries, entry)
+		filter.patterns = append(filter.patterns, pattern)
+	}
+	return filter, nil
+}
+
+// LoadWordFilter reads the entries of a word filter from a file, one per
+// line. Blank lines and lines starting with # are skipped.
+func LoadWordFilter(path string) (Check, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			entries = append(entries, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewWordFilter(entries)
+}
+
+func (f *wordFilter) Check(title string, body string) []Flag {
+	var flags []Flag
+	for i, pattern := range f.patterns {
+		if pattern.MatchString(title) || pattern.MatchString(body) {
+			flags = append(flags, Flag{Check: "word_filter", Reason: fmt.Sprintf("matches %q", f.entries[i])})
+		}
+	}
+	return flags
+}
+
+// linkPattern finds links with or without a scheme, such as
+// "https://example.com/offer" or "example.com".
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://)?(?:[a-z0-9](?:[a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}\b(?::\d+)?(?:/\S*)?`)
+
+// Hosts returns the lowercase host of every link in text.
+func Hosts(text string) []string {
+	var hosts []string
+	for _, link := range linkPattern.FindAllString(text, -1) {
+		host := strings.ToLower(link)
+		if i := strings.Index(host, "://"); i >= 0 {
+			host = host[i+3:]
+		}
+		if i := strings.IndexAny(host, ":/"); i >= 0 {
+			host = host[:i]
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts
+}
+
+type linkBlocklist map[string]bool
+
+// NewLinkBlocklist flags content linking to any of domains or to their
+// subdomains.
+func NewLinkBlocklist(domains []string) Check {
+	blocklist := make(linkBlocklist, len(domains))
+	for _, domain := range domains {
+		if domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "."); domain != "" {
+			blocklist[domain] = true
+		}
+	}
+	return blocklist
+}
+
+func (b linkBlocklist) Check(title string, body string) []Flag {
+	var flags []Flag
+	seen := make(map[string]bool)
+	for _, host := range Hosts(title + "\n" + body) {
+		for domain := host; domain != ""; {
+			if b[domain] && !seen[domain] {
+				seen[domain] = true
+				flags = append(flags, Flag{Check: "link_blocklist", Reason: fmt.Sprintf("links to blocked domain %s", domain)})
+			}
+			_, parent, ok := strings.Cut(domain, ".")
+			if !ok {
+				break
+			}
+			domain = parent
+		}
+	}
+	return flags
+}
+
+// spamPhrases are phrases typical of unsolicited advertising.
+var spamPhrases = []string{
+	"act now", "buy now", "click here", "earn cash", "free money", "limited time offer", "risk free", "work from home", "100% free",
+}
+
+var (
+	repeatedPunctuation = regexp.MustCompile(`[!?$]{3,}`)
+	repeatedLetters     = regexp.MustCompile(`(?i)(a{6,}|e{6,}|i{6,}|o{6,}|u{6,})`)
+)
+
+// SpamScore rates how much title and body look like spam, from 0 upwards;
+// content past 1 is spam beyond doubt. Every heuristic adds to the score:
+// more than one link, shouting, runs of punctuation or vowels and the
+// phrases of unsolicited advertising.
+func SpamScore(title string, body string) float64 {
+	text := title + "\n" + body
+	lower := strings.ToLower(text)
+	score := 0.0
+
+	if links := len(Hosts(text)); links > 1 {
+		score += 0.2 * float64(links-1)
+	}
+	letters, upper := 0, 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+			if unicode.IsUpper(r) {
+				upper++
+			}
+		}
+	}
+	if letters >= 20 && float64(upper) > 0.6*float64(letters) {
+		score += 0.3
+	}
+	if repeatedPunctuation.MatchString(text) {
+		score += 0.2
+	}
+	if repeatedLetters.MatchString(text) {
+		score += 0.1
+	}
+	for _, phrase := range spamPhrases {
+		if strings.Contains(lower, phrase) {
+			score += 0.25
+		}
+	}
+	return score
+}
+
+type spamScorer float64
+
+// NewSpamScorer flags content whose SpamScore reaches threshold.
+func NewSpamScorer(threshold float64) Check {
+	return spamScorer(threshold)
+}
+
+func (s spamScorer) Check(title string, body string) []Flag {
+	if score := SpamScore(title, body); score >= float64(s) {
+		return []Flag{{Check: "spam_score", Reason: fmt.Sprintf("spam score %.2f reaches %.2f", score, float64(s))}}
+	}
+	return nil
+}
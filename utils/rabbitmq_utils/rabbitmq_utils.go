@@ -1,10 +1,24 @@
 package rabbitmq_utils
 
 import (
+	"context"
+	"errors"
 	"github.com/streadway/amqp"
-	"log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"testing-project/utils/logger_utils"
+	"testing-project/utils/metrics_utils"
+	"testing-project/utils/tenant_utils"
+	"testing-project/utils/tracing_utils"
+	"time"
 )
 
+// MessagesExchange is the topic exchange events are published to. my_queue
+// is bound to every routing key; consumers interested in a single tenant can
+// bind their own queue to "<tenant>.#".
+const MessagesExchange = "messages"
+
 var rabbitConn *amqp.Connection
 var rabbitChannel *amqp.Channel
 
@@ -12,12 +26,12 @@ func InitRabbitMQ(brokerAddr string) {
 	var err error
 	rabbitConn, err = amqp.Dial(brokerAddr)
 	if err != nil {
-		log.Fatalf("Failed to connect to RabbitMQ: %s", err)
+		logger_utils.Fatal("failed to connect to RabbitMQ", "error", err)
 	}
 
 	rabbitChannel, err = rabbitConn.Channel()
 	if err != nil {
-		log.Fatalf("Failed to open a channel: %s", err)
+		logger_utils.Fatal("failed to open a channel", "error", err)
 	}
 
 	_, err = rabbitChannel.QueueDeclare(
@@ -29,24 +43,67 @@ func InitRabbitMQ(brokerAddr string) {
 		nil,
 	)
 	if err != nil {
-		log.Fatalf("Failed to declare a queue: %s", err)
+		logger_utils.Fatal("failed to declare a queue", "error", err)
+	}
+
+	if err = rabbitChannel.ExchangeDeclare(MessagesExchange, "topic", true, false, false, false, nil); err != nil {
+		logger_utils.Fatal("failed to declare an exchange", "error", err)
 	}
+	if err = rabbitChannel.QueueBind("my_queue", "#", MessagesExchange, false, nil); err != nil {
+		logger_utils.Fatal("failed to bind the queue", "error", err)
+	}
+}
+
+// RoutingKey is the key an event of eventType is published with, for example
+// "acme.message.created".
+func RoutingKey(tenantId string, eventType string) string {
+	return tenantId + ".message." + eventType
 }
 
-var PublishToQueue = func(message string) {
+// PublishToQueue publishes message to MessagesExchange, routed by the tenant
+// of ctx and eventType.
+var PublishToQueue = func(ctx context.Context, eventType string, message string) {
+	start := time.Now()
+	tenantId := tenant_utils.TenantFrom(ctx)
+	routingKey := RoutingKey(tenantId, eventType)
+	ctx, span := tracing_utils.Tracer().Start(ctx, MessagesExchange+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.destination.name", MessagesExchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", routingKey),
+			attribute.String("messaging.operation", "publish"),
+			attribute.String("event.type", eventType),
+		),
+	)
+
 	if rabbitChannel == nil {
-		log.Println("RabbitMQ channel not initialized")
+		err := errors.New("channel not initialized")
+		slog.WarnContext(ctx, "RabbitMQ channel not initialized", "event", eventType)
+		metrics_utils.ObservePublish(eventType, start, err)
+		tracing_utils.EndSpan(span, err)
 		return
 	}
 
+	headers := amqp.Table{tenant_utils.TenantHeader: tenantId}
+	tracing_utils.InjectAMQPHeaders(ctx, headers)
+	requestID := logger_utils.RequestIDFrom(ctx)
+	if requestID != "" {
+		headers[logger_utils.RequestIDHeader] = requestID
+	}
+
 	err := rabbitChannel.Publish(
-		"", "my_queue", false, false,
+		MessagesExchange, routingKey, false, false,
 		amqp.Publishing{
-			ContentType: "text/plain",
-			Body:        []byte(message),
+			Headers:       headers,
+			CorrelationId: requestID,
+			ContentType:   "text/plain",
+			Body:          []byte(message),
 		},
 	)
+	metrics_utils.ObservePublish(eventType, start, err)
+	tracing_utils.EndSpan(span, err)
 	if err != nil {
-		log.Printf("Failed to publish message: %s", err)
+		slog.ErrorContext(ctx, "failed to publish message", "event", eventType, "error", err)
 	}
 }
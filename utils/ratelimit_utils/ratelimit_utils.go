@@ -0,0 +1,194 @@
+package ratelimit_utils
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_utils"
+	"time"
+)
+
+// Limit is a token bucket refilled at Rate tokens per second and holding at
+// most Burst tokens.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result describes the bucket after a request has been counted.
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	ResetAfter time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. Implementations must make Take atomic per key.
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// Sweeper is a Store whose buckets are not removed as they go idle, and
+// need to be swept from time to time.
+type Sweeper interface {
+	// Sweep removes the buckets last taken from before idleBefore and
+	// returns how many it removed.
+	Sweep(ctx context.Context, idleBefore time.Time) (int, error)
+}
+
+// RefillTime is how long the slowest of limits takes to refill an empty
+// bucket. A bucket idle for that long behaves as a fresh one.
+func RefillTime(limits map[string]Limit) time.Duration {
+	var longest time.Duration
+	for _, limit := range limits {
+		if refill := secondsToDuration(float64(limit.Burst) / limit.Rate); refill > longest {
+			longest = refill
+		}
+	}
+	return longest
+}
+
+// TakeToken applies one request to a bucket that held tokens at updated and
+// returns the new token count along with the outcome. Stores share it so
+// every backend counts the same way.
+func TakeToken(tokens float64, updated time.Time, limit Limit, now time.Time) (float64, Result) {
+	burst := float64(limit.Burst)
+	if elapsed := now.Sub(updated).Seconds(); elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed*limit.Rate)
+	}
+
+	var result Result
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.ResetAfter = secondsToDuration((burst - tokens) / limit.Rate)
+	return tokens, result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// MemoryStore keeps buckets in process memory. Limits are per replica.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.takes++; s.takes%1024 == 0 {
+		s.sweep(now)
+	}
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(limit.Burst), updated: now}
+		s.buckets[key] = bucket
+	}
+	var result Result
+	bucket.tokens, result = TakeToken(bucket.tokens, bucket.updated, limit, now)
+	bucket.updated = now
+	bucket.limit = limit
+	return result, nil
+}
+
+// sweep forgets buckets that have refilled completely, since a fresh bucket
+// behaves the same. Callers hold s.mu.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		refill := secondsToDuration((float64(bucket.limit.Burst) - bucket.tokens) / bucket.limit.Rate)
+		if now.Sub(bucket.updated) >= refill {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// ClientKey identifies the caller: the authenticated principal when there is
+// one, the client IP otherwise, as before authentication. The client IP is
+// only taken from forwarded headers sent by a trusted proxy.
+func ClientKey(c *gin.Context) string {
+	if principal := auth_utils.PrincipalFrom(c.Request.Context()); principal != nil {
+		return "principal:" + principal.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// Middleware limits each client to limit on the route named route, setting
+// the RateLimit-* headers on every response and Retry-After on rejections.
+// If the store fails the request is let through.
+func Middleware(store Store, route string, limit Limit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := route + "|" + ClientKey(c)
+		result, err := store.Take(c.Request.Context(), key, limit, time.Now())
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "rate limit store failed", "route", route, "error", err)
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(limit.Burst))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+		if !result.Allowed {
+			c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			theErr := error_utils.NewTooManyRequestsError("rate limit exceeded")
+			c.AbortWithStatusJSON(theErr.Status(), theErr)
+			return
+		}
+		c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// ParseLimits reads overrides written as "route=rate:burst,route=rate:burst",
+// for example "messages.create=0.5:5".
+func ParseLimits(spec string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, value, ok := strings.Cut(entry, "=")
+		rate, burst, ok2 := strings.Cut(value, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("rate limit %q: expected route=rate:burst", entry)
+		}
+		r, err := strconv.ParseFloat(rate, 64)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid rate", entry)
+		}
+		b, err := strconv.Atoi(burst)
+		if err != nil || b <= 0 {
+			return nil, fmt.Errorf("rate limit %q: invalid burst", entry)
+		}
+		limits[strings.TrimSpace(route)] = Limit{Rate: r, Burst: b}
+	}
+	return limits, nil
+}
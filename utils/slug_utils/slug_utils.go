@@ -0,0 +1,75 @@
+package slug_utils
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// MaxLength is the longest slug Slugify returns, leaving room in a 120
+// character column for a collision suffix.
+const MaxLength = 100
+
+// fallback is the slug of a title with nothing to keep, such as one made
+// only of emoji.
+const fallback = "message"
+
+// transliterations covers the letters that do not decompose into an ASCII
+// letter and combining marks, plus Greek and Cyrillic.
+var transliterations = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'þ': "th", 'ł': "l", 'ı': "i", 'ŋ': "ng",
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th", 'ι': "i", 'κ': "k",
+	'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p", 'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t",
+	'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps", 'ω': "o",
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "yo", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya", 'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g",
+}
+
+// Slugify turns a title into a lowercase, URL-safe slug of ASCII letters,
+// digits and single hyphens: "Crème brûlée à 5 €" becomes
+// "creme-brulee-a-5". Letters are transliterated where possible and other
+// characters separate words.
+func Slugify(title string) string {
+	var slug strings.Builder
+	pendingHyphen := false
+	write := func(s string) {
+		if s == "" {
+			return
+		}
+		if pendingHyphen && slug.Len() > 0 {
+			slug.WriteByte('-')
+		}
+		pendingHyphen = false
+		slug.WriteString(s)
+	}
+	for _, r := range norm.NFD.String(strings.ToLower(title)) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			write(string(r))
+		case unicode.Is(unicode.Mn, r):
+			// Combining marks left over from decomposing accented letters.
+		case r == '\'' || r == '’':
+			// Apostrophes join words: "don't" becomes "dont".
+		default:
+			if ascii, ok := transliterations[r]; ok {
+				write(ascii)
+			} else {
+				pendingHyphen = true
+			}
+		}
+	}
+	result := slug.String()
+	if len(result) > MaxLength {
+		result = result[:MaxLength]
+		if cut := strings.LastIndexByte(result, '-'); cut > MaxLength/2 {
+			result = result[:cut]
+		}
+		result = strings.TrimRight(result, "-")
+	}
+	if result == "" {
+		return fallback
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package tenant_utils
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"regexp"
+	"testing-project/utils/auth_utils"
+	"testing-project/utils/error_utils"
+)
+
+const (
+	TenantHeader = "X-Tenant-ID"
+
+	// DefaultTenant owns requests that name no tenant, which keeps
+	// single-tenant deployments working unchanged.
+	DefaultTenant = "default"
+)
+
+// Tenant ids end up in AMQP routing keys, so dots and wildcards are not
+// allowed.
+var tenantIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type tenantKey struct{}
+
+// WithTenant returns a copy of ctx scoped to tenantId.
+func WithTenant(ctx context.Context, tenantId string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantId)
+}
+
+// TenantFrom returns the tenant ctx is scoped to, or DefaultTenant.
+func TenantFrom(ctx context.Context) string {
+	if ctx != nil {
+		if tenantId, ok := ctx.Value(tenantKey{}).(string); ok && tenantId != "" {
+			return tenantId
+		}
+	}
+	return DefaultTenant
+}
+
+func Validate(tenantId string) error_utils.MessageErr {
+	if !tenantIdPattern.MatchString(tenantId) {
+		return error_utils.NewBadRequestError("tenant id may only contain letters, digits, '-' and '_'")
+	}
+	return nil
+}
+
+// Middleware scopes the request to a tenant. A tenant bound to the caller's
+// credentials wins; the X-Tenant-ID header may only repeat it, unless the
+// caller is an admin acting on another tenant's behalf. Credentials that
+// carry no tenant are held to DefaultTenant, and only an admin may pick
+// another with the header. Without authentication, the header picks the
+// tenant.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader(TenantHeader)
+		tenantId := header
+		if principal := auth_utils.PrincipalFrom(c.Request.Context()); principal != nil {
+			bound := principal.TenantId
+			if bound == "" {
+				bound = DefaultTenant
+			}
+			switch {
+			case header == "" || header == bound:
+				tenantId = bound
+			case !principal.IsAdmin():
+				theErr := error_utils.NewForbiddenError("credentials are not valid for tenant " + header)
+				c.AbortWithStatusJSON(theErr.Status(), theErr)
+				return
+			}
+		}
+		if tenantId == "" {
+			tenantId = DefaultTenant
+		}
+		if err := Validate(tenantId); err != nil {
+			c.AbortWithStatusJSON(err.Status(), err)
+			return
+		}
+		c.Request = c.Request.WithContext(WithTenant(c.Request.Context(), tenantId))
+		c.Next()
+	}
+}